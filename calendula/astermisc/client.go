@@ -67,24 +67,44 @@ func doRequest(ctx context.Context, client AsterClient,
 	}
 
 	// it seems to send an HTML body along with 5xx error codes. Don't parse it.
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated &&
+		resp.StatusCode != http.StatusNoContent {
 		logResponse(resp, d)
 		return statusCodeError{Code: resp.StatusCode, Status: resp.Status}
 	}
+	if resp.StatusCode == http.StatusNoContent || intf == nil {
+		return nil
+	}
 
 	return parseResponseBody(resp.Body, intf, method, d)
 }
 
 // PostJSON sends POST in JSON.
 func PostJSON(ctx context.Context, client AsterClient, endpoint, token string, json []byte, intf interface{}, method SerialFunc, d debug) error {
+	return sendJSON(ctx, client, "POST", endpoint, token, json, intf, method, d)
+}
+
+// PatchJSON sends PATCH in JSON.
+func PatchJSON(ctx context.Context, client AsterClient, endpoint, token string, json []byte, intf interface{}, method SerialFunc, d debug) error {
+	return sendJSON(ctx, client, "PATCH", endpoint, token, json, intf, method, d)
+}
+
+// DeleteJSON sends DELETE in JSON.
+func DeleteJSON(ctx context.Context, client AsterClient, endpoint, token string, intf interface{}, method SerialFunc, d debug) error {
+	return sendJSON(ctx, client, "DELETE", endpoint, token, nil, intf, method, d)
+}
+
+func sendJSON(ctx context.Context, client AsterClient, httpMethod, endpoint, token string, json []byte, intf interface{}, method SerialFunc, d debug) error {
 	reqBody := bytes.NewBuffer(json)
-	req, err := http.NewRequest("POST", endpoint, reqBody)
+	req, err := http.NewRequest(httpMethod, endpoint, reqBody)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
 	return doRequest(ctx, client, req, intf, method, d)
 }
 
@@ -143,7 +163,9 @@ func GetJSON(ctx context.Context, client AsterClient, endpoint, token string, va
 		log.Printf("Error: %v\n", err)
 		return err
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
 	req.Header.Set("Content-Type", "application/json")
 	req.URL.RawQuery = values.Encode()
 	return doRequest(ctx, client, req, intf, method, d)
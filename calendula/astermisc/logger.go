@@ -47,14 +47,17 @@ func (t internalLog) Print(v ...interface{}) {
 	t.Output(2, fmt.Sprint(v...))
 }
 
-type discard struct{}
+// NoDebug is a debug sink that never logs. Pass it to the http helpers
+// for requests that carry credentials so they stay out of debug dumps.
+type NoDebug struct{}
 
-func (t discard) Debug() bool {
+// Debug always returns false.
+func (t NoDebug) Debug() bool {
 	return false
 }
 
 // Debugf print a formatted debug line.
-func (t discard) Debugf(format string, v ...interface{}) {}
+func (t NoDebug) Debugf(format string, v ...interface{}) {}
 
 // Debugln print a debug line.
-func (t discard) Debugln(v ...interface{}) {}
+func (t NoDebug) Debugln(v ...interface{}) {}
@@ -2,6 +2,7 @@ package auth0api
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -25,6 +26,7 @@ type Auth0Client struct {
 	Endpoint   *Auth0Endpoint
 	httpClient misc.AsterClient
 	SerialAPI  utils.SerialInterface
+	Metadata   *MetadataRegistry
 	debug      bool
 	log        misc.Ilogger
 	token      string
@@ -32,10 +34,11 @@ type Auth0Client struct {
 
 // Auth0Endpoint wraps necessary info of auth0 endpoint
 type Auth0Endpoint struct {
-	URL        string `json:"ur"`
-	Provider   string `json:"provider"`
-	Connection string `json:"connection"`
-	token      string
+	URL         string       `json:"ur"`
+	Provider    string       `json:"provider"`
+	Connection  string       `json:"connection"`
+	Connections []Connection `json:"connections"`
+	token       string
 }
 
 // NewAuth0Client returns a *AuthClient instance
@@ -49,13 +52,33 @@ func NewAuth0Client(rawtoken, endpoint string) *Auth0Client {
 		httpClient: &http.Client{},
 		token:      rawtoken,
 		SerialAPI:  &utils.JSONAPI{},
+		Metadata:   NewMetadataRegistry(),
+	}
+}
+
+func (client *Auth0Client) registry() *MetadataRegistry {
+	if client.Metadata == nil {
+		client.Metadata = NewMetadataRegistry()
 	}
+	return client.Metadata
 }
 
-// GetUserByName returns a user by unique name
+// GetUserByName returns a user by unique name in the default connection
 func (client *Auth0Client) GetUserByName(name string) (*User, error) {
-	userid := fmt.Sprintf("%s|%s|%s",
-		client.Endpoint.Provider, client.Endpoint.Connection, name)
+	return client.GetUserByConnection(name, "")
+}
+
+// GetUserByConnection returns a user by unique name in a connection
+func (client *Auth0Client) GetUserByConnection(name, connection string) (*User, error) {
+	conn, err := client.Endpoint.Conn(connection)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetUser(conn.UserID(name))
+}
+
+// GetUser returns a user by user_id
+func (client *Auth0Client) GetUser(userid string) (*User, error) {
 	user := &User{}
 	endpoint := client.Endpoint.URL + path.Join("users", userid)
 	values := url.Values{}
@@ -66,6 +89,83 @@ func (client *Auth0Client) GetUserByName(name string) (*User, error) {
 	return user, nil
 }
 
+// UpdateUserMeta updates app_metadata and user_metadata of a user,
+// a nil meta is left untouched; fields unknown to meta are kept.
+func (client *Auth0Client) UpdateUserMeta(
+	user *User, appMeta, userMeta interface{}) (*User, error) {
+	body := make(map[string]json.RawMessage)
+	if appMeta != nil {
+		raw, err := MergeMeta(user.RawAppMeta, appMeta, client.SerialAPI)
+		if err != nil {
+			return nil, err
+		}
+		body["app_metadata"] = raw
+	}
+	if userMeta != nil {
+		raw, err := MergeMeta(user.RawUserMeta, userMeta, client.SerialAPI)
+		if err != nil {
+			return nil, err
+		}
+		body["user_metadata"] = raw
+	}
+	content, err := client.SerialAPI.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := &User{}
+	endpoint := client.Endpoint.URL + path.Join("users", user.UserID)
+	if err := misc.PatchJSON(context.Background(), client.httpClient, endpoint,
+		client.token, content, updated, client.ParseUser, client); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// CreateUser creates a user w/ the properties in fields
+func (client *Auth0Client) CreateUser(fields map[string]interface{}) (*User, error) {
+	content, err := client.SerialAPI.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	created := &User{}
+	endpoint := client.Endpoint.URL + "users"
+	// the body carries the initial password of the user
+	if err := misc.PostJSON(context.Background(), client.httpClient, endpoint,
+		client.token, content, created, client.ParseUser, misc.NoDebug{}); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// PatchUser updates the root properties of a user w/ fields
+func (client *Auth0Client) PatchUser(
+	userid string, fields map[string]interface{}) (*User, error) {
+	content, err := client.SerialAPI.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	updated := &User{}
+	endpoint := client.Endpoint.URL + path.Join("users", userid)
+	if err := misc.PatchJSON(context.Background(), client.httpClient, endpoint,
+		client.token, content, updated, client.ParseUser, client); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// BlockUser blocks or unblocks a user
+func (client *Auth0Client) BlockUser(userid string, blocked bool) (*User, error) {
+	return client.PatchUser(userid, map[string]interface{}{"blocked": blocked})
+}
+
+// DeleteUser deletes a user by user_id
+func (client *Auth0Client) DeleteUser(userid string) error {
+	endpoint := client.Endpoint.URL + path.Join("users", userid)
+	return misc.DeleteJSON(context.Background(), client.httpClient, endpoint,
+		client.token, nil, nil, client)
+}
+
 // ListUsers fetches users in a paginated fashion, see GetUsersContext for usage.
 func (client *Auth0Client) ListUsers(start, size int) (results []User, err error) {
 	p := newUserPagination(start, size, client)
@@ -4,8 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
+	"net"
 	"net/url"
 	"strconv"
+	"time"
 
 	misc "github.com/xinnige/asteraceae/calendula/astermisc"
 	utils "github.com/xinnige/asteraceae/calendula/utils"
@@ -82,12 +85,14 @@ func (p *UserPagination) Next(ctx context.Context) (*UserPagination, error) {
 // User defines properties of an auth0 user
 type User struct {
 	AppMeta     interface{}
+	Blocked     bool            `json:"blocked"`
 	CreatedAt   string          `json:"created_at"`
 	DN          string          `json:"dn"`
+	Email       string          `json:"email"`
 	Identities  []Identity      `json:"identities"`
 	LastIP      string          `json:"last_ip"`
 	LastLogin   string          `json:"last_login"`
-	LoginsCount int             `json:"logs_count"`
+	LoginsCount int             `json:"logins_count"`
 	Name        string          `json:"name"`
 	Nickname    string          `json:"nickname"`
 	OrgUnits    string          `json:"organizationUnits"`
@@ -96,6 +101,11 @@ type User struct {
 	UpdatedAt   string          `json:"updated_at"`
 	UserID      string          `json:"user_id"`
 	UserMeta    interface{}
+
+	Created       time.Time `json:"-"`
+	LastIPAddr    net.IP    `json:"-"`
+	LastLoginTime time.Time `json:"-"`
+	Updated       time.Time `json:"-"`
 }
 
 // Identity defines user identity related info
@@ -127,27 +137,52 @@ func (client *Auth0Client) ParseUser(raw []byte, inf interface{}) error {
 	if err := client.SerialAPI.Unmarshal(raw, user); err != nil {
 		return err
 	}
-	return user.parseUser(client.SerialAPI)
+	return user.parseUser(client.SerialAPI, client.registry())
 }
 
-func (user *User) parseUser(siface utils.SerialInterface) error {
-	if len(user.RawAppMeta) != 0 {
-		appmeta := &AuthAppMeta{}
-		if err := siface.Unmarshal(user.RawAppMeta, appmeta); err != nil {
-			return err
-		}
-		user.AppMeta = appmeta
+func (user *User) parseUser(siface utils.SerialInterface, registry *MetadataRegistry) error {
+	appmeta, err := decodeMeta(user.RawAppMeta, registry.appMeta, siface)
+	if err != nil {
+		return err
 	}
-	if len(user.RawUserMeta) != 0 {
-		usermeta := &SimpleUserMeta{}
-		if err := siface.Unmarshal(user.RawUserMeta, usermeta); err != nil {
-			return err
-		}
-		user.UserMeta = usermeta
+	user.AppMeta = appmeta
+
+	usermeta, err := decodeMeta(user.RawUserMeta, registry.userMeta, siface)
+	if err != nil {
+		return err
 	}
+	user.UserMeta = usermeta
+	user.parseTimes()
 	return nil
 }
 
+// parseTimes parses the raw timestamps and ip, empty or invalid values are
+// left zero, so one bad record does not fail a whole page of users
+func (user *User) parseTimes() {
+	for _, field := range []struct {
+		name   string
+		raw    string
+		parsed *time.Time
+	}{
+		{"created_at", user.CreatedAt, &user.Created},
+		{"last_login", user.LastLogin, &user.LastLoginTime},
+		{"updated_at", user.UpdatedAt, &user.Updated},
+	} {
+		*field.parsed = time.Time{}
+		if field.raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, field.raw)
+		if err != nil {
+			log.Printf("Error: cannot parse %s of user %s, err: %v\n",
+				field.name, user.UserID, err)
+			continue
+		}
+		*field.parsed = parsed
+	}
+	user.LastIPAddr = net.ParseIP(user.LastIP)
+}
+
 // ParseUsers unmarshals a raw json to an array of Users
 func (client *Auth0Client) ParseUsers(raw []byte, inf interface{}) error {
 	users, ok := inf.(*[]User)
@@ -158,7 +193,7 @@ func (client *Auth0Client) ParseUsers(raw []byte, inf interface{}) error {
 		return err
 	}
 	for idx := range *users {
-		if err := (*users)[idx].parseUser(client.SerialAPI); err != nil {
+		if err := (*users)[idx].parseUser(client.SerialAPI, client.registry()); err != nil {
 			return err
 		}
 	}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	// "fmt"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -74,3 +75,34 @@ func TestParseUser(t *testing.T) {
 // 	fmt.Println(err)
 //
 // }
+
+func TestParseUserTimes(t *testing.T) {
+	client := &Auth0Client{
+		SerialAPI: &utils.JSONAPI{},
+	}
+	user := &User{}
+	err := client.ParseUser([]byte(fakeUser()), user)
+	assert.Nil(t, err)
+	assert.Equal(t, 8, user.LoginsCount)
+	assert.Equal(t, time.Date(2018, 11, 1, 0, 0, 0, 90000000, time.UTC), user.LastLoginTime)
+	assert.Equal(t, 2018, user.Created.Year())
+	assert.Equal(t, time.October, user.Updated.Month())
+	assert.Nil(t, user.LastIPAddr)
+
+	err = client.ParseUser([]byte(`{"last_ip":"10.0.0.1","last_login":""}`), user)
+	assert.Nil(t, err)
+	assert.True(t, user.LastLoginTime.IsZero())
+	assert.Equal(t, "10.0.0.1", user.LastIPAddr.String())
+
+	err = client.ParseUser([]byte(`{"created_at":"2018-05-29"}`), user)
+	assert.Nil(t, err)
+	assert.True(t, user.Created.IsZero())
+
+	users := []User{}
+	err = client.ParseUsers([]byte(`[{"user_id":"bad","last_login":"yesterday"},
+		{"user_id":"good","last_login":"2018-11-01T00:00:00.09Z"}]`), &users)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(users))
+	assert.True(t, users[0].LastLoginTime.IsZero())
+	assert.Equal(t, 2018, users[1].LastLoginTime.Year())
+}
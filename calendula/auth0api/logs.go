@@ -0,0 +1,349 @@
+package auth0api
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	misc "github.com/xinnige/asteraceae/calendula/astermisc"
+)
+
+const (
+	maxTake         = 100
+	defaultInterval = 10 * time.Second
+)
+
+// LogType defines the event code of an auth0 log event
+// see https://auth0.com/docs/logs#log-data-event-listing
+type LogType string
+
+// Known auth0 log event codes
+const (
+	LogTypeSuccessLogin          LogType = "s"
+	LogTypeFailedLogin           LogType = "f"
+	LogTypeFailedLoginPassword   LogType = "fp"
+	LogTypeFailedLoginUsername   LogType = "fu"
+	LogTypeSuccessLogout         LogType = "slo"
+	LogTypeFailedLogout          LogType = "flo"
+	LogTypeSuccessSignup         LogType = "ss"
+	LogTypeFailedSignup          LogType = "fs"
+	LogTypeSuccessChangePassword LogType = "scp"
+	LogTypeFailedChangePassword  LogType = "fcp"
+	LogTypeSuccessAPIOperation   LogType = "sapi"
+	LogTypeFailedAPIOperation    LogType = "fapi"
+	LogTypeSuccessExchange       LogType = "seccft"
+	LogTypeFailedExchange        LogType = "feccft"
+	LogTypeDeletedUser           LogType = "du"
+	LogTypeBlockedAccount        LogType = "limit_wc"
+	LogTypeWarning               LogType = "w"
+)
+
+var logTypeDescriptions = map[LogType]string{
+	LogTypeSuccessLogin:          "Success Login",
+	LogTypeFailedLogin:           "Failed Login",
+	LogTypeFailedLoginPassword:   "Failed Login (Incorrect Password)",
+	LogTypeFailedLoginUsername:   "Failed Login (Invalid Email/Username)",
+	LogTypeSuccessLogout:         "Success Logout",
+	LogTypeFailedLogout:          "Failed Logout",
+	LogTypeSuccessSignup:         "Success Signup",
+	LogTypeFailedSignup:          "Failed Signup",
+	LogTypeSuccessChangePassword: "Success Change Password",
+	LogTypeFailedChangePassword:  "Failed Change Password",
+	LogTypeSuccessAPIOperation:   "Success API Operation",
+	LogTypeFailedAPIOperation:    "Failed API Operation",
+	LogTypeSuccessExchange:       "Success Exchange",
+	LogTypeFailedExchange:        "Failed Exchange",
+	LogTypeDeletedUser:           "Deleted User",
+	LogTypeBlockedAccount:        "Blocked Account",
+	LogTypeWarning:               "Warnings During Login",
+}
+
+// Description returns a human readable name of the event code
+func (t LogType) Description() string {
+	if desc, ok := logTypeDescriptions[t]; ok {
+		return desc
+	}
+	return string(t)
+}
+
+// ParseLogTypes splits a comma separated list of event codes
+func ParseLogTypes(raw string) []LogType {
+	types := make([]LogType, 0)
+	for _, code := range strings.Split(raw, ",") {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue
+		}
+		types = append(types, LogType(code))
+	}
+	return types
+}
+
+// LogEvent defines properties of an auth0 tenant log event
+type LogEvent struct {
+	LogID        string          `json:"log_id"`
+	Date         string          `json:"date"`
+	Type         LogType         `json:"type"`
+	Description  string          `json:"description"`
+	ClientID     string          `json:"client_id"`
+	ClientName   string          `json:"client_name"`
+	Connection   string          `json:"connection"`
+	ConnectionID string          `json:"connection_id"`
+	Strategy     string          `json:"strategy"`
+	Hostname     string          `json:"hostname"`
+	IP           string          `json:"ip"`
+	UserAgent    string          `json:"user_agent"`
+	UserID       string          `json:"user_id"`
+	UserName     string          `json:"user_name"`
+	Details      json.RawMessage `json:"details,omitempty"`
+}
+
+// LogsOption provided when getting tenant logs.
+type LogsOption func(*LogPagination)
+
+// LogsOptionFrom sets the log_id to start reading from (exclusive).
+// see https://auth0.com/docs/logs/retrieve-log-events-using-mgmt-api
+func LogsOptionFrom(from string) LogsOption {
+	return func(p *LogPagination) {
+		p.from = from
+	}
+}
+
+// LogsOptionTake sets the number of events to fetch per request, maximum 100
+func LogsOptionTake(n int) LogsOption {
+	return func(p *LogPagination) {
+		if n > 0 {
+			p.take = min(n, maxTake)
+		}
+	}
+}
+
+// LogsOptionLimit sets the total number of events to return
+func LogsOptionLimit(n int) LogsOption {
+	return func(p *LogPagination) {
+		p.remain = n
+	}
+}
+
+// LogsOptionTypes filters events by event codes.
+// Checkpoint pagination does not support search queries,
+// so the filter is applied on the fetched events.
+func LogsOptionTypes(types ...LogType) LogsOption {
+	return func(p *LogPagination) {
+		p.types = types
+	}
+}
+
+// LogPagination allows for paginating over the tenant logs w/ checkpoints
+type LogPagination struct {
+	Events []LogEvent
+	from   string
+	take   int
+	remain int
+	types  []LogType
+	last   int
+	client *Auth0Client
+}
+
+func newLogPagination(aclient *Auth0Client, options ...LogsOption) *LogPagination {
+	p := &LogPagination{
+		take:   maxTake,
+		remain: -1,
+		last:   -1,
+		client: aclient,
+	}
+	for _, opt := range options {
+		opt(p)
+	}
+	return p
+}
+
+// Checkpoint returns the log_id of the last fetched event
+func (p *LogPagination) Checkpoint() string {
+	return p.from
+}
+
+// Done checks if the pagination has completed
+func (p *LogPagination) Done() bool {
+	if p == nil {
+		return true
+	}
+	if p.last == -1 {
+		return false
+	}
+	if p.remain == 0 {
+		return true
+	}
+	return p.last < p.take
+}
+
+func (p *LogPagination) match(event *LogEvent) bool {
+	if len(p.types) == 0 {
+		return true
+	}
+	for _, t := range p.types {
+		if event.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Next gets the next page
+func (p *LogPagination) Next(ctx context.Context) (*LogPagination, error) {
+	var (
+		resp []LogEvent
+	)
+	if p.Done() {
+		return p, nil
+	}
+
+	take := p.take
+	if p.remain > 0 && len(p.types) == 0 {
+		take = min(take, p.remain)
+	}
+	values := url.Values{
+		"take": {strconv.Itoa(take)},
+	}
+	if p.from != "" {
+		values.Set("from", p.from)
+	}
+
+	endpoint := p.client.Endpoint.URL + "logs"
+	if err := misc.GetJSON(ctx, p.client.httpClient, endpoint,
+		p.client.token, values, &resp, p.client.SerialAPI.Unmarshal, p.client); err != nil {
+		return p, err
+	}
+	p.client.Debugf("ListLogs: %d events from %q\n", len(resp), p.from)
+
+	p.Events = make([]LogEvent, 0, len(resp))
+	for idx := range resp {
+		if p.remain == 0 {
+			break
+		}
+		p.from = resp[idx].LogID
+		if !p.match(&resp[idx]) {
+			continue
+		}
+		p.Events = append(p.Events, resp[idx])
+		if p.remain > 0 {
+			p.remain--
+		}
+	}
+	p.last = len(resp)
+	return p, nil
+}
+
+// GetLogsPaginated returns a pagination over the tenant logs
+func (client *Auth0Client) GetLogsPaginated(options ...LogsOption) *LogPagination {
+	return newLogPagination(client, options...)
+}
+
+// ListLogs fetches log events after a checkpoint,
+// returns the events and the checkpoint to resume from.
+func (client *Auth0Client) ListLogs(from string, limit int,
+	types []LogType) (events []LogEvent, checkpoint string, err error) {
+	opts := []LogsOption{LogsOptionFrom(from), LogsOptionTypes(types...)}
+	if limit > 0 {
+		opts = append(opts, LogsOptionLimit(limit))
+	}
+	p := newLogPagination(client, opts...)
+	ctx := context.Background()
+
+	events = make([]LogEvent, 0)
+	for !p.Done() {
+		if p, err = p.Next(ctx); err != nil {
+			return events, p.Checkpoint(), err
+		}
+		events = append(events, p.Events...)
+	}
+	return events, p.Checkpoint(), nil
+}
+
+// LogCheckpoint persists the position of a log stream
+type LogCheckpoint interface {
+	Load() (string, error)
+	Save(string) error
+}
+
+// FileCheckpoint stores the checkpoint log_id in a local file
+type FileCheckpoint struct {
+	Path string
+}
+
+// Load reads the checkpoint, returns "" if the file does not exist
+func (c *FileCheckpoint) Load() (string, error) {
+	content, err := ioutil.ReadFile(filepath.Clean(c.Path))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// Save writes the checkpoint to the file
+func (c *FileCheckpoint) Save(checkpoint string) error {
+	return ioutil.WriteFile(c.Path, []byte(checkpoint+"\n"), 0600)
+}
+
+// LogHandler handles a streamed log event
+type LogHandler func(*LogEvent) error
+
+// TailLogs streams log events to handler until ctx is done.
+// The checkpoint is loaded before the first request and saved
+// after each handled page, so a restarted tail resumes where it stopped.
+func (client *Auth0Client) TailLogs(ctx context.Context, checkpoint LogCheckpoint,
+	interval time.Duration, types []LogType, handler LogHandler) error {
+	from, err := checkpoint.Load()
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	for {
+		p := newLogPagination(client, LogsOptionFrom(from), LogsOptionTypes(types...))
+		wait := interval
+		for !p.Done() {
+			if p, err = p.Next(ctx); err != nil {
+				break
+			}
+			for idx := range p.Events {
+				if err = handler(&p.Events[idx]); err != nil {
+					return err
+				}
+			}
+			if p.Checkpoint() != from {
+				from = p.Checkpoint()
+				if err = checkpoint.Save(from); err != nil {
+					return err
+				}
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			rlerr, ok := err.(*misc.RateLimitedError)
+			if !ok {
+				return err
+			}
+			wait = rlerr.RetryAfter
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
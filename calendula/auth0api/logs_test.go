@@ -0,0 +1,206 @@
+package auth0api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+func fakeLogs(ids []string, types []string) string {
+	events := make([]string, len(ids))
+	for idx := range ids {
+		events[idx] = fmt.Sprintf(`{"log_id":"%s","date":"2019-06-01T00:00:00.000Z","type":"%s","client_name":"fake-app","ip":"127.0.0.1","user_id":"ad|ldap01|yamada_taro","user_name":"yamada_taro","details":{"prompts":[]}}`,
+			ids[idx], types[idx])
+	}
+	return "[" + strings.Join(events, ",") + "]"
+}
+
+func TestLogType(t *testing.T) {
+	assert.Equal(t, "Failed Login (Incorrect Password)", LogTypeFailedLoginPassword.Description())
+	assert.Equal(t, "unknown", LogType("unknown").Description())
+	assert.Equal(t, []LogType{LogTypeSuccessLogin, LogTypeFailedLoginPassword},
+		ParseLogTypes("s, fp,,"))
+	assert.Equal(t, 0, len(ParseLogTypes("")))
+}
+
+func TestGetLogsPaginated(t *testing.T) {
+	api := fakeClient()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockClientiface := mock.NewMockAsterClient(mockCtrl)
+	gomock.InOrder(
+		mockClientiface.EXPECT().Do(gomock.Any()).DoAndReturn(
+			func(req *http.Request) (*http.Response, error) {
+				assert.Equal(t, "2", req.URL.Query().Get("take"))
+				assert.Equal(t, "", req.URL.Query().Get("from"))
+				return fakeResponse([]byte(fakeLogs(
+					[]string{"id1", "id2"}, []string{"s", "fp"}))), nil
+			}),
+		mockClientiface.EXPECT().Do(gomock.Any()).DoAndReturn(
+			func(req *http.Request) (*http.Response, error) {
+				assert.Equal(t, "id2", req.URL.Query().Get("from"))
+				return fakeResponse([]byte(fakeLogs(
+					[]string{"id3"}, []string{"sapi"}))), nil
+			}),
+	)
+	api.httpClient = mockClientiface
+
+	p := api.GetLogsPaginated(LogsOptionTake(2))
+	p, err := p.Next(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(p.Events))
+	assert.Equal(t, LogTypeFailedLoginPassword, p.Events[1].Type)
+	assert.Equal(t, "id2", p.Checkpoint())
+	assert.False(t, p.Done())
+
+	p, err = p.Next(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(p.Events))
+	assert.Equal(t, "id3", p.Checkpoint())
+	assert.True(t, p.Done())
+}
+
+func TestListLogs(t *testing.T) {
+	api := fakeClient()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockClientiface := mock.NewMockAsterClient(mockCtrl)
+	mockClientiface.EXPECT().Do(gomock.Any()).Return(
+		fakeResponse([]byte(fakeLogs(
+			[]string{"id1", "id2", "id3"}, []string{"s", "fp", "f"}))), nil).Times(1)
+	api.httpClient = mockClientiface
+
+	events, checkpoint, err := api.ListLogs("id0", 0,
+		[]LogType{LogTypeFailedLogin, LogTypeFailedLoginPassword})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, "id2", events[0].LogID)
+	assert.Equal(t, "id3", checkpoint)
+
+	// limit stops in the middle of a page
+	mockClientiface.EXPECT().Do(gomock.Any()).Return(
+		fakeResponse([]byte(fakeLogs(
+			[]string{"id1", "id2", "id3"}, []string{"s", "fp", "f"}))), nil).Times(1)
+	events, checkpoint, err = api.ListLogs("id0", 1, []LogType{LogTypeFailedLoginPassword})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "id2", checkpoint)
+}
+
+func TestListLogsError(t *testing.T) {
+	api := fakeClient()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockClientiface := mock.NewMockAsterClient(mockCtrl)
+	mockClientiface.EXPECT().Do(gomock.Any()).Return(
+		nil, errors.New("FakeDoError")).Times(1)
+	api.httpClient = mockClientiface
+
+	events, checkpoint, err := api.ListLogs("id0", 0, nil)
+	assert.Equal(t, "FakeDoError", err.Error())
+	assert.Equal(t, 0, len(events))
+	assert.Equal(t, "id0", checkpoint)
+}
+
+func TestFileCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth0logs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	store := &FileCheckpoint{Path: filepath.Join(dir, "checkpoint")}
+	checkpoint, err := store.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, "", checkpoint)
+
+	assert.Nil(t, store.Save("id1"))
+	checkpoint, err = store.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, "id1", checkpoint)
+
+	store = &FileCheckpoint{Path: dir}
+	_, err = store.Load()
+	assert.NotNil(t, err)
+}
+
+type memCheckpoint struct {
+	value string
+}
+
+func (c *memCheckpoint) Load() (string, error) { return c.value, nil }
+
+func (c *memCheckpoint) Save(v string) error {
+	c.value = v
+	return nil
+}
+
+func TestTailLogs(t *testing.T) {
+	api := fakeClient()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockClientiface := mock.NewMockAsterClient(mockCtrl)
+	ctx, cancel := context.WithCancel(context.Background())
+	gomock.InOrder(
+		mockClientiface.EXPECT().Do(gomock.Any()).DoAndReturn(
+			func(req *http.Request) (*http.Response, error) {
+				assert.Equal(t, "id0", req.URL.Query().Get("from"))
+				return fakeResponse([]byte(fakeLogs(
+					[]string{"id1", "id2"}, []string{"s", "f"}))), nil
+			}),
+		mockClientiface.EXPECT().Do(gomock.Any()).DoAndReturn(
+			func(req *http.Request) (*http.Response, error) {
+				assert.Equal(t, "id2", req.URL.Query().Get("from"))
+				cancel()
+				return fakeResponse([]byte(`[]`)), nil
+			}),
+	)
+	api.httpClient = mockClientiface
+
+	store := &memCheckpoint{value: "id0"}
+	handled := make([]string, 0)
+	err := api.TailLogs(ctx, store, time.Millisecond, []LogType{LogTypeFailedLogin},
+		func(event *LogEvent) error {
+			handled = append(handled, event.LogID)
+			return nil
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id2"}, handled)
+	assert.Equal(t, "id2", store.value)
+}
+
+func TestTailLogsError(t *testing.T) {
+	api := fakeClient()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockClientiface := mock.NewMockAsterClient(mockCtrl)
+	mockClientiface.EXPECT().Do(gomock.Any()).Return(
+		fakeResponse([]byte(fakeLogs([]string{"id1"}, []string{"s"}))), nil).Times(1)
+	mockClientiface.EXPECT().Do(gomock.Any()).Return(
+		nil, errors.New("FakeDoError")).Times(1)
+	api.httpClient = mockClientiface
+
+	store := &memCheckpoint{}
+	err := api.TailLogs(context.Background(), store, time.Millisecond, nil,
+		func(event *LogEvent) error { return errors.New("FakeHandlerError") })
+	assert.Equal(t, "FakeHandlerError", err.Error())
+	assert.Equal(t, "", store.value)
+
+	err = api.TailLogs(context.Background(), store, time.Millisecond, nil,
+		func(event *LogEvent) error { return nil })
+	assert.Equal(t, "FakeDoError", err.Error())
+}
@@ -0,0 +1,104 @@
+package auth0api
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	utils "github.com/xinnige/asteraceae/calendula/utils"
+)
+
+// MetaFactory returns a pointer to a new metadata value to decode into
+type MetaFactory func() interface{}
+
+// MetadataRegistry holds the metadata types of a tenant
+type MetadataRegistry struct {
+	appMeta  MetaFactory
+	userMeta MetaFactory
+}
+
+// NewMetadataRegistry returns a registry decoding
+// app_metadata as *AuthAppMeta and user_metadata as *SimpleUserMeta
+func NewMetadataRegistry() *MetadataRegistry {
+	return &MetadataRegistry{
+		appMeta:  func() interface{} { return &AuthAppMeta{} },
+		userMeta: func() interface{} { return &SimpleUserMeta{} },
+	}
+}
+
+// RegisterAppMeta sets the type of app_metadata,
+// a nil factory keeps app_metadata raw only
+func (r *MetadataRegistry) RegisterAppMeta(factory MetaFactory) {
+	r.appMeta = factory
+}
+
+// RegisterUserMeta sets the type of user_metadata,
+// a nil factory keeps user_metadata raw only
+func (r *MetadataRegistry) RegisterUserMeta(factory MetaFactory) {
+	r.userMeta = factory
+}
+
+func decodeMeta(raw json.RawMessage, factory MetaFactory,
+	siface utils.SerialInterface) (interface{}, error) {
+	if len(raw) == 0 || factory == nil {
+		return nil, nil
+	}
+	meta := factory()
+	if err := siface.Unmarshal(raw, meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// assignMeta copies a decoded meta to v if both are the same type,
+// otherwise decodes raw to v
+func assignMeta(meta interface{}, raw json.RawMessage, v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return fmt.Errorf("unsupport type %T (expected a pointer)", v)
+	}
+	if meta != nil && reflect.TypeOf(meta) == target.Type() {
+		target.Elem().Set(reflect.ValueOf(meta).Elem())
+		return nil
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// DecodeAppMeta stores app_metadata in the value pointed to by v
+func (user *User) DecodeAppMeta(v interface{}) error {
+	return assignMeta(user.AppMeta, user.RawAppMeta, v)
+}
+
+// DecodeUserMeta stores user_metadata in the value pointed to by v
+func (user *User) DecodeUserMeta(v interface{}) error {
+	return assignMeta(user.UserMeta, user.RawUserMeta, v)
+}
+
+// MergeMeta overlays the fields of meta onto a raw metadata object,
+// fields unknown to meta are kept as they are in raw.
+func MergeMeta(raw json.RawMessage, meta interface{},
+	siface utils.SerialInterface) (json.RawMessage, error) {
+	merged := make(map[string]json.RawMessage)
+	if len(raw) != 0 {
+		if err := siface.Unmarshal(raw, &merged); err != nil {
+			return nil, err
+		}
+	}
+	if meta != nil {
+		content, err := siface.Marshal(meta)
+		if err != nil {
+			return nil, err
+		}
+		fields := make(map[string]json.RawMessage)
+		if err := siface.Unmarshal(content, &fields); err != nil {
+			return nil, err
+		}
+		for key, value := range fields {
+			merged[key] = value
+		}
+	}
+	return siface.Marshal(merged)
+}
@@ -0,0 +1,107 @@
+package auth0api
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+	utils "github.com/xinnige/asteraceae/calendula/utils"
+)
+
+type fakeTenantMeta struct {
+	Apps  []string `json:"apps"`
+	Level int      `json:"level"`
+}
+
+func TestMetadataRegistry(t *testing.T) {
+	client := fakeClient()
+	client.Metadata = NewMetadataRegistry()
+	client.Metadata.RegisterAppMeta(func() interface{} { return &fakeTenantMeta{} })
+	client.Metadata.RegisterUserMeta(nil)
+
+	user := &User{}
+	err := client.ParseUser([]byte(fakeUser()), user)
+	assert.Nil(t, err)
+	appmeta, ok := user.AppMeta.(*fakeTenantMeta)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"app1", "app2"}, appmeta.Apps)
+	assert.Nil(t, user.UserMeta)
+	assert.NotEqual(t, 0, len(user.RawUserMeta))
+}
+
+func TestDecodeMeta(t *testing.T) {
+	client := fakeClient()
+	user := &User{}
+	assert.Nil(t, client.ParseUser([]byte(fakeUser()), user))
+
+	// same type as registered
+	appmeta := AuthAppMeta{}
+	assert.Nil(t, user.DecodeAppMeta(&appmeta))
+	assert.True(t, appmeta.LambdaAuthorizer)
+
+	// other type decoded from raw
+	tenantmeta := fakeTenantMeta{}
+	assert.Nil(t, user.DecodeAppMeta(&tenantmeta))
+	assert.Equal(t, 2, len(tenantmeta.Apps))
+
+	usermeta := map[string]string{}
+	assert.Nil(t, user.DecodeUserMeta(&usermeta))
+	assert.Equal(t, "yamada", usermeta["surname"])
+
+	err := user.DecodeUserMeta(usermeta)
+	assert.NotNil(t, err)
+
+	empty := &User{}
+	assert.Nil(t, empty.DecodeAppMeta(&appmeta))
+}
+
+func TestMergeMeta(t *testing.T) {
+	raw := json.RawMessage(`{"apps":["app1"],"lambda_authorizer":true,"team":"infra"}`)
+	merged, err := MergeMeta(raw, &fakeTenantMeta{Apps: []string{"app2"}, Level: 3},
+		&utils.JSONAPI{})
+	assert.Nil(t, err)
+	assert.JSONEq(t,
+		`{"apps":["app2"],"lambda_authorizer":true,"level":3,"team":"infra"}`,
+		string(merged))
+
+	merged, err = MergeMeta(nil, nil, &utils.JSONAPI{})
+	assert.Nil(t, err)
+	assert.Equal(t, "{}", string(merged))
+
+	_, err = MergeMeta(json.RawMessage(`[]`), nil, &utils.JSONAPI{})
+	assert.NotNil(t, err)
+}
+
+func TestUpdateUserMeta(t *testing.T) {
+	api := fakeClient()
+	user := &User{}
+	assert.Nil(t, api.ParseUser([]byte(fakeUser()), user))
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockClientiface := mock.NewMockAsterClient(mockCtrl)
+	mockClientiface.EXPECT().Do(gomock.Any()).DoAndReturn(
+		func(req *http.Request) (*http.Response, error) {
+			assert.Equal(t, "PATCH", req.Method)
+			body, _ := ioutil.ReadAll(req.Body)
+			assert.JSONEq(t,
+				`{"app_metadata":{"lambda_authorizer":false,"apps":["app3"]}}`,
+				string(body))
+			return fakeResponse([]byte(fakeUser())), nil
+		}).Times(1)
+	mockClientiface.EXPECT().Do(gomock.Any()).Return(
+		nil, errors.New("FakeDoError")).Times(1)
+	api.httpClient = mockClientiface
+
+	updated, err := api.UpdateUserMeta(user, &AuthAppMeta{Apps: []string{"app3"}}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "yamada_taro", updated.Nickname)
+
+	_, err = api.UpdateUserMeta(user, nil, &SimpleUserMeta{Surname: "yamada"})
+	assert.Equal(t, "FakeDoError", err.Error())
+}
@@ -0,0 +1,77 @@
+package auth0api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+func TestTokenURL(t *testing.T) {
+	endpoint := &Auth0Endpoint{URL: "https://tenant.auth0.com/api/v2/"}
+	tokenURL, err := endpoint.TokenURL()
+	assert.Nil(t, err)
+	assert.Equal(t, "https://tenant.auth0.com/oauth/token", tokenURL)
+
+	endpoint = &Auth0Endpoint{URL: "fake-url"}
+	_, err = endpoint.TokenURL()
+	assert.EqualError(t, err, `invalid auth0 endpoint "fake-url"`)
+	endpoint = &Auth0Endpoint{URL: "://"}
+	_, err = endpoint.TokenURL()
+	assert.NotNil(t, err)
+}
+
+func TestClientCredentialsToken(t *testing.T) {
+	api := fakeClient()
+	api.Endpoint.URL = "https://tenant.auth0.com/api/v2/"
+	logs := &bytes.Buffer{}
+	api.debug = true
+	api.log = log.New(logs, "", 0)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockClientiface := mock.NewMockAsterClient(mockCtrl)
+	mockClientiface.EXPECT().Do(gomock.Any()).DoAndReturn(
+		func(req *http.Request) (*http.Response, error) {
+			assert.Equal(t, "https://tenant.auth0.com/oauth/token", req.URL.String())
+			assert.Equal(t, "", req.Header.Get("Authorization"))
+			content, _ := ioutil.ReadAll(req.Body)
+			body := map[string]string{}
+			assert.Nil(t, json.Unmarshal(content, &body))
+			assert.Equal(t, map[string]string{
+				"grant_type":    "client_credentials",
+				"client_id":     "app",
+				"client_secret": "new-secret",
+				"audience":      "https://tenant.auth0.com/api/v2/",
+			}, body)
+			return fakeResponse([]byte(`{"access_token":"at","token_type":"Bearer","expires_in":86400}`)), nil
+		}).Times(1)
+	api.httpClient = mockClientiface
+	token, err := api.ClientCredentialsToken(context.Background(), "app", "new-secret", "")
+	assert.Nil(t, err)
+	assert.Equal(t, &OAuthToken{AccessToken: "at", TokenType: "Bearer", ExpiresIn: 86400}, token)
+	// neither the client secret nor the access token is logged
+	assert.Equal(t, "", logs.String())
+
+	mockClientiface.EXPECT().Do(gomock.Any()).Return(
+		fakeResponse([]byte(`{}`)), nil).Times(1)
+	_, err = api.ClientCredentialsToken(context.Background(), "app", "new-secret", "api")
+	assert.EqualError(t, err, "empty access token of client app")
+
+	mockClientiface.EXPECT().Do(gomock.Any()).Return(
+		nil, errors.New("FakeHTTPError")).Times(1)
+	_, err = api.ClientCredentialsToken(context.Background(), "app", "new-secret", "api")
+	assert.NotNil(t, err)
+
+	api.Endpoint.URL = "fake-url"
+	_, err = api.ClientCredentialsToken(context.Background(), "app", "new-secret", "")
+	assert.NotNil(t, err)
+}
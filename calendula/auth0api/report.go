@@ -0,0 +1,153 @@
+package auth0api
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	utils "github.com/xinnige/asteraceae/calendula/utils"
+)
+
+const day = 24 * time.Hour
+
+// StaleReason defines why a user is reported as stale
+type StaleReason string
+
+// Available stale reasons
+const (
+	// StaleInactive indicates the last login is older than the threshold
+	StaleInactive StaleReason = "inactive"
+	// StaleNeverLoggedIn indicates an old account w/o any login
+	StaleNeverLoggedIn StaleReason = "never_logged_in"
+	// StaleAppAccess indicates lambda_authorizer access w/o a recent login
+	StaleAppAccess StaleReason = "app_access_unused"
+)
+
+// StaleCriteria defines thresholds of the stale users report,
+// a zero threshold disables the related check
+type StaleCriteria struct {
+	Now                time.Time
+	InactiveDays       int
+	NeverLoggedInDays  int
+	AppAccessDays      int
+	IgnoreBlockedUsers bool
+}
+
+// StaleUser defines a row of the stale users report
+type StaleUser struct {
+	UserID       string        `json:"user_id"`
+	Name         string        `json:"name"`
+	Email        string        `json:"email"`
+	CreatedAt    string        `json:"created_at"`
+	LastLogin    string        `json:"last_login"`
+	LastIP       string        `json:"last_ip"`
+	LoginsCount  int           `json:"logins_count"`
+	InactiveDays int           `json:"inactive_days"`
+	Reasons      []StaleReason `json:"reasons"`
+}
+
+// FindStaleUsers returns users matching any of the criteria
+func FindStaleUsers(users []User, criteria StaleCriteria) []StaleUser {
+	if criteria.Now.IsZero() {
+		criteria.Now = time.Now()
+	}
+	stale := make([]StaleUser, 0)
+	for idx := range users {
+		user := &users[idx]
+		if criteria.IgnoreBlockedUsers && user.Blocked {
+			continue
+		}
+		reasons := criteria.check(user)
+		if len(reasons) == 0 {
+			continue
+		}
+		stale = append(stale, StaleUser{
+			UserID:       user.UserID,
+			Name:         user.Name,
+			Email:        user.Email,
+			CreatedAt:    user.CreatedAt,
+			LastLogin:    user.LastLogin,
+			LastIP:       user.LastIP,
+			LoginsCount:  user.LoginsCount,
+			InactiveDays: criteria.inactiveDays(user),
+			Reasons:      reasons,
+		})
+	}
+	return stale
+}
+
+// inactiveDays returns days since the last login, or since creation if never logged in
+func (criteria *StaleCriteria) inactiveDays(user *User) int {
+	since := user.LastLoginTime
+	if since.IsZero() {
+		since = user.Created
+	}
+	if since.IsZero() {
+		return 0
+	}
+	return int(criteria.Now.Sub(since) / day)
+}
+
+func (criteria *StaleCriteria) olderThan(t time.Time, days int) bool {
+	return !t.IsZero() && criteria.Now.Sub(t) > time.Duration(days)*day
+}
+
+func (criteria *StaleCriteria) check(user *User) []StaleReason {
+	reasons := make([]StaleReason, 0)
+	// an unparseable last_login is neither stale nor never logged in
+	neverLoggedIn := user.LastLogin == ""
+	if criteria.InactiveDays > 0 && !neverLoggedIn &&
+		criteria.olderThan(user.LastLoginTime, criteria.InactiveDays) {
+		reasons = append(reasons, StaleInactive)
+	}
+	if criteria.NeverLoggedInDays > 0 && neverLoggedIn &&
+		criteria.olderThan(user.Created, criteria.NeverLoggedInDays) {
+		reasons = append(reasons, StaleNeverLoggedIn)
+	}
+	if criteria.AppAccessDays > 0 {
+		appmeta := AuthAppMeta{}
+		if err := user.DecodeAppMeta(&appmeta); err == nil && appmeta.LambdaAuthorizer {
+			if neverLoggedIn || criteria.olderThan(user.LastLoginTime, criteria.AppAccessDays) {
+				reasons = append(reasons, StaleAppAccess)
+			}
+		}
+	}
+	return reasons
+}
+
+var staleUserHeader = []string{"user_id", "name", "email", "created_at",
+	"last_login", "last_ip", "logins_count", "inactive_days", "reasons"}
+
+// WriteStaleUsersCSV writes the report as csv w/ a header row
+func WriteStaleUsersCSV(w io.Writer, stale []StaleUser) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(staleUserHeader); err != nil {
+		return err
+	}
+	for _, user := range stale {
+		reasons := make([]string, len(user.Reasons))
+		for idx, reason := range user.Reasons {
+			reasons[idx] = string(reason)
+		}
+		if err := writer.Write([]string{user.UserID, user.Name, user.Email,
+			user.CreatedAt, user.LastLogin, user.LastIP,
+			strconv.Itoa(user.LoginsCount), strconv.Itoa(user.InactiveDays),
+			strings.Join(reasons, ";")}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteStaleUsersJSON writes the report as an indented json array
+func WriteStaleUsersJSON(w io.Writer, stale []StaleUser, siface utils.SerialInterface) error {
+	content, err := siface.MarshalIndent(stale, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
@@ -0,0 +1,81 @@
+package auth0api
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+	utils "github.com/xinnige/asteraceae/calendula/utils"
+)
+
+func fakeStaleUsers(t *testing.T) []User {
+	raw := `[
+{"user_id":"ad|ldap01|active","created_at":"2019-01-01T00:00:00.000Z","last_login":"2019-06-28T00:00:00.000Z","logins_count":30,"app_metadata":{"lambda_authorizer":true}},
+{"user_id":"ad|ldap01|inactive","created_at":"2019-01-01T00:00:00.000Z","last_login":"2019-02-01T00:00:00.000Z","logins_count":2,"app_metadata":{"lambda_authorizer":true}},
+{"user_id":"ad|ldap01|never","created_at":"2019-01-01T00:00:00.000Z","app_metadata":{"lambda_authorizer":false}},
+{"user_id":"ad|ldap01|new","created_at":"2019-06-25T00:00:00.000Z","app_metadata":{"lambda_authorizer":true}},
+{"user_id":"ad|ldap01|blocked","created_at":"2019-01-01T00:00:00.000Z","blocked":true}]`
+	users := make([]User, 0)
+	client := fakeClient()
+	assert.Nil(t, client.ParseUsers([]byte(raw), &users))
+	return users
+}
+
+func TestFindStaleUsers(t *testing.T) {
+	users := fakeStaleUsers(t)
+	criteria := StaleCriteria{
+		Now:                time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC),
+		InactiveDays:       90,
+		NeverLoggedInDays:  30,
+		AppAccessDays:      7,
+		IgnoreBlockedUsers: true,
+	}
+	stale := FindStaleUsers(users, criteria)
+	assert.Equal(t, 3, len(stale))
+	assert.Equal(t, "ad|ldap01|inactive", stale[0].UserID)
+	assert.Equal(t, []StaleReason{StaleInactive, StaleAppAccess}, stale[0].Reasons)
+	assert.Equal(t, 150, stale[0].InactiveDays)
+	assert.Equal(t, "ad|ldap01|never", stale[1].UserID)
+	assert.Equal(t, []StaleReason{StaleNeverLoggedIn}, stale[1].Reasons)
+	assert.Equal(t, 181, stale[1].InactiveDays)
+	assert.Equal(t, "ad|ldap01|new", stale[2].UserID)
+	assert.Equal(t, []StaleReason{StaleAppAccess}, stale[2].Reasons)
+
+	criteria.IgnoreBlockedUsers = false
+	criteria.AppAccessDays = 0
+	stale = FindStaleUsers(users, criteria)
+	assert.Equal(t, 3, len(stale))
+	assert.Equal(t, "ad|ldap01|blocked", stale[2].UserID)
+
+	stale = FindStaleUsers(users, StaleCriteria{})
+	assert.Equal(t, 0, len(stale))
+}
+
+func TestWriteStaleUsers(t *testing.T) {
+	stale := []StaleUser{{UserID: "ad|ldap01|inactive", Name: "Inactive, User",
+		LoginsCount: 2, InactiveDays: 150,
+		Reasons: []StaleReason{StaleInactive, StaleAppAccess}}}
+
+	var buf bytes.Buffer
+	assert.Nil(t, WriteStaleUsersCSV(&buf, stale))
+	assert.Equal(t, "user_id,name,email,created_at,last_login,last_ip,"+
+		"logins_count,inactive_days,reasons\n"+
+		"ad|ldap01|inactive,\"Inactive, User\",,,,,2,150,inactive;app_access_unused\n",
+		buf.String())
+
+	buf.Reset()
+	assert.Nil(t, WriteStaleUsersJSON(&buf, stale, &utils.JSONAPI{}))
+	assert.Contains(t, buf.String(), "\"reasons\": [\n      \"inactive\",")
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockSiface := mock.NewMockSerialInterface(mockCtrl)
+	mockSiface.EXPECT().MarshalIndent(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+		nil, errors.New("FakeJSONError")).Times(1)
+	err := WriteStaleUsersJSON(&buf, stale, mockSiface)
+	assert.Equal(t, "FakeJSONError", err.Error())
+}
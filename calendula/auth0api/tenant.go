@@ -0,0 +1,130 @@
+package auth0api
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	utils "github.com/xinnige/asteraceae/calendula/utils"
+)
+
+const (
+	// ProviderDatabase indicates the provider of auth0 database connections
+	ProviderDatabase = "auth0"
+)
+
+// Connection defines an auth0 connection users belong to
+type Connection struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+}
+
+// UserID builds the auth0 user_id of a user in the connection,
+// database users are identified by `auth0|<id>`,
+// enterprise (ad/ldap) users by `<provider>|<connection>|<name>`.
+func (conn *Connection) UserID(name string) string {
+	if conn.Provider == ProviderDatabase {
+		return fmt.Sprintf("%s|%s", conn.Provider, name)
+	}
+	return fmt.Sprintf("%s|%s|%s", conn.Provider, conn.Name, name)
+}
+
+// TenantProfile defines an auth0 tenant with its credentials source
+type TenantProfile struct {
+	Name              string       `json:"name"`
+	Domain            string       `json:"domain"`
+	TokenEnv          string       `json:"token_env"`
+	TokenFile         string       `json:"token_file"`
+	Connections       []Connection `json:"connections"`
+	DefaultConnection string       `json:"default_connection"`
+}
+
+// TenantConfig holds a list of named tenant profiles
+type TenantConfig struct {
+	Default string          `json:"default"`
+	Tenants []TenantProfile `json:"tenants"`
+}
+
+// LoadTenantConfig reads tenant profiles from a json file
+func LoadTenantConfig(filename string, siface utils.SerialInterface) (*TenantConfig, error) {
+	content, err := utils.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	config := &TenantConfig{}
+	if err := siface.Unmarshal(content, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Tenant returns a tenant profile by name, or the default one if name is empty
+func (config *TenantConfig) Tenant(name string) (*TenantProfile, error) {
+	if name == "" {
+		name = config.Default
+	}
+	for idx := range config.Tenants {
+		if config.Tenants[idx].Name == name {
+			return &config.Tenants[idx], nil
+		}
+	}
+	return nil, fmt.Errorf("tenant %q not found", name)
+}
+
+// URL returns the management api endpoint of the tenant
+func (profile *TenantProfile) URL() string {
+	domain := strings.TrimSuffix(profile.Domain, "/")
+	if !strings.HasPrefix(domain, "https://") && !strings.HasPrefix(domain, "http://") {
+		domain = "https://" + domain
+	}
+	return domain + "/api/v2/"
+}
+
+// Token reads the management api token from the credentials source
+func (profile *TenantProfile) Token() (string, error) {
+	if profile.TokenEnv != "" {
+		if token := os.Getenv(profile.TokenEnv); token != "" {
+			return token, nil
+		}
+	}
+	if profile.TokenFile != "" {
+		content, err := utils.ReadFile(profile.TokenFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(content)), nil
+	}
+	return "", fmt.Errorf("empty auth token of tenant %q", profile.Name)
+}
+
+// NewAuth0ClientFromProfile returns a *Auth0Client of a tenant profile
+func NewAuth0ClientFromProfile(profile *TenantProfile) (*Auth0Client, error) {
+	token, err := profile.Token()
+	if err != nil {
+		return nil, err
+	}
+	client := NewAuth0Client(token, profile.URL())
+	client.Endpoint.Connections = profile.Connections
+	if profile.DefaultConnection != "" {
+		conn, err := client.Endpoint.Conn(profile.DefaultConnection)
+		if err != nil {
+			return nil, err
+		}
+		client.Endpoint.Provider = conn.Provider
+		client.Endpoint.Connection = conn.Name
+	}
+	return client, nil
+}
+
+// Conn returns a connection by name, or the default connection if name is empty
+func (endpoint *Auth0Endpoint) Conn(name string) (*Connection, error) {
+	if name == "" || name == endpoint.Connection {
+		return &Connection{Name: endpoint.Connection, Provider: endpoint.Provider}, nil
+	}
+	for idx := range endpoint.Connections {
+		if endpoint.Connections[idx].Name == name {
+			return &endpoint.Connections[idx], nil
+		}
+	}
+	return nil, fmt.Errorf("connection %q not found", name)
+}
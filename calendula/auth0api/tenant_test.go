@@ -0,0 +1,123 @@
+package auth0api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+	utils "github.com/xinnige/asteraceae/calendula/utils"
+)
+
+func fakeTenantConfig(tokenFile string) string {
+	return fmt.Sprintf(`{"default":"dev","tenants":[
+{"name":"dev","domain":"dev-asteraceae.auth0.com","token_env":"FAKE_AUTH0_DEV_TOKEN",
+ "connections":[{"name":"ldap01","provider":"ad"},{"name":"Username-Password-Authentication","provider":"auth0"}],
+ "default_connection":"ldap01"},
+{"name":"prod","domain":"https://prod-asteraceae.auth0.com/","token_file":"%s"}]}`, tokenFile)
+}
+
+func writeTenantConfig(t *testing.T, dir string) string {
+	tokenFile := filepath.Join(dir, "token")
+	assert.Nil(t, ioutil.WriteFile(tokenFile, []byte("fake-prod-token\n"), 0600))
+	configFile := filepath.Join(dir, "tenants.json")
+	content := []byte(fakeTenantConfig(tokenFile))
+	assert.Nil(t, ioutil.WriteFile(configFile, content, 0600))
+	return configFile
+}
+
+func TestConnectionUserID(t *testing.T) {
+	conn := &Connection{Name: "ldap01", Provider: "ad"}
+	assert.Equal(t, "ad|ldap01|yamada_taro", conn.UserID("yamada_taro"))
+	conn = &Connection{Name: "Username-Password-Authentication", Provider: ProviderDatabase}
+	assert.Equal(t, "auth0|5d0a1b2c", conn.UserID("5d0a1b2c"))
+}
+
+func TestLoadTenantConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth0tenant")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	configFile := writeTenantConfig(t, dir)
+
+	config, err := LoadTenantConfig(configFile, &utils.JSONAPI{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(config.Tenants))
+
+	dev, err := config.Tenant("")
+	assert.Nil(t, err)
+	assert.Equal(t, "dev", dev.Name)
+	assert.Equal(t, "https://dev-asteraceae.auth0.com/api/v2/", dev.URL())
+	_, err = dev.Token()
+	assert.NotNil(t, err)
+
+	prod, err := config.Tenant("prod")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://prod-asteraceae.auth0.com/api/v2/", prod.URL())
+	token, err := prod.Token()
+	assert.Nil(t, err)
+	assert.Equal(t, "fake-prod-token", token)
+
+	_, err = config.Tenant("stg")
+	assert.Equal(t, "tenant \"stg\" not found", err.Error())
+
+	_, err = LoadTenantConfig(filepath.Join(dir, "none.json"), &utils.JSONAPI{})
+	assert.NotNil(t, err)
+	_, err = LoadTenantConfig(filepath.Join(dir, "token"), &utils.JSONAPI{})
+	assert.NotNil(t, err)
+}
+
+func TestNewAuth0ClientFromProfile(t *testing.T) {
+	os.Setenv("FAKE_AUTH0_DEV_TOKEN", "fake-dev-token")
+	defer os.Unsetenv("FAKE_AUTH0_DEV_TOKEN")
+	config := &TenantConfig{}
+	assert.Nil(t, (&utils.JSONAPI{}).Unmarshal([]byte(fakeTenantConfig("")), config))
+	dev, _ := config.Tenant("dev")
+
+	client, err := NewAuth0ClientFromProfile(dev)
+	assert.Nil(t, err)
+	assert.Equal(t, "fake-dev-token", client.token)
+	assert.Equal(t, "ad", client.Endpoint.Provider)
+	assert.Equal(t, "ldap01", client.Endpoint.Connection)
+
+	conn, err := client.Endpoint.Conn("Username-Password-Authentication")
+	assert.Nil(t, err)
+	assert.Equal(t, ProviderDatabase, conn.Provider)
+	_, err = client.Endpoint.Conn("ldap02")
+	assert.NotNil(t, err)
+
+	dev.DefaultConnection = "ldap02"
+	_, err = NewAuth0ClientFromProfile(dev)
+	assert.NotNil(t, err)
+
+	prod, _ := config.Tenant("prod")
+	_, err = NewAuth0ClientFromProfile(prod)
+	assert.NotNil(t, err)
+}
+
+func TestGetUserByConnection(t *testing.T) {
+	api := fakeClient()
+	api.Endpoint.Connections = []Connection{
+		{Name: "Username-Password-Authentication", Provider: ProviderDatabase}}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockClientiface := mock.NewMockAsterClient(mockCtrl)
+	mockClientiface.EXPECT().Do(gomock.Any()).DoAndReturn(
+		func(req *http.Request) (*http.Response, error) {
+			assert.Equal(t, "fake-urlusers/auth0|5d0a1b2c", req.URL.Path)
+			return fakeResponse([]byte(fakeUser())), nil
+		}).Times(1)
+	api.httpClient = mockClientiface
+
+	user, err := api.GetUserByConnection("5d0a1b2c", "Username-Password-Authentication")
+	assert.Nil(t, err)
+	assert.Equal(t, "yamada_taro", user.Nickname)
+
+	_, err = api.GetUserByConnection("5d0a1b2c", "no-such-conn")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,210 @@
+package auth0sync
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xinnige/asteraceae/calendula/auth0api"
+)
+
+// Operations of applied actions
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpBlock  = "block"
+	OpDelete = "delete"
+)
+
+// UserManager defines the auth0 operations to apply a plan,
+// implemented by *auth0api.Auth0Client
+type UserManager interface {
+	CreateUser(fields map[string]interface{}) (*auth0api.User, error)
+	PatchUser(userid string, fields map[string]interface{}) (*auth0api.User, error)
+	BlockUser(userid string, blocked bool) (*auth0api.User, error)
+	DeleteUser(userid string) error
+}
+
+// Action defines an applied (or planned in dry-run) operation
+type Action struct {
+	Op     string `json:"op"`
+	UserID string `json:"user_id"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ApplyResult holds the actions of an applied plan
+type ApplyResult struct {
+	DryRun  bool     `json:"dry_run"`
+	Actions []Action `json:"actions"`
+}
+
+// Failed returns the actions w/ errors
+func (result *ApplyResult) Failed() []Action {
+	failed := make([]Action, 0)
+	for _, action := range result.Actions {
+		if action.Error != "" {
+			failed = append(failed, action)
+		}
+	}
+	return failed
+}
+
+// Render writes a human readable summary of the result
+func (result *ApplyResult) Render(w io.Writer) {
+	mode := "applied"
+	if result.DryRun {
+		mode = "dry-run"
+	}
+	fmt.Fprintf(w, "Actions (%s): %d, failed: %d\n",
+		mode, len(result.Actions), len(result.Failed()))
+	for _, action := range result.Failed() {
+		fmt.Fprintf(w, "  %s %s: %s\n", action.Op, action.UserID, action.Error)
+	}
+}
+
+// ApplyOptions defines how a plan is applied
+type ApplyOptions struct {
+	// DryRun only records the actions w/o calling the manager
+	DryRun bool
+	// Connection is the connection of created users
+	Connection string
+	// Provider is the provider of the connection, users are only created in
+	// database connections, enterprise users are provisioned by their directory
+	Provider string
+	// Password returns the initial password of a created user, a random one
+	// which nobody knows if nil, so users set their own by a password reset
+	Password func() (string, error)
+}
+
+// randomPassword returns 24 random bytes in base64 w/ a digit and a symbol,
+// so it passes the password policies of auth0
+func randomPassword() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf) + "#7", nil
+}
+
+// Apply runs the plan against auth0, failed actions are recorded
+// and do not stop the rest of the plan.
+// organizationUnits is reported in diffs but not updated,
+// since it is provided by the enterprise connection.
+func (plan *SyncPlan) Apply(manager UserManager, opts ApplyOptions) *ApplyResult {
+	result := &ApplyResult{DryRun: opts.DryRun, Actions: make([]Action, 0)}
+	record := func(op, userid string, err error) {
+		action := Action{Op: op, UserID: userid}
+		if err != nil {
+			action.Error = err.Error()
+		}
+		result.Actions = append(result.Actions, action)
+	}
+
+	for idx := range plan.Creates {
+		entry := &plan.Creates[idx]
+		userid, err := createUserID(entry, opts)
+		if err != nil || opts.DryRun {
+			record(OpCreate, entry.key(), err)
+			continue
+		}
+		fields, err := createFields(entry, userid, opts)
+		if err == nil {
+			_, err = manager.CreateUser(fields)
+		}
+		record(OpCreate, entry.key(), err)
+	}
+	for _, update := range plan.Updates {
+		fields := updateFields(update.Diffs)
+		if len(fields) == 0 {
+			continue
+		}
+		if opts.DryRun {
+			record(OpUpdate, update.UserID, nil)
+			continue
+		}
+		_, err := manager.PatchUser(update.UserID, fields)
+		record(OpUpdate, update.UserID, err)
+	}
+	for _, user := range plan.Blocks {
+		if opts.DryRun {
+			record(OpBlock, user.UserID, nil)
+			continue
+		}
+		_, err := manager.BlockUser(user.UserID, true)
+		record(OpBlock, user.UserID, err)
+	}
+	for _, user := range plan.Deletes {
+		if opts.DryRun {
+			record(OpDelete, user.UserID, nil)
+			continue
+		}
+		record(OpDelete, user.UserID, manager.DeleteUser(user.UserID))
+	}
+	return result
+}
+
+// createUserID returns the user_id of a created user w/o the provider prefix,
+// it is empty if the directory has no user id
+func createUserID(entry *DirectoryUser, opts ApplyOptions) (string, error) {
+	if opts.Provider != auth0api.ProviderDatabase {
+		return "", fmt.Errorf("cannot create users in %s connection %s, only %s connections",
+			opts.Provider, opts.Connection, auth0api.ProviderDatabase)
+	}
+	userid := strings.TrimPrefix(entry.UserID, opts.Provider+"|")
+	if strings.Contains(userid, "|") {
+		return "", fmt.Errorf("user id %s is not of connection %s", entry.UserID, opts.Connection)
+	}
+	return userid, nil
+}
+
+func createFields(entry *DirectoryUser, userid string, opts ApplyOptions) (map[string]interface{}, error) {
+	password := opts.Password
+	if password == nil {
+		password = randomPassword
+	}
+	secret, err := password()
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{"connection": opts.Connection, "password": secret}
+	for key, value := range map[string]string{
+		"user_id":  userid,
+		"email":    entry.Email,
+		"name":     entry.Name,
+		"nickname": entry.Nickname,
+	} {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	if len(entry.AppMeta) != 0 {
+		fields["app_metadata"] = entry.AppMeta
+	}
+	if len(entry.UserMeta) != 0 {
+		fields["user_metadata"] = entry.UserMeta
+	}
+	return fields, nil
+}
+
+// updateFields builds a patch body, metadata is merged by auth0 at the first level
+func updateFields(diffs []FieldDiff) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for _, diff := range diffs {
+		parts := strings.SplitN(diff.Field, ".", 2)
+		switch {
+		case len(parts) == 2:
+			meta, ok := fields[parts[0]].(map[string]interface{})
+			if !ok {
+				meta = make(map[string]interface{})
+				fields[parts[0]] = meta
+			}
+			meta[parts[1]] = diff.New
+		case diff.Field == "organizationUnits":
+		default:
+			fields[diff.Field] = diff.New
+		}
+	}
+	return fields
+}
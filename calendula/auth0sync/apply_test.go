@@ -0,0 +1,147 @@
+package auth0sync
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/auth0api"
+)
+
+type fakeManager struct {
+	calls   []string
+	fields  []map[string]interface{}
+	failing string
+}
+
+func (m *fakeManager) call(op, userid string, fields map[string]interface{}) error {
+	m.calls = append(m.calls, op+" "+userid)
+	m.fields = append(m.fields, fields)
+	if userid == m.failing {
+		return errors.New("FakeManagerError")
+	}
+	return nil
+}
+
+func (m *fakeManager) CreateUser(fields map[string]interface{}) (*auth0api.User, error) {
+	email, _ := fields["email"].(string)
+	return &auth0api.User{}, m.call(OpCreate, email, fields)
+}
+
+func (m *fakeManager) PatchUser(userid string, fields map[string]interface{}) (*auth0api.User, error) {
+	return &auth0api.User{}, m.call(OpUpdate, userid, fields)
+}
+
+func (m *fakeManager) BlockUser(userid string, blocked bool) (*auth0api.User, error) {
+	return &auth0api.User{}, m.call(OpBlock, userid, nil)
+}
+
+func (m *fakeManager) DeleteUser(userid string) error {
+	return m.call(OpDelete, userid, nil)
+}
+
+func fakePlan() *SyncPlan {
+	return &SyncPlan{
+		Creates: []DirectoryUser{{UserID: "auth0|suzuki_hanako", Email: "suzuki_hanako@asteraceae.local",
+			UserMeta: map[string]interface{}{"surname": "suzuki"}}},
+		Updates: []UserUpdate{
+			{UserID: "ad|ldap01|yamada_taro", Diffs: []FieldDiff{
+				{Field: "name", New: "Yamada Taro"},
+				{Field: "app_metadata.apps", New: []interface{}{"app3"}},
+				{Field: "app_metadata.lambda_authorizer", New: false},
+			}},
+			{UserID: "ad|ldap01|sato_jiro", Diffs: []FieldDiff{
+				{Field: "organizationUnits", New: "ou=People"},
+			}},
+		},
+		Blocks:  []auth0api.User{{UserID: "ad|ldap01|tanaka_ichiro"}},
+		Deletes: []auth0api.User{{UserID: "ad|ldap01|kato_saburo"}},
+	}
+}
+
+func TestApplyDryRun(t *testing.T) {
+	manager := &fakeManager{}
+	result := fakePlan().Apply(manager, ApplyOptions{DryRun: true,
+		Provider: auth0api.ProviderDatabase})
+	assert.Equal(t, 0, len(manager.calls))
+	assert.Equal(t, 4, len(result.Actions))
+	assert.Equal(t, 0, len(result.Failed()))
+
+	var buf bytes.Buffer
+	result.Render(&buf)
+	assert.Equal(t, "Actions (dry-run): 4, failed: 0\n", buf.String())
+}
+
+func TestApply(t *testing.T) {
+	manager := &fakeManager{failing: "ad|ldap01|tanaka_ichiro"}
+	result := fakePlan().Apply(manager, ApplyOptions{Connection: "db01",
+		Provider: auth0api.ProviderDatabase,
+		Password: func() (string, error) { return "fake-password", nil }})
+	assert.Equal(t, []string{
+		"create suzuki_hanako@asteraceae.local",
+		"update ad|ldap01|yamada_taro",
+		"block ad|ldap01|tanaka_ichiro",
+		"delete ad|ldap01|kato_saburo"}, manager.calls)
+	// the user id is w/o the provider prefix, as POST /users expects
+	assert.Equal(t, map[string]interface{}{
+		"connection":    "db01",
+		"user_id":       "suzuki_hanako",
+		"password":      "fake-password",
+		"email":         "suzuki_hanako@asteraceae.local",
+		"user_metadata": map[string]interface{}{"surname": "suzuki"},
+	}, manager.fields[0])
+	assert.Equal(t, map[string]interface{}{
+		"name": "Yamada Taro",
+		"app_metadata": map[string]interface{}{
+			"apps": []interface{}{"app3"}, "lambda_authorizer": false},
+	}, manager.fields[1])
+
+	failed := result.Failed()
+	assert.Equal(t, []Action{{Op: OpBlock, UserID: "ad|ldap01|tanaka_ichiro",
+		Error: "FakeManagerError"}}, failed)
+
+	var buf bytes.Buffer
+	result.Render(&buf)
+	assert.Equal(t, "Actions (applied): 4, failed: 1\n"+
+		"  block ad|ldap01|tanaka_ichiro: FakeManagerError\n", buf.String())
+}
+
+func TestApplyCreate(t *testing.T) {
+	plan := &SyncPlan{Creates: []DirectoryUser{
+		{UserID: "suzuki_hanako", Email: "suzuki_hanako@asteraceae.local"},
+		{Email: "sato_jiro@asteraceae.local"},
+		{UserID: "ad|ldap01|yamada_taro", Email: "yamada_taro@asteraceae.local"},
+	}}
+	manager := &fakeManager{}
+	result := plan.Apply(manager, ApplyOptions{Connection: "db01",
+		Provider: auth0api.ProviderDatabase})
+	assert.Equal(t, 2, len(manager.fields))
+	assert.Equal(t, "suzuki_hanako", manager.fields[0]["user_id"])
+	_, ok := manager.fields[1]["user_id"]
+	assert.False(t, ok)
+	// a random password is generated for every user
+	first, _ := manager.fields[0]["password"].(string)
+	second, _ := manager.fields[1]["password"].(string)
+	assert.Equal(t, 34, len(first))
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, []Action{{Op: OpCreate, UserID: "ad|ldap01|yamada_taro",
+		Error: "user id ad|ldap01|yamada_taro is not of connection db01"}}, result.Failed())
+
+	manager = &fakeManager{}
+	result = plan.Apply(manager, ApplyOptions{Connection: "db01",
+		Provider: auth0api.ProviderDatabase,
+		Password: func() (string, error) { return "", errors.New("FakePasswordError") }})
+	assert.Equal(t, 0, len(manager.calls))
+	assert.Equal(t, "FakePasswordError", result.Failed()[0].Error)
+
+	// users are not created in enterprise connections, even in a dry run
+	for _, dryRun := range []bool{true, false} {
+		result = plan.Apply(manager, ApplyOptions{DryRun: dryRun,
+			Connection: "ldap01", Provider: "ad"})
+		assert.Equal(t, 0, len(manager.calls))
+		assert.Equal(t, 3, len(result.Failed()))
+		assert.Equal(t, "cannot create users in ad connection ldap01, only auth0 connections",
+			result.Failed()[0].Error)
+	}
+}
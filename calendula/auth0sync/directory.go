@@ -0,0 +1,230 @@
+package auth0sync
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	utils "github.com/xinnige/asteraceae/calendula/utils"
+)
+
+const (
+	prefixAppMeta  = "app_metadata."
+	prefixUserMeta = "user_metadata."
+)
+
+// DirectoryUser defines a user in the authoritative directory export
+type DirectoryUser struct {
+	UserID   string                 `json:"user_id"`
+	DN       string                 `json:"dn"`
+	Email    string                 `json:"email"`
+	Name     string                 `json:"name"`
+	Nickname string                 `json:"nickname"`
+	OrgUnits string                 `json:"organizationUnits"`
+	Blocked  bool                   `json:"blocked"`
+	AppMeta  map[string]interface{} `json:"app_metadata,omitempty"`
+	UserMeta map[string]interface{} `json:"user_metadata,omitempty"`
+}
+
+// LoadDirectory reads a directory export, the format is chosen by extension
+// (.csv, .json or .ldif)
+func LoadDirectory(filename string, siface utils.SerialInterface) ([]DirectoryUser, error) {
+	content, err := utils.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return ParseCSV(bytes.NewReader(content))
+	case ".json":
+		return ParseJSON(content, siface)
+	case ".ldif":
+		return ParseLDIF(bytes.NewReader(content))
+	}
+	return nil, fmt.Errorf("unsupport directory format %q", filepath.Ext(filename))
+}
+
+// ParseJSON parses an array of DirectoryUser
+func ParseJSON(content []byte, siface utils.SerialInterface) ([]DirectoryUser, error) {
+	users := make([]DirectoryUser, 0)
+	if err := siface.Unmarshal(content, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// ParseCSV parses a csv w/ a header row of DirectoryUser json names,
+// columns named `app_metadata.<key>` and `user_metadata.<key>` fill metadata.
+func ParseCSV(r io.Reader) ([]DirectoryUser, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	users := make([]DirectoryUser, 0)
+	if len(records) == 0 {
+		return users, nil
+	}
+	header := records[0]
+	for _, record := range records[1:] {
+		user := DirectoryUser{}
+		for idx, column := range header {
+			if err := user.set(strings.TrimSpace(column), record[idx]); err != nil {
+				return nil, err
+			}
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (user *DirectoryUser) set(column, value string) error {
+	switch {
+	case value == "":
+	case column == "user_id":
+		user.UserID = value
+	case column == "dn":
+		user.DN = value
+	case column == "email":
+		user.Email = value
+	case column == "name":
+		user.Name = value
+	case column == "nickname":
+		user.Nickname = value
+	case column == "organizationUnits":
+		user.OrgUnits = value
+	case column == "blocked":
+		blocked, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid blocked value %q of %s", value, user.key())
+		}
+		user.Blocked = blocked
+	case strings.HasPrefix(column, prefixAppMeta):
+		if user.AppMeta == nil {
+			user.AppMeta = make(map[string]interface{})
+		}
+		user.AppMeta[strings.TrimPrefix(column, prefixAppMeta)] = metaValue(value)
+	case strings.HasPrefix(column, prefixUserMeta):
+		user.setUserMeta(strings.TrimPrefix(column, prefixUserMeta), metaValue(value))
+	}
+	return nil
+}
+
+func (user *DirectoryUser) setUserMeta(key string, value interface{}) {
+	if user.UserMeta == nil {
+		user.UserMeta = make(map[string]interface{})
+	}
+	user.UserMeta[key] = value
+}
+
+// metaValue decodes a json value (e.g. `true`, `["app1"]`), or keeps the string
+func metaValue(value string) interface{} {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		return value
+	}
+	return decoded
+}
+
+// ldifAttributes maps ldap attributes to DirectoryUser columns
+var ldifAttributes = map[string]string{
+	"dn":                "dn",
+	"uid":               "nickname",
+	"mail":              "email",
+	"cn":                "name",
+	"ou":                "organizationUnits",
+	"organizationunits": "organizationUnits",
+	"auth0userid":       "user_id",
+}
+
+// ParseLDIF parses ldif entries, attributes are mapped by ldifAttributes,
+// `givenName`/`sn` fill user_metadata givenname/surname.
+func ParseLDIF(r io.Reader) ([]DirectoryUser, error) {
+	users := make([]DirectoryUser, 0)
+	lines, err := unfoldLDIF(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *DirectoryUser
+	for _, line := range lines {
+		if line == "" {
+			if user != nil {
+				users = append(users, *user)
+				user = nil
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "version:") {
+			continue
+		}
+		attr, value, err := splitLDIF(line)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			user = &DirectoryUser{}
+		}
+		switch strings.ToLower(attr) {
+		case "givenname":
+			user.setUserMeta("givenname", value)
+		case "sn":
+			user.setUserMeta("surname", value)
+		default:
+			if column, ok := ldifAttributes[strings.ToLower(attr)]; ok {
+				user.set(column, value)
+			}
+		}
+	}
+	if user != nil {
+		users = append(users, *user)
+	}
+	return users, nil
+}
+
+// unfoldLDIF joins continuation lines (starting w/ a space)
+func unfoldLDIF(r io.Reader) ([]string, error) {
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, " ") && len(lines) != 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func splitLDIF(line string) (string, string, error) {
+	idx := strings.Index(line, ":")
+	if idx <= 0 {
+		return "", "", fmt.Errorf("invalid ldif line %q", line)
+	}
+	attr, value := line[:idx], line[idx+1:]
+	if strings.HasPrefix(value, ":") {
+		decoded := utils.DecodeBase64(strings.TrimSpace(value[1:]))
+		if decoded == nil {
+			return "", "", fmt.Errorf("invalid base64 value of %s", attr)
+		}
+		return attr, string(decoded), nil
+	}
+	return attr, strings.TrimSpace(value), nil
+}
+
+func (user *DirectoryUser) key() string {
+	for _, key := range []string{user.UserID, user.DN, user.Email, user.Nickname} {
+		if key != "" {
+			return key
+		}
+	}
+	return "<unknown>"
+}
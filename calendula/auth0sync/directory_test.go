@@ -0,0 +1,63 @@
+package auth0sync
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	utils "github.com/xinnige/asteraceae/calendula/utils"
+)
+
+func TestLoadDirectoryCSV(t *testing.T) {
+	users, err := LoadDirectory("../test/auth0/directory.csv", &utils.JSONAPI{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(users))
+	assert.Equal(t, "ad|ldap01|yamada_taro", users[0].UserID)
+	assert.Equal(t, true, users[0].AppMeta["lambda_authorizer"])
+	assert.Equal(t, []interface{}{"app1", "app3"}, users[0].AppMeta["apps"])
+	assert.Equal(t, "yamada", users[0].UserMeta["surname"])
+	assert.Equal(t, "", users[1].UserID)
+	assert.True(t, users[2].Blocked)
+	assert.Nil(t, users[2].AppMeta)
+}
+
+func TestLoadDirectoryJSON(t *testing.T) {
+	users, err := LoadDirectory("../test/auth0/directory.json", &utils.JSONAPI{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(users))
+	assert.Equal(t, "Suzuki Hanako", users[1].Name)
+	assert.Equal(t, []interface{}{"app1", "app3"}, users[0].AppMeta["apps"])
+}
+
+func TestLoadDirectoryLDIF(t *testing.T) {
+	users, err := LoadDirectory("../test/auth0/directory.ldif", &utils.JSONAPI{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(users))
+	assert.Equal(t, "yamada_taro", users[0].Nickname)
+	assert.Equal(t, "taro", users[0].UserMeta["givenname"])
+	assert.Equal(t, "ou=People,dc=asteraceae,dc=local", users[0].OrgUnits)
+	assert.Equal(t, "uid=suzuki_hanako,ou=People,dc=asteraceae,dc=local", users[1].DN)
+	assert.Equal(t, "鈴木 花子", users[1].Name)
+}
+
+func TestLoadDirectoryError(t *testing.T) {
+	_, err := LoadDirectory("../test/auth0/none.csv", &utils.JSONAPI{})
+	assert.NotNil(t, err)
+	_, err = LoadDirectory("../test/slack/auditlogs.json", &utils.JSONAPI{})
+	assert.NotNil(t, err)
+	_, err = LoadDirectory("../../README.md", &utils.JSONAPI{})
+	assert.Equal(t, "unsupport directory format \".md\"", err.Error())
+
+	_, err = ParseCSV(strings.NewReader("user_id,blocked\nabc,maybe\n"))
+	assert.Equal(t, "invalid blocked value \"maybe\" of abc", err.Error())
+	_, err = ParseCSV(strings.NewReader("user_id,email\nabc\n"))
+	assert.NotNil(t, err)
+	users, err := ParseCSV(strings.NewReader(""))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(users))
+
+	_, err = ParseLDIF(strings.NewReader("dn uid=abc\n"))
+	assert.NotNil(t, err)
+	_, err = ParseLDIF(strings.NewReader("cn:: !!!\n"))
+	assert.Equal(t, "invalid base64 value of cn", err.Error())
+}
@@ -0,0 +1,296 @@
+package auth0sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/xinnige/asteraceae/calendula/auth0api"
+	utils "github.com/xinnige/asteraceae/calendula/utils"
+)
+
+// MatchKey defines a key to match directory users w/ auth0 users
+type MatchKey string
+
+// Available match keys
+const (
+	MatchUserID MatchKey = "user_id"
+	MatchDN     MatchKey = "dn"
+	MatchEmail  MatchKey = "email"
+)
+
+// ParseMatchKeys splits a comma separated list of match keys
+func ParseMatchKeys(raw string) ([]MatchKey, error) {
+	keys := make([]MatchKey, 0)
+	for _, key := range strings.Split(raw, ",") {
+		key = strings.TrimSpace(key)
+		switch MatchKey(key) {
+		case MatchUserID, MatchDN, MatchEmail:
+			keys = append(keys, MatchKey(key))
+		case "":
+		default:
+			return nil, fmt.Errorf("unsupport match key %q", key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("empty match keys")
+	}
+	return keys, nil
+}
+
+func (key MatchKey) auth0Value(user *auth0api.User) string {
+	switch key {
+	case MatchUserID:
+		return user.UserID
+	case MatchDN:
+		return strings.ToLower(user.DN)
+	case MatchEmail:
+		return strings.ToLower(user.Email)
+	}
+	return ""
+}
+
+func (key MatchKey) directoryValue(user *DirectoryUser) string {
+	switch key {
+	case MatchUserID:
+		return user.UserID
+	case MatchDN:
+		return strings.ToLower(user.DN)
+	case MatchEmail:
+		return strings.ToLower(user.Email)
+	}
+	return ""
+}
+
+// OrphanAction defines how auth0 users missing in the directory are handled
+type OrphanAction string
+
+// Available orphan actions
+const (
+	OrphanIgnore OrphanAction = "ignore"
+	OrphanBlock  OrphanAction = "block"
+	OrphanDelete OrphanAction = "delete"
+)
+
+// FieldDiff defines a changed field of a user,
+// metadata fields are named as `app_metadata.<key>`/`user_metadata.<key>`
+type FieldDiff struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// UserUpdate defines the changes of a matched user
+type UserUpdate struct {
+	UserID string        `json:"user_id"`
+	Source DirectoryUser `json:"source"`
+	Diffs  []FieldDiff   `json:"diffs"`
+}
+
+// SyncPlan defines the changes to make auth0 consistent w/ the directory
+type SyncPlan struct {
+	Creates []DirectoryUser `json:"creates"`
+	Updates []UserUpdate    `json:"updates"`
+	Blocks  []auth0api.User `json:"blocks"`
+	Deletes []auth0api.User `json:"deletes"`
+}
+
+// Empty checks if the plan has no change
+func (plan *SyncPlan) Empty() bool {
+	return len(plan.Creates)+len(plan.Updates)+len(plan.Blocks)+len(plan.Deletes) == 0
+}
+
+// Planner builds a SyncPlan by matching users w/ Keys in order
+type Planner struct {
+	Keys      []MatchKey
+	Orphans   OrphanAction
+	SerialAPI utils.SerialInterface
+}
+
+// NewPlanner returns a *Planner
+func NewPlanner(keys []MatchKey, orphans OrphanAction) *Planner {
+	return &Planner{
+		Keys:      keys,
+		Orphans:   orphans,
+		SerialAPI: &utils.JSONAPI{},
+	}
+}
+
+// Plan compares auth0 users w/ the directory
+func (planner *Planner) Plan(users []auth0api.User,
+	directory []DirectoryUser) (*SyncPlan, error) {
+	index := make(map[MatchKey]map[string]int)
+	for _, key := range planner.Keys {
+		index[key] = make(map[string]int)
+		for idx := range users {
+			if value := key.auth0Value(&users[idx]); value != "" {
+				index[key][value] = idx
+			}
+		}
+	}
+
+	plan := &SyncPlan{
+		Creates: make([]DirectoryUser, 0),
+		Updates: make([]UserUpdate, 0),
+		Blocks:  make([]auth0api.User, 0),
+		Deletes: make([]auth0api.User, 0),
+	}
+	matched := make(map[int]string)
+	for _, entry := range directory {
+		idx, found := planner.match(index, &entry)
+		if !found {
+			if !entry.Blocked {
+				plan.Creates = append(plan.Creates, entry)
+			}
+			continue
+		}
+		if prev, ok := matched[idx]; ok {
+			return nil, fmt.Errorf("auth0 user %s matches both %s and %s",
+				users[idx].UserID, prev, entry.key())
+		}
+		matched[idx] = entry.key()
+
+		user := &users[idx]
+		if entry.Blocked && !user.Blocked {
+			plan.Blocks = append(plan.Blocks, *user)
+		}
+		diffs, err := planner.diff(user, &entry)
+		if err != nil {
+			return nil, err
+		}
+		if len(diffs) != 0 {
+			plan.Updates = append(plan.Updates,
+				UserUpdate{UserID: user.UserID, Source: entry, Diffs: diffs})
+		}
+	}
+
+	for idx := range users {
+		if _, ok := matched[idx]; ok {
+			continue
+		}
+		switch planner.Orphans {
+		case OrphanBlock:
+			if !users[idx].Blocked {
+				plan.Blocks = append(plan.Blocks, users[idx])
+			}
+		case OrphanDelete:
+			plan.Deletes = append(plan.Deletes, users[idx])
+		}
+	}
+	return plan, nil
+}
+
+func (planner *Planner) match(index map[MatchKey]map[string]int,
+	entry *DirectoryUser) (int, bool) {
+	for _, key := range planner.Keys {
+		value := key.directoryValue(entry)
+		if value == "" {
+			continue
+		}
+		if idx, ok := index[key][value]; ok {
+			return idx, true
+		}
+	}
+	return -1, false
+}
+
+// diff returns changed fields, empty fields of the directory are not compared
+func (planner *Planner) diff(user *auth0api.User,
+	entry *DirectoryUser) ([]FieldDiff, error) {
+	diffs := make([]FieldDiff, 0)
+	for _, field := range []struct {
+		name     string
+		old, new string
+		fold     bool
+	}{
+		{"name", user.Name, entry.Name, false},
+		{"email", user.Email, entry.Email, true},
+		{"organizationUnits", user.OrgUnits, entry.OrgUnits, true},
+	} {
+		if field.new == "" || field.old == field.new ||
+			(field.fold && strings.EqualFold(field.old, field.new)) {
+			continue
+		}
+		diffs = append(diffs, FieldDiff{Field: field.name, Old: field.old, New: field.new})
+	}
+	if !entry.Blocked && user.Blocked {
+		diffs = append(diffs, FieldDiff{Field: "blocked", Old: true, New: false})
+	}
+
+	appdiffs, err := planner.diffMeta("app_metadata", user.RawAppMeta, entry.AppMeta)
+	if err != nil {
+		return nil, err
+	}
+	userdiffs, err := planner.diffMeta("user_metadata", user.RawUserMeta, entry.UserMeta)
+	if err != nil {
+		return nil, err
+	}
+	diffs = append(diffs, appdiffs...)
+	return append(diffs, userdiffs...), nil
+}
+
+func (planner *Planner) diffMeta(name string, raw json.RawMessage,
+	meta map[string]interface{}) ([]FieldDiff, error) {
+	diffs := make([]FieldDiff, 0)
+	if len(meta) == 0 {
+		return diffs, nil
+	}
+	current := make(map[string]interface{})
+	if len(raw) != 0 {
+		if err := planner.SerialAPI.Unmarshal(raw, &current); err != nil {
+			return nil, err
+		}
+	}
+	keys := make([]string, 0, len(meta))
+	for key := range meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		old, ok := current[key]
+		if ok && reflect.DeepEqual(old, meta[key]) {
+			continue
+		}
+		diffs = append(diffs, FieldDiff{
+			Field: name + "." + key, Old: old, New: meta[key]})
+	}
+	return diffs, nil
+}
+
+// Render writes a human readable report of the plan
+func (plan *SyncPlan) Render(w io.Writer) {
+	fmt.Fprintf(w, "Creates: %d\n", len(plan.Creates))
+	for idx := range plan.Creates {
+		fmt.Fprintf(w, "  + %s\n", plan.Creates[idx].key())
+	}
+	fmt.Fprintf(w, "Updates: %d\n", len(plan.Updates))
+	for _, update := range plan.Updates {
+		fmt.Fprintf(w, "  ~ %s\n", update.UserID)
+		for _, diff := range update.Diffs {
+			fmt.Fprintf(w, "      %s: %s -> %s\n",
+				diff.Field, renderValue(diff.Old), renderValue(diff.New))
+		}
+	}
+	fmt.Fprintf(w, "Blocks: %d\n", len(plan.Blocks))
+	for _, user := range plan.Blocks {
+		fmt.Fprintf(w, "  ! %s\n", user.UserID)
+	}
+	fmt.Fprintf(w, "Deletes: %d\n", len(plan.Deletes))
+	for _, user := range plan.Deletes {
+		fmt.Fprintf(w, "  - %s\n", user.UserID)
+	}
+}
+
+func renderValue(value interface{}) string {
+	if value == nil {
+		return "<none>"
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(raw)
+}
@@ -0,0 +1,113 @@
+package auth0sync
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/auth0api"
+	utils "github.com/xinnige/asteraceae/calendula/utils"
+)
+
+func fakeAuth0Users() []auth0api.User {
+	raw := `[
+{"user_id":"ad|ldap01|yamada_taro","dn":"uid=yamada_taro,ou=People,dc=asteraceae,dc=local","email":"yamada_taro@asteraceae.local","name":"Yamada Taro","organizationUnits":"ou=People,dc=asteraceae,dc=local","app_metadata":{"lambda_authorizer":true,"apps":["app1","app2"]},"user_metadata":{"surname":"yamada","givenname":"taro"}},
+{"user_id":"ad|ldap01|sato_jiro","dn":"UID=sato_jiro,ou=People,dc=asteraceae,dc=local","email":"sato_jiro@asteraceae.local"},
+{"user_id":"ad|ldap01|tanaka_ichiro","email":"tanaka_ichiro@asteraceae.local"},
+{"user_id":"ad|ldap01|kato_saburo","email":"kato_saburo@asteraceae.local","blocked":true}]`
+	users := make([]auth0api.User, 0)
+	json.Unmarshal([]byte(raw), &users)
+	return users
+}
+
+func fakeDirectory(t *testing.T) []DirectoryUser {
+	directory, err := LoadDirectory("../test/auth0/directory.csv", &utils.JSONAPI{})
+	assert.Nil(t, err)
+	return directory
+}
+
+func TestParseMatchKeys(t *testing.T) {
+	keys, err := ParseMatchKeys("user_id, dn,email")
+	assert.Nil(t, err)
+	assert.Equal(t, []MatchKey{MatchUserID, MatchDN, MatchEmail}, keys)
+
+	_, err = ParseMatchKeys("uid")
+	assert.Equal(t, "unsupport match key \"uid\"", err.Error())
+	_, err = ParseMatchKeys(",")
+	assert.NotNil(t, err)
+}
+
+func TestPlan(t *testing.T) {
+	planner := NewPlanner([]MatchKey{MatchUserID, MatchDN}, OrphanBlock)
+	plan, err := planner.Plan(fakeAuth0Users(), fakeDirectory(t))
+	assert.Nil(t, err)
+	assert.False(t, plan.Empty())
+
+	// suzuki_hanako is not in auth0
+	assert.Equal(t, 1, len(plan.Creates))
+	assert.Equal(t, "suzuki_hanako@asteraceae.local", plan.Creates[0].Email)
+
+	// yamada_taro apps changed, sato_jiro name added
+	assert.Equal(t, 2, len(plan.Updates))
+	assert.Equal(t, "ad|ldap01|yamada_taro", plan.Updates[0].UserID)
+	assert.Equal(t, []FieldDiff{{Field: "app_metadata.apps",
+		Old: []interface{}{"app1", "app2"}, New: []interface{}{"app1", "app3"}}},
+		plan.Updates[0].Diffs)
+	assert.Equal(t, []FieldDiff{{Field: "name", Old: "", New: "Sato Jiro"}},
+		plan.Updates[1].Diffs)
+
+	// sato_jiro matched by dn (case insensitive) and blocked in the directory,
+	// tanaka_ichiro is an orphan, kato_saburo is already blocked
+	assert.Equal(t, 2, len(plan.Blocks))
+	assert.Equal(t, "ad|ldap01|sato_jiro", plan.Blocks[0].UserID)
+	assert.Equal(t, "ad|ldap01|tanaka_ichiro", plan.Blocks[1].UserID)
+	assert.Equal(t, 0, len(plan.Deletes))
+
+	var buf bytes.Buffer
+	plan.Render(&buf)
+	assert.Contains(t, buf.String(), "Creates: 1\n  + uid=suzuki_hanako")
+	assert.Contains(t, buf.String(),
+		"      app_metadata.apps: [\"app1\",\"app2\"] -> [\"app1\",\"app3\"]\n")
+	assert.Contains(t, buf.String(), "Deletes: 0\n")
+}
+
+func TestPlanOrphans(t *testing.T) {
+	directory := []DirectoryUser{{
+		Email:    "KATO_SABURO@asteraceae.local",
+		Name:     "Kato Saburo",
+		UserMeta: map[string]interface{}{"surname": "kato"},
+	}}
+	planner := NewPlanner([]MatchKey{MatchEmail}, OrphanDelete)
+	plan, err := planner.Plan(fakeAuth0Users(), directory)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(plan.Creates))
+	assert.Equal(t, 3, len(plan.Deletes))
+	assert.Equal(t, []FieldDiff{
+		{Field: "name", Old: "", New: "Kato Saburo"},
+		{Field: "blocked", Old: true, New: false},
+		{Field: "user_metadata.surname", Old: nil, New: "kato"}},
+		plan.Updates[0].Diffs)
+
+	planner.Orphans = OrphanIgnore
+	plan, err = planner.Plan(fakeAuth0Users(), nil)
+	assert.Nil(t, err)
+	assert.True(t, plan.Empty())
+}
+
+func TestPlanError(t *testing.T) {
+	directory := []DirectoryUser{
+		{UserID: "ad|ldap01|tanaka_ichiro"},
+		{Email: "tanaka_ichiro@asteraceae.local"}}
+	planner := NewPlanner([]MatchKey{MatchUserID, MatchEmail}, OrphanIgnore)
+	_, err := planner.Plan(fakeAuth0Users(), directory)
+	assert.Equal(t, "auth0 user ad|ldap01|tanaka_ichiro matches both "+
+		"ad|ldap01|tanaka_ichiro and tanaka_ichiro@asteraceae.local", err.Error())
+
+	users := fakeAuth0Users()
+	users[2].RawAppMeta = json.RawMessage(`[]`)
+	directory = []DirectoryUser{{UserID: "ad|ldap01|tanaka_ichiro",
+		AppMeta: map[string]interface{}{"apps": []interface{}{}}}}
+	_, err = planner.Plan(users, directory)
+	assert.NotNil(t, err)
+}
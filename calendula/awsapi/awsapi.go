@@ -1,11 +1,29 @@
 package awsapi
 
 import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
+	cweiface "github.com/aws/aws-sdk-go/service/cloudwatchevents/cloudwatcheventsiface"
 	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
 	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/aws/aws-sdk-go/service/support/supportiface"
+	"github.com/xinnige/asteraceae/calendula/utils"
+)
+
+const (
+	envRoleARN    = "AWS_ASSUME_ROLE_ARN"
+	envExternalID = "AWS_ASSUME_ROLE_EXTERNAL_ID"
+	envMFASerial  = "AWS_MFA_SERIAL"
+
+	deRoleSessionName = "asteraceae"
 )
 
 // AWSSession defines an interface of session.NewSession
@@ -13,23 +31,138 @@ type AWSSession interface {
 	NewSession() (*session.Session, error)
 }
 
-// AWSServiceSession defiens a struct to implement AWSSession
+// SessionConfig defines options to build an aws session,
+// empty options fall back to the sdk defaults (env, shared config).
+type SessionConfig struct {
+	// Region overrides AWS_REGION and the profile region
+	Region string
+	// Profile selects a named profile of the shared config files
+	Profile string
+	// RoleARN assumes a role w/ the base credentials
+	RoleARN         string
+	RoleSessionName string
+	ExternalID      string
+	Duration        time.Duration
+	// MFASerial is the serial number or arn of the mfa device of the role,
+	// MFATokenProvider reads the token code (stdin if nil)
+	MFASerial        string
+	MFATokenProvider func() (string, error)
+	// Endpoints overrides endpoints by service id (e.g. "s3", "ssm"),
+	// for LocalStack/MinIO
+	Endpoints        map[string]string
+	S3ForcePathStyle bool
+}
+
+// NewSessionConfigFromEnv returns a SessionConfig w/ the assume-role options
+// read from AWS_ASSUME_ROLE_ARN, AWS_ASSUME_ROLE_EXTERNAL_ID and AWS_MFA_SERIAL
+func NewSessionConfigFromEnv() SessionConfig {
+	return SessionConfig{
+		RoleARN:    utils.GetEnv(envRoleARN, ""),
+		ExternalID: utils.GetEnv(envExternalID, ""),
+		MFASerial:  utils.GetEnv(envMFASerial, ""),
+	}
+}
+
+// AWSServiceSession defiens a struct to implement AWSSession,
+// the session is created once and shared by all service clients
 type AWSServiceSession struct {
+	Config SessionConfig
+	mutex  sync.Mutex
+	sess   *session.Session
+}
+
+// NewSessionFactory returns a *AWSServiceSession w/ options
+func NewSessionFactory(config SessionConfig) *AWSServiceSession {
+	return &AWSServiceSession{Config: config}
 }
 
 // NewSession implements AWSSession.NewSession
 func (awsSession *AWSServiceSession) NewSession() (*session.Session, error) {
-	return session.NewSession()
+	awsSession.mutex.Lock()
+	defer awsSession.mutex.Unlock()
+	if awsSession.sess != nil {
+		return awsSession.sess, nil
+	}
+	sess, err := awsSession.Config.newSession()
+	if err != nil {
+		return nil, err
+	}
+	awsSession.sess = sess
+	return sess, nil
+}
+
+func (config *SessionConfig) newSession() (*session.Session, error) {
+	awsConfig := aws.Config{}
+	if config.Region != "" {
+		awsConfig.Region = aws.String(config.Region)
+	}
+	if config.S3ForcePathStyle {
+		awsConfig.S3ForcePathStyle = aws.Bool(true)
+	}
+	if len(config.Endpoints) != 0 {
+		awsConfig.EndpointResolver = config.resolver()
+	}
+	tokenProvider := config.MFATokenProvider
+	if tokenProvider == nil {
+		tokenProvider = stscreds.StdinTokenProvider
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:                  awsConfig,
+		Profile:                 config.Profile,
+		SharedConfigState:       session.SharedConfigEnable,
+		AssumeRoleTokenProvider: tokenProvider,
+	})
+	if err != nil || config.RoleARN == "" {
+		return sess, err
+	}
+
+	creds := stscreds.NewCredentials(sess, config.RoleARN,
+		func(provider *stscreds.AssumeRoleProvider) {
+			provider.RoleSessionName = config.RoleSessionName
+			if provider.RoleSessionName == "" {
+				provider.RoleSessionName = deRoleSessionName
+			}
+			if config.Duration != 0 {
+				provider.Duration = config.Duration
+			}
+			if config.ExternalID != "" {
+				provider.ExternalID = aws.String(config.ExternalID)
+			}
+			if config.MFASerial != "" {
+				provider.SerialNumber = aws.String(config.MFASerial)
+				provider.TokenProvider = tokenProvider
+			}
+		})
+	return sess.Copy(&aws.Config{Credentials: creds}), nil
+}
+
+// resolver returns the overridden endpoints, or the default ones
+func (config *SessionConfig) resolver() endpoints.Resolver {
+	defaultResolver := endpoints.DefaultResolver()
+	return endpoints.ResolverFunc(func(service, region string,
+		opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+		if url, ok := config.Endpoints[service]; ok {
+			return endpoints.ResolvedEndpoint{
+				URL:           url,
+				SigningRegion: region,
+			}, nil
+		}
+		return defaultResolver.EndpointFor(service, region, opts...)
+	})
 }
 
 // AWSAPI defines a struct to implement AWSInterface
 type AWSAPI struct {
 }
 
-// AWSIface holds aws clients
+// AWSIface holds aws clients, see Clients to build them
 type AWSIface struct {
-	KMSSVC       kmsiface.KMSAPI
-	S3ManagerSVC s3manageriface.UploaderAPI
-	S3SVC        s3iface.S3API
-	SSMSVC       ssmiface.SSMAPI
+	CWESVC          cweiface.CloudWatchEventsAPI
+	KMSSVC          kmsiface.KMSAPI
+	LambdaSVC       lambdaiface.LambdaAPI
+	S3DownloaderSVC s3manageriface.DownloaderAPI
+	S3ManagerSVC    s3manageriface.UploaderAPI
+	S3SVC           s3iface.S3API
+	SSMSVC          ssmiface.SSMAPI
+	SupportSVC      supportiface.SupportAPI
 }
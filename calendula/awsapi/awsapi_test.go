@@ -0,0 +1,87 @@
+package awsapi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSessionCached(t *testing.T) {
+	factory := &AWSServiceSession{}
+	sess, err := factory.NewSession()
+	assert.Nil(t, err)
+	cached, err := factory.NewSession()
+	assert.Nil(t, err)
+	assert.True(t, sess == cached)
+}
+
+func TestNewSessionRegionEndpoints(t *testing.T) {
+	factory := NewSessionFactory(SessionConfig{
+		Region:           "ap-northeast-1",
+		Endpoints:        map[string]string{s3.EndpointsID: "http://localhost:4572"},
+		S3ForcePathStyle: true,
+	})
+	sess, err := factory.NewSession()
+	assert.Nil(t, err)
+	assert.Equal(t, "ap-northeast-1", *sess.Config.Region)
+
+	s3svc := s3.New(sess)
+	assert.Equal(t, "http://localhost:4572", s3svc.Endpoint)
+	ssmsvc := ssm.New(sess)
+	assert.Equal(t, "https://ssm.ap-northeast-1.amazonaws.com", ssmsvc.Endpoint)
+}
+
+func TestNewSessionProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "awsconfig")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	config := filepath.Join(dir, "config")
+	assert.Nil(t, ioutil.WriteFile(config,
+		[]byte("[profile fake-profile]\nregion = eu-west-1\n"), 0600))
+	creds := filepath.Join(dir, "credentials")
+	assert.Nil(t, ioutil.WriteFile(creds,
+		[]byte("[fake-profile]\naws_access_key_id = AKIAFAKE\naws_secret_access_key = fake\n"), 0600))
+	os.Setenv("AWS_CONFIG_FILE", config)
+	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", creds)
+	defer os.Unsetenv("AWS_CONFIG_FILE")
+	defer os.Unsetenv("AWS_SHARED_CREDENTIALS_FILE")
+
+	sess, err := NewSessionFactory(SessionConfig{Profile: "fake-profile"}).NewSession()
+	assert.Nil(t, err)
+	assert.Equal(t, "eu-west-1", *sess.Config.Region)
+	value, err := sess.Config.Credentials.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, "AKIAFAKE", value.AccessKeyID)
+
+	// an unknown profile fails when credentials are resolved
+	sess, err = NewSessionFactory(SessionConfig{Profile: "no-such-profile"}).NewSession()
+	assert.Nil(t, err)
+	_, err = sess.Config.Credentials.Get()
+	assert.NotNil(t, err)
+}
+
+func TestNewSessionAssumeRole(t *testing.T) {
+	os.Setenv(envRoleARN, "arn:aws:iam::123456789012:role/fake-role")
+	os.Setenv(envExternalID, "fake-external-id")
+	os.Setenv(envMFASerial, "arn:aws:iam::123456789012:mfa/fake-user")
+	defer os.Unsetenv(envRoleARN)
+	defer os.Unsetenv(envExternalID)
+	defer os.Unsetenv(envMFASerial)
+
+	config := NewSessionConfigFromEnv()
+	assert.Equal(t, "fake-external-id", config.ExternalID)
+	config.Region = "us-east-1"
+	config.MFATokenProvider = stscreds.StdinTokenProvider
+
+	base, err := NewSessionFactory(SessionConfig{Region: "us-east-1"}).NewSession()
+	assert.Nil(t, err)
+	sess, err := NewSessionFactory(config).NewSession()
+	assert.Nil(t, err)
+	assert.False(t, base.Config.Credentials == sess.Config.Credentials)
+}
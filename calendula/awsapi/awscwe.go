@@ -1,9 +1,11 @@
 package awsapi
 
 import (
+	"context"
 	"log"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	cwe "github.com/aws/aws-sdk-go/service/cloudwatchevents"
 	cweiface "github.com/aws/aws-sdk-go/service/cloudwatchevents/cloudwatcheventsiface"
 )
@@ -56,25 +58,42 @@ func NewEventRule(arn, desc, event, managedby,
 
 }
 
-// NewCloudWatchEventsAPI returns a CloudWatch API
-func NewCloudWatchEventsAPI(sess AWSSession) cweiface.CloudWatchEventsAPI {
+// NewCloudWatchEventsClient returns a CloudWatch API, or an error if the session fails
+func NewCloudWatchEventsClient(sess AWSSession) (cweiface.CloudWatchEventsAPI, error) {
 	cwesess, err := sess.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	return cwe.New(cwesess), nil
+}
+
+// NewCloudWatchEventsAPI returns a CloudWatch API, or nil if the session fails.
+//
+// Deprecated: use NewCloudWatchEventsClient or Clients.CloudWatchEvents, which return the error.
+func NewCloudWatchEventsAPI(sess AWSSession) cweiface.CloudWatchEventsAPI {
+	svc, err := NewCloudWatchEventsClient(sess)
 	if err != nil {
 		log.Printf("AWSError: Cannot create aws session, err %#v", err)
 		return nil
 	}
-	return cwe.New(cwesess)
+	return svc
 }
 
 // ListRuleNamesByTarget returns a list of rule names related to a target arn
 func (awsapi *AWSAPI) ListRuleNamesByTarget(svc cweiface.CloudWatchEventsAPI,
 	arn string) ([]string, error) {
+	return awsapi.ListRuleNamesByTargetWithContext(aws.BackgroundContext(), svc, arn)
+}
+
+// ListRuleNamesByTargetWithContext is ListRuleNamesByTarget w/ a context and request options
+func (awsapi *AWSAPI) ListRuleNamesByTargetWithContext(ctx context.Context,
+	svc cweiface.CloudWatchEventsAPI, arn string, opts ...request.Option) ([]string, error) {
 	ruleNames := make([]string, 0)
 	input := &cwe.ListRuleNamesByTargetInput{
 		TargetArn: aws.String(arn),
 		Limit:     aws.Int64(size),
 	}
-	output, err := svc.ListRuleNamesByTarget(input)
+	output, err := svc.ListRuleNamesByTargetWithContext(ctx, input, opts...)
 	if err != nil {
 		log.Printf("AWSError: Cannot list rules of %s, err %v", arn, err)
 		return nil, err
@@ -82,7 +101,7 @@ func (awsapi *AWSAPI) ListRuleNamesByTarget(svc cweiface.CloudWatchEventsAPI,
 	ruleNames = append(ruleNames, aws.StringValueSlice(output.RuleNames)...)
 	for aws.StringValue(output.NextToken) != "" {
 		input.NextToken = output.NextToken
-		output, err := svc.ListRuleNamesByTarget(input)
+		output, err := svc.ListRuleNamesByTargetWithContext(ctx, input, opts...)
 		if err != nil {
 			log.Printf("AWSError: Cannot list rules of %s, err %v", arn, err)
 			return ruleNames, err
@@ -95,10 +114,17 @@ func (awsapi *AWSAPI) ListRuleNamesByTarget(svc cweiface.CloudWatchEventsAPI,
 // DescribeRule returns an expression of a rule scheule searchd by name
 func (awsapi *AWSAPI) DescribeRule(svc cweiface.CloudWatchEventsAPI,
 	name string) (*CloudWatchEventRule, error) {
+	return awsapi.DescribeRuleWithContext(aws.BackgroundContext(), svc, name)
+}
+
+// DescribeRuleWithContext is DescribeRule w/ a context and request options
+func (awsapi *AWSAPI) DescribeRuleWithContext(ctx context.Context,
+	svc cweiface.CloudWatchEventsAPI, name string,
+	opts ...request.Option) (*CloudWatchEventRule, error) {
 	input := &cwe.DescribeRuleInput{
 		Name: aws.String(name),
 	}
-	output, err := svc.DescribeRule(input)
+	output, err := svc.DescribeRuleWithContext(ctx, input, opts...)
 	if err != nil {
 		log.Printf("AWSError: Get schedule expression of rule %s, err %v", name, err)
 		return nil, err
@@ -111,6 +137,13 @@ func (awsapi *AWSAPI) DescribeRule(svc cweiface.CloudWatchEventsAPI,
 // PutRule helps to call PutRule api
 func (awsapi *AWSAPI) PutRule(svc cweiface.CloudWatchEventsAPI,
 	rule *CloudWatchEventRule) (string, error) {
+	return awsapi.PutRuleWithContext(aws.BackgroundContext(), svc, rule)
+}
+
+// PutRuleWithContext is PutRule w/ a context and request options
+func (awsapi *AWSAPI) PutRuleWithContext(ctx context.Context,
+	svc cweiface.CloudWatchEventsAPI, rule *CloudWatchEventRule,
+	opts ...request.Option) (string, error) {
 	input := &cwe.PutRuleInput{
 		Name:               aws.String(rule.Name),
 		ScheduleExpression: aws.String(rule.ScheduleExpression),
@@ -126,7 +159,7 @@ func (awsapi *AWSAPI) PutRule(svc cweiface.CloudWatchEventsAPI,
 		input.EventPattern = aws.String(rule.EventPattern)
 	}
 
-	output, err := svc.PutRule(input)
+	output, err := svc.PutRuleWithContext(ctx, input, opts...)
 	if err != nil {
 		log.Printf("AWSError: Cannot put rule %+v, err %v", input, err)
 		return "", err
@@ -137,6 +170,14 @@ func (awsapi *AWSAPI) PutRule(svc cweiface.CloudWatchEventsAPI,
 // PutTargets helps to call PutTargets api
 func (awsapi *AWSAPI) PutTargets(svc cweiface.CloudWatchEventsAPI,
 	rule, targetID, targetArn, targetInput string) error {
+	return awsapi.PutTargetsWithContext(aws.BackgroundContext(), svc,
+		rule, targetID, targetArn, targetInput)
+}
+
+// PutTargetsWithContext is PutTargets w/ a context and request options
+func (awsapi *AWSAPI) PutTargetsWithContext(ctx context.Context,
+	svc cweiface.CloudWatchEventsAPI, rule, targetID, targetArn,
+	targetInput string, opts ...request.Option) error {
 	target := &cwe.Target{
 		Arn:   aws.String(targetArn),
 		Id:    aws.String(targetID),
@@ -145,7 +186,7 @@ func (awsapi *AWSAPI) PutTargets(svc cweiface.CloudWatchEventsAPI,
 		Rule:    aws.String(rule),
 		Targets: []*cwe.Target{target},
 	}
-	output, err := svc.PutTargets(input)
+	output, err := svc.PutTargetsWithContext(ctx, input, opts...)
 	if err != nil {
 		log.Printf("AWSError: Cannot put targets (%s w/ %v), output=(%v), err %+v", rule, target, output, err)
 		return err
@@ -1,6 +1,7 @@
 package awsapi
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -21,10 +22,14 @@ func TestNewCWEAPIError(t *testing.T) {
 	defer mockCtrl.Finish()
 	mockSession := mock.NewMockAWSSession(mockCtrl)
 	mockSession.EXPECT().NewSession().Return(
-		nil, errors.New("FakeNewSessionError")).Times(1)
+		nil, errors.New("FakeNewSessionError")).Times(2)
 
 	cweapi := NewCloudWatchEventsAPI(mockSession)
 	assert.Nil(t, cweapi)
+
+	svc, err := NewCloudWatchEventsClient(mockSession)
+	assert.Nil(t, svc)
+	assert.EqualError(t, err, "FakeNewSessionError")
 }
 
 func TestNewEventRule(t *testing.T) {
@@ -96,3 +101,15 @@ func TestPutTargets(t *testing.T) {
 	err = awsapi.PutTargets(cwesvc, "rule-arn", "target-id", "target-arn", "{target-nput}")
 	assert.Equal(t, "FakePutTargetsError", err.Error())
 }
+
+func TestPutRuleWithContextCancelled(t *testing.T) {
+	awsapi := AWSAPI{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rule := &CloudWatchEventRule{
+		Name: "fake-name", ScheduleExpression: "fake-cron", State: "Enabled"}
+	output, err := awsapi.PutRuleWithContext(ctx, mock.CWEAPIPutRule("fake-arn"), rule)
+	assert.Equal(t, "", output)
+	assert.Equal(t, context.Canceled, err)
+}
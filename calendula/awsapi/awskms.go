@@ -1,30 +1,50 @@
 package awsapi
 
 import (
+	"context"
 	"log"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
 )
 
-// NewKMSAPI returns a KMS API
-func NewKMSAPI(sess AWSSession) kmsiface.KMSAPI {
+// NewKMSClient returns a KMS API, or an error if the session fails
+func NewKMSClient(sess AWSSession) (kmsiface.KMSAPI, error) {
 	ksess, err := sess.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	return kms.New(ksess), nil
+}
+
+// NewKMSAPI returns a KMS API, or nil if the session fails.
+//
+// Deprecated: use NewKMSClient or Clients.KMS, which return the error.
+func NewKMSAPI(sess AWSSession) kmsiface.KMSAPI {
+	svc, err := NewKMSClient(sess)
 	if err != nil {
 		log.Printf("AWSError: Cannot create aws session, err %#v", err)
 		return nil
 	}
-	return kms.New(ksess)
+	return svc
 }
 
-// NewDataKey returns plain datakey with ciphertext-blob
+// NewDataKey returns plain datakey with ciphertext-blob,
+// use GenerateDataKey to bind it to an encryption context
 func (awsapi *AWSAPI) NewDataKey(svc kmsiface.KMSAPI, keyAlias string, keySpec string) ([]byte, []byte, error) {
+	return awsapi.NewDataKeyWithContext(aws.BackgroundContext(), svc, keyAlias, keySpec)
+}
+
+// NewDataKeyWithContext is NewDataKey w/ a context and request options
+func (awsapi *AWSAPI) NewDataKeyWithContext(ctx context.Context, svc kmsiface.KMSAPI,
+	keyAlias string, keySpec string, opts ...request.Option) ([]byte, []byte, error) {
 	input := &kms.GenerateDataKeyInput{
 		KeyId:   aws.String(keyAlias),
 		KeySpec: aws.String(keySpec),
 	}
-	result, err := svc.GenerateDataKey(input)
+	result, err := svc.GenerateDataKeyWithContext(ctx, input, opts...)
 	if err != nil {
 		log.Printf("AWSError: Cannot generate a new data key, err %+v\n", err)
 		return nil, nil, err
@@ -33,12 +53,19 @@ func (awsapi *AWSAPI) NewDataKey(svc kmsiface.KMSAPI, keyAlias string, keySpec s
 	return result.Plaintext, result.CiphertextBlob, nil
 }
 
-// GetDataKey returns plain datakey
+// GetDataKey returns plain datakey,
+// use DecryptDataKey if it is bound to an encryption context
 func (awsapi *AWSAPI) GetDataKey(svc kmsiface.KMSAPI, ciphertext []byte) ([]byte, error) {
+	return awsapi.GetDataKeyWithContext(aws.BackgroundContext(), svc, ciphertext)
+}
+
+// GetDataKeyWithContext is GetDataKey w/ a context and request options
+func (awsapi *AWSAPI) GetDataKeyWithContext(ctx context.Context, svc kmsiface.KMSAPI,
+	ciphertext []byte, opts ...request.Option) ([]byte, error) {
 	input := &kms.DecryptInput{
 		CiphertextBlob: ciphertext,
 	}
-	result, err := svc.Decrypt(input)
+	result, err := svc.DecryptWithContext(ctx, input, opts...)
 	if err != nil {
 		log.Printf("AWSError: Cannot decrypt to get data key, err %+v\n", err)
 		return nil, err
@@ -46,3 +73,144 @@ func (awsapi *AWSAPI) GetDataKey(svc kmsiface.KMSAPI, ciphertext []byte) ([]byte
 	log.Printf("Get the decrypted data key, length %d\n", len(result.Plaintext))
 	return result.Plaintext, nil
 }
+
+// DataKey defines a data key of kms, KeyID is the arn of the master key
+type DataKey struct {
+	KeyID          string
+	Plaintext      []byte
+	CiphertextBlob []byte
+}
+
+// GenerateDataKey returns a new data key of a master key,
+// the encryption context must be given again to decrypt it
+func (awsapi *AWSAPI) GenerateDataKey(svc kmsiface.KMSAPI, keyID string, keySpec string,
+	encCtx map[string]string) (*DataKey, error) {
+	return awsapi.GenerateDataKeyWithContext(aws.BackgroundContext(), svc, keyID, keySpec, encCtx)
+}
+
+// GenerateDataKeyWithContext is GenerateDataKey w/ a context and request options
+func (awsapi *AWSAPI) GenerateDataKeyWithContext(ctx context.Context, svc kmsiface.KMSAPI,
+	keyID string, keySpec string, encCtx map[string]string,
+	opts ...request.Option) (*DataKey, error) {
+	input := &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(keyID),
+		KeySpec: aws.String(keySpec),
+	}
+	if len(encCtx) != 0 {
+		input.EncryptionContext = aws.StringMap(encCtx)
+	}
+	result, err := svc.GenerateDataKeyWithContext(ctx, input, opts...)
+	if err != nil {
+		log.Printf("AWSError: Cannot generate a data key of %s, err %+v\n", keyID, err)
+		return nil, err
+	}
+	return &DataKey{
+		KeyID:          aws.StringValue(result.KeyId),
+		Plaintext:      result.Plaintext,
+		CiphertextBlob: result.CiphertextBlob,
+	}, nil
+}
+
+// DecryptDataKey returns a data key decrypted w/ its encryption context
+func (awsapi *AWSAPI) DecryptDataKey(svc kmsiface.KMSAPI, ciphertext []byte,
+	encCtx map[string]string) (*DataKey, error) {
+	return awsapi.DecryptDataKeyWithContext(aws.BackgroundContext(), svc, ciphertext, encCtx)
+}
+
+// DecryptDataKeyWithContext is DecryptDataKey w/ a context and request options
+func (awsapi *AWSAPI) DecryptDataKeyWithContext(ctx context.Context, svc kmsiface.KMSAPI,
+	ciphertext []byte, encCtx map[string]string, opts ...request.Option) (*DataKey, error) {
+	input := &kms.DecryptInput{CiphertextBlob: ciphertext}
+	if len(encCtx) != 0 {
+		input.EncryptionContext = aws.StringMap(encCtx)
+	}
+	result, err := svc.DecryptWithContext(ctx, input, opts...)
+	if err != nil {
+		log.Printf("AWSError: Cannot decrypt a data key, err %+v\n", err)
+		return nil, err
+	}
+	return &DataKey{
+		KeyID:          aws.StringValue(result.KeyId),
+		Plaintext:      result.Plaintext,
+		CiphertextBlob: ciphertext,
+	}, nil
+}
+
+// Encrypt encrypts up to 4 KB w/ a master key, e.g. a secret or a password,
+// it returns the ciphertext blob and the arn of the master key.
+// The encryption context must be given again to decrypt it.
+func (awsapi *AWSAPI) Encrypt(svc kmsiface.KMSAPI, keyID string, plaintext []byte,
+	encCtx map[string]string) ([]byte, string, error) {
+	return awsapi.EncryptWithContext(aws.BackgroundContext(), svc, keyID, plaintext, encCtx)
+}
+
+// EncryptWithContext is Encrypt w/ a context and request options
+func (awsapi *AWSAPI) EncryptWithContext(ctx context.Context, svc kmsiface.KMSAPI,
+	keyID string, plaintext []byte, encCtx map[string]string,
+	opts ...request.Option) ([]byte, string, error) {
+	input := &kms.EncryptInput{
+		KeyId:     aws.String(keyID),
+		Plaintext: plaintext,
+	}
+	if len(encCtx) != 0 {
+		input.EncryptionContext = aws.StringMap(encCtx)
+	}
+	result, err := svc.EncryptWithContext(ctx, input, opts...)
+	if err != nil {
+		log.Printf("AWSError: Cannot encrypt w/ %s, err %+v\n", keyID, err)
+		return nil, "", err
+	}
+	return result.CiphertextBlob, aws.StringValue(result.KeyId), nil
+}
+
+// Decrypt decrypts a ciphertext blob of Encrypt w/ its encryption context,
+// it returns the plaintext and the arn of the master key
+func (awsapi *AWSAPI) Decrypt(svc kmsiface.KMSAPI, ciphertext []byte,
+	encCtx map[string]string) ([]byte, string, error) {
+	return awsapi.DecryptWithContext(aws.BackgroundContext(), svc, ciphertext, encCtx)
+}
+
+// DecryptWithContext is Decrypt w/ a context and request options
+func (awsapi *AWSAPI) DecryptWithContext(ctx context.Context, svc kmsiface.KMSAPI,
+	ciphertext []byte, encCtx map[string]string,
+	opts ...request.Option) ([]byte, string, error) {
+	dataKey, err := awsapi.DecryptDataKeyWithContext(ctx, svc, ciphertext, encCtx, opts...)
+	if err != nil {
+		return nil, "", err
+	}
+	return dataKey.Plaintext, dataKey.KeyID, nil
+}
+
+// ReEncrypt decrypts a ciphertext blob and encrypts it w/ another master key
+// or encryption context inside kms, the plaintext is never exposed.
+// It returns the new ciphertext blob and the arn of the new master key.
+func (awsapi *AWSAPI) ReEncrypt(svc kmsiface.KMSAPI, ciphertext []byte,
+	srcCtx map[string]string, destKeyID string,
+	destCtx map[string]string) ([]byte, string, error) {
+	return awsapi.ReEncryptWithContext(aws.BackgroundContext(), svc,
+		ciphertext, srcCtx, destKeyID, destCtx)
+}
+
+// ReEncryptWithContext is ReEncrypt w/ a context and request options
+func (awsapi *AWSAPI) ReEncryptWithContext(ctx context.Context, svc kmsiface.KMSAPI,
+	ciphertext []byte, srcCtx map[string]string, destKeyID string,
+	destCtx map[string]string, opts ...request.Option) ([]byte, string, error) {
+	input := &kms.ReEncryptInput{
+		CiphertextBlob:   ciphertext,
+		DestinationKeyId: aws.String(destKeyID),
+	}
+	if len(srcCtx) != 0 {
+		input.SourceEncryptionContext = aws.StringMap(srcCtx)
+	}
+	if len(destCtx) != 0 {
+		input.DestinationEncryptionContext = aws.StringMap(destCtx)
+	}
+	result, err := svc.ReEncryptWithContext(ctx, input, opts...)
+	if err != nil {
+		log.Printf("AWSError: Cannot re-encrypt w/ %s, err %+v\n", destKeyID, err)
+		return nil, "", err
+	}
+	log.Printf("Re-encrypted a ciphertext of %s w/ %s\n",
+		aws.StringValue(result.SourceKeyId), aws.StringValue(result.KeyId))
+	return result.CiphertextBlob, aws.StringValue(result.KeyId), nil
+}
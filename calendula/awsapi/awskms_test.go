@@ -1,6 +1,7 @@
 package awsapi
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -20,10 +21,14 @@ func TestNewKMSAPIError(t *testing.T) {
 	defer mockCtrl.Finish()
 	mockSession := mock.NewMockAWSSession(mockCtrl)
 	mockSession.EXPECT().NewSession().Return(
-		nil, errors.New("FakeNewSessionError")).Times(1)
+		nil, errors.New("FakeNewSessionError")).Times(2)
 
 	kmsapi := NewKMSAPI(mockSession)
 	assert.Nil(t, kmsapi)
+
+	svc, err := NewKMSClient(mockSession)
+	assert.Nil(t, svc)
+	assert.EqualError(t, err, "FakeNewSessionError")
 }
 
 func TestGetDataKey(t *testing.T) {
@@ -75,3 +80,111 @@ func TestNewDataKeyError(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Equal(t, "FakeGenDataKeyError", err.Error())
 }
+
+func TestGetDataKeyWithContextCancelled(t *testing.T) {
+	awsapi := AWSAPI{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	plainkey, err := awsapi.GetDataKeyWithContext(ctx,
+		mock.KMSAPIDecrypt([]byte("plain"), "fake keyid"), []byte("blob"))
+	assert.Nil(t, plainkey)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestGenerateDataKey(t *testing.T) {
+	awsapi := AWSAPI{}
+	kmsapi := mock.KMSAPIKeys("key-1")
+	encCtx := map[string]string{"app": "calendula"}
+	dataKey, err := awsapi.GenerateDataKey(kmsapi, "key-1", "AES_256", encCtx)
+	assert.Nil(t, err)
+	assert.Equal(t, "arn:aws:kms:us-east-1:123456789012:key/key-1", dataKey.KeyID)
+	assert.Equal(t, 32, len(dataKey.Plaintext))
+
+	decrypted, err := awsapi.DecryptDataKey(kmsapi, dataKey.CiphertextBlob, encCtx)
+	assert.Nil(t, err)
+	assert.Equal(t, dataKey, decrypted)
+
+	_, err = awsapi.DecryptDataKey(kmsapi, dataKey.CiphertextBlob, nil)
+	assert.NotNil(t, err)
+	_, err = awsapi.GenerateDataKey(kmsapi, "key-2", "AES_256", nil)
+	assert.NotNil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = awsapi.GenerateDataKeyWithContext(ctx, kmsapi, "key-1", "AES_128", nil)
+	assert.Equal(t, context.Canceled, err)
+	_, err = awsapi.DecryptDataKeyWithContext(ctx, kmsapi, dataKey.CiphertextBlob, encCtx)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	awsapi := AWSAPI{}
+	kmsapi := mock.KMSAPIKeys("key-1", "key-2")
+	kmsapi.SetAlias("alias/app", "key-1")
+	tenant := map[string]string{"tenant": "t1", "purpose": "password"}
+
+	blob, keyID, err := awsapi.Encrypt(kmsapi, "alias/app", []byte("secret"), tenant)
+	assert.Nil(t, err)
+	assert.Equal(t, "arn:aws:kms:us-east-1:123456789012:key/key-1", keyID)
+
+	plain, keyID, err := awsapi.Decrypt(kmsapi, blob, tenant)
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", string(plain))
+	assert.Equal(t, "arn:aws:kms:us-east-1:123456789012:key/key-1", keyID)
+
+	// bound to the encryption context
+	_, _, err = awsapi.Decrypt(kmsapi, blob, map[string]string{"tenant": "t2", "purpose": "password"})
+	assert.NotNil(t, err)
+	_, _, err = awsapi.Decrypt(kmsapi, blob, nil)
+	assert.NotNil(t, err)
+
+	_, _, err = awsapi.Encrypt(kmsapi, "alias/missing", []byte("secret"), nil)
+	assert.NotNil(t, err)
+	_, _, err = awsapi.Encrypt(kmsapi, "key-1", []byte{}, nil)
+	assert.NotNil(t, err)
+
+	kmsapi.SetKeyEnabled("key-1", false)
+	_, _, err = awsapi.Decrypt(kmsapi, blob, tenant)
+	assert.NotNil(t, err)
+	kmsapi.SetKeyEnabled("key-1", true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = awsapi.EncryptWithContext(ctx, kmsapi, "key-1", []byte("secret"), nil)
+	assert.Equal(t, context.Canceled, err)
+	_, _, err = awsapi.DecryptWithContext(ctx, kmsapi, blob, tenant)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestReEncrypt(t *testing.T) {
+	awsapi := AWSAPI{}
+	kmsapi := mock.KMSAPIKeys("key-1", "key-2")
+	srcCtx := map[string]string{"tenant": "t1"}
+	destCtx := map[string]string{"tenant": "t1", "rotated": "2019"}
+	blob, _, err := awsapi.Encrypt(kmsapi, "key-1", []byte("secret"), srcCtx)
+	assert.Nil(t, err)
+
+	rotated, keyID, err := awsapi.ReEncrypt(kmsapi, blob, srcCtx, "key-2", destCtx)
+	assert.Nil(t, err)
+	assert.Equal(t, "arn:aws:kms:us-east-1:123456789012:key/key-2", keyID)
+	plain, keyID, err := awsapi.Decrypt(kmsapi, rotated, destCtx)
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", string(plain))
+	assert.Equal(t, "arn:aws:kms:us-east-1:123456789012:key/key-2", keyID)
+
+	// the old key is no longer needed
+	kmsapi.RemoveKey("key-1")
+	_, _, err = awsapi.Decrypt(kmsapi, rotated, destCtx)
+	assert.Nil(t, err)
+
+	_, _, err = awsapi.ReEncrypt(kmsapi, rotated, srcCtx, "key-2", destCtx)
+	assert.NotNil(t, err)
+	_, _, err = awsapi.ReEncrypt(kmsapi, rotated, destCtx, "key-3", nil)
+	assert.NotNil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = awsapi.ReEncryptWithContext(ctx, kmsapi, rotated, destCtx, "key-2", nil)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,210 @@
+package awsapi
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// GrantOptions defines options to create a grant
+type GrantOptions struct {
+	Name string
+	// Operations are kms operations allowed, e.g. Decrypt or GenerateDataKey
+	Operations        []string
+	RetiringPrincipal string
+	// EncryptionContextEquals or EncryptionContextSubset restricts the grant
+	// to cryptographic operations w/ the encryption context, not both
+	EncryptionContextEquals map[string]string
+	EncryptionContextSubset map[string]string
+}
+
+// Validate checks missing or conflicting options
+func (opts *GrantOptions) Validate() error {
+	if len(opts.Operations) == 0 {
+		return fmt.Errorf("grant requires at least an operation")
+	}
+	if len(opts.EncryptionContextEquals) != 0 && len(opts.EncryptionContextSubset) != 0 {
+		return fmt.Errorf("grant constraints of encryption context equals and subset are exclusive")
+	}
+	return nil
+}
+
+func (opts *GrantOptions) createGrantInput(keyID, grantee string) *kms.CreateGrantInput {
+	input := &kms.CreateGrantInput{
+		KeyId:            aws.String(keyID),
+		GranteePrincipal: aws.String(grantee),
+		Operations:       aws.StringSlice(opts.Operations),
+	}
+	if opts.Name != "" {
+		input.Name = aws.String(opts.Name)
+	}
+	if opts.RetiringPrincipal != "" {
+		input.RetiringPrincipal = aws.String(opts.RetiringPrincipal)
+	}
+	switch {
+	case len(opts.EncryptionContextEquals) != 0:
+		input.Constraints = &kms.GrantConstraints{
+			EncryptionContextEquals: aws.StringMap(opts.EncryptionContextEquals)}
+	case len(opts.EncryptionContextSubset) != 0:
+		input.Constraints = &kms.GrantConstraints{
+			EncryptionContextSubset: aws.StringMap(opts.EncryptionContextSubset)}
+	}
+	return input
+}
+
+// Grant defines a grant of a master key
+type Grant struct {
+	GrantID                 string            `json:"grant_id"`
+	KeyID                   string            `json:"key_id"`
+	Name                    string            `json:"name"`
+	GranteePrincipal        string            `json:"grantee_principal"`
+	RetiringPrincipal       string            `json:"retiring_principal"`
+	IssuingAccount          string            `json:"issuing_account"`
+	Operations              []string          `json:"operations"`
+	EncryptionContextEquals map[string]string `json:"encryption_context_equals,omitempty"`
+	EncryptionContextSubset map[string]string `json:"encryption_context_subset,omitempty"`
+	CreationDate            time.Time         `json:"creation_date"`
+}
+
+func newGrant(entry *kms.GrantListEntry) Grant {
+	grant := Grant{
+		GrantID:           aws.StringValue(entry.GrantId),
+		KeyID:             aws.StringValue(entry.KeyId),
+		Name:              aws.StringValue(entry.Name),
+		GranteePrincipal:  aws.StringValue(entry.GranteePrincipal),
+		RetiringPrincipal: aws.StringValue(entry.RetiringPrincipal),
+		IssuingAccount:    aws.StringValue(entry.IssuingAccount),
+		Operations:        aws.StringValueSlice(entry.Operations),
+		CreationDate:      aws.TimeValue(entry.CreationDate),
+	}
+	if entry.Constraints != nil {
+		if len(entry.Constraints.EncryptionContextEquals) != 0 {
+			grant.EncryptionContextEquals = aws.StringValueMap(entry.Constraints.EncryptionContextEquals)
+		}
+		if len(entry.Constraints.EncryptionContextSubset) != 0 {
+			grant.EncryptionContextSubset = aws.StringValueMap(entry.Constraints.EncryptionContextSubset)
+		}
+	}
+	return grant
+}
+
+// CreateGrant allows a principal to use a master key, it returns the grant
+// id and a grant token usable before the grant is eventually consistent
+func (awsapi *AWSAPI) CreateGrant(svc kmsiface.KMSAPI, keyID string, grantee string,
+	grantOpts GrantOptions) (string, string, error) {
+	return awsapi.CreateGrantWithContext(aws.BackgroundContext(), svc, keyID, grantee, grantOpts)
+}
+
+// CreateGrantWithContext is CreateGrant w/ a context and request options
+func (awsapi *AWSAPI) CreateGrantWithContext(ctx context.Context, svc kmsiface.KMSAPI,
+	keyID string, grantee string, grantOpts GrantOptions,
+	opts ...request.Option) (string, string, error) {
+	if err := grantOpts.Validate(); err != nil {
+		log.Printf("AWSError: Cannot create a grant of %s to %s, err %+v\n", keyID, grantee, err)
+		return "", "", err
+	}
+	result, err := svc.CreateGrantWithContext(ctx,
+		grantOpts.createGrantInput(keyID, grantee), opts...)
+	if err != nil {
+		log.Printf("AWSError: Cannot create a grant of %s to %s, err %+v\n", keyID, grantee, err)
+		return "", "", err
+	}
+	log.Printf("Created grant %s of %s to %s\n", aws.StringValue(result.GrantId), keyID, grantee)
+	return aws.StringValue(result.GrantId), aws.StringValue(result.GrantToken), nil
+}
+
+// RetireGrant retires a grant by its retiring principal or grantee
+func (awsapi *AWSAPI) RetireGrant(svc kmsiface.KMSAPI, keyID string, grantID string) error {
+	return awsapi.RetireGrantWithContext(aws.BackgroundContext(), svc, keyID, grantID)
+}
+
+// RetireGrantWithContext is RetireGrant w/ a context and request options
+func (awsapi *AWSAPI) RetireGrantWithContext(ctx context.Context, svc kmsiface.KMSAPI,
+	keyID string, grantID string, opts ...request.Option) error {
+	input := &kms.RetireGrantInput{
+		KeyId:   aws.String(keyID),
+		GrantId: aws.String(grantID),
+	}
+	if _, err := svc.RetireGrantWithContext(ctx, input, opts...); err != nil {
+		log.Printf("AWSError: Cannot retire grant %s of %s, err %+v\n", grantID, keyID, err)
+		return err
+	}
+	log.Printf("Retired grant %s of %s\n", grantID, keyID)
+	return nil
+}
+
+// RevokeGrant revokes a grant by the key owner
+func (awsapi *AWSAPI) RevokeGrant(svc kmsiface.KMSAPI, keyID string, grantID string) error {
+	return awsapi.RevokeGrantWithContext(aws.BackgroundContext(), svc, keyID, grantID)
+}
+
+// RevokeGrantWithContext is RevokeGrant w/ a context and request options
+func (awsapi *AWSAPI) RevokeGrantWithContext(ctx context.Context, svc kmsiface.KMSAPI,
+	keyID string, grantID string, opts ...request.Option) error {
+	input := &kms.RevokeGrantInput{
+		KeyId:   aws.String(keyID),
+		GrantId: aws.String(grantID),
+	}
+	if _, err := svc.RevokeGrantWithContext(ctx, input, opts...); err != nil {
+		log.Printf("AWSError: Cannot revoke grant %s of %s, err %+v\n", grantID, keyID, err)
+		return err
+	}
+	log.Printf("Revoked grant %s of %s\n", grantID, keyID)
+	return nil
+}
+
+// ListGrants returns all grants of a master key
+func (awsapi *AWSAPI) ListGrants(svc kmsiface.KMSAPI, keyID string) ([]Grant, error) {
+	return awsapi.ListGrantsWithContext(aws.BackgroundContext(), svc, keyID)
+}
+
+// ListGrantsWithContext is ListGrants w/ a context and request options
+func (awsapi *AWSAPI) ListGrantsWithContext(ctx context.Context, svc kmsiface.KMSAPI,
+	keyID string, opts ...request.Option) ([]Grant, error) {
+	grants := []Grant{}
+	err := svc.ListGrantsPagesWithContext(ctx, &kms.ListGrantsInput{KeyId: aws.String(keyID)},
+		func(page *kms.ListGrantsResponse, lastPage bool) bool {
+			for _, entry := range page.Grants {
+				grants = append(grants, newGrant(entry))
+			}
+			return true
+		}, opts...)
+	if err != nil {
+		log.Printf("AWSError: Cannot list grants of %s, err %+v\n", keyID, err)
+		return nil, err
+	}
+	return grants, nil
+}
+
+// ListRetirableGrants returns grants a principal can retire, of all keys
+func (awsapi *AWSAPI) ListRetirableGrants(svc kmsiface.KMSAPI,
+	principal string) ([]Grant, error) {
+	return awsapi.ListRetirableGrantsWithContext(aws.BackgroundContext(), svc, principal)
+}
+
+// ListRetirableGrantsWithContext is ListRetirableGrants w/ a context and request options
+func (awsapi *AWSAPI) ListRetirableGrantsWithContext(ctx context.Context,
+	svc kmsiface.KMSAPI, principal string, opts ...request.Option) ([]Grant, error) {
+	input := &kms.ListRetirableGrantsInput{RetiringPrincipal: aws.String(principal)}
+	grants := []Grant{}
+	for {
+		page, err := svc.ListRetirableGrantsWithContext(ctx, input, opts...)
+		if err != nil {
+			log.Printf("AWSError: Cannot list grants retirable by %s, err %+v\n", principal, err)
+			return nil, err
+		}
+		for _, entry := range page.Grants {
+			grants = append(grants, newGrant(entry))
+		}
+		if !aws.BoolValue(page.Truncated) || aws.StringValue(page.NextMarker) == "" {
+			return grants, nil
+		}
+		input.Marker = page.NextMarker
+	}
+}
@@ -0,0 +1,102 @@
+package awsapi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+func TestGrantOptionsValidate(t *testing.T) {
+	opts := GrantOptions{Operations: []string{"Decrypt"}}
+	assert.Nil(t, opts.Validate())
+	opts = GrantOptions{}
+	assert.EqualError(t, opts.Validate(), "grant requires at least an operation")
+	opts = GrantOptions{
+		Operations:              []string{"Decrypt"},
+		EncryptionContextEquals: map[string]string{"tenant": "t1"},
+		EncryptionContextSubset: map[string]string{"tenant": "t1"},
+	}
+	assert.EqualError(t, opts.Validate(),
+		"grant constraints of encryption context equals and subset are exclusive")
+
+	awsapi := AWSAPI{}
+	_, _, err := awsapi.CreateGrant(mock.KMSAPIKeys("key-1"), "key-1", "role", opts)
+	assert.NotNil(t, err)
+}
+
+func TestGrants(t *testing.T) {
+	awsapi := AWSAPI{}
+	kmsapi := mock.KMSAPIKeys("key-1", "key-2")
+	kmsapi.SetPageSize(2)
+	worker := "arn:aws:iam::123456789012:role/worker"
+	admin := "arn:aws:iam::123456789012:role/admin"
+
+	grantID, token, err := awsapi.CreateGrant(kmsapi, "key-1", worker, GrantOptions{
+		Name:                    "tenant-t1",
+		Operations:              []string{"Decrypt", "GenerateDataKey"},
+		RetiringPrincipal:       admin,
+		EncryptionContextSubset: map[string]string{"tenant": "t1"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "grant-1", grantID)
+	assert.NotEmpty(t, token)
+	for _, name := range []string{"tenant-t2", "tenant-t3"} {
+		_, _, err = awsapi.CreateGrant(kmsapi, "key-1", worker, GrantOptions{
+			Name:                    name,
+			Operations:              []string{"Decrypt"},
+			EncryptionContextEquals: map[string]string{"tenant": name},
+		})
+		assert.Nil(t, err)
+	}
+	_, _, err = awsapi.CreateGrant(kmsapi, "key-2", worker, GrantOptions{
+		Operations: []string{"Encrypt"}, RetiringPrincipal: admin})
+	assert.Nil(t, err)
+
+	grants, err := awsapi.ListGrants(kmsapi, "key-1")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(grants))
+	assert.Equal(t, Grant{
+		GrantID:                 "grant-1",
+		KeyID:                   "arn:aws:kms:us-east-1:123456789012:key/key-1",
+		Name:                    "tenant-t1",
+		GranteePrincipal:        worker,
+		RetiringPrincipal:       admin,
+		IssuingAccount:          "arn:aws:iam::123456789012:root",
+		Operations:              []string{"Decrypt", "GenerateDataKey"},
+		EncryptionContextSubset: map[string]string{"tenant": "t1"},
+		CreationDate:            grants[0].CreationDate,
+	}, grants[0])
+	assert.Equal(t, map[string]string{"tenant": "tenant-t2"}, grants[1].EncryptionContextEquals)
+
+	grants, err = awsapi.ListRetirableGrants(kmsapi, admin)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"grant-1", "grant-4"}, []string{grants[0].GrantID, grants[1].GrantID})
+
+	assert.Nil(t, awsapi.RetireGrant(kmsapi, "key-1", "grant-1"))
+	assert.Nil(t, awsapi.RevokeGrant(kmsapi, "key-1", "grant-2"))
+	assert.NotNil(t, awsapi.RevokeGrant(kmsapi, "key-1", "grant-2"))
+	assert.NotNil(t, awsapi.RetireGrant(kmsapi, "key-2", "grant-3"))
+	grants, err = awsapi.ListGrants(kmsapi, "key-1")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(grants))
+	assert.Equal(t, "grant-3", grants[0].GrantID)
+
+	_, err = awsapi.ListGrants(kmsapi, "key-3")
+	assert.NotNil(t, err)
+	_, _, err = awsapi.CreateGrant(kmsapi, "key-3", worker, GrantOptions{Operations: []string{"Decrypt"}})
+	assert.NotNil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = awsapi.ListGrantsWithContext(ctx, kmsapi, "key-1")
+	assert.Equal(t, context.Canceled, err)
+	_, err = awsapi.ListRetirableGrantsWithContext(ctx, kmsapi, admin)
+	assert.Equal(t, context.Canceled, err)
+	_, _, err = awsapi.CreateGrantWithContext(ctx, kmsapi, "key-1", worker,
+		GrantOptions{Operations: []string{"Decrypt"}})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, context.Canceled, awsapi.RetireGrantWithContext(ctx, kmsapi, "key-1", "grant-3"))
+	assert.Equal(t, context.Canceled, awsapi.RevokeGrantWithContext(ctx, kmsapi, "key-1", "grant-3"))
+}
@@ -0,0 +1,98 @@
+package awsapi
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// KeyInfo defines the metadata of a kms master key
+type KeyInfo struct {
+	KeyID        string    `json:"key_id"`
+	ARN          string    `json:"arn"`
+	Description  string    `json:"description"`
+	Enabled      bool      `json:"enabled"`
+	KeyState     string    `json:"key_state"`
+	KeyUsage     string    `json:"key_usage"`
+	KeyManager   string    `json:"key_manager"`
+	Origin       string    `json:"origin"`
+	CreationDate time.Time `json:"creation_date"`
+	// DeletionDate is set if the key is pending deletion
+	DeletionDate time.Time `json:"deletion_date"`
+}
+
+func newKeyInfo(metadata *kms.KeyMetadata) *KeyInfo {
+	return &KeyInfo{
+		KeyID:        aws.StringValue(metadata.KeyId),
+		ARN:          aws.StringValue(metadata.Arn),
+		Description:  aws.StringValue(metadata.Description),
+		Enabled:      aws.BoolValue(metadata.Enabled),
+		KeyState:     aws.StringValue(metadata.KeyState),
+		KeyUsage:     aws.StringValue(metadata.KeyUsage),
+		KeyManager:   aws.StringValue(metadata.KeyManager),
+		Origin:       aws.StringValue(metadata.Origin),
+		CreationDate: aws.TimeValue(metadata.CreationDate),
+		DeletionDate: aws.TimeValue(metadata.DeletionDate),
+	}
+}
+
+// DescribeKey returns the metadata of a master key by id, arn or alias
+func (awsapi *AWSAPI) DescribeKey(svc kmsiface.KMSAPI, keyID string) (*KeyInfo, error) {
+	return awsapi.DescribeKeyWithContext(aws.BackgroundContext(), svc, keyID)
+}
+
+// DescribeKeyWithContext is DescribeKey w/ a context and request options
+func (awsapi *AWSAPI) DescribeKeyWithContext(ctx context.Context, svc kmsiface.KMSAPI,
+	keyID string, opts ...request.Option) (*KeyInfo, error) {
+	result, err := svc.DescribeKeyWithContext(ctx,
+		&kms.DescribeKeyInput{KeyId: aws.String(keyID)}, opts...)
+	if err != nil {
+		log.Printf("AWSError: Cannot describe key %s, err %+v\n", keyID, err)
+		return nil, err
+	}
+	return newKeyInfo(result.KeyMetadata), nil
+}
+
+// KeyAlias defines an alias of a master key
+type KeyAlias struct {
+	Name string `json:"name"`
+	ARN  string `json:"arn"`
+	// TargetKeyID is empty for aws managed aliases w/o a key yet
+	TargetKeyID string `json:"target_key_id"`
+}
+
+// ListAliases returns aliases of all master keys, or of a key if keyID is set
+func (awsapi *AWSAPI) ListAliases(svc kmsiface.KMSAPI, keyID string) ([]KeyAlias, error) {
+	return awsapi.ListAliasesWithContext(aws.BackgroundContext(), svc, keyID)
+}
+
+// ListAliasesWithContext is ListAliases w/ a context and request options
+func (awsapi *AWSAPI) ListAliasesWithContext(ctx context.Context, svc kmsiface.KMSAPI,
+	keyID string, opts ...request.Option) ([]KeyAlias, error) {
+	input := &kms.ListAliasesInput{}
+	if keyID != "" {
+		input.KeyId = aws.String(keyID)
+	}
+	aliases := []KeyAlias{}
+	err := svc.ListAliasesPagesWithContext(ctx, input,
+		func(page *kms.ListAliasesOutput, lastPage bool) bool {
+			for _, entry := range page.Aliases {
+				aliases = append(aliases, KeyAlias{
+					Name:        aws.StringValue(entry.AliasName),
+					ARN:         aws.StringValue(entry.AliasArn),
+					TargetKeyID: aws.StringValue(entry.TargetKeyId),
+				})
+			}
+			return true
+		}, opts...)
+	if err != nil {
+		log.Printf("AWSError: Cannot list aliases, err %+v\n", err)
+		return nil, err
+	}
+	return aliases, nil
+}
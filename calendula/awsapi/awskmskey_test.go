@@ -0,0 +1,68 @@
+package awsapi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+func TestDescribeKey(t *testing.T) {
+	awsapi := AWSAPI{}
+	kmsapi := mock.KMSAPIKeys("key-1")
+	kmsapi.SetAlias("alias/app", "key-1")
+
+	info, err := awsapi.DescribeKey(kmsapi, "alias/app")
+	assert.Nil(t, err)
+	assert.Equal(t, "key-1", info.KeyID)
+	assert.Equal(t, "arn:aws:kms:us-east-1:123456789012:key/key-1", info.ARN)
+	assert.True(t, info.Enabled)
+	assert.Equal(t, "Enabled", info.KeyState)
+	assert.Equal(t, "ENCRYPT_DECRYPT", info.KeyUsage)
+	assert.Equal(t, "CUSTOMER", info.KeyManager)
+	assert.False(t, info.CreationDate.IsZero())
+	assert.True(t, info.DeletionDate.IsZero())
+
+	kmsapi.SetKeyEnabled("key-1", false)
+	info, err = awsapi.DescribeKey(kmsapi, "key-1")
+	assert.Nil(t, err)
+	assert.False(t, info.Enabled)
+	assert.Equal(t, "Disabled", info.KeyState)
+
+	_, err = awsapi.DescribeKey(kmsapi, "key-2")
+	assert.NotNil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = awsapi.DescribeKeyWithContext(ctx, kmsapi, "key-1")
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestListAliases(t *testing.T) {
+	awsapi := AWSAPI{}
+	kmsapi := mock.KMSAPIKeys("key-1", "key-2")
+	kmsapi.SetPageSize(2)
+	kmsapi.SetAlias("alias/app", "key-1")
+	kmsapi.SetAlias("alias/audit", "key-2")
+	kmsapi.SetAlias("alias/app-next", "key-1")
+
+	aliases, err := awsapi.ListAliases(kmsapi, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []KeyAlias{
+		{Name: "alias/app", ARN: "arn:aws:kms:us-east-1:123456789012:alias/app", TargetKeyID: "key-1"},
+		{Name: "alias/app-next", ARN: "arn:aws:kms:us-east-1:123456789012:alias/app-next", TargetKeyID: "key-1"},
+		{Name: "alias/audit", ARN: "arn:aws:kms:us-east-1:123456789012:alias/audit", TargetKeyID: "key-2"},
+	}, aliases)
+
+	aliases, err = awsapi.ListAliases(kmsapi, "key-2")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(aliases))
+	assert.Equal(t, "alias/audit", aliases[0].Name)
+
+	_, err = awsapi.ListAliases(kmsapi, "key-3")
+	assert.NotNil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = awsapi.ListAliasesWithContext(ctx, kmsapi, "")
+	assert.Equal(t, context.Canceled, err)
+}
@@ -1,9 +1,11 @@
 package awsapi
 
 import (
+	"context"
 	"log"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
 )
@@ -23,13 +25,20 @@ const (
 // InvokeLambda invoke a lambda function
 func (awsapi *AWSAPI) InvokeLambda(lambdasvc lambdaiface.LambdaAPI,
 	funcArn string, payload []byte, invokeType string) (*lambda.InvokeOutput, error) {
+	return awsapi.InvokeLambdaWithContext(aws.BackgroundContext(), lambdasvc,
+		funcArn, payload, invokeType)
+}
 
+// InvokeLambdaWithContext is InvokeLambda w/ a context and request options
+func (awsapi *AWSAPI) InvokeLambdaWithContext(ctx context.Context,
+	lambdasvc lambdaiface.LambdaAPI, funcArn string, payload []byte,
+	invokeType string, opts ...request.Option) (*lambda.InvokeOutput, error) {
 	input := lambda.InvokeInput{
 		FunctionName:   aws.String(funcArn),
 		Payload:        payload,
 		InvocationType: aws.String(invokeType),
 	}
-	output, err := lambdasvc.Invoke(&input)
+	output, err := lambdasvc.InvokeWithContext(ctx, &input, opts...)
 	if err != nil {
 		log.Printf("AWSError: fail to invoke lambda %s input=(%s) invocation=(%s), err: %v",
 			funcArn, string(payload), invokeType, err)
@@ -43,6 +52,14 @@ func (awsapi *AWSAPI) InvokeLambda(lambdasvc lambdaiface.LambdaAPI,
 // LambdaAddPermission helps to call lambda.AddPermission api
 func (awsapi *AWSAPI) LambdaAddPermission(lambdasvc lambdaiface.LambdaAPI,
 	action, funcName, principal, source, stateID string) error {
+	return awsapi.LambdaAddPermissionWithContext(aws.BackgroundContext(), lambdasvc,
+		action, funcName, principal, source, stateID)
+}
+
+// LambdaAddPermissionWithContext is LambdaAddPermission w/ a context and request options
+func (awsapi *AWSAPI) LambdaAddPermissionWithContext(ctx context.Context,
+	lambdasvc lambdaiface.LambdaAPI, action, funcName, principal, source,
+	stateID string, opts ...request.Option) error {
 	input := &lambda.AddPermissionInput{
 		Action:       aws.String(action),
 		FunctionName: aws.String(funcName),
@@ -50,7 +67,7 @@ func (awsapi *AWSAPI) LambdaAddPermission(lambdasvc lambdaiface.LambdaAPI,
 		SourceArn:    aws.String(source),
 		StatementId:  aws.String(stateID),
 	}
-	output, err := lambdasvc.AddPermission(input)
+	output, err := lambdasvc.AddPermissionWithContext(ctx, input, opts...)
 	if err != nil {
 		log.Printf("AWSError: fail to add permission to lambda %s "+
 			"w/ (source=%s, action=%s, principal=%s, stateID=%s)", funcName, source,
@@ -70,12 +87,23 @@ func (awsapi *AWSAPI) LambdaAddPermission(lambdasvc lambdaiface.LambdaAPI,
 	return nil
 }
 
-// NewLambdaAPI returns a Lambda API
-func NewLambdaAPI(sess AWSSession) lambdaiface.LambdaAPI {
+// NewLambdaClient returns a Lambda API, or an error if the session fails
+func NewLambdaClient(sess AWSSession) (lambdaiface.LambdaAPI, error) {
 	lambdasess, err := sess.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	return lambda.New(lambdasess), nil
+}
+
+// NewLambdaAPI returns a Lambda API, or nil if the session fails.
+//
+// Deprecated: use NewLambdaClient or Clients.Lambda, which return the error.
+func NewLambdaAPI(sess AWSSession) lambdaiface.LambdaAPI {
+	svc, err := NewLambdaClient(sess)
 	if err != nil {
 		log.Printf("AWSError: Cannot create aws session, err %#v", err)
 		return nil
 	}
-	return lambda.New(lambdasess)
+	return svc
 }
@@ -1,6 +1,7 @@
 package awsapi
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -20,10 +21,14 @@ func TestNewLambdaAPIError(t *testing.T) {
 	defer mockCtrl.Finish()
 	mockSession := mock.NewMockAWSSession(mockCtrl)
 	mockSession.EXPECT().NewSession().Return(
-		nil, errors.New("FakeNewSessionError")).Times(1)
+		nil, errors.New("FakeNewSessionError")).Times(2)
 
 	lambdaapi := NewLambdaAPI(mockSession)
 	assert.Nil(t, lambdaapi)
+
+	svc, err := NewLambdaClient(mockSession)
+	assert.Nil(t, svc)
+	assert.EqualError(t, err, "FakeNewSessionError")
 }
 
 func TestInvokeLambda(t *testing.T) {
@@ -61,3 +66,14 @@ func TestLambdaAddPermission(t *testing.T) {
 		"randstateid12345")
 	assert.Equal(t, "MockAddPermError", err.Error())
 }
+
+func TestInvokeLambdaWithContextCancelled(t *testing.T) {
+	awsapi := AWSAPI{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	output, err := awsapi.InvokeLambdaWithContext(ctx, mock.LambdaAPIInvoke(),
+		"fakearn", []byte("json"), "Event")
+	assert.Nil(t, output)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -2,10 +2,12 @@ package awsapi
 
 import (
 	"bytes"
+	"context"
 	"log"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -17,14 +19,25 @@ const (
 	maxsize = 1000
 )
 
-// NewS3API returns a s3 client
-func NewS3API(sess AWSSession) s3iface.S3API {
+// NewS3Client returns a s3 client, or an error if the session fails
+func NewS3Client(sess AWSSession) (s3iface.S3API, error) {
 	s3ess, err := sess.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	return s3.New(s3ess), nil
+}
+
+// NewS3API returns a s3 client, or nil if the session fails.
+//
+// Deprecated: use NewS3Client or Clients.S3, which return the error.
+func NewS3API(sess AWSSession) s3iface.S3API {
+	svc, err := NewS3Client(sess)
 	if err != nil {
 		log.Printf("AWSError: Cannot create aws session. err %#v", err)
 		return nil
 	}
-	return s3.New(s3ess)
+	return svc
 }
 
 // NewS3UploaderAPI returns a s3manager.Uploader pointer
@@ -34,18 +47,20 @@ func NewS3UploaderAPI(svc s3iface.S3API) s3manageriface.UploaderAPI {
 
 // GetObject returns the string content of a s3 object
 func (awsapi *AWSAPI) GetObject(svc s3iface.S3API, bucket string, key string) (string, error) {
-	input := &s3.GetObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-	}
-	result, err := svc.GetObject(input)
+	return awsapi.GetObjectWithContext(aws.BackgroundContext(), svc, bucket, key)
+}
+
+// GetObjectWithContext is GetObject w/ a context and request options
+func (awsapi *AWSAPI) GetObjectWithContext(ctx context.Context, svc s3iface.S3API,
+	bucket string, key string, opts ...request.Option) (string, error) {
+	body, err := awsapi.GetObjectReader(ctx, svc, bucket, key,
+		ReadOptions{RequestOptions: opts})
 	if err != nil {
-		log.Printf("AWSError: Cannot get objects from s3://%s/%s, err: %+v", bucket, key, err)
 		return "", err
 	}
-	log.Printf("Get a object from s3://%s/%s, VersionId %s\n", bucket, key, *result.VersionId)
+	defer body.Close()
 	var buf bytes.Buffer
-	return utils.ReadFrom(&buf, result.Body)
+	return utils.ReadFrom(&buf, body)
 }
 
 func list2array(result *s3.ListObjectsV2Output) []string {
@@ -58,13 +73,20 @@ func list2array(result *s3.ListObjectsV2Output) []string {
 
 // ListObjects helps to list all objects in a bucket with a certain prefix
 func (awsapi *AWSAPI) ListObjects(svc s3iface.S3API, bucket string, prefix string) ([]string, error) {
+	return awsapi.ListObjectsWithContext(aws.BackgroundContext(), svc, bucket, prefix)
+}
+
+// ListObjectsWithContext is ListObjects w/ a context and request options,
+// the loop stops at the first page failed by a cancelled context
+func (awsapi *AWSAPI) ListObjectsWithContext(ctx context.Context, svc s3iface.S3API,
+	bucket string, prefix string, opts ...request.Option) ([]string, error) {
 	found := make([]string, 0)
 	input := &s3.ListObjectsV2Input{
 		Bucket:  aws.String(bucket),
 		Prefix:  aws.String(prefix),
 		MaxKeys: aws.Int64(maxsize),
 	}
-	result, err := svc.ListObjectsV2(input)
+	result, err := svc.ListObjectsV2WithContext(ctx, input, opts...)
 
 	// if truncated, loop to get all results
 	for err == nil && *result.IsTruncated && result.NextContinuationToken != nil {
@@ -75,7 +97,7 @@ func (awsapi *AWSAPI) ListObjects(svc s3iface.S3API, bucket string, prefix strin
 			MaxKeys:           aws.Int64(maxsize),
 			ContinuationToken: result.NextContinuationToken,
 		}
-		result, err = svc.ListObjectsV2(input)
+		result, err = svc.ListObjectsV2WithContext(ctx, input, opts...)
 	}
 	if err != nil {
 		log.Printf("AWSError: Cannot list objects from s3://%s/%s, err: %+v", bucket, prefix, err)
@@ -87,6 +109,14 @@ func (awsapi *AWSAPI) ListObjects(svc s3iface.S3API, bucket string, prefix strin
 
 // ListObjectsPaginated return a truncated list of object keys
 func (awsapi *AWSAPI) ListObjectsPaginated(svc s3iface.S3API, bucket string, prefix string, size int, marker string) ([]string, string, bool, error) {
+	return awsapi.ListObjectsPaginatedWithContext(aws.BackgroundContext(),
+		svc, bucket, prefix, size, marker)
+}
+
+// ListObjectsPaginatedWithContext is ListObjectsPaginated w/ a context and request options
+func (awsapi *AWSAPI) ListObjectsPaginatedWithContext(ctx context.Context, svc s3iface.S3API,
+	bucket string, prefix string, size int, marker string,
+	opts ...request.Option) ([]string, string, bool, error) {
 	input := &s3.ListObjectsV2Input{
 		Bucket:  aws.String(bucket),
 		Prefix:  aws.String(prefix),
@@ -95,7 +125,7 @@ func (awsapi *AWSAPI) ListObjectsPaginated(svc s3iface.S3API, bucket string, pre
 	if len(marker) != 0 {
 		input.ContinuationToken = aws.String(marker)
 	}
-	result, err := svc.ListObjectsV2(input)
+	result, err := svc.ListObjectsV2WithContext(ctx, input, opts...)
 	if err != nil {
 		log.Printf("AWSError: Cannot list objects from s3://%s/%s, err: %+v", bucket, prefix, err)
 		return nil, "", false, err
@@ -110,31 +140,36 @@ func (awsapi *AWSAPI) ListObjectsPaginated(svc s3iface.S3API, bucket string, pre
 
 // DeleteObject deletes a object in s3
 func (awsapi *AWSAPI) DeleteObject(svc s3iface.S3API, bucket string, key string) error {
+	return awsapi.DeleteObjectWithContext(aws.BackgroundContext(), svc, bucket, key)
+}
+
+// DeleteObjectWithContext is DeleteObject w/ a context and request options
+func (awsapi *AWSAPI) DeleteObjectWithContext(ctx context.Context, svc s3iface.S3API,
+	bucket string, key string, opts ...request.Option) error {
 	input := &s3.DeleteObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	}
-	result, err := svc.DeleteObject(input)
+	result, err := svc.DeleteObjectWithContext(ctx, input, opts...)
 	if err != nil {
 		log.Printf("AWSError: Cannot delete object at s3://%s/%s, err: %+v", bucket, key, err)
 		return err
 	}
-	log.Printf("Deleted a object from s3://%s/%s, VersionId %s\n", bucket, key, *result.VersionId)
+	log.Printf("Deleted a object from s3://%s/%s, VersionId %s\n", bucket, key, aws.StringValue(result.VersionId))
 	return nil
 }
 
 // PutObject uploads a string as a s3 object
 func (awsapi *AWSAPI) PutObject(uploaderAPI s3manageriface.UploaderAPI, bucket string, key string, content string) error {
-	input := &s3manager.UploadInput{
-		Body:   strings.NewReader(content),
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-	}
-	result, err := uploaderAPI.Upload(input)
-	if err != nil {
-		log.Printf("AWSError: Cannot upload object to s3://%s/%s, err: %+v", bucket, key, err)
-		return err
-	}
-	log.Printf("Upload a object to s3://%s/%s, VersionId %s", bucket, key, *result.VersionID)
-	return nil
+	return awsapi.PutObjectWithContext(aws.BackgroundContext(), uploaderAPI, bucket, key, content)
+}
+
+// PutObjectWithContext is PutObject w/ a context, the request options
+// are applied to every request of the upload
+func (awsapi *AWSAPI) PutObjectWithContext(ctx context.Context,
+	uploaderAPI s3manageriface.UploaderAPI, bucket string, key string,
+	content string, opts ...request.Option) error {
+	_, err := awsapi.UploadObject(ctx, uploaderAPI, bucket, key,
+		strings.NewReader(content), UploadOptions{RequestOptions: opts})
+	return err
 }
@@ -1,6 +1,7 @@
 package awsapi
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
@@ -22,10 +23,14 @@ func TestNewS3APIError(t *testing.T) {
 	defer mockCtrl.Finish()
 	mockSession := mock.NewMockAWSSession(mockCtrl)
 	mockSession.EXPECT().NewSession().Return(
-		nil, errors.New("FakeNewSessionError")).Times(1)
+		nil, errors.New("FakeNewSessionError")).Times(2)
 
 	s3api := NewS3API(mockSession)
 	assert.Nil(t, s3api)
+
+	svc, err := NewS3Client(mockSession)
+	assert.Nil(t, svc)
+	assert.EqualError(t, err, "FakeNewSessionError")
 }
 
 func TestNewUploaderAPI(t *testing.T) {
@@ -187,3 +192,25 @@ func TestPutObjectError(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Equal(t, "FakeUploadError", err.Error())
 }
+
+func TestObjectsWithContextCancelled(t *testing.T) {
+	awsapi := AWSAPI{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := awsapi.GetObjectWithContext(ctx,
+		mock.S3APIGetObjects([]string{"content"}, "vid"), "bucket", "key")
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = awsapi.ListObjectsWithContext(ctx,
+		mock.S3APIListObjects("bucket", "prefix", []string{"name"}), "bucket", "prefix")
+	assert.Equal(t, context.Canceled, err)
+
+	err = awsapi.DeleteObjectWithContext(ctx,
+		mock.S3APIDeleteObject("vid"), "bucket", "key")
+	assert.Equal(t, context.Canceled, err)
+
+	err = awsapi.PutObjectWithContext(ctx,
+		mock.S3ManagerAPIUpload("vid"), "bucket", "key", "content")
+	assert.Equal(t, context.Canceled, err)
+}
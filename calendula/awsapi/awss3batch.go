@@ -0,0 +1,344 @@
+package awsapi
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/xinnige/asteraceae/calendula/utils"
+)
+
+const (
+	// maxDeleteObjects is the limit of keys of a DeleteObjects request
+	maxDeleteObjects = 1000
+	// maxCopyObjectSize is the limit of a single CopyObject request
+	maxCopyObjectSize = 5 * 1024 * 1024 * 1024
+	// minCopyPartSize and maxCopyParts are the limits of multipart copy
+	minCopyPartSize = 5 * 1024 * 1024
+	maxCopyParts    = 10000
+
+	deCopyPartSize    = 512 * 1024 * 1024
+	deCopyConcurrency = 8
+)
+
+// KeyError defines a failure of an object in a batch operation
+type KeyError struct {
+	Key     string `json:"key"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err KeyError) Error() string {
+	return fmt.Sprintf("%s: %s %s", err.Key, err.Code, err.Message)
+}
+
+// BatchResult holds succeeded keys and per-key errors of a batch operation
+type BatchResult struct {
+	Succeeded []string   `json:"succeeded"`
+	Errors    []KeyError `json:"errors"`
+}
+
+func newBatchResult() *BatchResult {
+	return &BatchResult{Succeeded: make([]string, 0), Errors: make([]KeyError, 0)}
+}
+
+// Failed checks if any key failed
+func (result *BatchResult) Failed() bool {
+	return len(result.Errors) != 0
+}
+
+// DeleteObjects deletes keys in batches of 1000, failed keys are
+// reported in the result, an error is returned if a request fails
+func (awsapi *AWSAPI) DeleteObjects(ctx context.Context, svc s3iface.S3API,
+	bucket string, keys []string) (*BatchResult, error) {
+	result := newBatchResult()
+	for _, chunk := range utils.ChunkStringArray(keys, maxDeleteObjects) {
+		objects := make([]*s3.ObjectIdentifier, len(chunk))
+		for idx, key := range chunk {
+			objects[idx] = &s3.ObjectIdentifier{Key: aws.String(key)}
+		}
+		input := &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(false)},
+		}
+		output, err := svc.DeleteObjectsWithContext(ctx, input)
+		if err != nil {
+			log.Printf("AWSError: Cannot delete %d objects from s3://%s, err: %+v",
+				len(chunk), bucket, err)
+			return result, err
+		}
+		for _, deleted := range output.Deleted {
+			result.Succeeded = append(result.Succeeded, aws.StringValue(deleted.Key))
+		}
+		for _, failed := range output.Errors {
+			result.Errors = append(result.Errors, KeyError{
+				Key:     aws.StringValue(failed.Key),
+				Code:    aws.StringValue(failed.Code),
+				Message: aws.StringValue(failed.Message),
+			})
+		}
+	}
+	log.Printf("Deleted %d objects from s3://%s, failed %d\n",
+		len(result.Succeeded), bucket, len(result.Errors))
+	return result, nil
+}
+
+// CopyOptions defines options of server side copies
+type CopyOptions struct {
+	// PartSize of a multipart copy, 512MB if 0 and enlarged to fit 10000 parts
+	PartSize int64
+	// Concurrency limits concurrent part copies of an object,
+	// and concurrent object copies of a prefix, 8 if 0
+	Concurrency int
+}
+
+func (opts *CopyOptions) concurrency() int {
+	if opts.Concurrency <= 0 {
+		return deCopyConcurrency
+	}
+	return opts.Concurrency
+}
+
+func (opts *CopyOptions) partSize(size int64) int64 {
+	partSize := opts.PartSize
+	if partSize <= 0 {
+		partSize = deCopyPartSize
+	}
+	if partSize < minCopyPartSize {
+		partSize = minCopyPartSize
+	}
+	if min := (size + maxCopyParts - 1) / maxCopyParts; partSize < min {
+		partSize = min
+	}
+	return partSize
+}
+
+// CopyObject copies an object on the server side,
+// objects over 5GB are copied in parts
+func (awsapi *AWSAPI) CopyObject(ctx context.Context, svc s3iface.S3API,
+	srcBucket, srcKey, dstBucket, dstKey string, opts CopyOptions) error {
+	return awsapi.copyObject(ctx, svc, srcBucket, srcKey, dstBucket, dstKey, nil, &opts)
+}
+
+// copyObject copies an object, the head of the source is fetched if it is nil.
+// The encryption of the source is kept whatever the size is, the listing of
+// a prefix does not report it, so every object is headed.
+func (awsapi *AWSAPI) copyObject(ctx context.Context, svc s3iface.S3API,
+	srcBucket, srcKey, dstBucket, dstKey string,
+	head *ObjectHead, opts *CopyOptions) error {
+	if head == nil {
+		var err error
+		if head, err = awsapi.HeadObjectWithContext(ctx, svc, srcBucket, srcKey); err != nil {
+			return err
+		}
+	}
+	if head.ContentLength > maxCopyObjectSize {
+		return awsapi.copyObjectParts(ctx, svc, head, dstBucket, dstKey, opts)
+	}
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(dstBucket),
+		Key:        aws.String(dstKey),
+		CopySource: aws.String(copySource(srcBucket, srcKey, "")),
+	}
+	if head.ServerSideEncryption != "" {
+		input.ServerSideEncryption = aws.String(head.ServerSideEncryption)
+	}
+	if head.SSEKMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(head.SSEKMSKeyID)
+	}
+	if _, err := svc.CopyObjectWithContext(ctx, input); err != nil {
+		log.Printf("AWSError: Cannot copy s3://%s/%s to s3://%s/%s, err: %+v",
+			srcBucket, srcKey, dstBucket, dstKey, err)
+		return err
+	}
+	return nil
+}
+
+// copyObjectParts copies the object of head by UploadPartCopy concurrently.
+// Parts are copied from the version and etag of head, so an overwrite of the
+// source fails the copy instead of mixing parts of two objects.
+// The content type, metadata, encryption and tags of the source are kept,
+// the acl is not copied, as by CopyObject.
+func (awsapi *AWSAPI) copyObjectParts(ctx context.Context, svc s3iface.S3API,
+	head *ObjectHead, dstBucket, dstKey string, opts *CopyOptions) error {
+	srcBucket, srcKey, size := head.Bucket, head.Key, head.ContentLength
+	tags, err := awsapi.GetObjectTaggingWithContext(ctx, svc, srcBucket, srcKey)
+	if err != nil {
+		return err
+	}
+	createInput := &s3.CreateMultipartUploadInput{
+		Bucket:   aws.String(dstBucket),
+		Key:      aws.String(dstKey),
+		Metadata: aws.StringMap(head.Metadata),
+	}
+	if head.ContentType != "" {
+		createInput.ContentType = aws.String(head.ContentType)
+	}
+	if head.ServerSideEncryption != "" {
+		createInput.ServerSideEncryption = aws.String(head.ServerSideEncryption)
+	}
+	if head.SSEKMSKeyID != "" {
+		createInput.SSEKMSKeyId = aws.String(head.SSEKMSKeyID)
+	}
+	if len(tags) != 0 {
+		createInput.Tagging = aws.String(EncodeTags(tags))
+	}
+	upload, err := svc.CreateMultipartUploadWithContext(ctx, createInput)
+	if err != nil {
+		log.Printf("AWSError: Cannot create multipart upload to s3://%s/%s, err: %+v",
+			dstBucket, dstKey, err)
+		return err
+	}
+
+	partSize := opts.partSize(size)
+	count := int((size + partSize - 1) / partSize)
+	parts := make([]*s3.CompletedPart, count)
+	errs := make([]error, count)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	source := copySource(srcBucket, srcKey, head.VersionID)
+	var ifMatch *string
+	if head.ETag != "" {
+		ifMatch = aws.String(`"` + head.ETag + `"`)
+	}
+	utils.RunPool(ctx, opts.concurrency(), count, func(idx int) {
+		start := int64(idx) * partSize
+		end := start + partSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		output, err := svc.UploadPartCopyWithContext(ctx, &s3.UploadPartCopyInput{
+			Bucket:            aws.String(dstBucket),
+			Key:               aws.String(dstKey),
+			CopySource:        aws.String(source),
+			CopySourceIfMatch: ifMatch,
+			CopySourceRange:   aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+			PartNumber:        aws.Int64(int64(idx + 1)),
+			UploadId:          upload.UploadId,
+		})
+		if err != nil {
+			errs[idx] = err
+			cancel()
+			return
+		}
+		parts[idx] = &s3.CompletedPart{
+			ETag:       output.CopyPartResult.ETag,
+			PartNumber: aws.Int64(int64(idx + 1)),
+		}
+	})
+
+	err = firstError(errs)
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		_, err = svc.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(dstBucket),
+			Key:             aws.String(dstKey),
+			UploadId:        upload.UploadId,
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+		})
+	}
+	if err != nil {
+		log.Printf("AWSError: Cannot copy s3://%s/%s to s3://%s/%s in parts, err: %+v",
+			srcBucket, srcKey, dstBucket, dstKey, err)
+		// abort w/o the cancelled context to release the parts
+		if _, abortErr := svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(dstBucket),
+			Key:      aws.String(dstKey),
+			UploadId: upload.UploadId,
+		}); abortErr != nil {
+			log.Printf("AWSError: Cannot abort multipart upload %s, err: %+v",
+				aws.StringValue(upload.UploadId), abortErr)
+		}
+		return err
+	}
+	log.Printf("Copied s3://%s/%s to s3://%s/%s in %d parts\n",
+		srcBucket, srcKey, dstBucket, dstKey, count)
+	return nil
+}
+
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MoveObject copies an object then deletes the source
+func (awsapi *AWSAPI) MoveObject(ctx context.Context, svc s3iface.S3API,
+	srcBucket, srcKey, dstBucket, dstKey string, opts CopyOptions) error {
+	if err := awsapi.CopyObject(ctx, svc, srcBucket, srcKey, dstBucket, dstKey, opts); err != nil {
+		return err
+	}
+	return awsapi.DeleteObjectWithContext(ctx, svc, srcBucket, srcKey)
+}
+
+// CopyPrefix copies all objects under srcPrefix to dstPrefix concurrently,
+// failed keys are reported in the result w/ the source keys
+func (awsapi *AWSAPI) CopyPrefix(ctx context.Context, svc s3iface.S3API,
+	srcBucket, srcPrefix, dstBucket, dstPrefix string,
+	opts CopyOptions) (*BatchResult, error) {
+	listed, err := awsapi.ListObjectInfosWithContext(ctx, svc, srcBucket,
+		ListOptions{Prefix: srcPrefix})
+	if err != nil {
+		return nil, err
+	}
+	objects := listed.Objects
+	errs := make([]error, len(objects))
+	done := make([]bool, len(objects))
+	utils.RunPool(ctx, opts.concurrency(), len(objects), func(idx int) {
+		obj := objects[idx]
+		dstKey := dstPrefix + strings.TrimPrefix(obj.Key, srcPrefix)
+		errs[idx] = awsapi.copyObject(ctx, svc, srcBucket, obj.Key,
+			dstBucket, dstKey, nil, &opts)
+		done[idx] = true
+	})
+
+	// objects not scheduled after a cancellation are not reported
+	result := newBatchResult()
+	for idx, obj := range objects {
+		switch {
+		case !done[idx]:
+		case errs[idx] != nil:
+			result.Errors = append(result.Errors, KeyError{Key: obj.Key,
+				Code: errorCode(errs[idx]), Message: errs[idx].Error()})
+		default:
+			result.Succeeded = append(result.Succeeded, obj.Key)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	log.Printf("Copied %d objects from s3://%s/%s to s3://%s/%s, failed %d\n",
+		len(result.Succeeded), srcBucket, srcPrefix, dstBucket, dstPrefix, len(result.Errors))
+	return result, nil
+}
+
+// MovePrefix copies all objects under srcPrefix to dstPrefix,
+// then deletes the copied sources
+func (awsapi *AWSAPI) MovePrefix(ctx context.Context, svc s3iface.S3API,
+	srcBucket, srcPrefix, dstBucket, dstPrefix string,
+	opts CopyOptions) (*BatchResult, error) {
+	copied, err := awsapi.CopyPrefix(ctx, svc, srcBucket, srcPrefix, dstBucket, dstPrefix, opts)
+	if err != nil {
+		return copied, err
+	}
+	deleted, err := awsapi.DeleteObjects(ctx, svc, srcBucket, copied.Succeeded)
+	deleted.Errors = append(copied.Errors, deleted.Errors...)
+	return deleted, err
+}
+
+// errorCode returns the code of an aws error, or "Error"
+func errorCode(err error) string {
+	if coder, ok := err.(interface{ Code() string }); ok {
+		return coder.Code()
+	}
+	return "Error"
+}
@@ -0,0 +1,208 @@
+package awsapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+const gigabyte = int64(1024 * 1024 * 1024)
+
+func TestDeleteObjects(t *testing.T) {
+	awsapi := AWSAPI{}
+	keys := make([]string, 2500)
+	for idx := range keys {
+		keys[idx] = fmt.Sprintf("logs/%04d.gz", idx)
+	}
+	s3api := mock.S3APIBatch("bucket", nil, 0, "bucket/logs/0042.gz")
+
+	result, err := awsapi.DeleteObjects(context.Background(), s3api, "bucket", keys)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, s3api.DeleteCalls())
+	assert.Equal(t, 2499, len(result.Succeeded))
+	assert.True(t, result.Failed())
+	assert.Equal(t, []KeyError{{Key: "logs/0042.gz", Code: "AccessDenied",
+		Message: "Access Denied"}}, result.Errors)
+	assert.Equal(t, "logs/0042.gz: AccessDenied Access Denied", result.Errors[0].Error())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = awsapi.DeleteObjects(ctx, s3api, "bucket", keys)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestCopyOptionsPartSize(t *testing.T) {
+	opts := CopyOptions{}
+	assert.Equal(t, int64(deCopyPartSize), opts.partSize(6*gigabyte))
+	opts = CopyOptions{PartSize: 1024}
+	assert.Equal(t, int64(minCopyPartSize), opts.partSize(6*gigabyte))
+	opts = CopyOptions{PartSize: minCopyPartSize}
+	assert.Equal(t, int64((5*1024*gigabyte+maxCopyParts-1)/maxCopyParts),
+		opts.partSize(5*1024*gigabyte))
+}
+
+func TestCopyObject(t *testing.T) {
+	awsapi := AWSAPI{}
+	s3api := mock.S3APIBatch("bucket", nil, 100)
+	err := awsapi.CopyObject(context.Background(), s3api,
+		"src", "a.gz", "dst", "b.gz", CopyOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b.gz"}, s3api.CopiedKeys())
+	assert.Nil(t, s3api.PartRanges())
+	input := s3api.CopyObjectInputs()[0]
+	assert.Nil(t, input.ServerSideEncryption)
+	assert.Nil(t, input.SSEKMSKeyId)
+
+	// a small sse-kms object keeps its key as a multipart copy does
+	s3api = mock.S3APIBatch("bucket", nil, 100)
+	s3api.SetHeadObject(&s3.HeadObjectOutput{
+		ContentLength:        aws.Int64(100),
+		ServerSideEncryption: aws.String(s3.ServerSideEncryptionAwsKms),
+		SSEKMSKeyId:          aws.String("fake-key"),
+	})
+	err = awsapi.CopyObject(context.Background(), s3api,
+		"src", "a.gz", "dst", "b.gz", CopyOptions{})
+	assert.Nil(t, err)
+	input = s3api.CopyObjectInputs()[0]
+	assert.Equal(t, "aws:kms", aws.StringValue(input.ServerSideEncryption))
+	assert.Equal(t, "fake-key", aws.StringValue(input.SSEKMSKeyId))
+
+	err = awsapi.CopyObject(context.Background(), mock.S3APIHeadObjectError(),
+		"src", "a.gz", "dst", "b.gz", CopyOptions{})
+	assert.EqualError(t, err, "FakeHeadObjectError")
+}
+
+func TestCopyObjectMultipart(t *testing.T) {
+	awsapi := AWSAPI{}
+	size := 6*gigabyte + 1
+	s3api := mock.S3APIBatch("bucket", nil, size)
+	err := awsapi.CopyObject(context.Background(), s3api,
+		"src", "big.gz", "dst", "big.gz", CopyOptions{PartSize: 2 * gigabyte, Concurrency: 2})
+	assert.Nil(t, err)
+	assert.Nil(t, s3api.CopiedKeys())
+	ranges := s3api.PartRanges()
+	sort.Strings(ranges)
+	assert.Equal(t, []string{
+		"bytes=0-2147483647",
+		"bytes=2147483648-4294967295",
+		"bytes=4294967296-6442450943",
+		"bytes=6442450944-6442450944",
+	}, ranges)
+	parts := s3api.CompletedParts()
+	assert.Equal(t, 4, len(parts))
+	for idx, part := range parts {
+		assert.Equal(t, int64(idx+1), aws.Int64Value(part.PartNumber))
+		assert.Equal(t, fmt.Sprintf(`"etag-%d"`, idx+1), aws.StringValue(part.ETag))
+	}
+	assert.False(t, s3api.Aborted())
+
+	s3api = mock.S3APIBatch("bucket", nil, size, "bytes=2147483648-4294967295")
+	err = awsapi.CopyObject(context.Background(), s3api,
+		"src", "big.gz", "dst", "big.gz", CopyOptions{PartSize: 2 * gigabyte})
+	assert.EqualError(t, err, "FakeUploadPartCopyError")
+	assert.Nil(t, s3api.CompletedParts())
+	assert.True(t, s3api.Aborted())
+}
+
+func TestCopyObjectMultipartSource(t *testing.T) {
+	awsapi := AWSAPI{}
+	s3api := mock.S3APIBatch("bucket", nil, 0)
+	s3api.SetHeadObject(&s3.HeadObjectOutput{
+		ContentLength:        aws.Int64(6 * gigabyte),
+		ETag:                 aws.String(`"src-etag"`),
+		VersionId:            aws.String("v1"),
+		Metadata:             aws.StringMap(map[string]string{"source": "audit"}),
+		ServerSideEncryption: aws.String(s3.ServerSideEncryptionAwsKms),
+		SSEKMSKeyId:          aws.String("fake-key"),
+	})
+	s3api.SetObjectTags(map[string]string{"retention": "1y"})
+	err := awsapi.CopyObject(context.Background(), s3api,
+		"src", "big.gz", "dst", "big.gz", CopyOptions{PartSize: 4 * gigabyte})
+	assert.Nil(t, err)
+
+	input := s3api.LastCreateMultipartUploadInput()
+	assert.Equal(t, "aws:kms", aws.StringValue(input.ServerSideEncryption))
+	assert.Equal(t, "fake-key", aws.StringValue(input.SSEKMSKeyId))
+	assert.Equal(t, "retention=1y", aws.StringValue(input.Tagging))
+	assert.Equal(t, "audit", aws.StringValue(input.Metadata["source"]))
+	assert.Nil(t, input.ContentType)
+	parts := s3api.PartCopyInputs()
+	assert.Equal(t, 2, len(parts))
+	for _, part := range parts {
+		assert.Equal(t, "src/big.gz?versionId=v1", aws.StringValue(part.CopySource))
+		assert.Equal(t, `"src-etag"`, aws.StringValue(part.CopySourceIfMatch))
+	}
+}
+
+func TestMoveObject(t *testing.T) {
+	awsapi := AWSAPI{}
+	s3api := mock.S3APIBatch("bucket", nil, 100)
+	err := awsapi.MoveObject(context.Background(), s3api,
+		"src", "a.gz", "dst", "b.gz", CopyOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b.gz"}, s3api.CopiedKeys())
+	assert.Equal(t, "src", aws.StringValue(s3api.LastDeleteObjectInput().Bucket))
+	assert.Equal(t, "a.gz", aws.StringValue(s3api.LastDeleteObjectInput().Key))
+
+	s3api = mock.S3APIBatch("bucket", nil, 100, "src/a.gz")
+	err = awsapi.MoveObject(context.Background(), s3api,
+		"src", "a.gz", "dst", "b.gz", CopyOptions{})
+	assert.EqualError(t, err, "AccessDenied: FakeCopyObjectError")
+	assert.Nil(t, s3api.LastDeleteObjectInput().Key)
+}
+
+func TestCopyPrefix(t *testing.T) {
+	awsapi := AWSAPI{}
+	s3api := mock.S3APIBatch("src", fakeTree, 0, "src/logs/2019/b.json")
+	result, err := awsapi.CopyPrefix(context.Background(), s3api,
+		"src", "logs/2019/", "dst", "archive/2019/", CopyOptions{Concurrency: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"logs/2019/a.gz"}, result.Succeeded)
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, "logs/2019/b.json", result.Errors[0].Key)
+	assert.Equal(t, "AccessDenied", result.Errors[0].Code)
+	assert.Equal(t, []string{"archive/2019/a.gz"}, s3api.CopiedKeys())
+
+	// objects are headed for their encryption which the listing lacks
+	s3api = mock.S3APIBatch("src", fakeTree, 0)
+	s3api.SetHeadObject(&s3.HeadObjectOutput{
+		ContentLength:        aws.Int64(10),
+		ServerSideEncryption: aws.String(s3.ServerSideEncryptionAwsKms),
+		SSEKMSKeyId:          aws.String("fake-key"),
+	})
+	_, err = awsapi.CopyPrefix(context.Background(), s3api,
+		"src", "logs/2019/", "dst", "archive/2019/", CopyOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(s3api.CopyObjectInputs()))
+	for _, input := range s3api.CopyObjectInputs() {
+		assert.Equal(t, "fake-key", aws.StringValue(input.SSEKMSKeyId))
+	}
+
+	_, err = awsapi.CopyPrefix(context.Background(),
+		mock.S3APIListObjectsTree("src", fakeTree, "logs/"),
+		"src", "logs/", "dst", "archive/", CopyOptions{})
+	assert.EqualError(t, err, "FakeListObjectsError")
+}
+
+func TestMovePrefix(t *testing.T) {
+	awsapi := AWSAPI{}
+	s3api := mock.S3APIBatch("src", fakeTree, 0, "src/logs/2019/b.json")
+	result, err := awsapi.MovePrefix(context.Background(), s3api,
+		"src", "logs/2019/", "dst", "archive/2019/", CopyOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"logs/2019/a.gz"}, result.Succeeded)
+	assert.Equal(t, []string{"logs/2019/a.gz"}, s3api.DeletedKeys())
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, "logs/2019/b.json", result.Errors[0].Key)
+}
+
+func TestErrorCode(t *testing.T) {
+	assert.Equal(t, "Error", errorCode(errors.New("fake")))
+}
@@ -0,0 +1,104 @@
+package awsapi
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// ObjectHead defines the metadata of a s3 object
+type ObjectHead struct {
+	Bucket        string    `json:"bucket"`
+	Key           string    `json:"key"`
+	VersionID     string    `json:"version_id"`
+	ContentType   string    `json:"content_type"`
+	ContentLength int64     `json:"content_length"`
+	ETag          string    `json:"etag"`
+	LastModified  time.Time `json:"last_modified"`
+	StorageClass  string    `json:"storage_class"`
+	// Metadata holds x-amz-meta-* headers w/ lower case keys
+	Metadata              map[string]string `json:"metadata"`
+	ServerSideEncryption  string            `json:"server_side_encryption"`
+	SSEKMSKeyID           string            `json:"sse_kms_key_id"`
+	ObjectLockMode        string            `json:"object_lock_mode"`
+	ObjectLockRetainUntil time.Time         `json:"object_lock_retain_until"`
+	ObjectLockLegalHold   bool              `json:"object_lock_legal_hold"`
+}
+
+func newObjectHead(bucket, key string, output *s3.HeadObjectOutput) *ObjectHead {
+	head := &ObjectHead{
+		Bucket:                bucket,
+		Key:                   key,
+		VersionID:             aws.StringValue(output.VersionId),
+		ContentType:           aws.StringValue(output.ContentType),
+		ContentLength:         aws.Int64Value(output.ContentLength),
+		ETag:                  strings.Trim(aws.StringValue(output.ETag), `"`),
+		LastModified:          aws.TimeValue(output.LastModified),
+		StorageClass:          aws.StringValue(output.StorageClass),
+		Metadata:              make(map[string]string),
+		ServerSideEncryption:  aws.StringValue(output.ServerSideEncryption),
+		SSEKMSKeyID:           aws.StringValue(output.SSEKMSKeyId),
+		ObjectLockMode:        aws.StringValue(output.ObjectLockMode),
+		ObjectLockRetainUntil: aws.TimeValue(output.ObjectLockRetainUntilDate),
+		ObjectLockLegalHold: aws.StringValue(output.ObjectLockLegalHoldStatus) ==
+			s3.ObjectLockLegalHoldStatusOn,
+	}
+	// the sdk canonicalizes header names, e.g. X-Amz-Meta-Source => Source
+	for name, value := range output.Metadata {
+		head.Metadata[strings.ToLower(name)] = aws.StringValue(value)
+	}
+	if head.StorageClass == "" {
+		head.StorageClass = s3.StorageClassStandard
+	}
+	return head
+}
+
+// HeadObject returns the metadata of a s3 object
+func (awsapi *AWSAPI) HeadObject(svc s3iface.S3API, bucket string, key string) (*ObjectHead, error) {
+	return awsapi.HeadObjectWithContext(aws.BackgroundContext(), svc, bucket, key)
+}
+
+// HeadObjectWithContext is HeadObject w/ a context and request options
+func (awsapi *AWSAPI) HeadObjectWithContext(ctx context.Context, svc s3iface.S3API,
+	bucket string, key string, opts ...request.Option) (*ObjectHead, error) {
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	output, err := svc.HeadObjectWithContext(ctx, input, opts...)
+	if err != nil {
+		log.Printf("AWSError: Cannot head object s3://%s/%s, err: %+v", bucket, key, err)
+		return nil, err
+	}
+	return newObjectHead(bucket, key, output), nil
+}
+
+// GetObjectTagging returns the tags of a s3 object
+func (awsapi *AWSAPI) GetObjectTagging(svc s3iface.S3API, bucket string, key string) (map[string]string, error) {
+	return awsapi.GetObjectTaggingWithContext(aws.BackgroundContext(), svc, bucket, key)
+}
+
+// GetObjectTaggingWithContext is GetObjectTagging w/ a context and request options
+func (awsapi *AWSAPI) GetObjectTaggingWithContext(ctx context.Context, svc s3iface.S3API,
+	bucket string, key string, opts ...request.Option) (map[string]string, error) {
+	input := &s3.GetObjectTaggingInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	output, err := svc.GetObjectTaggingWithContext(ctx, input, opts...)
+	if err != nil {
+		log.Printf("AWSError: Cannot get tags of s3://%s/%s, err: %+v", bucket, key, err)
+		return nil, err
+	}
+	tags := make(map[string]string)
+	for _, tag := range output.TagSet {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags, nil
+}
@@ -0,0 +1,51 @@
+package awsapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+func TestHeadObject(t *testing.T) {
+	awsapi := AWSAPI{}
+	retainUntil := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	s3api := mock.S3APIHeadObject("vid",
+		map[string]string{"Source": "audit"}, "fake-key", retainUntil)
+
+	head, err := awsapi.HeadObject(s3api, "bucket", "key")
+	assert.Nil(t, err)
+	assert.Equal(t, &ObjectHead{
+		Bucket:                "bucket",
+		Key:                   "key",
+		VersionID:             "vid",
+		ContentType:           "text/plain",
+		ContentLength:         7,
+		ETag:                  "fake-etag",
+		LastModified:          retainUntil.AddDate(0, 0, -1),
+		StorageClass:          "STANDARD",
+		Metadata:              map[string]string{"source": "audit"},
+		ServerSideEncryption:  "aws:kms",
+		SSEKMSKeyID:           "fake-key",
+		ObjectLockMode:        "GOVERNANCE",
+		ObjectLockRetainUntil: retainUntil,
+		ObjectLockLegalHold:   true,
+	}, head)
+
+	head, err = awsapi.HeadObject(mock.S3APIHeadObjectError(), "bucket", "key")
+	assert.Nil(t, head)
+	assert.EqualError(t, err, "FakeHeadObjectError")
+}
+
+func TestGetObjectTagging(t *testing.T) {
+	awsapi := AWSAPI{}
+	tags := map[string]string{"retention": "1y", "team": "sec"}
+	result, err := awsapi.GetObjectTagging(mock.S3APIGetObjectTagging(tags), "bucket", "key")
+	assert.Nil(t, err)
+	assert.Equal(t, tags, result)
+
+	result, err = awsapi.GetObjectTagging(mock.S3APIGetObjectTaggingError(), "bucket", "key")
+	assert.Nil(t, result)
+	assert.EqualError(t, err, "FakeGetObjectTaggingError")
+}
@@ -0,0 +1,260 @@
+package awsapi
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+const (
+	deDelimiter        = "/"
+	deWalkConcurrency  = 8
+	deListObjectsCount = maxsize
+)
+
+// ObjectInfo defines a listed s3 object
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"last_modified"`
+	StorageClass string    `json:"storage_class"`
+}
+
+func newObjectInfo(obj *s3.Object) ObjectInfo {
+	return ObjectInfo{
+		Key:          aws.StringValue(obj.Key),
+		Size:         aws.Int64Value(obj.Size),
+		ETag:         strings.Trim(aws.StringValue(obj.ETag), `"`),
+		LastModified: aws.TimeValue(obj.LastModified),
+		StorageClass: aws.StringValue(obj.StorageClass),
+	}
+}
+
+// ObjectFilter returns true to keep an object in a listing
+type ObjectFilter func(*ObjectInfo) bool
+
+// FilterSuffix keeps objects w/ a key suffix
+func FilterSuffix(suffix string) ObjectFilter {
+	return func(obj *ObjectInfo) bool {
+		return strings.HasSuffix(obj.Key, suffix)
+	}
+}
+
+// FilterModifiedSince keeps objects modified after t
+func FilterModifiedSince(t time.Time) ObjectFilter {
+	return func(obj *ObjectInfo) bool {
+		return obj.LastModified.After(t)
+	}
+}
+
+// FilterMinSize keeps objects of at least size bytes
+func FilterMinSize(size int64) ObjectFilter {
+	return func(obj *ObjectInfo) bool {
+		return obj.Size >= size
+	}
+}
+
+// FilterStorageClass keeps objects of a storage class
+func FilterStorageClass(class string) ObjectFilter {
+	return func(obj *ObjectInfo) bool {
+		return obj.StorageClass == class
+	}
+}
+
+// ListOptions defines options of a rich listing
+type ListOptions struct {
+	Prefix string
+	// Delimiter groups keys into CommonPrefixes, e.g. "/" lists a "directory"
+	Delimiter  string
+	StartAfter string
+	// MaxKeys is the size of a page, 1000 if 0
+	MaxKeys int
+	// Filters are applied to objects, all of them must match
+	Filters        []ObjectFilter
+	RequestOptions []request.Option
+}
+
+func (opts *ListOptions) match(obj *ObjectInfo) bool {
+	for _, filter := range opts.Filters {
+		if !filter(obj) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListResult holds objects and common prefixes of a listing
+type ListResult struct {
+	Objects        []ObjectInfo `json:"objects"`
+	CommonPrefixes []string     `json:"common_prefixes"`
+}
+
+// listPages calls fn w/ every page of a listing
+func (awsapi *AWSAPI) listPages(ctx context.Context, svc s3iface.S3API,
+	bucket string, opts *ListOptions, fn func(*ListResult) error) error {
+	maxKeys := opts.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = deListObjectsCount
+	}
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(opts.Prefix),
+		MaxKeys: aws.Int64(int64(maxKeys)),
+	}
+	if opts.Delimiter != "" {
+		input.Delimiter = aws.String(opts.Delimiter)
+	}
+	if opts.StartAfter != "" {
+		input.StartAfter = aws.String(opts.StartAfter)
+	}
+	for {
+		result, err := svc.ListObjectsV2WithContext(ctx, input, opts.RequestOptions...)
+		if err != nil {
+			log.Printf("AWSError: Cannot list objects from s3://%s/%s, err: %+v",
+				bucket, opts.Prefix, err)
+			return err
+		}
+		page := &ListResult{
+			Objects:        make([]ObjectInfo, 0, len(result.Contents)),
+			CommonPrefixes: make([]string, 0, len(result.CommonPrefixes)),
+		}
+		for _, obj := range result.Contents {
+			info := newObjectInfo(obj)
+			if opts.match(&info) {
+				page.Objects = append(page.Objects, info)
+			}
+		}
+		for _, prefix := range result.CommonPrefixes {
+			page.CommonPrefixes = append(page.CommonPrefixes, aws.StringValue(prefix.Prefix))
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if !aws.BoolValue(result.IsTruncated) || result.NextContinuationToken == nil {
+			return nil
+		}
+		input.ContinuationToken = result.NextContinuationToken
+	}
+}
+
+// ListObjectInfos returns all objects and common prefixes of a listing
+func (awsapi *AWSAPI) ListObjectInfos(svc s3iface.S3API, bucket string,
+	opts ListOptions) (*ListResult, error) {
+	return awsapi.ListObjectInfosWithContext(aws.BackgroundContext(), svc, bucket, opts)
+}
+
+// ListObjectInfosWithContext is ListObjectInfos w/ a context
+func (awsapi *AWSAPI) ListObjectInfosWithContext(ctx context.Context,
+	svc s3iface.S3API, bucket string, opts ListOptions) (*ListResult, error) {
+	found := &ListResult{
+		Objects:        make([]ObjectInfo, 0),
+		CommonPrefixes: make([]string, 0),
+	}
+	err := awsapi.listPages(ctx, svc, bucket, &opts, func(page *ListResult) error {
+		found.Objects = append(found.Objects, page.Objects...)
+		found.CommonPrefixes = append(found.CommonPrefixes, page.CommonPrefixes...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// WalkFunc is called w/ every object of a walk,
+// calls are serialized and an error stops the walk
+type WalkFunc func(obj ObjectInfo) error
+
+// WalkOptions defines options of a concurrent walk
+type WalkOptions struct {
+	// Delimiter splits keys into prefixes listed concurrently, "/" if empty
+	Delimiter string
+	// Concurrency limits the number of concurrent list requests, 8 if 0
+	Concurrency    int
+	Filters        []ObjectFilter
+	RequestOptions []request.Option
+}
+
+// WalkObjects lists all objects under a prefix, fanning out over
+// common prefixes concurrently. Objects are not visited in key order.
+func (awsapi *AWSAPI) WalkObjects(ctx context.Context, svc s3iface.S3API,
+	bucket string, prefix string, opts WalkOptions, fn WalkFunc) error {
+	if opts.Delimiter == "" {
+		opts.Delimiter = deDelimiter
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = deWalkConcurrency
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, opts.Concurrency)
+	fail := func(err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	visit := func(page *ListResult) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if firstErr != nil {
+			return firstErr
+		}
+		for _, obj := range page.Objects {
+			if err := fn(obj); err != nil {
+				firstErr = err
+				cancel()
+				return err
+			}
+		}
+		return nil
+	}
+
+	var walk func(prefix string)
+	walk = func(prefix string) {
+		defer wg.Done()
+		listOpts := &ListOptions{
+			Prefix:         prefix,
+			Delimiter:      opts.Delimiter,
+			Filters:        opts.Filters,
+			RequestOptions: opts.RequestOptions,
+		}
+		// children are listed after the semaphore is released,
+		// so a deep tree cannot exhaust the slots
+		prefixes := make([]string, 0)
+		sem <- struct{}{}
+		err := awsapi.listPages(ctx, svc, bucket, listOpts, func(page *ListResult) error {
+			prefixes = append(prefixes, page.CommonPrefixes...)
+			return visit(page)
+		})
+		<-sem
+		if err != nil {
+			fail(err)
+			return
+		}
+		for _, child := range prefixes {
+			wg.Add(1)
+			go walk(child)
+		}
+	}
+	wg.Add(1)
+	go walk(prefix)
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,109 @@
+package awsapi
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+var fakeTree = map[string][]string{
+	"logs/":            {"2019/", "2020/", "index.json"},
+	"logs/2019/":       {"01/", "a.gz", "b.json"},
+	"logs/2019/01/":    {"c.gz"},
+	"logs/2020/":       {"d.gz", "empty/"},
+	"logs/2020/empty/": {},
+}
+
+func TestListObjectInfos(t *testing.T) {
+	awsapi := AWSAPI{}
+	s3api := mock.S3APIListObjectsTree("bucket", fakeTree)
+
+	result, err := awsapi.ListObjectInfos(s3api, "bucket",
+		ListOptions{Prefix: "logs/2019/", Delimiter: "/"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"logs/2019/01/"}, result.CommonPrefixes)
+	assert.Equal(t, 2, len(result.Objects))
+	assert.Equal(t, ObjectInfo{
+		Key: "logs/2019/a.gz", Size: 4, ETag: "etag-a.gz",
+		LastModified: time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC),
+		StorageClass: "STANDARD",
+	}, result.Objects[0])
+
+	result, err = awsapi.ListObjectInfos(s3api, "bucket", ListOptions{
+		Prefix: "logs/2019/", Delimiter: "/",
+		Filters: []ObjectFilter{FilterSuffix(".json"), FilterMinSize(6)},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(result.Objects))
+	assert.Equal(t, "logs/2019/b.json", result.Objects[0].Key)
+
+	_, err = awsapi.ListObjectInfos(mock.S3APIListObjectsTree("bucket", fakeTree, "logs/"),
+		"bucket", ListOptions{Prefix: "logs/"})
+	assert.EqualError(t, err, "FakeListObjectsError")
+}
+
+func TestListObjectInfosInput(t *testing.T) {
+	awsapi := AWSAPI{}
+	s3api := mock.S3APIListObjects("bucket", "prefix", []string{"a", "b"})
+	result, err := awsapi.ListObjectInfos(s3api, "bucket", ListOptions{
+		Prefix: "prefix/", Delimiter: "/", StartAfter: "prefix/a", MaxKeys: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(result.Objects))
+	input := s3api.LastListObjectsInput()
+	assert.Equal(t, "/", aws.StringValue(input.Delimiter))
+	assert.Equal(t, "prefix/a", aws.StringValue(input.StartAfter))
+	assert.Equal(t, int64(10), aws.Int64Value(input.MaxKeys))
+}
+
+func TestObjectFilters(t *testing.T) {
+	now := time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)
+	obj := &ObjectInfo{Key: "a.gz", Size: 10, LastModified: now, StorageClass: "GLACIER"}
+	assert.True(t, FilterSuffix(".gz")(obj))
+	assert.False(t, FilterSuffix(".json")(obj))
+	assert.True(t, FilterModifiedSince(now.Add(-time.Hour))(obj))
+	assert.False(t, FilterModifiedSince(now)(obj))
+	assert.True(t, FilterMinSize(10)(obj))
+	assert.False(t, FilterMinSize(11)(obj))
+	assert.True(t, FilterStorageClass("GLACIER")(obj))
+	assert.False(t, FilterStorageClass("STANDARD")(obj))
+}
+
+func TestWalkObjects(t *testing.T) {
+	awsapi := AWSAPI{}
+	s3api := mock.S3APIListObjectsTree("bucket", fakeTree)
+
+	keys := make([]string, 0)
+	err := awsapi.WalkObjects(context.Background(), s3api, "bucket", "logs/",
+		WalkOptions{Concurrency: 2, Filters: []ObjectFilter{FilterSuffix(".gz")}},
+		func(obj ObjectInfo) error {
+			keys = append(keys, obj.Key)
+			return nil
+		})
+	assert.Nil(t, err)
+	sort.Strings(keys)
+	assert.Equal(t, []string{"logs/2019/01/c.gz", "logs/2019/a.gz", "logs/2020/d.gz"}, keys)
+}
+
+func TestWalkObjectsError(t *testing.T) {
+	awsapi := AWSAPI{}
+	s3api := mock.S3APIListObjectsTree("bucket", fakeTree, "logs/2019/01/")
+	err := awsapi.WalkObjects(context.Background(), s3api, "bucket", "logs/",
+		WalkOptions{}, func(obj ObjectInfo) error { return nil })
+	assert.EqualError(t, err, "FakeListObjectsError")
+
+	s3api = mock.S3APIListObjectsTree("bucket", fakeTree)
+	count := 0
+	err = awsapi.WalkObjects(context.Background(), s3api, "bucket", "logs/",
+		WalkOptions{}, func(obj ObjectInfo) error {
+			count++
+			return errors.New("FakeWalkError")
+		})
+	assert.EqualError(t, err, "FakeWalkError")
+	assert.Equal(t, 1, count)
+}
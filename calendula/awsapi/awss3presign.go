@@ -0,0 +1,317 @@
+package awsapi
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/xinnige/asteraceae/calendula/utils"
+)
+
+const (
+	dePresignExpires  = 15 * time.Minute
+	maxPresignExpires = 7 * 24 * time.Hour
+
+	postAlgorithm  = "AWS4-HMAC-SHA256"
+	postDateFormat = "20060102T150405Z"
+	postDayFormat  = "20060102"
+)
+
+// PresignOptions defines options of a presigned url
+type PresignOptions struct {
+	// Expires is the lifetime of the url, 15 minutes if 0 and at most 7 days
+	Expires   time.Duration
+	VersionID string
+	// ContentDisposition overrides the Content-Disposition of a GET response,
+	// e.g. `attachment; filename="evidence.tar.gz"`
+	ContentDisposition string
+	// ContentType overrides the Content-Type of a GET response,
+	// or is the Content-Type a PUT must be sent w/
+	ContentType string
+	// ContentMD5 is the base64 md5 a PUT body must match, see ContentMD5
+	ContentMD5 string
+}
+
+func (opts *PresignOptions) expires() (time.Duration, error) {
+	if opts.Expires <= 0 {
+		return dePresignExpires, nil
+	}
+	if opts.Expires > maxPresignExpires {
+		return 0, fmt.Errorf("presign expiry %s exceeds %s", opts.Expires, maxPresignExpires)
+	}
+	return opts.Expires, nil
+}
+
+// ContentMD5 returns the base64 md5 of content as a Content-MD5 header
+func ContentMD5(content []byte) string {
+	sum := md5.Sum(content)
+	return utils.EncodeBase64(sum[:])
+}
+
+// PresignGetObject returns a url to download an object w/o credentials
+func (awsapi *AWSAPI) PresignGetObject(svc s3iface.S3API, bucket string,
+	key string, opts PresignOptions) (string, error) {
+	expires, err := opts.expires()
+	if err != nil {
+		return "", err
+	}
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if opts.VersionID != "" {
+		input.VersionId = aws.String(opts.VersionID)
+	}
+	if opts.ContentDisposition != "" {
+		input.ResponseContentDisposition = aws.String(opts.ContentDisposition)
+	}
+	if opts.ContentType != "" {
+		input.ResponseContentType = aws.String(opts.ContentType)
+	}
+	req, _ := svc.GetObjectRequest(input)
+	presigned, err := req.Presign(expires)
+	if err != nil {
+		log.Printf("AWSError: Cannot presign GET s3://%s/%s, err: %+v", bucket, key, err)
+		return "", err
+	}
+	return presigned, nil
+}
+
+// PresignPutObject returns a url to upload an object w/o credentials,
+// and the headers the upload must be sent w/ (e.g. Content-MD5)
+func (awsapi *AWSAPI) PresignPutObject(svc s3iface.S3API, bucket string,
+	key string, opts PresignOptions) (string, http.Header, error) {
+	expires, err := opts.expires()
+	if err != nil {
+		return "", nil, err
+	}
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	if opts.ContentMD5 != "" {
+		input.ContentMD5 = aws.String(opts.ContentMD5)
+	}
+	req, _ := svc.PutObjectRequest(input)
+	presigned, signed, err := req.PresignRequest(expires)
+	if err != nil {
+		log.Printf("AWSError: Cannot presign PUT s3://%s/%s, err: %+v", bucket, key, err)
+		return "", nil, err
+	}
+	// the signer returns lower case names, canonicalize them for Header.Get
+	header := make(http.Header)
+	for name, values := range signed {
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+	return presigned, header, nil
+}
+
+// PostPolicy builds a presigned POST policy for browser uploads
+type PostPolicy struct {
+	Bucket string
+	// Key is the exact key to upload, or a prefix of keys if KeyPrefix is set,
+	// the form key of a prefix is "<prefix>${filename}" and s3 replaces
+	// ${filename} by the name of the uploaded file
+	Key       string
+	KeyPrefix bool
+	// Expires is the lifetime of the policy, 15 minutes if 0 and at most 7 days
+	Expires time.Duration
+	// ContentType is the exact Content-Type of uploads if not empty
+	ContentType string
+	// MinSize and MaxSize limit the size of uploads if MaxSize is not 0
+	MinSize int64
+	MaxSize int64
+	// SuccessActionStatus is the status of a succeeded upload, e.g. "201"
+	SuccessActionStatus  string
+	ACL                  string
+	ServerSideEncryption string
+	Metadata             map[string]string
+	// Endpoint is the s3 endpoint of the form url, e.g. of LocalStack/MinIO,
+	// the regional endpoint of the partition of the region if empty
+	Endpoint string
+	// PathStyle puts the bucket in the path of the url instead of the host
+	PathStyle bool
+}
+
+// PresignedPost defines the url and the form fields of a POST upload,
+// the file field must be the last field of the form
+type PresignedPost struct {
+	URL    string            `json:"url"`
+	Fields map[string]string `json:"fields"`
+}
+
+// s3Endpoint returns the regional s3 endpoint of a region,
+// e.g. https://s3.cn-north-1.amazonaws.com.cn
+func s3Endpoint(region string) (string, error) {
+	partition, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region)
+	if ok && partition.ID() == endpoints.AwsPartitionID {
+		// the global endpoint of us-east-1 redirects posts of other regions
+		return fmt.Sprintf("https://s3.%s.amazonaws.com", region), nil
+	}
+	resolved, err := endpoints.DefaultResolver().EndpointFor(endpoints.S3ServiceID, region)
+	if err != nil {
+		return "", err
+	}
+	return resolved.URL, nil
+}
+
+// postURL returns the virtual hosted url of the bucket of a policy,
+// buckets w/ dots use the path style to match the certificate
+func (policy *PostPolicy) postURL(region string) (string, error) {
+	endpoint := policy.Endpoint
+	if endpoint == "" {
+		var err error
+		if endpoint, err = s3Endpoint(region); err != nil {
+			return "", err
+		}
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return "", fmt.Errorf("invalid s3 endpoint %q", endpoint)
+	}
+	if policy.PathStyle || strings.Contains(policy.Bucket, ".") {
+		parsed.Path = strings.TrimSuffix(parsed.Path, "/") + "/" + policy.Bucket
+		return parsed.String(), nil
+	}
+	parsed.Host = policy.Bucket + "." + parsed.Host
+	parsed.Path = "/"
+	return parsed.String(), nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	hash := hmac.New(sha256.New, key)
+	hash.Write([]byte(data))
+	return hash.Sum(nil)
+}
+
+// signingKey derives a sigv4 signing key
+func signingKey(secret, day, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+// Sign returns the signed form of the policy w/ credentials at a time
+func (policy *PostPolicy) Sign(value credentials.Value, region string,
+	now time.Time) (*PresignedPost, error) {
+	if policy.Bucket == "" || policy.Key == "" {
+		return nil, fmt.Errorf("bucket and key of a post policy cannot be empty")
+	}
+	if policy.MinSize < 0 || policy.MaxSize > 0 && policy.MaxSize < policy.MinSize {
+		return nil, fmt.Errorf("invalid content length range %d-%d", policy.MinSize, policy.MaxSize)
+	}
+	expires, err := (&PresignOptions{Expires: policy.Expires}).expires()
+	if err != nil {
+		return nil, err
+	}
+	postURL, err := policy.postURL(region)
+	if err != nil {
+		return nil, err
+	}
+	now = now.UTC()
+	day := now.Format(postDayFormat)
+	fields := map[string]string{
+		"x-amz-algorithm": postAlgorithm,
+		"x-amz-credential": fmt.Sprintf("%s/%s/%s/s3/aws4_request",
+			value.AccessKeyID, day, region),
+		"x-amz-date": now.Format(postDateFormat),
+	}
+	if value.SessionToken != "" {
+		fields["x-amz-security-token"] = value.SessionToken
+	}
+	if policy.ContentType != "" {
+		fields["Content-Type"] = policy.ContentType
+	}
+	if policy.SuccessActionStatus != "" {
+		fields["success_action_status"] = policy.SuccessActionStatus
+	}
+	if policy.ACL != "" {
+		fields["acl"] = policy.ACL
+	}
+	if policy.ServerSideEncryption != "" {
+		fields["x-amz-server-side-encryption"] = policy.ServerSideEncryption
+	}
+	for name, value := range policy.Metadata {
+		fields["x-amz-meta-"+strings.ToLower(name)] = value
+	}
+
+	conditions := []interface{}{map[string]string{"bucket": policy.Bucket}}
+	if policy.KeyPrefix {
+		conditions = append(conditions, []string{"starts-with", "$key", policy.Key})
+	} else {
+		conditions = append(conditions, map[string]string{"key": policy.Key})
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		conditions = append(conditions, map[string]string{name: fields[name]})
+	}
+	if policy.MaxSize > 0 {
+		conditions = append(conditions,
+			[]interface{}{"content-length-range", policy.MinSize, policy.MaxSize})
+	}
+	document := map[string]interface{}{
+		"expiration": now.Add(expires).Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	}
+	raw, err := (&utils.JSONAPI{}).Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	encoded := utils.EncodeBase64(raw)
+	key := signingKey(value.SecretAccessKey, day, region, "s3")
+
+	fields["key"] = policy.Key
+	if policy.KeyPrefix {
+		fields["key"] = policy.Key + "${filename}"
+	}
+	fields["policy"] = encoded
+	fields["x-amz-signature"] = hex.EncodeToString(hmacSHA256(key, encoded))
+	return &PresignedPost{URL: postURL, Fields: fields}, nil
+}
+
+// PresignPost signs a post policy w/ credentials of a region
+func (awsapi *AWSAPI) PresignPost(creds *credentials.Credentials, region string,
+	policy *PostPolicy) (*PresignedPost, error) {
+	value, err := creds.Get()
+	if err != nil {
+		log.Printf("AWSError: Cannot get credentials to presign POST s3://%s/%s, err: %+v",
+			policy.Bucket, policy.Key, err)
+		return nil, err
+	}
+	return policy.Sign(value, region, time.Now())
+}
+
+// PresignPostWithClient signs a post policy w/ the credentials, region,
+// endpoint and path style of a s3 client, the endpoint and path style
+// of the policy take precedence if set
+func (awsapi *AWSAPI) PresignPostWithClient(svc *s3.S3, policy *PostPolicy) (*PresignedPost, error) {
+	signed := *policy
+	if signed.Endpoint == "" {
+		signed.Endpoint = svc.Endpoint
+	}
+	signed.PathStyle = signed.PathStyle || aws.BoolValue(svc.Config.S3ForcePathStyle)
+	return awsapi.PresignPost(svc.Config.Credentials, aws.StringValue(svc.Config.Region), &signed)
+}
@@ -0,0 +1,189 @@
+package awsapi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+	"github.com/xinnige/asteraceae/calendula/utils"
+)
+
+func TestPresignGetObject(t *testing.T) {
+	awsapi := AWSAPI{}
+	presigned, err := awsapi.PresignGetObject(mock.S3APIPresign("ap-northeast-1"),
+		"bucket", "evidence/2019/audit.tar.gz", PresignOptions{
+			Expires:            time.Hour,
+			VersionID:          "vid",
+			ContentDisposition: `attachment; filename="audit.tar.gz"`,
+		})
+	assert.Nil(t, err)
+	parsed, err := url.Parse(presigned)
+	assert.Nil(t, err)
+	assert.Equal(t, "bucket.s3.ap-northeast-1.amazonaws.com", parsed.Host)
+	assert.Equal(t, "/evidence/2019/audit.tar.gz", parsed.Path)
+	query := parsed.Query()
+	assert.Equal(t, "3600", query.Get("X-Amz-Expires"))
+	assert.Equal(t, "vid", query.Get("versionId"))
+	assert.Equal(t, `attachment; filename="audit.tar.gz"`,
+		query.Get("response-content-disposition"))
+	assert.Contains(t, query.Get("X-Amz-Credential"), "AKID/")
+	assert.NotEqual(t, "", query.Get("X-Amz-Signature"))
+
+	// default expiry
+	presigned, err = awsapi.PresignGetObject(mock.S3APIPresign("us-east-1"),
+		"bucket", "key", PresignOptions{})
+	assert.Nil(t, err)
+	parsed, _ = url.Parse(presigned)
+	assert.Equal(t, "900", parsed.Query().Get("X-Amz-Expires"))
+
+	_, err = awsapi.PresignGetObject(mock.S3APIPresign("us-east-1"),
+		"bucket", "key", PresignOptions{Expires: 8 * 24 * time.Hour})
+	assert.EqualError(t, err, "presign expiry 192h0m0s exceeds 168h0m0s")
+	_, err = awsapi.PresignGetObject(mock.S3APIPresignError(),
+		"bucket", "key", PresignOptions{})
+	assert.EqualError(t, err, "FakePresignError")
+}
+
+func TestPresignPutObject(t *testing.T) {
+	awsapi := AWSAPI{}
+	md5 := ContentMD5([]byte("evidence"))
+	assert.Equal(t, 24, len(md5))
+
+	presigned, header, err := awsapi.PresignPutObject(mock.S3APIPresign("us-west-2"),
+		"bucket", "upload/report.json", PresignOptions{
+			ContentType: "application/json",
+			ContentMD5:  md5,
+		})
+	assert.Nil(t, err)
+	parsed, err := url.Parse(presigned)
+	assert.Nil(t, err)
+	assert.Equal(t, "/upload/report.json", parsed.Path)
+	assert.Contains(t, parsed.Query().Get("X-Amz-SignedHeaders"), "content-md5")
+	assert.Equal(t, md5, header.Get("Content-Md5"))
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+
+	_, _, err = awsapi.PresignPutObject(mock.S3APIPresign("us-east-1"),
+		"bucket", "key", PresignOptions{Expires: 8 * 24 * time.Hour})
+	assert.NotNil(t, err)
+	_, _, err = awsapi.PresignPutObject(mock.S3APIPresignError(),
+		"bucket", "key", PresignOptions{})
+	assert.EqualError(t, err, "FakePresignError")
+}
+
+func TestSigningKey(t *testing.T) {
+	// the example of the sigv4 signing key derivation in aws docs
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	assert.Equal(t, "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d",
+		hex.EncodeToString(key))
+}
+
+func TestPostPolicySign(t *testing.T) {
+	now := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	value := credentials.Value{AccessKeyID: "AKID", SecretAccessKey: "SECRET", SessionToken: "TOKEN"}
+	policy := &PostPolicy{
+		Bucket:               "bucket",
+		Key:                  "uploads/",
+		KeyPrefix:            true,
+		Expires:              time.Hour,
+		ContentType:          "image/png",
+		MaxSize:              1 << 20,
+		SuccessActionStatus:  "201",
+		ServerSideEncryption: "AES256",
+		Metadata:             map[string]string{"Uploader": "slack"},
+	}
+	post, err := policy.Sign(value, "ap-northeast-1", now)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://bucket.s3.ap-northeast-1.amazonaws.com/", post.URL)
+	assert.Equal(t, "uploads/${filename}", post.Fields["key"])
+	assert.Equal(t, "AKID/20190701/ap-northeast-1/s3/aws4_request", post.Fields["x-amz-credential"])
+	assert.Equal(t, "20190701T120000Z", post.Fields["x-amz-date"])
+	assert.Equal(t, "TOKEN", post.Fields["x-amz-security-token"])
+	assert.Equal(t, "slack", post.Fields["x-amz-meta-uploader"])
+
+	expected := hex.EncodeToString(hmacSHA256(
+		signingKey("SECRET", "20190701", "ap-northeast-1", "s3"), post.Fields["policy"]))
+	assert.Equal(t, expected, post.Fields["x-amz-signature"])
+
+	document := struct {
+		Expiration string        `json:"expiration"`
+		Conditions []interface{} `json:"conditions"`
+	}{}
+	assert.Nil(t, json.Unmarshal(utils.DecodeBase64(post.Fields["policy"]), &document))
+	assert.Equal(t, "2019-07-01T13:00:00.000Z", document.Expiration)
+	assert.Equal(t, map[string]interface{}{"bucket": "bucket"}, document.Conditions[0])
+	assert.Equal(t, []interface{}{"starts-with", "$key", "uploads/"}, document.Conditions[1])
+	assert.Contains(t, document.Conditions, map[string]interface{}{"Content-Type": "image/png"})
+	assert.Contains(t, document.Conditions, map[string]interface{}{"x-amz-meta-uploader": "slack"})
+	assert.Equal(t, []interface{}{"content-length-range", float64(0), float64(1 << 20)},
+		document.Conditions[len(document.Conditions)-1])
+
+	// dotted buckets use the path style
+	post, err = (&PostPolicy{Bucket: "evidence.example", Key: "a.txt"}).Sign(value, "us-east-1", now)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://s3.us-east-1.amazonaws.com/evidence.example", post.URL)
+	assert.Equal(t, "a.txt", post.Fields["key"])
+
+	_, err = (&PostPolicy{Bucket: "bucket"}).Sign(value, "us-east-1", now)
+	assert.EqualError(t, err, "bucket and key of a post policy cannot be empty")
+	_, err = (&PostPolicy{Bucket: "bucket", Key: "a", MinSize: 10, MaxSize: 5}).Sign(value, "us-east-1", now)
+	assert.EqualError(t, err, "invalid content length range 10-5")
+	_, err = (&PostPolicy{Bucket: "bucket", Key: "a", Expires: 8 * 24 * time.Hour}).Sign(value, "us-east-1", now)
+	assert.NotNil(t, err)
+}
+
+func TestPresignPost(t *testing.T) {
+	awsapi := AWSAPI{}
+	post, err := awsapi.PresignPost(credentials.NewStaticCredentials("AKID", "SECRET", ""),
+		"us-east-1", &PostPolicy{Bucket: "bucket", Key: "key"})
+	assert.Nil(t, err)
+	assert.Equal(t, "key", post.Fields["key"])
+	_, ok := post.Fields["x-amz-security-token"]
+	assert.False(t, ok)
+
+	_, err = awsapi.PresignPost(credentials.NewStaticCredentials("", "", ""),
+		"us-east-1", &PostPolicy{Bucket: "bucket", Key: "key"})
+	assert.NotNil(t, err)
+}
+
+func TestPostPolicyEndpoint(t *testing.T) {
+	now := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	value := credentials.Value{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}
+	post, err := (&PostPolicy{Bucket: "bucket", Key: "a"}).Sign(value, "cn-north-1", now)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://bucket.s3.cn-north-1.amazonaws.com.cn/", post.URL)
+
+	post, err = (&PostPolicy{Bucket: "bucket", Key: "a", Endpoint: "http://localhost:4566",
+		PathStyle: true}).Sign(value, "us-east-1", now)
+	assert.Nil(t, err)
+	assert.Equal(t, "http://localhost:4566/bucket", post.URL)
+	post, err = (&PostPolicy{Bucket: "bucket", Key: "a",
+		Endpoint: "http://minio.local:9000"}).Sign(value, "us-east-1", now)
+	assert.Nil(t, err)
+	assert.Equal(t, "http://bucket.minio.local:9000/", post.URL)
+
+	_, err = (&PostPolicy{Bucket: "bucket", Key: "a",
+		Endpoint: "localhost:4566"}).Sign(value, "us-east-1", now)
+	assert.EqualError(t, err, `invalid s3 endpoint "localhost:4566"`)
+}
+
+func TestPresignPostWithClient(t *testing.T) {
+	awsapi := AWSAPI{}
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String("http://localhost:4566"),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("AKID", "SECRET", ""),
+	}))
+	post, err := awsapi.PresignPostWithClient(s3.New(sess), &PostPolicy{Bucket: "bucket", Key: "key"})
+	assert.Nil(t, err)
+	assert.Equal(t, "http://localhost:4566/bucket", post.URL)
+	assert.Contains(t, post.Fields["x-amz-credential"], "/us-east-1/s3/aws4_request")
+}
@@ -0,0 +1,264 @@
+package awsapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/xinnige/asteraceae/calendula/utils"
+)
+
+// NewS3DownloaderAPI returns a s3manager.Downloader pointer
+func NewS3DownloaderAPI(svc s3iface.S3API) s3manageriface.DownloaderAPI {
+	return s3manager.NewDownloaderWithClient(svc)
+}
+
+// ReadOptions defines options to read a s3 object
+type ReadOptions struct {
+	// VersionID reads a version instead of the current object
+	VersionID string
+	// Offset and Length select a byte range, a zero Length reads to the end
+	Offset int64
+	Length int64
+	// RequestOptions are applied to every request
+	RequestOptions []request.Option
+}
+
+// byteRange returns the http Range header, or "" for the whole object
+func (opts *ReadOptions) byteRange() string {
+	switch {
+	case opts.Length > 0:
+		return fmt.Sprintf("bytes=%d-%d", opts.Offset, opts.Offset+opts.Length-1)
+	case opts.Offset > 0:
+		return fmt.Sprintf("bytes=%d-", opts.Offset)
+	}
+	return ""
+}
+
+func (opts *ReadOptions) getObjectInput(bucket, key string) *s3.GetObjectInput {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if opts.VersionID != "" {
+		input.VersionId = aws.String(opts.VersionID)
+	}
+	if rng := opts.byteRange(); rng != "" {
+		input.Range = aws.String(rng)
+	}
+	return input
+}
+
+// GetObjectReader returns the body of a s3 object,
+// the caller must close the reader
+func (awsapi *AWSAPI) GetObjectReader(ctx context.Context, svc s3iface.S3API,
+	bucket string, key string, opts ReadOptions) (io.ReadCloser, error) {
+	result, err := svc.GetObjectWithContext(ctx,
+		opts.getObjectInput(bucket, key), opts.RequestOptions...)
+	if err != nil {
+		log.Printf("AWSError: Cannot get objects from s3://%s/%s, err: %+v", bucket, key, err)
+		return nil, err
+	}
+	log.Printf("Get a object from s3://%s/%s, VersionId %s\n",
+		bucket, key, aws.StringValue(result.VersionId))
+	return result.Body, nil
+}
+
+// DownloadOptions defines options of a concurrent multipart download
+type DownloadOptions struct {
+	ReadOptions
+	// PartSize and Concurrency default to the s3manager defaults if 0
+	PartSize    int64
+	Concurrency int
+}
+
+func (opts *DownloadOptions) apply(downloader *s3manager.Downloader) {
+	if opts.PartSize > 0 {
+		downloader.PartSize = opts.PartSize
+	}
+	if opts.Concurrency > 0 {
+		downloader.Concurrency = opts.Concurrency
+	}
+	downloader.RequestOptions = append(downloader.RequestOptions, opts.RequestOptions...)
+}
+
+// DownloadObject downloads a s3 object in parts concurrently to w,
+// a byte range is downloaded in a single request.
+// It returns the number of bytes downloaded.
+func (awsapi *AWSAPI) DownloadObject(ctx context.Context,
+	downloaderAPI s3manageriface.DownloaderAPI, w io.WriterAt,
+	bucket string, key string, opts DownloadOptions) (int64, error) {
+	size, err := downloaderAPI.DownloadWithContext(ctx, w,
+		opts.getObjectInput(bucket, key), opts.apply)
+	if err != nil {
+		log.Printf("AWSError: Cannot download object from s3://%s/%s, err: %+v", bucket, key, err)
+		return size, err
+	}
+	log.Printf("Downloaded a object from s3://%s/%s, size %d\n", bucket, key, size)
+	return size, nil
+}
+
+// UploadOptions defines options to upload a s3 object
+type UploadOptions struct {
+	// PartSize and Concurrency default to the s3manager defaults if 0
+	PartSize    int64
+	Concurrency int
+	ContentType string
+	// Metadata is sent as x-amz-meta-* headers
+	Metadata map[string]string
+	Tags     map[string]string
+	// ServerSideEncryption is AES256 or aws:kms,
+	// it defaults to aws:kms if SSEKMSKeyID is set
+	ServerSideEncryption string
+	SSEKMSKeyID          string
+	// ACL is a canned acl, e.g. private or bucket-owner-full-control
+	ACL string
+	// ObjectLockMode is GOVERNANCE or COMPLIANCE, and requires ObjectLockRetainUntil
+	ObjectLockMode        string
+	ObjectLockRetainUntil time.Time
+	ObjectLockLegalHold   bool
+	// RequestOptions are applied to every request of the upload
+	RequestOptions []request.Option
+}
+
+func (opts *UploadOptions) apply(uploader *s3manager.Uploader) {
+	if opts.PartSize > 0 {
+		uploader.PartSize = opts.PartSize
+	}
+	if opts.Concurrency > 0 {
+		uploader.Concurrency = opts.Concurrency
+	}
+	uploader.RequestOptions = append(uploader.RequestOptions, opts.RequestOptions...)
+}
+
+// Validate checks conflicting options
+func (opts *UploadOptions) Validate() error {
+	if opts.SSEKMSKeyID != "" && opts.ServerSideEncryption != "" &&
+		opts.ServerSideEncryption != s3.ServerSideEncryptionAwsKms {
+		return fmt.Errorf("kms key %s requires server side encryption %s, got %s",
+			opts.SSEKMSKeyID, s3.ServerSideEncryptionAwsKms, opts.ServerSideEncryption)
+	}
+	if (opts.ObjectLockMode == "") != opts.ObjectLockRetainUntil.IsZero() {
+		return fmt.Errorf("object lock mode and retain until date must be set together")
+	}
+	return nil
+}
+
+func (opts *UploadOptions) uploadInput(bucket, key string,
+	body io.Reader) *s3manager.UploadInput {
+	input := &s3manager.UploadInput{
+		Body:   body,
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	if len(opts.Metadata) != 0 {
+		input.Metadata = aws.StringMap(opts.Metadata)
+	}
+	if len(opts.Tags) != 0 {
+		input.Tagging = aws.String(EncodeTags(opts.Tags))
+	}
+	if opts.ServerSideEncryption != "" {
+		input.ServerSideEncryption = aws.String(opts.ServerSideEncryption)
+	}
+	if opts.SSEKMSKeyID != "" {
+		input.ServerSideEncryption = aws.String(s3.ServerSideEncryptionAwsKms)
+		input.SSEKMSKeyId = aws.String(opts.SSEKMSKeyID)
+	}
+	if opts.ACL != "" {
+		input.ACL = aws.String(opts.ACL)
+	}
+	if opts.ObjectLockMode != "" {
+		input.ObjectLockMode = aws.String(opts.ObjectLockMode)
+		input.ObjectLockRetainUntilDate = aws.Time(opts.ObjectLockRetainUntil)
+	}
+	if opts.ObjectLockLegalHold {
+		input.ObjectLockLegalHoldStatus = aws.String(s3.ObjectLockLegalHoldStatusOn)
+	}
+	return input
+}
+
+// EncodeTags returns tags as an url query sorted by key,
+// the format of x-amz-tagging (spaces are encoded as %20)
+func EncodeTags(tags map[string]string) string {
+	values := url.Values{}
+	for key, value := range tags {
+		values.Set(key, value)
+	}
+	return strings.Replace(values.Encode(), "+", "%20", -1)
+}
+
+// UploadObject uploads a stream as a s3 object,
+// large bodies are uploaded in parts concurrently
+func (awsapi *AWSAPI) UploadObject(ctx context.Context,
+	uploaderAPI s3manageriface.UploaderAPI, bucket string, key string,
+	body io.Reader, opts UploadOptions) (*s3manager.UploadOutput, error) {
+	if err := opts.Validate(); err != nil {
+		log.Printf("AWSError: Cannot upload object to s3://%s/%s, err: %+v", bucket, key, err)
+		return nil, err
+	}
+	result, err := uploaderAPI.UploadWithContext(ctx,
+		opts.uploadInput(bucket, key, body), opts.apply)
+	if err != nil {
+		log.Printf("AWSError: Cannot upload object to s3://%s/%s, err: %+v", bucket, key, err)
+		return nil, err
+	}
+	log.Printf("Upload a object to s3://%s/%s, VersionId %s",
+		bucket, key, aws.StringValue(result.VersionID))
+	return result, nil
+}
+
+// UploadObjectEncrypted encrypts a stream w/ utils.NewEncryptReader while
+// uploading it, so large archives are never held in memory. The aad is
+// authenticated but not uploaded and must be given again to decrypt.
+func (awsapi *AWSAPI) UploadObjectEncrypted(ctx context.Context,
+	uploaderAPI s3manageriface.UploaderAPI, bucket string, key string,
+	body io.Reader, encKey []byte, aad []byte,
+	opts UploadOptions) (*s3manager.UploadOutput, error) {
+	encrypted, err := utils.NewEncryptReader(body, encKey, aad, 0, &utils.CommonAPI{})
+	if err != nil {
+		log.Printf("Error: Cannot encrypt object to s3://%s/%s, err: %+v", bucket, key, err)
+		return nil, err
+	}
+	return awsapi.UploadObject(ctx, uploaderAPI, bucket, key, encrypted, opts)
+}
+
+// decryptReadCloser closes the body of a decrypted s3 object
+type decryptReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// GetObjectDecryptReader returns the decrypted body of a s3 object
+// uploaded by UploadObjectEncrypted, the caller must close the reader.
+// Byte ranges are not supported, the whole stream is authenticated.
+func (awsapi *AWSAPI) GetObjectDecryptReader(ctx context.Context, svc s3iface.S3API,
+	bucket string, key string, encKey []byte, aad []byte,
+	opts ReadOptions) (io.ReadCloser, error) {
+	if opts.byteRange() != "" {
+		return nil, fmt.Errorf("cannot decrypt a byte range of s3://%s/%s", bucket, key)
+	}
+	body, err := awsapi.GetObjectReader(ctx, svc, bucket, key, opts)
+	if err != nil {
+		return nil, err
+	}
+	decrypted, err := utils.NewDecryptReader(body, encKey, aad)
+	if err != nil {
+		body.Close()
+		log.Printf("Error: Cannot decrypt object from s3://%s/%s, err: %+v", bucket, key, err)
+		return nil, err
+	}
+	return decryptReadCloser{Reader: decrypted, Closer: body}, nil
+}
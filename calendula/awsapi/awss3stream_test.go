@@ -0,0 +1,211 @@
+package awsapi
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+	"github.com/xinnige/asteraceae/calendula/utils"
+)
+
+func TestNewDownloaderAPI(t *testing.T) {
+	sess := &AWSServiceSession{}
+	s3api := NewS3API(sess)
+	downloader := NewS3DownloaderAPI(s3api)
+	assert.NotNil(t, downloader)
+}
+
+func TestByteRange(t *testing.T) {
+	opts := ReadOptions{}
+	assert.Equal(t, "", opts.byteRange())
+	opts = ReadOptions{Offset: 10}
+	assert.Equal(t, "bytes=10-", opts.byteRange())
+	opts = ReadOptions{Offset: 10, Length: 5}
+	assert.Equal(t, "bytes=10-14", opts.byteRange())
+	opts = ReadOptions{Length: 1}
+	assert.Equal(t, "bytes=0-0", opts.byteRange())
+}
+
+func TestGetObjectReader(t *testing.T) {
+	awsapi := AWSAPI{}
+	mockS3API := mock.S3APIGetObjects([]string{"partial"}, "vid")
+
+	body, err := awsapi.GetObjectReader(context.Background(), mockS3API,
+		"bucket", "key", ReadOptions{Offset: 100, Length: 7})
+	assert.Nil(t, err)
+	defer body.Close()
+	content, err := ioutil.ReadAll(body)
+	assert.Nil(t, err)
+	assert.Equal(t, "partial", string(content))
+	input := mockS3API.LastGetObjectInput()
+	assert.Equal(t, "bucket", aws.StringValue(input.Bucket))
+	assert.Equal(t, "key", aws.StringValue(input.Key))
+	assert.Equal(t, "bytes=100-106", aws.StringValue(input.Range))
+
+	body, err = awsapi.GetObjectReader(context.Background(),
+		mock.S3APIGetObjectError(), "bucket", "key", ReadOptions{})
+	assert.Nil(t, body)
+	assert.EqualError(t, err, "FakeGetObjectError")
+}
+
+func TestDownloadObject(t *testing.T) {
+	awsapi := AWSAPI{}
+	downloader := mock.S3DownloaderAPIDownload("archive content")
+	buf := aws.NewWriteAtBuffer([]byte{})
+
+	size, err := awsapi.DownloadObject(context.Background(), downloader, buf,
+		"bucket", "key", DownloadOptions{PartSize: 8 << 20, Concurrency: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(15), size)
+	assert.Equal(t, "archive content", string(buf.Bytes()))
+	assert.Equal(t, int64(8<<20), downloader.Downloader().PartSize)
+	assert.Equal(t, 3, downloader.Downloader().Concurrency)
+	assert.Nil(t, downloader.LastDownloadInput().Range)
+
+	downloader = mock.S3DownloaderAPIDownloadError()
+	_, err = awsapi.DownloadObject(context.Background(), downloader, buf,
+		"bucket", "key", DownloadOptions{ReadOptions: ReadOptions{Offset: 5}})
+	assert.EqualError(t, err, "FakeDownloadError")
+	assert.Equal(t, "bytes=5-", aws.StringValue(downloader.LastDownloadInput().Range))
+}
+
+func TestUploadObject(t *testing.T) {
+	awsapi := AWSAPI{}
+	uploader := mock.S3ManagerAPIUpload("vid")
+
+	output, err := awsapi.UploadObject(context.Background(), uploader,
+		"bucket", "key", strings.NewReader("archive"), UploadOptions{
+			PartSize:             16 << 20,
+			Concurrency:          4,
+			ContentType:          "application/gzip",
+			Metadata:             map[string]string{"source": "audit"},
+			Tags:                 map[string]string{"retention": "1y", "team": "sec ops"},
+			ServerSideEncryption: s3.ServerSideEncryptionAes256,
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, "vid", aws.StringValue(output.VersionID))
+
+	input := uploader.LastUploadInput()
+	assert.Equal(t, "application/gzip", aws.StringValue(input.ContentType))
+	assert.Equal(t, "audit", aws.StringValue(input.Metadata["source"]))
+	assert.Equal(t, "AES256", aws.StringValue(input.ServerSideEncryption))
+	tags, err := url.ParseQuery(aws.StringValue(input.Tagging))
+	assert.Nil(t, err)
+	assert.Equal(t, "1y", tags.Get("retention"))
+	assert.Equal(t, "sec ops", tags.Get("team"))
+	assert.Equal(t, int64(16<<20), uploader.Uploader().PartSize)
+	assert.Equal(t, 4, uploader.Uploader().Concurrency)
+
+	output, err = awsapi.UploadObject(context.Background(),
+		mock.S3ManagerAPIUploadError(), "bucket", "key",
+		strings.NewReader("archive"), UploadOptions{})
+	assert.Nil(t, output)
+	assert.EqualError(t, err, "FakeUploadError")
+}
+
+func TestEncodeTags(t *testing.T) {
+	assert.Equal(t, "a=1&b=x%20y", EncodeTags(map[string]string{"b": "x y", "a": "1"}))
+	assert.Equal(t, "", EncodeTags(nil))
+}
+
+func TestUploadObjectEncryptionLock(t *testing.T) {
+	awsapi := AWSAPI{}
+	uploader := mock.S3ManagerAPIUpload("vid")
+	retainUntil := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := awsapi.UploadObject(context.Background(), uploader,
+		"bucket", "key", strings.NewReader("archive"), UploadOptions{
+			SSEKMSKeyID:           "fake-key",
+			ACL:                   s3.ObjectCannedACLBucketOwnerFullControl,
+			ObjectLockMode:        s3.ObjectLockModeCompliance,
+			ObjectLockRetainUntil: retainUntil,
+			ObjectLockLegalHold:   true,
+		})
+	assert.Nil(t, err)
+	input := uploader.LastUploadInput()
+	assert.Equal(t, "aws:kms", aws.StringValue(input.ServerSideEncryption))
+	assert.Equal(t, "fake-key", aws.StringValue(input.SSEKMSKeyId))
+	assert.Equal(t, "bucket-owner-full-control", aws.StringValue(input.ACL))
+	assert.Equal(t, "COMPLIANCE", aws.StringValue(input.ObjectLockMode))
+	assert.Equal(t, retainUntil, aws.TimeValue(input.ObjectLockRetainUntilDate))
+	assert.Equal(t, "ON", aws.StringValue(input.ObjectLockLegalHoldStatus))
+}
+
+func TestUploadOptionsValidate(t *testing.T) {
+	opts := UploadOptions{SSEKMSKeyID: "fake-key", ServerSideEncryption: "aws:kms"}
+	assert.Nil(t, opts.Validate())
+	opts = UploadOptions{SSEKMSKeyID: "fake-key", ServerSideEncryption: "AES256"}
+	assert.EqualError(t, opts.Validate(),
+		"kms key fake-key requires server side encryption aws:kms, got AES256")
+	opts = UploadOptions{ObjectLockMode: "GOVERNANCE"}
+	assert.EqualError(t, opts.Validate(),
+		"object lock mode and retain until date must be set together")
+
+	awsapi := AWSAPI{}
+	uploader := mock.S3ManagerAPIUpload("vid")
+	output, err := awsapi.UploadObject(context.Background(), uploader,
+		"bucket", "key", strings.NewReader("archive"), opts)
+	assert.Nil(t, output)
+	assert.NotNil(t, err)
+	assert.Nil(t, uploader.LastUploadInput().Key)
+}
+
+func TestUploadObjectEncrypted(t *testing.T) {
+	awsapi := AWSAPI{}
+	encKey := utils.DecodeBase64("DfdjIjQDhx9Nh5aWA13fuQQsIGsk8yYUWrm4dbPwokA=")
+	archive := strings.Repeat("audit log line\n", 10000)
+	uploader := mock.S3ManagerAPIUploads("vid")
+
+	output, err := awsapi.UploadObjectEncrypted(context.Background(), uploader,
+		"bucket", "audit.tar.gz", strings.NewReader(archive), encKey,
+		[]byte("audit.tar.gz"), UploadOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "vid", aws.StringValue(output.VersionID))
+	uploaded := uploader.UploadedBody("audit.tar.gz")
+	assert.NotContains(t, uploaded, "audit log line")
+
+	mockS3API := mock.S3APIGetObjects([]string{uploaded}, "vid")
+	body, err := awsapi.GetObjectDecryptReader(context.Background(), mockS3API,
+		"bucket", "audit.tar.gz", encKey, []byte("audit.tar.gz"), ReadOptions{})
+	assert.Nil(t, err)
+	content, err := ioutil.ReadAll(body)
+	assert.Nil(t, err)
+	assert.Equal(t, archive, string(content))
+	assert.Nil(t, body.Close())
+
+	// the aad binds the object to its key
+	body, err = awsapi.GetObjectDecryptReader(context.Background(),
+		mock.S3APIGetObjects([]string{uploaded}, "vid"),
+		"bucket", "other.tar.gz", encKey, []byte("other.tar.gz"), ReadOptions{})
+	assert.Nil(t, err)
+	_, err = ioutil.ReadAll(body)
+	assert.Equal(t, utils.ErrInvalidCiphertext, err)
+}
+
+func TestUploadObjectEncryptedError(t *testing.T) {
+	awsapi := AWSAPI{}
+	_, err := awsapi.UploadObjectEncrypted(context.Background(),
+		mock.S3ManagerAPIUploads("vid"), "bucket", "key",
+		strings.NewReader("archive"), []byte("00000"), nil, UploadOptions{})
+	assert.NotNil(t, err)
+
+	encKey := utils.DecodeBase64("DfdjIjQDhx9Nh5aWA13fuQQsIGsk8yYUWrm4dbPwokA=")
+	_, err = awsapi.GetObjectDecryptReader(context.Background(),
+		mock.S3APIGetObjects([]string{"partial"}, "vid"),
+		"bucket", "key", encKey, nil, ReadOptions{Length: 7})
+	assert.EqualError(t, err, "cannot decrypt a byte range of s3://bucket/key")
+	_, err = awsapi.GetObjectDecryptReader(context.Background(),
+		mock.S3APIGetObjectError(), "bucket", "key", encKey, nil, ReadOptions{})
+	assert.EqualError(t, err, "FakeGetObjectError")
+	_, err = awsapi.GetObjectDecryptReader(context.Background(),
+		mock.S3APIGetObjects([]string{"plain"}, "vid"),
+		"bucket", "key", encKey, nil, ReadOptions{})
+	assert.Equal(t, utils.ErrInvalidCiphertext, err)
+}
@@ -0,0 +1,200 @@
+package awsapi
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/xinnige/asteraceae/calendula/utils"
+)
+
+// ObjectVersion defines a version or a delete marker of a s3 object
+type ObjectVersion struct {
+	Key            string    `json:"key"`
+	VersionID      string    `json:"version_id"`
+	IsLatest       bool      `json:"is_latest"`
+	IsDeleteMarker bool      `json:"is_delete_marker"`
+	LastModified   time.Time `json:"last_modified"`
+	Size           int64     `json:"size"`
+	ETag           string    `json:"etag"`
+}
+
+// versions2array merges versions and delete markers of a page,
+// sorted by key then from the newest to the oldest
+func versions2array(result *s3.ListObjectVersionsOutput) []ObjectVersion {
+	versions := make([]ObjectVersion, 0, len(result.Versions)+len(result.DeleteMarkers))
+	for _, version := range result.Versions {
+		versions = append(versions, ObjectVersion{
+			Key:          aws.StringValue(version.Key),
+			VersionID:    aws.StringValue(version.VersionId),
+			IsLatest:     aws.BoolValue(version.IsLatest),
+			LastModified: aws.TimeValue(version.LastModified),
+			Size:         aws.Int64Value(version.Size),
+			ETag:         strings.Trim(aws.StringValue(version.ETag), `"`),
+		})
+	}
+	for _, marker := range result.DeleteMarkers {
+		versions = append(versions, ObjectVersion{
+			Key:            aws.StringValue(marker.Key),
+			VersionID:      aws.StringValue(marker.VersionId),
+			IsLatest:       aws.BoolValue(marker.IsLatest),
+			IsDeleteMarker: true,
+			LastModified:   aws.TimeValue(marker.LastModified),
+		})
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].Key != versions[j].Key {
+			return versions[i].Key < versions[j].Key
+		}
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+	return versions
+}
+
+// ListObjectVersionsPaginated returns a truncated list of versions w/ the next markers
+func (awsapi *AWSAPI) ListObjectVersionsPaginated(svc s3iface.S3API, bucket string,
+	prefix string, size int, keyMarker string,
+	versionMarker string) ([]ObjectVersion, string, string, bool, error) {
+	return awsapi.ListObjectVersionsPaginatedWithContext(aws.BackgroundContext(),
+		svc, bucket, prefix, size, keyMarker, versionMarker)
+}
+
+// ListObjectVersionsPaginatedWithContext is ListObjectVersionsPaginated
+// w/ a context and request options
+func (awsapi *AWSAPI) ListObjectVersionsPaginatedWithContext(ctx context.Context,
+	svc s3iface.S3API, bucket string, prefix string, size int, keyMarker string,
+	versionMarker string, opts ...request.Option) ([]ObjectVersion, string, string, bool, error) {
+	input := &s3.ListObjectVersionsInput{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(int64(size)),
+	}
+	if len(keyMarker) != 0 {
+		input.KeyMarker = aws.String(keyMarker)
+	}
+	if len(versionMarker) != 0 {
+		input.VersionIdMarker = aws.String(versionMarker)
+	}
+	result, err := svc.ListObjectVersionsWithContext(ctx, input, opts...)
+	if err != nil {
+		log.Printf("AWSError: Cannot list object versions from s3://%s/%s, err: %+v",
+			bucket, prefix, err)
+		return nil, "", "", false, err
+	}
+	return versions2array(result), aws.StringValue(result.NextKeyMarker),
+		aws.StringValue(result.NextVersionIdMarker), aws.BoolValue(result.IsTruncated), nil
+}
+
+// ListObjectVersions returns all versions and delete markers w/ a certain prefix
+func (awsapi *AWSAPI) ListObjectVersions(svc s3iface.S3API, bucket string,
+	prefix string) ([]ObjectVersion, error) {
+	return awsapi.ListObjectVersionsWithContext(aws.BackgroundContext(), svc, bucket, prefix)
+}
+
+// ListObjectVersionsWithContext is ListObjectVersions w/ a context and request options
+func (awsapi *AWSAPI) ListObjectVersionsWithContext(ctx context.Context,
+	svc s3iface.S3API, bucket string, prefix string,
+	opts ...request.Option) ([]ObjectVersion, error) {
+	found := make([]ObjectVersion, 0)
+	keyMarker, versionMarker := "", ""
+	for {
+		versions, nextKey, nextVersion, truncated, err :=
+			awsapi.ListObjectVersionsPaginatedWithContext(ctx, svc, bucket, prefix,
+				maxsize, keyMarker, versionMarker, opts...)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, versions...)
+		if !truncated || (nextKey == "" && nextVersion == "") {
+			return found, nil
+		}
+		keyMarker, versionMarker = nextKey, nextVersion
+	}
+}
+
+// GetObjectVersion returns the string content of a version of a s3 object
+func (awsapi *AWSAPI) GetObjectVersion(svc s3iface.S3API, bucket string,
+	key string, versionID string) (string, error) {
+	body, err := awsapi.GetObjectReader(aws.BackgroundContext(), svc, bucket, key,
+		ReadOptions{VersionID: versionID})
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	var buf bytes.Buffer
+	return utils.ReadFrom(&buf, body)
+}
+
+// DeleteObjectVersion deletes a version of a s3 object permanently,
+// or removes a delete marker
+func (awsapi *AWSAPI) DeleteObjectVersion(svc s3iface.S3API, bucket string,
+	key string, versionID string) error {
+	return awsapi.DeleteObjectVersionWithContext(aws.BackgroundContext(),
+		svc, bucket, key, versionID)
+}
+
+// DeleteObjectVersionWithContext is DeleteObjectVersion w/ a context and request options
+func (awsapi *AWSAPI) DeleteObjectVersionWithContext(ctx context.Context,
+	svc s3iface.S3API, bucket string, key string, versionID string,
+	opts ...request.Option) error {
+	input := &s3.DeleteObjectInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	}
+	_, err := svc.DeleteObjectWithContext(ctx, input, opts...)
+	if err != nil {
+		log.Printf("AWSError: Cannot delete object at s3://%s/%s?versionId=%s, err: %+v",
+			bucket, key, versionID, err)
+		return err
+	}
+	log.Printf("Deleted a object version from s3://%s/%s, VersionId %s\n", bucket, key, versionID)
+	return nil
+}
+
+// copySource returns the url-encoded x-amz-copy-source of an object
+func copySource(bucket, key, versionID string) string {
+	source := (&url.URL{Path: bucket + "/" + key}).EscapedPath()
+	if versionID != "" {
+		source += "?versionId=" + url.QueryEscape(versionID)
+	}
+	return source
+}
+
+// RestoreVersion copies an older version over the current one,
+// and returns the version id of the restored copy.
+// Objects over 5GB cannot be copied by a single CopyObject.
+func (awsapi *AWSAPI) RestoreVersion(svc s3iface.S3API, bucket string,
+	key string, versionID string) (string, error) {
+	return awsapi.RestoreVersionWithContext(aws.BackgroundContext(),
+		svc, bucket, key, versionID)
+}
+
+// RestoreVersionWithContext is RestoreVersion w/ a context and request options
+func (awsapi *AWSAPI) RestoreVersionWithContext(ctx context.Context,
+	svc s3iface.S3API, bucket string, key string, versionID string,
+	opts ...request.Option) (string, error) {
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(key),
+		CopySource: aws.String(copySource(bucket, key, versionID)),
+	}
+	result, err := svc.CopyObjectWithContext(ctx, input, opts...)
+	if err != nil {
+		log.Printf("AWSError: Cannot restore s3://%s/%s to VersionId %s, err: %+v",
+			bucket, key, versionID, err)
+		return "", err
+	}
+	restored := aws.StringValue(result.VersionId)
+	log.Printf("Restored s3://%s/%s from VersionId %s, VersionId %s\n",
+		bucket, key, versionID, restored)
+	return restored, nil
+}
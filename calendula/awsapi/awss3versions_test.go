@@ -0,0 +1,103 @@
+package awsapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+func TestListObjectVersions(t *testing.T) {
+	awsapi := AWSAPI{}
+	now := time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)
+	s3api := mock.S3APIListObjectVersions([][]mock.S3ObjectVersion{
+		{
+			{Key: "conf/a.json", VersionID: "a1", LastModified: now.Add(-2 * time.Hour)},
+			{Key: "conf/a.json", VersionID: "a3", IsLatest: true, DeleteMarker: true, LastModified: now},
+			{Key: "conf/a.json", VersionID: "a2", LastModified: now.Add(-time.Hour)},
+		},
+		{
+			{Key: "conf/b.json", VersionID: "b1", IsLatest: true, LastModified: now},
+		},
+	})
+
+	versions, err := awsapi.ListObjectVersions(s3api, "bucket", "conf/")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(versions))
+	ids := make([]string, len(versions))
+	for idx, version := range versions {
+		ids[idx] = version.VersionID
+	}
+	assert.Equal(t, []string{"a3", "a2", "a1", "b1"}, ids)
+	assert.True(t, versions[0].IsDeleteMarker)
+	assert.True(t, versions[0].IsLatest)
+	assert.Equal(t, ObjectVersion{
+		Key: "conf/a.json", VersionID: "a2", LastModified: now.Add(-time.Hour),
+		Size: 2, ETag: "etag-a2",
+	}, versions[1])
+
+	_, err = awsapi.ListObjectVersions(mock.S3APIListObjectVersionsError(), "bucket", "conf/")
+	assert.EqualError(t, err, "FakeListObjectVersionsError")
+}
+
+func TestListObjectVersionsPaginated(t *testing.T) {
+	awsapi := AWSAPI{}
+	s3api := mock.S3APIListObjectVersions([][]mock.S3ObjectVersion{
+		{{Key: "a", VersionID: "a1"}},
+		{{Key: "b", VersionID: "b1"}},
+	})
+	versions, nextKey, nextVersion, truncated, err :=
+		awsapi.ListObjectVersionsPaginated(s3api, "bucket", "", 1, "", "")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(versions))
+	assert.Equal(t, "next-key-0", nextKey)
+	assert.Equal(t, "next-version-0", nextVersion)
+	assert.True(t, truncated)
+
+	versions, nextKey, nextVersion, truncated, err =
+		awsapi.ListObjectVersionsPaginated(s3api, "bucket", "", 1, nextKey, nextVersion)
+	assert.Nil(t, err)
+	assert.Equal(t, "b1", versions[0].VersionID)
+	assert.Equal(t, "", nextKey)
+	assert.False(t, truncated)
+}
+
+func TestGetObjectVersion(t *testing.T) {
+	awsapi := AWSAPI{}
+	s3api := mock.S3APIGetObjects([]string{"old content"}, "v1")
+	content, err := awsapi.GetObjectVersion(s3api, "bucket", "key", "v1")
+	assert.Nil(t, err)
+	assert.Equal(t, "old content", content)
+	assert.Equal(t, "v1", aws.StringValue(s3api.LastGetObjectInput().VersionId))
+
+	_, err = awsapi.GetObjectVersion(mock.S3APIGetObjectError(), "bucket", "key", "v1")
+	assert.EqualError(t, err, "FakeGetObjectError")
+}
+
+func TestDeleteObjectVersion(t *testing.T) {
+	awsapi := AWSAPI{}
+	s3api := mock.S3APIDeleteObject("v1")
+	err := awsapi.DeleteObjectVersion(s3api, "bucket", "key", "v1")
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", aws.StringValue(s3api.LastDeleteObjectInput().VersionId))
+
+	err = awsapi.DeleteObjectVersion(mock.S3APIDeleteObjectError(), "bucket", "key", "v1")
+	assert.EqualError(t, err, "FakeDeleteObjectError")
+}
+
+func TestRestoreVersion(t *testing.T) {
+	awsapi := AWSAPI{}
+	s3api := mock.S3APICopyObject("v3")
+	restored, err := awsapi.RestoreVersion(s3api, "bucket", "conf/app config.json", "v1+/x")
+	assert.Nil(t, err)
+	assert.Equal(t, "v3", restored)
+	input := s3api.LastCopyObjectInput()
+	assert.Equal(t, "conf/app config.json", aws.StringValue(input.Key))
+	assert.Equal(t, "bucket/conf/app%20config.json?versionId=v1%2B%2Fx",
+		aws.StringValue(input.CopySource))
+
+	_, err = awsapi.RestoreVersion(mock.S3APICopyObjectError(), "bucket", "key", "v1")
+	assert.EqualError(t, err, "FakeCopyObjectError")
+}
@@ -1,27 +1,52 @@
 package awsapi
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"sort"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/xinnige/asteraceae/calendula/utils"
 )
 
-// NewSSMAPI returns a s3 client
-func NewSSMAPI(sess AWSSession) ssmiface.SSMAPI {
+// NewSSMClient returns a ssm client, or an error if the session fails
+func NewSSMClient(sess AWSSession) (ssmiface.SSMAPI, error) {
 	s3ess, err := sess.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	return ssm.New(s3ess), nil
+}
+
+// NewSSMAPI returns a ssm client, or nil if the session fails.
+//
+// Deprecated: use NewSSMClient or Clients.SSM, which return the error.
+func NewSSMAPI(sess AWSSession) ssmiface.SSMAPI {
+	svc, err := NewSSMClient(sess)
 	if err != nil {
 		log.Printf("AWSError: Cannot create aws session. err %#v", err)
 		return nil
 	}
-	return ssm.New(s3ess)
+	return svc
 }
 
 // PutParameter helps to put parameter to ssm as Secure String
 func (api *AWSAPI) PutParameter(svc ssmiface.SSMAPI, name, value,
 	keyid string, tags map[string]string, overwrite bool, isArray bool) error {
+	return api.PutParameterWithContext(aws.BackgroundContext(), svc,
+		name, value, keyid, tags, overwrite, isArray)
+}
+
+// PutParameterWithContext is PutParameter w/ a context and request options
+func (api *AWSAPI) PutParameterWithContext(ctx context.Context, svc ssmiface.SSMAPI,
+	name, value, keyid string, tags map[string]string, overwrite bool,
+	isArray bool, opts ...request.Option) error {
 	input := &ssm.PutParameterInput{
 		Name:      aws.String(name),
 		Overwrite: aws.Bool(overwrite),
@@ -46,7 +71,7 @@ func (api *AWSAPI) PutParameter(svc ssmiface.SSMAPI, name, value,
 			idx++
 		}
 	}
-	result, err := svc.PutParameter(input)
+	result, err := svc.PutParameterWithContext(ctx, input, opts...)
 	if err != nil {
 		log.Printf("SSMError: fail to put parameter to ssm (name=%s, value=%s, "+
 			"keyid=%s, tags=%v, overwrite=%t), err: %v\n",
@@ -62,6 +87,13 @@ func (api *AWSAPI) PutParameter(svc ssmiface.SSMAPI, name, value,
 // AddTagsToResource helps to add tags to ssm resources
 func (api *AWSAPI) AddTagsToResource(svc ssmiface.SSMAPI,
 	resID, resType string, tags map[string]string) error {
+	return api.AddTagsToResourceWithContext(aws.BackgroundContext(), svc,
+		resID, resType, tags)
+}
+
+// AddTagsToResourceWithContext is AddTagsToResource w/ a context and request options
+func (api *AWSAPI) AddTagsToResourceWithContext(ctx context.Context, svc ssmiface.SSMAPI,
+	resID, resType string, tags map[string]string, opts ...request.Option) error {
 	input := &ssm.AddTagsToResourceInput{
 		ResourceId:   aws.String(resID),
 		ResourceType: aws.String(resType),
@@ -75,7 +107,7 @@ func (api *AWSAPI) AddTagsToResource(svc ssmiface.SSMAPI,
 		}
 		idx++
 	}
-	_, err := svc.AddTagsToResource(input)
+	_, err := svc.AddTagsToResourceWithContext(ctx, input, opts...)
 	if err != nil {
 		log.Printf("SSMError: fail to add tags %v to %s\n", tags, resID)
 		return err
@@ -87,6 +119,14 @@ func (api *AWSAPI) AddTagsToResource(svc ssmiface.SSMAPI,
 // GetParametersByPathIter helps to get all parameters in a certain path
 func (api *AWSAPI) GetParametersByPathIter(svc ssmiface.SSMAPI,
 	name string, next *string, recursive bool, maxsize int64) ([]*ssm.Parameter, *string, error) {
+	return api.GetParametersByPathIterWithContext(aws.BackgroundContext(), svc,
+		name, next, recursive, maxsize)
+}
+
+// GetParametersByPathIterWithContext is GetParametersByPathIter w/ a context and request options
+func (api *AWSAPI) GetParametersByPathIterWithContext(ctx context.Context,
+	svc ssmiface.SSMAPI, name string, next *string, recursive bool,
+	maxsize int64, opts ...request.Option) ([]*ssm.Parameter, *string, error) {
 	input := &ssm.GetParametersByPathInput{
 		MaxResults:     aws.Int64(maxsize),
 		Path:           aws.String(name),
@@ -96,7 +136,7 @@ func (api *AWSAPI) GetParametersByPathIter(svc ssmiface.SSMAPI,
 	if next != nil {
 		input.NextToken = next
 	}
-	result, err := svc.GetParametersByPath(input)
+	result, err := svc.GetParametersByPathWithContext(ctx, input, opts...)
 	if err != nil {
 		log.Printf("SSMError: fail to get parameters by path %s, err: %v\n", name, err)
 		return nil, nil, err
@@ -107,14 +147,25 @@ func (api *AWSAPI) GetParametersByPathIter(svc ssmiface.SSMAPI,
 // GetParametersByPath helps to get all parameters in a certain path
 func (api *AWSAPI) GetParametersByPath(svc ssmiface.SSMAPI,
 	name string, recursive bool, maxsize int64) (map[string]string, error) {
+	return api.GetParametersByPathWithContext(aws.BackgroundContext(), svc,
+		name, recursive, maxsize)
+}
+
+// GetParametersByPathWithContext is GetParametersByPath w/ a context and request options,
+// the loop stops at the first page failed by a cancelled context
+func (api *AWSAPI) GetParametersByPathWithContext(ctx context.Context,
+	svc ssmiface.SSMAPI, name string, recursive bool, maxsize int64,
+	opts ...request.Option) (map[string]string, error) {
 	results := make([]*ssm.Parameter, 0)
-	sparams, next, err := api.GetParametersByPathIter(svc, name, nil, recursive, maxsize)
+	sparams, next, err := api.GetParametersByPathIterWithContext(ctx, svc,
+		name, nil, recursive, maxsize, opts...)
 	if err == nil && len(sparams) == 0 {
 		return nil, fmt.Errorf("parameter %s not found", name)
 	}
 	for next != nil && err == nil {
 		results = append(results, sparams...)
-		sparams, next, err = api.GetParametersByPathIter(svc, name, next, recursive, maxsize)
+		sparams, next, err = api.GetParametersByPathIterWithContext(ctx, svc,
+			name, next, recursive, maxsize, opts...)
 	}
 	if err != nil {
 		return nil, err
@@ -131,11 +182,17 @@ func (api *AWSAPI) GetParametersByPath(svc ssmiface.SSMAPI,
 // ListTagsForResource helps to list tags of a certain resource
 func (api *AWSAPI) ListTagsForResource(svc ssmiface.SSMAPI,
 	resID, resType string) (map[string]string, error) {
+	return api.ListTagsForResourceWithContext(aws.BackgroundContext(), svc, resID, resType)
+}
+
+// ListTagsForResourceWithContext is ListTagsForResource w/ a context and request options
+func (api *AWSAPI) ListTagsForResourceWithContext(ctx context.Context, svc ssmiface.SSMAPI,
+	resID, resType string, opts ...request.Option) (map[string]string, error) {
 	input := &ssm.ListTagsForResourceInput{
 		ResourceId:   aws.String(resID),
 		ResourceType: aws.String(resType),
 	}
-	result, err := svc.ListTagsForResource(input)
+	result, err := svc.ListTagsForResourceWithContext(ctx, input, opts...)
 	if err != nil {
 		log.Printf("SSMError: fail to list tags of %s: %v\n", resID, err)
 		return nil, err
@@ -147,14 +204,200 @@ func (api *AWSAPI) ListTagsForResource(svc ssmiface.SSMAPI,
 	return tags, nil
 }
 
-// DeleteParameters helps to delete a list of parameters at maxsize 10
-func (api *AWSAPI) DeleteParameters(svc ssmiface.SSMAPI, names []string) error {
+const (
+	// maxDeleteParameters is the limit of names of a DeleteParameters request
+	maxDeleteParameters = 10
+
+	deDeleteConcurrency = 4
+	deDeleteRetries     = 5
+	deDeleteBackoff     = 200 * time.Millisecond
+)
+
+// DeleteParametersResult holds the names deleted, not found (invalid)
+// and failed by errors of a DeleteParameters
+type DeleteParametersResult struct {
+	Deleted []string   `json:"deleted"`
+	Invalid []string   `json:"invalid"`
+	Errors  []KeyError `json:"errors"`
+}
+
+// Failed checks if any name failed by an error, invalid names are not failures
+func (result *DeleteParametersResult) Failed() bool {
+	return len(result.Errors) != 0
+}
+
+// DeleteParametersOptions defines how batches of a DeleteParameters run
+type DeleteParametersOptions struct {
+	// Concurrency limits concurrent batches of 10 names, 4 if 0
+	Concurrency int
+	// Retries limits retries of a throttled batch, 5 if 0
+	Retries int
+	// Backoff is the wait before the first retry, doubled every retry, 200ms if 0
+	Backoff time.Duration
+}
+
+func (opts *DeleteParametersOptions) withDefaults() DeleteParametersOptions {
+	filled := *opts
+	if filled.Concurrency <= 0 {
+		filled.Concurrency = deDeleteConcurrency
+	}
+	if filled.Retries <= 0 {
+		filled.Retries = deDeleteRetries
+	}
+	if filled.Backoff <= 0 {
+		filled.Backoff = deDeleteBackoff
+	}
+	return filled
+}
+
+// DeleteParameters helps to delete a list of parameters in batches of 10
+func (api *AWSAPI) DeleteParameters(svc ssmiface.SSMAPI,
+	names []string) (*DeleteParametersResult, error) {
+	return api.DeleteParametersWithContext(aws.BackgroundContext(), svc, names)
+}
+
+// DeleteParametersWithContext is DeleteParameters w/ a context and request options
+func (api *AWSAPI) DeleteParametersWithContext(ctx context.Context, svc ssmiface.SSMAPI,
+	names []string, opts ...request.Option) (*DeleteParametersResult, error) {
+	return api.DeleteParametersWithOptions(ctx, svc, names, DeleteParametersOptions{}, opts...)
+}
+
+// DeleteParametersWithOptions deletes parameters in concurrent batches of 10,
+// throttled batches are retried w/ an exponential backoff. Names of failed
+// batches are reported in the result and the first error is returned.
+func (api *AWSAPI) DeleteParametersWithOptions(ctx context.Context, svc ssmiface.SSMAPI,
+	names []string, delOpts DeleteParametersOptions,
+	opts ...request.Option) (*DeleteParametersResult, error) {
+	delOpts = delOpts.withDefaults()
+	chunks := utils.ChunkStringArray(names, maxDeleteParameters)
+	outputs := make([]*ssm.DeleteParametersOutput, len(chunks))
+	errs := make([]error, len(chunks))
+	done := make([]bool, len(chunks))
+	utils.RunPool(ctx, delOpts.Concurrency, len(chunks), func(idx int) {
+		outputs[idx], errs[idx] = deleteParametersChunk(ctx, svc, chunks[idx], delOpts, opts...)
+		done[idx] = true
+	})
+
+	result := &DeleteParametersResult{
+		Deleted: make([]string, 0),
+		Invalid: make([]string, 0),
+		Errors:  make([]KeyError, 0),
+	}
+	var first error
+	for idx, chunk := range chunks {
+		err := errs[idx]
+		if !done[idx] {
+			// not scheduled after a cancellation
+			err = ctx.Err()
+		}
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			code, message := "Error", err.Error()
+			if aerr, ok := err.(awserr.Error); ok {
+				code, message = aerr.Code(), aerr.Message()
+			} else if err == ctx.Err() {
+				code = request.CanceledErrorCode
+			}
+			for _, name := range chunk {
+				result.Errors = append(result.Errors, KeyError{Key: name, Code: code, Message: message})
+			}
+			continue
+		}
+		result.Deleted = append(result.Deleted, aws.StringValueSlice(outputs[idx].DeletedParameters)...)
+		result.Invalid = append(result.Invalid, aws.StringValueSlice(outputs[idx].InvalidParameters)...)
+	}
+	sort.Strings(result.Deleted)
+	sort.Strings(result.Invalid)
+	log.Printf("DeleteParameters: deleted %d, invalid %v, failed %d parameters\n",
+		len(result.Deleted), result.Invalid, len(result.Errors))
+	return result, first
+}
+
+// deleteParametersChunk deletes a batch, retrying it while throttled
+func deleteParametersChunk(ctx context.Context, svc ssmiface.SSMAPI, names []string,
+	delOpts DeleteParametersOptions, opts ...request.Option) (*ssm.DeleteParametersOutput, error) {
 	input := &ssm.DeleteParametersInput{Names: aws.StringSlice(names)}
-	output, err := svc.DeleteParameters(input)
-	if err != nil {
-		return err
+	wait := delOpts.Backoff
+	for retry := 0; ; retry++ {
+		output, err := svc.DeleteParametersWithContext(ctx, input, opts...)
+		if err == nil {
+			return output, nil
+		}
+		throttled := request.IsErrorThrottle(err)
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ssm.ErrCodeTooManyUpdates {
+			throttled = true
+		}
+		if !throttled || retry >= delOpts.Retries {
+			log.Printf("SSMError: fail to delete parameters %v, err: %v\n", names, err)
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// DeletePath deletes the parameters under a path in batches of 10,
+// the names are listed w/ GetParametersByPathIter before deleting
+func (api *AWSAPI) DeletePath(ctx context.Context, svc ssmiface.SSMAPI, path string,
+	recursive bool, delOpts DeleteParametersOptions) (*DeleteParametersResult, error) {
+	names := make([]string, 0)
+	var next *string
+	for {
+		params, token, err := api.GetParametersByPathIterWithContext(ctx, svc,
+			path, next, recursive, maxDeleteParameters)
+		if err != nil {
+			return nil, err
+		}
+		for _, param := range params {
+			names = append(names, aws.StringValue(param.Name))
+		}
+		if token == nil {
+			break
+		}
+		next = token
+	}
+	return api.DeleteParametersWithOptions(ctx, svc, names, delOpts)
+}
+
+// DescribeParametersByPath returns the metadata of parameters in a certain path,
+// including the kms key ids of SecureString parameters
+func (api *AWSAPI) DescribeParametersByPath(svc ssmiface.SSMAPI,
+	name string, recursive bool) ([]*ssm.ParameterMetadata, error) {
+	return api.DescribeParametersByPathWithContext(aws.BackgroundContext(), svc, name, recursive)
+}
+
+// DescribeParametersByPathWithContext is DescribeParametersByPath w/ a context and request options
+func (api *AWSAPI) DescribeParametersByPathWithContext(ctx context.Context, svc ssmiface.SSMAPI,
+	name string, recursive bool, opts ...request.Option) ([]*ssm.ParameterMetadata, error) {
+	option := "OneLevel"
+	if recursive {
+		option = "Recursive"
+	}
+	input := &ssm.DescribeParametersInput{
+		MaxResults: aws.Int64(50),
+		ParameterFilters: []*ssm.ParameterStringFilter{{
+			Key:    aws.String("Path"),
+			Option: aws.String(option),
+			Values: aws.StringSlice([]string{name}),
+		}},
+	}
+	found := make([]*ssm.ParameterMetadata, 0)
+	for {
+		result, err := svc.DescribeParametersWithContext(ctx, input, opts...)
+		if err != nil {
+			log.Printf("SSMError: fail to describe parameters by path %s, err: %v\n", name, err)
+			return nil, err
+		}
+		found = append(found, result.Parameters...)
+		if result.NextToken == nil {
+			return found, nil
+		}
+		input.NextToken = result.NextToken
 	}
-	log.Printf("DeleteParameters: deleted parameters %v\n", output.DeletedParameters)
-	log.Printf("DeleteParameters: invalid parameters %v\n", output.InvalidParameters)
-	return nil
 }
@@ -1,10 +1,14 @@
 package awsapi
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/xinnige/asteraceae/calendula/mock"
@@ -22,10 +26,14 @@ func TestNewSSMAPIError(t *testing.T) {
 	defer mockCtrl.Finish()
 	mockSession := mock.NewMockAWSSession(mockCtrl)
 	mockSession.EXPECT().NewSession().Return(
-		nil, errors.New("FakeNewSessionError")).Times(1)
+		nil, errors.New("FakeNewSessionError")).Times(2)
 
 	ssmapi := NewSSMAPI(mockSession)
 	assert.Nil(t, ssmapi)
+
+	svc, err := NewSSMClient(mockSession)
+	assert.Nil(t, svc)
+	assert.EqualError(t, err, "FakeNewSessionError")
 }
 
 func TestPutParameter(t *testing.T) {
@@ -124,13 +132,173 @@ func TestListTagsError(t *testing.T) {
 func TestDeleteParams(t *testing.T) {
 	awsapi := AWSAPI{}
 	ssmapi := mock.SSMDeleteParams([]string{"abc"}, []string{"123"})
-	err := awsapi.DeleteParameters(ssmapi, []string{"abc", "123"})
+	result, err := awsapi.DeleteParameters(ssmapi, []string{"abc", "123"})
 	assert.Nil(t, err)
+	assert.Equal(t, &DeleteParametersResult{
+		Deleted: []string{"abc"},
+		Invalid: []string{"123"},
+		Errors:  []KeyError{},
+	}, result)
+	assert.False(t, result.Failed())
 }
 
 func TestDeleteParamsError(t *testing.T) {
 	awsapi := AWSAPI{}
 	ssmapi := mock.SSMDeleteParamsError()
-	err := awsapi.DeleteParameters(ssmapi, []string{})
+	result, err := awsapi.DeleteParameters(ssmapi, []string{"abc"})
 	assert.Equal(t, "FakeSSMDeleteParamsError", err.Error())
+	assert.True(t, result.Failed())
+	assert.Equal(t, []KeyError{{Key: "abc", Code: "Error", Message: "FakeSSMDeleteParamsError"}},
+		result.Errors)
+
+	result, err = awsapi.DeleteParameters(ssmapi, []string{})
+	assert.Nil(t, err)
+	assert.False(t, result.Failed())
+}
+
+func TestDeleteParamsBatches(t *testing.T) {
+	awsapi := AWSAPI{}
+	ssmapi := mock.SSMParameterStore()
+	names := make([]string, 0)
+	for idx := 0; idx < 25; idx++ {
+		name := fmt.Sprintf("/app/p%02d", idx)
+		ssmapi.SetParameter(name, "x", "String")
+		names = append(names, name)
+	}
+	names = append(names, "/app/missing")
+	// throttled batches are retried
+	ssmapi.SetDeleteThrottle(2)
+	result, err := awsapi.DeleteParametersWithOptions(context.Background(), ssmapi, names,
+		DeleteParametersOptions{Concurrency: 2, Backoff: time.Millisecond})
+	assert.Nil(t, err)
+	assert.Equal(t, names[:25], result.Deleted)
+	assert.Equal(t, []string{"/app/missing"}, result.Invalid)
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, 5, ssmapi.DeleteCalls())
+
+	// retries are limited
+	ssmapi.SetParameter("/app/p00", "x", "String")
+	ssmapi.SetDeleteThrottle(3)
+	result, err = awsapi.DeleteParametersWithOptions(context.Background(), ssmapi,
+		[]string{"/app/p00"}, DeleteParametersOptions{Retries: 2, Backoff: time.Millisecond})
+	assert.EqualError(t, err, "ThrottlingException: Rate exceeded")
+	assert.Equal(t, []KeyError{{Key: "/app/p00", Code: "ThrottlingException", Message: "Rate exceeded"}},
+		result.Errors)
+	assert.Equal(t, 8, ssmapi.DeleteCalls())
+
+	// other errors are not retried
+	ssmapi.SetDeleteError(errors.New("AccessDenied"))
+	_, err = awsapi.DeleteParameters(ssmapi, []string{"/app/p00"})
+	assert.EqualError(t, err, "AccessDenied")
+	assert.Equal(t, 9, ssmapi.DeleteCalls())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ssmapi.SetDeleteError(nil)
+	result, err = awsapi.DeleteParametersWithContext(ctx, ssmapi, []string{"/app/p00"})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, len(result.Errors))
+}
+
+func TestDeleteParametersCancel(t *testing.T) {
+	awsapi := AWSAPI{}
+	ssmapi := mock.SSMParameterStore()
+	names := make([]string, 0)
+	for idx := 0; idx < 30; idx++ {
+		name := fmt.Sprintf("/app/p%02d", idx)
+		ssmapi.SetParameter(name, "x", "String")
+		names = append(names, name)
+	}
+	// cancel after the first batch, the rest are not scheduled
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ssmapi.SetDeleteCancel(1, cancel)
+	result, err := awsapi.DeleteParametersWithOptions(ctx, ssmapi, names,
+		DeleteParametersOptions{Concurrency: 1})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, names[:10], result.Deleted)
+	assert.Equal(t, 20, len(result.Errors))
+	for _, keyErr := range result.Errors {
+		assert.Equal(t, "RequestCanceled", keyErr.Code)
+		assert.Equal(t, "context canceled", keyErr.Message)
+	}
+	assert.Equal(t, 1, ssmapi.DeleteCalls())
+
+	// the error of a failed batch is returned rather than the cancellation
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	ssmapi.SetDeleteError(awserr.New("AccessDeniedException", "FakeDeleteError", nil))
+	ssmapi.SetDeleteCancel(1, cancel)
+	result, err = awsapi.DeleteParametersWithOptions(ctx, ssmapi, names[10:],
+		DeleteParametersOptions{Concurrency: 1})
+	assert.EqualError(t, err, "AccessDeniedException: FakeDeleteError")
+	assert.Equal(t, 20, len(result.Errors))
+	assert.Equal(t, "AccessDeniedException", result.Errors[0].Code)
+	assert.Equal(t, "RequestCanceled", result.Errors[10].Code)
+	assert.Equal(t, 2, ssmapi.DeleteCalls())
+}
+
+func TestDeletePath(t *testing.T) {
+	awsapi := AWSAPI{}
+	ssmapi := mock.SSMParameterStore("/app/keep=1")
+	for idx := 0; idx < 15; idx++ {
+		ssmapi.SetParameter(fmt.Sprintf("/app/old/p%02d", idx), "x", "String")
+	}
+	ssmapi.SetParameter("/app/old/nested/p", "x", "String")
+
+	result, err := awsapi.DeletePath(context.Background(), ssmapi, "/app/old", false,
+		DeleteParametersOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 15, len(result.Deleted))
+	assert.NotNil(t, ssmapi.Parameter("/app/old/nested/p"))
+
+	result, err = awsapi.DeletePath(context.Background(), ssmapi, "/app/old", true,
+		DeleteParametersOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/app/old/nested/p"}, result.Deleted)
+	assert.NotNil(t, ssmapi.Parameter("/app/keep"))
+
+	ssmapi.SetGetError(errors.New("ThrottlingException"))
+	_, err = awsapi.DeletePath(context.Background(), ssmapi, "/app", true,
+		DeleteParametersOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestGetParamsWithContextCancelled(t *testing.T) {
+	awsapi := AWSAPI{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	names := [][]string{[]string{"n11"}, []string{"n21"}}
+	values := [][]string{[]string{"v11"}, []string{"v21"}}
+	nexts := []*string{aws.String("fake-next-1"), nil}
+	ssmapi := mock.SSMGetParams(names, values, nexts)
+	result, err := awsapi.GetParametersByPathWithContext(ctx, ssmapi, "fake-name", true, 10)
+	assert.Nil(t, result)
+	assert.Equal(t, context.Canceled, err)
+
+	err = awsapi.PutParameterWithContext(ctx, mock.SSMPutParameter(1),
+		"fake-name", "fake-value", "", nil, false, false)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestDescribeParametersByPath(t *testing.T) {
+	awsapi := AWSAPI{}
+	ssmapi := mock.SSMParameterStore("/app/port=80", "/app/db/host=db.local", "/web/port=8080")
+	ssmapi.SetParameter("/app/token", "secret", "SecureString")
+
+	params, err := awsapi.DescribeParametersByPath(ssmapi, "/app", true)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(params))
+	assert.Equal(t, "/app/db/host", aws.StringValue(params[0].Name))
+	assert.Equal(t, "alias/aws/ssm", aws.StringValue(params[2].KeyId))
+
+	params, err = awsapi.DescribeParametersByPath(ssmapi, "/app", false)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(params))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = awsapi.DescribeParametersByPathWithContext(ctx, ssmapi, "/app", true)
+	assert.Equal(t, context.Canceled, err)
 }
@@ -0,0 +1,370 @@
+package awsapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+const (
+	// deGetParametersSize is the max number of names of a GetParameters request
+	deGetParametersSize = 10
+	// deHistoryPageSize is the max page size of GetParameterHistory
+	deHistoryPageSize = 50
+)
+
+// ParameterInfo defines a parameter got from ssm, SecureString values are decrypted
+type ParameterInfo struct {
+	Name         string    `json:"name"`
+	Value        string    `json:"value"`
+	Type         string    `json:"type"`
+	Version      int64     `json:"version"`
+	LastModified time.Time `json:"last_modified"`
+	ARN          string    `json:"arn"`
+	// Selector is the ":version" or ":label" of a name if requested w/ one
+	Selector string `json:"selector,omitempty"`
+}
+
+func newParameterInfo(param *ssm.Parameter) ParameterInfo {
+	return ParameterInfo{
+		Name:         aws.StringValue(param.Name),
+		Value:        aws.StringValue(param.Value),
+		Type:         aws.StringValue(param.Type),
+		Version:      aws.Int64Value(param.Version),
+		LastModified: aws.TimeValue(param.LastModifiedDate),
+		ARN:          aws.StringValue(param.ARN),
+		Selector:     aws.StringValue(param.Selector),
+	}
+}
+
+// ParameterPolicyStatus defines a policy attached to a parameter version
+type ParameterPolicyStatus struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	Status string `json:"status"`
+}
+
+// ParameterVersion defines a version of the history of a parameter
+type ParameterVersion struct {
+	Name             string                  `json:"name"`
+	Value            string                  `json:"value"`
+	Type             string                  `json:"type"`
+	Version          int64                   `json:"version"`
+	LastModified     time.Time               `json:"last_modified"`
+	LastModifiedUser string                  `json:"last_modified_user"`
+	KeyID            string                  `json:"key_id,omitempty"`
+	Description      string                  `json:"description,omitempty"`
+	Tier             string                  `json:"tier,omitempty"`
+	Labels           []string                `json:"labels"`
+	Policies         []ParameterPolicyStatus `json:"policies,omitempty"`
+}
+
+func newParameterVersion(history *ssm.ParameterHistory) ParameterVersion {
+	version := ParameterVersion{
+		Name:             aws.StringValue(history.Name),
+		Value:            aws.StringValue(history.Value),
+		Type:             aws.StringValue(history.Type),
+		Version:          aws.Int64Value(history.Version),
+		LastModified:     aws.TimeValue(history.LastModifiedDate),
+		LastModifiedUser: aws.StringValue(history.LastModifiedUser),
+		KeyID:            aws.StringValue(history.KeyId),
+		Description:      aws.StringValue(history.Description),
+		Tier:             aws.StringValue(history.Tier),
+		Labels:           aws.StringValueSlice(history.Labels),
+	}
+	for _, policy := range history.Policies {
+		version.Policies = append(version.Policies, ParameterPolicyStatus{
+			Type:   aws.StringValue(policy.PolicyType),
+			Text:   aws.StringValue(policy.PolicyText),
+			Status: aws.StringValue(policy.PolicyStatus),
+		})
+	}
+	return version
+}
+
+// ParameterPolicies defines the policies of an Advanced tier parameter,
+// zero values are not attached
+type ParameterPolicies struct {
+	// Expiration deletes the parameter at a time
+	Expiration time.Time
+	// ExpirationNotification notifies cloudwatch events a duration before Expiration
+	ExpirationNotification time.Duration
+	// NoChangeNotification notifies cloudwatch events if the parameter
+	// is not changed for a duration
+	NoChangeNotification time.Duration
+}
+
+// Empty checks if no policy is defined
+func (policies *ParameterPolicies) Empty() bool {
+	return policies.Expiration.IsZero() &&
+		policies.ExpirationNotification == 0 && policies.NoChangeNotification == 0
+}
+
+type parameterPolicy struct {
+	Type       string            `json:"Type"`
+	Version    string            `json:"Version"`
+	Attributes map[string]string `json:"Attributes"`
+}
+
+// policyUnit returns a whole number of days or hours of a duration
+func policyUnit(kind string, d time.Duration) (string, string, error) {
+	if d <= 0 || d%time.Hour != 0 {
+		return "", "", fmt.Errorf("%s must be a positive number of hours, got %s", kind, d)
+	}
+	if d%(24*time.Hour) == 0 {
+		return strconv.FormatInt(int64(d/(24*time.Hour)), 10), "Days", nil
+	}
+	return strconv.FormatInt(int64(d/time.Hour), 10), "Hours", nil
+}
+
+// JSON returns the policies in the json of ssm.PutParameterInput.Policies
+func (policies *ParameterPolicies) JSON() (string, error) {
+	list := make([]parameterPolicy, 0)
+	if !policies.Expiration.IsZero() {
+		list = append(list, parameterPolicy{Type: "Expiration", Version: "1.0",
+			Attributes: map[string]string{
+				"Timestamp": policies.Expiration.UTC().Format("2006-01-02T15:04:05.000Z"),
+			}})
+	}
+	if policies.ExpirationNotification != 0 {
+		if policies.Expiration.IsZero() {
+			return "", fmt.Errorf("expiration notification requires an expiration")
+		}
+		before, unit, err := policyUnit("expiration notification", policies.ExpirationNotification)
+		if err != nil {
+			return "", err
+		}
+		list = append(list, parameterPolicy{Type: "ExpirationNotification", Version: "1.0",
+			Attributes: map[string]string{"Before": before, "Unit": unit}})
+	}
+	if policies.NoChangeNotification != 0 {
+		after, unit, err := policyUnit("no change notification", policies.NoChangeNotification)
+		if err != nil {
+			return "", err
+		}
+		list = append(list, parameterPolicy{Type: "NoChangeNotification", Version: "1.0",
+			Attributes: map[string]string{"After": after, "Unit": unit}})
+	}
+	content, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// PutParameterOptions defines the options of PutParameterWithOptions
+type PutParameterOptions struct {
+	// Type is String if empty
+	Type        string
+	KeyID       string
+	Description string
+	// Tier is Advanced if policies are set, the account default otherwise
+	Tier           string
+	Policies       *ParameterPolicies
+	AllowedPattern string
+	// Tags are only accepted on creation, they can not be put w/ Overwrite
+	Tags      map[string]string
+	Overwrite bool
+}
+
+func (opts *PutParameterOptions) putInput(name, value string) (*ssm.PutParameterInput, error) {
+	input := &ssm.PutParameterInput{
+		Name:      aws.String(name),
+		Value:     aws.String(value),
+		Type:      aws.String(ssm.ParameterTypeString),
+		Overwrite: aws.Bool(opts.Overwrite),
+	}
+	if opts.Type != "" {
+		input.Type = aws.String(opts.Type)
+	}
+	if opts.KeyID != "" {
+		if opts.Type != ssm.ParameterTypeSecureString {
+			return nil, fmt.Errorf("kms key %s requires type %s, got %s",
+				opts.KeyID, ssm.ParameterTypeSecureString, aws.StringValue(input.Type))
+		}
+		input.KeyId = aws.String(opts.KeyID)
+	}
+	if opts.Description != "" {
+		input.Description = aws.String(opts.Description)
+	}
+	if opts.AllowedPattern != "" {
+		input.AllowedPattern = aws.String(opts.AllowedPattern)
+	}
+	if opts.Tier != "" {
+		input.Tier = aws.String(opts.Tier)
+	}
+	if opts.Policies != nil && !opts.Policies.Empty() {
+		if opts.Tier != "" && opts.Tier != ssm.ParameterTierAdvanced {
+			return nil, fmt.Errorf("parameter policies require tier %s, got %s",
+				ssm.ParameterTierAdvanced, opts.Tier)
+		}
+		policies, err := opts.Policies.JSON()
+		if err != nil {
+			return nil, err
+		}
+		input.Tier = aws.String(ssm.ParameterTierAdvanced)
+		input.Policies = aws.String(policies)
+	}
+	if len(opts.Tags) != 0 {
+		if opts.Overwrite {
+			return nil, fmt.Errorf("tags can not be put w/ overwrite, add tags to resource instead")
+		}
+		for key, val := range opts.Tags {
+			input.Tags = append(input.Tags, &ssm.Tag{Key: aws.String(key), Value: aws.String(val)})
+		}
+	}
+	return input, nil
+}
+
+// PutParameterWithOptions puts a parameter w/ tier and policies,
+// it returns the new version. Values are never logged.
+func (api *AWSAPI) PutParameterWithOptions(svc ssmiface.SSMAPI,
+	name, value string, opts PutParameterOptions) (int64, error) {
+	return api.PutParameterWithOptionsWithContext(aws.BackgroundContext(), svc, name, value, opts)
+}
+
+// PutParameterWithOptionsWithContext is PutParameterWithOptions w/ a context and request options
+func (api *AWSAPI) PutParameterWithOptionsWithContext(ctx context.Context, svc ssmiface.SSMAPI,
+	name, value string, opts PutParameterOptions, reqOpts ...request.Option) (int64, error) {
+	input, err := opts.putInput(name, value)
+	if err != nil {
+		return 0, err
+	}
+	result, err := svc.PutParameterWithContext(ctx, input, reqOpts...)
+	if err != nil {
+		log.Printf("SSMError: fail to put parameter %s (type=%s, tier=%s, overwrite=%t), err: %v\n",
+			name, aws.StringValue(input.Type), aws.StringValue(input.Tier), opts.Overwrite, err)
+		return 0, err
+	}
+	log.Printf("SSM: succeed to put parameter %s (type=%s, tier=%s, overwrite=%t), version %d\n",
+		name, aws.StringValue(input.Type), aws.StringValue(input.Tier), opts.Overwrite,
+		aws.Int64Value(result.Version))
+	return aws.Int64Value(result.Version), nil
+}
+
+// GetParameter gets a decrypted parameter by name,
+// the name may select a version or label, e.g. "/app/token:3" or "/app/token:prod"
+func (api *AWSAPI) GetParameter(svc ssmiface.SSMAPI, name string) (*ParameterInfo, error) {
+	return api.GetParameterWithContext(aws.BackgroundContext(), svc, name)
+}
+
+// GetParameterWithContext is GetParameter w/ a context and request options
+func (api *AWSAPI) GetParameterWithContext(ctx context.Context, svc ssmiface.SSMAPI,
+	name string, opts ...request.Option) (*ParameterInfo, error) {
+	input := &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	}
+	result, err := svc.GetParameterWithContext(ctx, input, opts...)
+	if err != nil {
+		log.Printf("SSMError: fail to get parameter %s, err: %v\n", name, err)
+		return nil, err
+	}
+	info := newParameterInfo(result.Parameter)
+	return &info, nil
+}
+
+// GetParameters gets decrypted parameters by names in requests of 10 names,
+// it returns the found parameters by requested name and the invalid names
+func (api *AWSAPI) GetParameters(svc ssmiface.SSMAPI,
+	names []string) (map[string]ParameterInfo, []string, error) {
+	return api.GetParametersWithContext(aws.BackgroundContext(), svc, names)
+}
+
+// GetParametersWithContext is GetParameters w/ a context and request options
+func (api *AWSAPI) GetParametersWithContext(ctx context.Context, svc ssmiface.SSMAPI,
+	names []string, opts ...request.Option) (map[string]ParameterInfo, []string, error) {
+	found := make(map[string]ParameterInfo, len(names))
+	invalid := make([]string, 0)
+	for start := 0; start < len(names); start += deGetParametersSize {
+		end := start + deGetParametersSize
+		if end > len(names) {
+			end = len(names)
+		}
+		input := &ssm.GetParametersInput{
+			Names:          aws.StringSlice(names[start:end]),
+			WithDecryption: aws.Bool(true),
+		}
+		result, err := svc.GetParametersWithContext(ctx, input, opts...)
+		if err != nil {
+			log.Printf("SSMError: fail to get parameters %v, err: %v\n", names[start:end], err)
+			return nil, nil, err
+		}
+		for _, param := range result.Parameters {
+			info := newParameterInfo(param)
+			found[info.Name+info.Selector] = info
+		}
+		invalid = append(invalid, aws.StringValueSlice(result.InvalidParameters)...)
+	}
+	return found, invalid, nil
+}
+
+// GetParameterHistory returns all versions of a parameter, oldest first
+func (api *AWSAPI) GetParameterHistory(svc ssmiface.SSMAPI,
+	name string) ([]ParameterVersion, error) {
+	return api.GetParameterHistoryWithContext(aws.BackgroundContext(), svc, name)
+}
+
+// GetParameterHistoryWithContext is GetParameterHistory w/ a context and request options
+func (api *AWSAPI) GetParameterHistoryWithContext(ctx context.Context, svc ssmiface.SSMAPI,
+	name string, opts ...request.Option) ([]ParameterVersion, error) {
+	input := &ssm.GetParameterHistoryInput{
+		Name:           aws.String(name),
+		MaxResults:     aws.Int64(deHistoryPageSize),
+		WithDecryption: aws.Bool(true),
+	}
+	versions := make([]ParameterVersion, 0)
+	for {
+		result, err := svc.GetParameterHistoryWithContext(ctx, input, opts...)
+		if err != nil {
+			log.Printf("SSMError: fail to get parameter history of %s, err: %v\n", name, err)
+			return nil, err
+		}
+		for _, history := range result.Parameters {
+			versions = append(versions, newParameterVersion(history))
+		}
+		if result.NextToken == nil {
+			return versions, nil
+		}
+		input.NextToken = result.NextToken
+	}
+}
+
+// LabelParameterVersion attaches labels to a version of a parameter (the latest if 0),
+// a label attached to another version is moved. It returns the invalid labels.
+func (api *AWSAPI) LabelParameterVersion(svc ssmiface.SSMAPI,
+	name string, version int64, labels ...string) ([]string, error) {
+	return api.LabelParameterVersionWithContext(aws.BackgroundContext(), svc,
+		name, version, labels)
+}
+
+// LabelParameterVersionWithContext is LabelParameterVersion w/ a context and request options
+func (api *AWSAPI) LabelParameterVersionWithContext(ctx context.Context, svc ssmiface.SSMAPI,
+	name string, version int64, labels []string, opts ...request.Option) ([]string, error) {
+	input := &ssm.LabelParameterVersionInput{
+		Name:   aws.String(name),
+		Labels: aws.StringSlice(labels),
+	}
+	if version > 0 {
+		input.ParameterVersion = aws.Int64(version)
+	}
+	result, err := svc.LabelParameterVersionWithContext(ctx, input, opts...)
+	if err != nil {
+		log.Printf("SSMError: fail to label parameter %s:%d w/ %v, err: %v\n",
+			name, version, labels, err)
+		return nil, err
+	}
+	invalid := aws.StringValueSlice(result.InvalidLabels)
+	if len(invalid) != 0 {
+		log.Printf("SSMError: invalid labels %v of parameter %s\n", invalid, name)
+	}
+	return invalid, nil
+}
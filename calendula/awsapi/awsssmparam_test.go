@@ -0,0 +1,190 @@
+package awsapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+func TestGetParameter(t *testing.T) {
+	api := &AWSAPI{}
+	svc := mock.SSMParameterStore("/app/token=v1")
+	svc.SetParameter("/app/token", "v2", ssm.ParameterTypeSecureString)
+
+	param, err := api.GetParameter(svc, "/app/token")
+	assert.Nil(t, err)
+	assert.Equal(t, &ParameterInfo{
+		Name:         "/app/token",
+		Value:        "v2",
+		Type:         "SecureString",
+		Version:      2,
+		LastModified: time.Date(2019, 7, 1, 0, 2, 0, 0, time.UTC),
+		ARN:          "arn:aws:ssm:us-east-1:123456789012:parameter/app/token",
+	}, param)
+
+	param, err = api.GetParameter(svc, "/app/token:1")
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", param.Value)
+	assert.Equal(t, ":1", param.Selector)
+
+	_, err = api.GetParameter(svc, "/app/missing")
+	assert.NotNil(t, err)
+	_, err = api.GetParameter(svc, "/app/token:3")
+	assert.NotNil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = api.GetParameterWithContext(ctx, svc, "/app/token")
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestGetParameters(t *testing.T) {
+	api := &AWSAPI{}
+	svc := mock.SSMParameterStore()
+	names := make([]string, 0)
+	for idx := 0; idx < 12; idx++ {
+		name := fmt.Sprintf("/app/p%02d", idx)
+		svc.SetParameter(name, fmt.Sprintf("v%d", idx), ssm.ParameterTypeString)
+		names = append(names, name)
+	}
+	names = append(names, "/app/missing", "/app/p00:1")
+
+	found, invalid, err := api.GetParameters(svc, names)
+	assert.Nil(t, err)
+	assert.Equal(t, 13, len(found))
+	assert.Equal(t, "v11", found["/app/p11"].Value)
+	assert.Equal(t, "v0", found["/app/p00:1"].Value)
+	assert.Equal(t, []string{"/app/missing"}, invalid)
+	// 14 names in requests of 10
+	assert.Equal(t, 2, svc.GetCalls())
+
+	svc.SetGetError(errors.New("ThrottlingException"))
+	_, _, err = api.GetParameters(svc, names)
+	assert.EqualError(t, err, "ThrottlingException")
+}
+
+func TestGetParameterHistory(t *testing.T) {
+	api := &AWSAPI{}
+	svc := mock.SSMParameterStore()
+	for idx := 1; idx <= 60; idx++ {
+		svc.SetParameter("/app/token", fmt.Sprintf("v%d", idx), ssm.ParameterTypeSecureString)
+	}
+	versions, err := api.GetParameterHistory(svc, "/app/token")
+	assert.Nil(t, err)
+	assert.Equal(t, 60, len(versions))
+	assert.Equal(t, ParameterVersion{
+		Name:             "/app/token",
+		Value:            "v60",
+		Type:             "SecureString",
+		Version:          60,
+		LastModified:     time.Date(2019, 7, 1, 1, 0, 0, 0, time.UTC),
+		LastModifiedUser: "arn:aws:iam::123456789012:user/fake",
+		KeyID:            "alias/aws/ssm",
+		Labels:           []string{},
+	}, versions[59])
+
+	_, err = api.GetParameterHistory(svc, "/app/missing")
+	assert.NotNil(t, err)
+}
+
+func TestLabelParameterVersion(t *testing.T) {
+	api := &AWSAPI{}
+	svc := mock.SSMParameterStore("/app/token=v1")
+	svc.SetParameter("/app/token", "v2", ssm.ParameterTypeString)
+
+	invalid, err := api.LabelParameterVersion(svc, "/app/token", 1, "prod", "aws-x", "1st")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"aws-x", "1st"}, invalid)
+	param, err := api.GetParameter(svc, "/app/token:prod")
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", param.Value)
+
+	// the label moves to the latest version
+	invalid, err = api.LabelParameterVersion(svc, "/app/token", 0, "prod")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(invalid))
+	versions, _ := api.GetParameterHistory(svc, "/app/token")
+	assert.Equal(t, []string{}, versions[0].Labels)
+	assert.Equal(t, []string{"prod"}, versions[1].Labels)
+
+	_, err = api.LabelParameterVersion(svc, "/app/token", 3, "prod")
+	assert.NotNil(t, err)
+}
+
+func TestParameterPoliciesJSON(t *testing.T) {
+	policies := &ParameterPolicies{}
+	assert.True(t, policies.Empty())
+
+	policies = &ParameterPolicies{
+		Expiration:             time.Date(2019, 12, 2, 21, 34, 33, 0, time.UTC),
+		ExpirationNotification: 15 * 24 * time.Hour,
+		NoChangeNotification:   5 * time.Hour,
+	}
+	text, err := policies.JSON()
+	assert.Nil(t, err)
+	assert.Equal(t, `[{"Type":"Expiration","Version":"1.0","Attributes":`+
+		`{"Timestamp":"2019-12-02T21:34:33.000Z"}},`+
+		`{"Type":"ExpirationNotification","Version":"1.0","Attributes":{"Before":"15","Unit":"Days"}},`+
+		`{"Type":"NoChangeNotification","Version":"1.0","Attributes":{"After":"5","Unit":"Hours"}}]`,
+		text)
+
+	_, err = (&ParameterPolicies{ExpirationNotification: time.Hour}).JSON()
+	assert.EqualError(t, err, "expiration notification requires an expiration")
+	_, err = (&ParameterPolicies{NoChangeNotification: time.Minute}).JSON()
+	assert.EqualError(t, err, "no change notification must be a positive number of hours, got 1m0s")
+}
+
+func TestPutParameterWithOptions(t *testing.T) {
+	api := &AWSAPI{}
+	svc := mock.SSMParameterStore()
+	version, err := api.PutParameterWithOptions(svc, "/app/token", "secret", PutParameterOptions{
+		Type:        ssm.ParameterTypeSecureString,
+		KeyID:       "alias/app",
+		Description: "token",
+		Policies:    &ParameterPolicies{NoChangeNotification: 30 * 24 * time.Hour},
+		Tags:        map[string]string{"team": "infra"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), version)
+	versions, _ := api.GetParameterHistory(svc, "/app/token")
+	assert.Equal(t, "Advanced", versions[0].Tier)
+	assert.Equal(t, "alias/app", versions[0].KeyID)
+	assert.Equal(t, []ParameterPolicyStatus{{
+		Type:   "NoChangeNotification",
+		Text:   `{"Attributes":{"After":"30","Unit":"Days"},"Type":"NoChangeNotification","Version":"1.0"}`,
+		Status: "Pending",
+	}}, versions[0].Policies)
+	tags, _ := api.ListTagsForResource(svc, "/app/token", "Parameter")
+	assert.Equal(t, map[string]string{"team": "infra"}, tags)
+
+	version, err = api.PutParameterWithOptions(svc, "/app/token", "secret2",
+		PutParameterOptions{Type: ssm.ParameterTypeSecureString, Overwrite: true})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), version)
+	_, err = api.PutParameterWithOptions(svc, "/app/token", "x", PutParameterOptions{})
+	assert.NotNil(t, err)
+
+	invalids := []PutParameterOptions{
+		{KeyID: "alias/app"},
+		{Tier: ssm.ParameterTierStandard, Policies: &ParameterPolicies{NoChangeNotification: time.Hour}},
+		{Policies: &ParameterPolicies{NoChangeNotification: time.Second}},
+		{Tags: map[string]string{"a": "b"}, Overwrite: true},
+	}
+	for _, opts := range invalids {
+		_, err = api.PutParameterWithOptions(svc, "/app/other", "x", opts)
+		assert.NotNil(t, err)
+	}
+	assert.Equal(t, 2, len(svc.PutInputs()))
+
+	input, err := (&PutParameterOptions{}).putInput("/app/name", "value")
+	assert.Nil(t, err)
+	assert.Equal(t, aws.String(ssm.ParameterTypeString), input.Type)
+	assert.Nil(t, input.Tier)
+}
@@ -7,12 +7,23 @@ import (
 	"github.com/aws/aws-sdk-go/service/support/supportiface"
 )
 
-// NewSupportAPI returns a s3 client
-func NewSupportAPI(sess AWSSession) supportiface.SupportAPI {
+// NewSupportClient returns a support client, or an error if the session fails
+func NewSupportClient(sess AWSSession) (supportiface.SupportAPI, error) {
 	awssess, err := sess.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	return support.New(awssess), nil
+}
+
+// NewSupportAPI returns a support client, or nil if the session fails.
+//
+// Deprecated: use NewSupportClient or Clients.Support, which return the error.
+func NewSupportAPI(sess AWSSession) supportiface.SupportAPI {
+	svc, err := NewSupportClient(sess)
 	if err != nil {
 		log.Printf("AWSError: Cannot create aws session. err %#v", err)
 		return nil
 	}
-	return support.New(awssess)
+	return svc
 }
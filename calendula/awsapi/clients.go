@@ -0,0 +1,194 @@
+package awsapi
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	cwe "github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	cweiface "github.com/aws/aws-sdk-go/service/cloudwatchevents/cloudwatcheventsiface"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/aws/aws-sdk-go/service/support"
+	"github.com/aws/aws-sdk-go/service/support/supportiface"
+)
+
+// Clients lazily builds aws service clients from one session,
+// each client is created on first use and reused afterwards.
+type Clients struct {
+	Session AWSSession
+	mutex   sync.Mutex
+	sess    *session.Session
+	iface   AWSIface
+}
+
+// NewClients returns a *Clients w/ a session factory
+func NewClients(sess AWSSession) *Clients {
+	return &Clients{Session: sess}
+}
+
+// session returns the shared session, must be called w/ mutex locked
+func (clients *Clients) session() (*session.Session, error) {
+	if clients.sess != nil {
+		return clients.sess, nil
+	}
+	sess, err := clients.Session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	clients.sess = sess
+	return sess, nil
+}
+
+// S3 returns a s3 client
+func (clients *Clients) S3() (s3iface.S3API, error) {
+	clients.mutex.Lock()
+	defer clients.mutex.Unlock()
+	return clients.s3()
+}
+
+func (clients *Clients) s3() (s3iface.S3API, error) {
+	if clients.iface.S3SVC != nil {
+		return clients.iface.S3SVC, nil
+	}
+	sess, err := clients.session()
+	if err != nil {
+		return nil, err
+	}
+	clients.iface.S3SVC = s3.New(sess)
+	return clients.iface.S3SVC, nil
+}
+
+// S3Uploader returns a s3manager uploader sharing the s3 client
+func (clients *Clients) S3Uploader() (s3manageriface.UploaderAPI, error) {
+	clients.mutex.Lock()
+	defer clients.mutex.Unlock()
+	if clients.iface.S3ManagerSVC != nil {
+		return clients.iface.S3ManagerSVC, nil
+	}
+	svc, err := clients.s3()
+	if err != nil {
+		return nil, err
+	}
+	clients.iface.S3ManagerSVC = s3manager.NewUploaderWithClient(svc)
+	return clients.iface.S3ManagerSVC, nil
+}
+
+// S3Downloader returns a s3manager downloader sharing the s3 client
+func (clients *Clients) S3Downloader() (s3manageriface.DownloaderAPI, error) {
+	clients.mutex.Lock()
+	defer clients.mutex.Unlock()
+	if clients.iface.S3DownloaderSVC != nil {
+		return clients.iface.S3DownloaderSVC, nil
+	}
+	svc, err := clients.s3()
+	if err != nil {
+		return nil, err
+	}
+	clients.iface.S3DownloaderSVC = s3manager.NewDownloaderWithClient(svc)
+	return clients.iface.S3DownloaderSVC, nil
+}
+
+// SSM returns a ssm client
+func (clients *Clients) SSM() (ssmiface.SSMAPI, error) {
+	clients.mutex.Lock()
+	defer clients.mutex.Unlock()
+	if clients.iface.SSMSVC != nil {
+		return clients.iface.SSMSVC, nil
+	}
+	sess, err := clients.session()
+	if err != nil {
+		return nil, err
+	}
+	clients.iface.SSMSVC = ssm.New(sess)
+	return clients.iface.SSMSVC, nil
+}
+
+// KMS returns a kms client
+func (clients *Clients) KMS() (kmsiface.KMSAPI, error) {
+	clients.mutex.Lock()
+	defer clients.mutex.Unlock()
+	if clients.iface.KMSSVC != nil {
+		return clients.iface.KMSSVC, nil
+	}
+	sess, err := clients.session()
+	if err != nil {
+		return nil, err
+	}
+	clients.iface.KMSSVC = kms.New(sess)
+	return clients.iface.KMSSVC, nil
+}
+
+// Lambda returns a lambda client
+func (clients *Clients) Lambda() (lambdaiface.LambdaAPI, error) {
+	clients.mutex.Lock()
+	defer clients.mutex.Unlock()
+	if clients.iface.LambdaSVC != nil {
+		return clients.iface.LambdaSVC, nil
+	}
+	sess, err := clients.session()
+	if err != nil {
+		return nil, err
+	}
+	clients.iface.LambdaSVC = lambda.New(sess)
+	return clients.iface.LambdaSVC, nil
+}
+
+// CloudWatchEvents returns a cloudwatch events client
+func (clients *Clients) CloudWatchEvents() (cweiface.CloudWatchEventsAPI, error) {
+	clients.mutex.Lock()
+	defer clients.mutex.Unlock()
+	if clients.iface.CWESVC != nil {
+		return clients.iface.CWESVC, nil
+	}
+	sess, err := clients.session()
+	if err != nil {
+		return nil, err
+	}
+	clients.iface.CWESVC = cwe.New(sess)
+	return clients.iface.CWESVC, nil
+}
+
+// Support returns a support client
+func (clients *Clients) Support() (supportiface.SupportAPI, error) {
+	clients.mutex.Lock()
+	defer clients.mutex.Unlock()
+	if clients.iface.SupportSVC != nil {
+		return clients.iface.SupportSVC, nil
+	}
+	sess, err := clients.session()
+	if err != nil {
+		return nil, err
+	}
+	clients.iface.SupportSVC = support.New(sess)
+	return clients.iface.SupportSVC, nil
+}
+
+// All builds every client and returns a fully-populated *AWSIface
+func (clients *Clients) All() (*AWSIface, error) {
+	for _, build := range []func() error{
+		func() error { _, err := clients.S3(); return err },
+		func() error { _, err := clients.S3Uploader(); return err },
+		func() error { _, err := clients.S3Downloader(); return err },
+		func() error { _, err := clients.SSM(); return err },
+		func() error { _, err := clients.KMS(); return err },
+		func() error { _, err := clients.Lambda(); return err },
+		func() error { _, err := clients.CloudWatchEvents(); return err },
+		func() error { _, err := clients.Support(); return err },
+	} {
+		if err := build(); err != nil {
+			return nil, err
+		}
+	}
+	clients.mutex.Lock()
+	defer clients.mutex.Unlock()
+	iface := clients.iface
+	return &iface, nil
+}
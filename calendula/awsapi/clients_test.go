@@ -0,0 +1,56 @@
+package awsapi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+func TestClientsLazy(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockSession := mock.NewMockAWSSession(mockCtrl)
+	sess, err := NewSessionFactory(SessionConfig{Region: "us-east-1"}).NewSession()
+	assert.Nil(t, err)
+	mockSession.EXPECT().NewSession().Return(sess, nil).Times(1)
+
+	clients := NewClients(mockSession)
+	s3svc, err := clients.S3()
+	assert.Nil(t, err)
+	assert.NotNil(t, s3svc)
+	cached, err := clients.S3()
+	assert.Nil(t, err)
+	assert.True(t, s3svc == cached)
+
+	iface, err := clients.All()
+	assert.Nil(t, err)
+	assert.True(t, s3svc == iface.S3SVC)
+	assert.NotNil(t, iface.S3ManagerSVC)
+	assert.NotNil(t, iface.S3DownloaderSVC)
+	assert.NotNil(t, iface.SSMSVC)
+	assert.NotNil(t, iface.KMSSVC)
+	assert.NotNil(t, iface.LambdaSVC)
+	assert.NotNil(t, iface.CWESVC)
+	assert.NotNil(t, iface.SupportSVC)
+}
+
+func TestClientsError(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockSession := mock.NewMockAWSSession(mockCtrl)
+	mockSession.EXPECT().NewSession().Return(
+		(*session.Session)(nil), errors.New("FakeNewSessionError")).Times(2)
+
+	clients := NewClients(mockSession)
+	ssmsvc, err := clients.SSM()
+	assert.EqualError(t, err, "FakeNewSessionError")
+	assert.Nil(t, ssmsvc)
+
+	iface, err := clients.All()
+	assert.EqualError(t, err, "FakeNewSessionError")
+	assert.Nil(t, iface)
+}
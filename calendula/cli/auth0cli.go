@@ -1,11 +1,15 @@
 package cli
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"time"
 
 	"github.com/xinnige/asteraceae/calendula/auth0api"
+	"github.com/xinnige/asteraceae/calendula/auth0sync"
 	"github.com/xinnige/asteraceae/calendula/utils"
 )
 
@@ -15,11 +19,19 @@ type Auth0CLI struct {
 	client   *auth0api.Auth0Client
 	token    string
 	endpoint string
+	tenant   *string
+	config   *string
 }
 
 const (
 	cmdGetUser   = "get-user"
 	cmdListUsers = "list-users"
+	cmdTailLogs  = "tail-logs"
+	cmdSyncPlan  = "sync-plan"
+	cmdStale     = "stale-users"
+
+	envTenant = "AUTH_TENANT"
+	envConfig = "AUTH_CONFIG"
 )
 
 // NewAuth0CLI return a CLI controller
@@ -31,7 +43,46 @@ func NewAuth0CLI() *Auth0CLI {
 		client:   auth0api.NewAuth0Client(token, endpoint),
 		token:    token,
 		endpoint: endpoint,
+		tenant:   new(string),
+		config:   new(string),
+	}
+}
+
+// flagSet returns a FlagSet w/ tenant selectors
+func (cli *Auth0CLI) flagSet(name string) *flag.FlagSet {
+	cmd := flag.NewFlagSet(name, cli.ErrorBehavior)
+	cli.tenant = cmd.String("tenant", utils.GetEnv(envTenant, ""),
+		"specify the tenant profile to use (default tenant of config if empty)")
+	cli.config = cmd.String("config", utils.GetEnv(envConfig, ""),
+		"specify the tenant profile config file (env token/endpoint if empty)")
+	return cmd
+}
+
+// setup selects the tenant after flags are parsed
+func (cli *Auth0CLI) setup() error {
+	if *cli.config == "" {
+		if *cli.tenant != "" {
+			return fmt.Errorf("tenant %q requires a config file (-config or %s)",
+				*cli.tenant, envConfig)
+		}
+		return cli.audit()
+	}
+	config, err := auth0api.LoadTenantConfig(*cli.config, &utils.JSONAPI{})
+	if err != nil {
+		return err
+	}
+	profile, err := config.Tenant(*cli.tenant)
+	if err != nil {
+		return err
 	}
+	client, err := auth0api.NewAuth0ClientFromProfile(profile)
+	if err != nil {
+		return err
+	}
+	cli.client = client
+	cli.endpoint = client.Endpoint.URL
+	cli.token, _ = profile.Token()
+	return cli.audit()
 }
 
 func (cli *Auth0CLI) audit() error {
@@ -49,32 +100,36 @@ func (cli *Auth0CLI) Commands() map[string]func() {
 	mapper := map[string]func(){
 		cmdGetUser:   cli.methodGetUser,
 		cmdListUsers: cli.methodListUser,
+		cmdTailLogs:  cli.methodTailLogs,
+		cmdSyncPlan:  cli.methodSyncPlan,
+		cmdStale:     cli.methodStaleUsers,
 	}
 	return mapper
 }
 
 // methodGetUser helps to get a user info by user_id
 func (cli *Auth0CLI) methodGetUser() {
-	if err := cli.audit(); err != nil {
-		fmt.Printf("CLIError: %v\n", err)
-		return
-	}
-
-	cmd := flag.NewFlagSet(cmdGetUser, cli.ErrorBehavior)
+	cmd := cli.flagSet(cmdGetUser)
 	name := cmd.String("name", "", "specify the unique user name")
+	conn := cmd.String("connection", "",
+		"specify the connection of the user (default connection if empty)")
 
 	err := cmd.Parse(os.Args[2:])
 	if err != nil || !cmd.Parsed() {
 		fmt.Printf("Cannot parse arguments (%v)\n", err)
 		return
 	}
+	if err := cli.setup(); err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
 	if *name == "" {
 		fmt.Println("Invalid input parameters, -h to show help message.")
 		fmt.Println("user name cannot be empty")
 		return
 	}
 
-	user, err := cli.client.GetUserByName(*name)
+	user, err := cli.client.GetUserByConnection(*name, *conn)
 	if err != nil {
 		fmt.Printf("Cannot get user of %s\n%v", *name, err)
 		return
@@ -84,12 +139,7 @@ func (cli *Auth0CLI) methodGetUser() {
 
 // methodListUser helps to list users
 func (cli *Auth0CLI) methodListUser() {
-	if err := cli.audit(); err != nil {
-		fmt.Printf("CLIError: %v\n", err)
-		return
-	}
-
-	cmd := flag.NewFlagSet(cmdListUsers, cli.ErrorBehavior)
+	cmd := cli.flagSet(cmdListUsers)
 	start := cmd.Int("start", 0, "specify the page number to start (from 0)")
 	limit := cmd.Int("limit", -1, "specify the number of users to list")
 
@@ -98,6 +148,10 @@ func (cli *Auth0CLI) methodListUser() {
 		fmt.Printf("Cannot parse arguments (%v)\n", err)
 		return
 	}
+	if err := cli.setup(); err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
 
 	users, err := cli.client.ListUsers(*start, *limit)
 	if err != nil {
@@ -106,3 +160,203 @@ func (cli *Auth0CLI) methodListUser() {
 	}
 	fmt.Printf("Total: %d users", len(users))
 }
+
+// methodTailLogs helps to export tenant log events as json lines
+func (cli *Auth0CLI) methodTailLogs() {
+	cmd := cli.flagSet(cmdTailLogs)
+	from := cmd.String("from", "", "specify the log_id to start after")
+	limit := cmd.Int("limit", 0, "specify the number of events to list (0 for all)")
+	types := cmd.String("type", "",
+		"specify comma separated event codes to filter results (e.g. s,f,fp)")
+	follow := cmd.Bool("follow", false, "keep polling for new events")
+	checkpoint := cmd.String("checkpoint", "",
+		"specify a file to persist the last log_id while following")
+	interval := cmd.Duration("interval", 10*time.Second,
+		"specify the polling interval while following")
+
+	err := cmd.Parse(os.Args[2:])
+	if err != nil || !cmd.Parsed() {
+		fmt.Printf("Cannot parse arguments (%v)\n", err)
+		return
+	}
+	if err := cli.setup(); err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
+
+	printEvent := func(event *auth0api.LogEvent) error {
+		fmt.Printf("%s\n", utils.Marshal(event, &utils.JSONAPI{}))
+		return nil
+	}
+
+	if !*follow {
+		events, last, err := cli.client.ListLogs(
+			*from, *limit, auth0api.ParseLogTypes(*types))
+		for idx := range events {
+			printEvent(&events[idx])
+		}
+		if err != nil {
+			fmt.Printf("Cannot list logs from %q\n%v\n", *from, err)
+		}
+		fmt.Fprintf(os.Stderr, "Total: %d events, checkpoint %q\n", len(events), last)
+		return
+	}
+
+	if *checkpoint == "" {
+		fmt.Println("Invalid input parameters, -h to show help message.")
+		fmt.Println("checkpoint file cannot be empty in follow mode")
+		return
+	}
+	store := &auth0api.FileCheckpoint{Path: *checkpoint}
+	if *from != "" {
+		if err := store.Save(*from); err != nil {
+			fmt.Printf("Cannot save checkpoint %s\n%v\n", *checkpoint, err)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	go func() {
+		<-sigs
+		cancel()
+	}()
+
+	if err := cli.client.TailLogs(ctx, store, *interval,
+		auth0api.ParseLogTypes(*types), printEvent); err != nil {
+		fmt.Printf("Cannot tail logs\n%v\n", err)
+	}
+}
+
+// methodSyncPlan helps to reconcile auth0 users w/ a directory export
+func (cli *Auth0CLI) methodSyncPlan() {
+	cmd := cli.flagSet(cmdSyncPlan)
+	source := cmd.String("source", "",
+		"specify the directory export file (.csv, .json or .ldif)")
+	keys := cmd.String("keys", "user_id,dn,email",
+		"specify comma separated keys to match users in order")
+	orphans := cmd.String("orphans", string(auth0sync.OrphanIgnore),
+		"specify how to handle auth0 users missing in the directory (ignore|block|delete)")
+	limit := cmd.Int("limit", 10000, "specify the max number of auth0 users to compare")
+	apply := cmd.Bool("apply", false, "apply the plan (dry-run by default)")
+	asJSON := cmd.Bool("json", false, "print the plan in json")
+
+	err := cmd.Parse(os.Args[2:])
+	if err != nil || !cmd.Parsed() {
+		fmt.Printf("Cannot parse arguments (%v)\n", err)
+		return
+	}
+	if err := cli.setup(); err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
+	if *source == "" {
+		fmt.Println("Invalid input parameters, -h to show help message.")
+		fmt.Println("source file cannot be empty")
+		return
+	}
+	switch auth0sync.OrphanAction(*orphans) {
+	case auth0sync.OrphanIgnore, auth0sync.OrphanBlock, auth0sync.OrphanDelete:
+	default:
+		fmt.Printf("Invalid orphans action %q, -h to show help message.\n", *orphans)
+		return
+	}
+	matchKeys, err := auth0sync.ParseMatchKeys(*keys)
+	if err != nil {
+		fmt.Printf("Invalid match keys %s\n%v\n", *keys, err)
+		return
+	}
+
+	directory, err := auth0sync.LoadDirectory(*source, &utils.JSONAPI{})
+	if err != nil {
+		fmt.Printf("Cannot load directory %s\n%v\n", *source, err)
+		return
+	}
+	users, err := cli.client.ListUsers(0, *limit)
+	if err != nil {
+		fmt.Printf("Cannot list users of (0,%d)\n%v\n", *limit, err)
+		return
+	}
+
+	planner := auth0sync.NewPlanner(matchKeys, auth0sync.OrphanAction(*orphans))
+	plan, err := planner.Plan(users, directory)
+	if err != nil {
+		fmt.Printf("Cannot build sync plan\n%v\n", err)
+		return
+	}
+	if *asJSON {
+		fmt.Printf("%s\n", utils.MarshalIndent(plan, "", "  ", &utils.JSONAPI{}))
+	} else {
+		plan.Render(os.Stdout)
+	}
+
+	result := plan.Apply(cli.client, auth0sync.ApplyOptions{
+		DryRun:     !*apply,
+		Connection: cli.client.Endpoint.Connection,
+		Provider:   cli.client.Endpoint.Provider,
+	})
+	result.Render(os.Stdout)
+}
+
+// methodStaleUsers helps to report inactive accounts
+func (cli *Auth0CLI) methodStaleUsers() {
+	cmd := cli.flagSet(cmdStale)
+	inactive := cmd.Int("inactive-days", 90,
+		"report users whose last login is older than N days (0 to disable)")
+	never := cmd.Int("never-days", 30,
+		"report users never logged in and created more than N days ago (0 to disable)")
+	app := cmd.Int("app-days", 30,
+		"report lambda_authorizer users w/o login in N days (0 to disable)")
+	skipBlocked := cmd.Bool("skip-blocked", true, "ignore blocked users")
+	limit := cmd.Int("limit", 10000, "specify the max number of users to check")
+	format := cmd.String("format", "csv", "specify the output format (csv|json)")
+	output := cmd.String("output", "", "specify the output file (stdout if empty)")
+
+	err := cmd.Parse(os.Args[2:])
+	if err != nil || !cmd.Parsed() {
+		fmt.Printf("Cannot parse arguments (%v)\n", err)
+		return
+	}
+	if err := cli.setup(); err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
+	if *format != "csv" && *format != "json" {
+		fmt.Printf("Invalid format %q, -h to show help message.\n", *format)
+		return
+	}
+
+	users, err := cli.client.ListUsers(0, *limit)
+	if err != nil {
+		fmt.Printf("Cannot list users of (0,%d)\n%v\n", *limit, err)
+		return
+	}
+	stale := auth0api.FindStaleUsers(users, auth0api.StaleCriteria{
+		InactiveDays:       *inactive,
+		NeverLoggedInDays:  *never,
+		AppAccessDays:      *app,
+		IgnoreBlockedUsers: *skipBlocked,
+	})
+
+	writer := os.Stdout
+	if *output != "" {
+		writer, err = os.Create(*output)
+		if err != nil {
+			fmt.Printf("Cannot create %s\n%v\n", *output, err)
+			return
+		}
+		defer writer.Close()
+	}
+	if *format == "json" {
+		err = auth0api.WriteStaleUsersJSON(writer, stale, &utils.JSONAPI{})
+	} else {
+		err = auth0api.WriteStaleUsersCSV(writer, stale)
+	}
+	if err != nil {
+		fmt.Printf("Cannot write report\n%v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Total: %d users, stale: %d users\n", len(users), len(stale))
+}
@@ -16,6 +16,7 @@ const (
 type CLI struct {
 	AWSAPI        *awsapi.AWSAPI
 	AWSClient     *awsapi.AWSIface
+	AWSClients    *awsapi.Clients
 	SerialAPI     utils.SerialInterface
 	ErrorBehavior flag.ErrorHandling
 }
@@ -24,5 +25,7 @@ type CLI struct {
 func NewCLI() *CLI {
 	return &CLI{
 		AWSAPI: &awsapi.AWSAPI{},
+		AWSClients: awsapi.NewClients(
+			awsapi.NewSessionFactory(awsapi.NewSessionConfigFromEnv())),
 	}
 }
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+	"github.com/xinnige/asteraceae/calendula/s3sync"
+	"github.com/xinnige/asteraceae/calendula/utils"
+)
+
+// S3CLI defines s3cli controller
+type S3CLI struct {
+	*CLI
+}
+
+const (
+	cmdSync = "sync"
+)
+
+// NewS3CLI return a CLI controller
+func NewS3CLI() *S3CLI {
+	return &S3CLI{CLI: NewCLI()}
+}
+
+// Commands returns available commands
+func (cli *S3CLI) Commands() map[string]func() {
+	mapper := map[string]func(){
+		cmdSync: cli.methodSync,
+	}
+	return mapper
+}
+
+// methodSync helps to sync a local directory to a s3 prefix
+func (cli *S3CLI) methodSync() {
+	cmd := flag.NewFlagSet(cmdSync, cli.ErrorBehavior)
+	source := cmd.String("source", "", "specify the local directory to sync")
+	bucket := cmd.String("bucket", "", "specify the target bucket")
+	prefix := cmd.String("prefix", "", "specify the target prefix (bucket root if empty)")
+	include := cmd.String("include", "",
+		"specify comma separated glob patterns to sync (all files if empty)")
+	exclude := cmd.String("exclude", "",
+		"specify comma separated glob patterns not to sync")
+	compare := cmd.String("compare", string(s3sync.CompareETag),
+		"specify how to detect changes (etag|mtime|size), etag is mtime for aws:kms objects")
+	deleteExtra := cmd.Bool("delete", false, "delete objects missing in the source directory")
+	dryRun := cmd.Bool("dryrun", false, "print the actions w/o applying them")
+	concurrency := cmd.Int("concurrency", 4, "specify the number of concurrent uploads")
+	sse := cmd.String("sse", "", "specify the server side encryption (AES256|aws:kms)")
+	kmsKey := cmd.String("kms-key", "", "specify the kms key id of aws:kms encryption")
+	asJSON := cmd.Bool("json", false, "print the plan in json")
+
+	err := cmd.Parse(os.Args[2:])
+	if err != nil || !cmd.Parsed() {
+		fmt.Printf("Cannot parse arguments (%v)\n", err)
+		return
+	}
+	if *source == "" || *bucket == "" {
+		fmt.Println("Invalid input parameters, -h to show help message.")
+		fmt.Println("source and bucket cannot be empty")
+		return
+	}
+	mode, err := s3sync.ParseCompareMode(*compare)
+	if err != nil {
+		fmt.Printf("Invalid compare mode %s\n%v\n", *compare, err)
+		return
+	}
+	filter := &s3sync.Filter{
+		Includes: s3sync.ParsePatterns(*include),
+		Excludes: s3sync.ParsePatterns(*exclude),
+	}
+	if err := filter.Validate(); err != nil {
+		fmt.Printf("Invalid filter\n%v\n", err)
+		return
+	}
+	opts := s3sync.ApplyOptions{
+		DryRun:      *dryRun,
+		Concurrency: *concurrency,
+		Upload: awsapi.UploadOptions{
+			ServerSideEncryption: *sse,
+			SSEKMSKeyID:          *kmsKey,
+		},
+	}
+	if err := opts.Upload.Validate(); err != nil {
+		fmt.Printf("Invalid encryption options\n%v\n", err)
+		return
+	}
+
+	s3api, err := cli.AWSClients.S3()
+	if err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
+	uploader, err := cli.AWSClients.S3Uploader()
+	if err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	go func() {
+		<-sigs
+		cancel()
+	}()
+
+	target := s3sync.NewTarget(s3api, uploader)
+	planner := s3sync.NewPlanner(mode, filter, *deleteExtra)
+	plan, err := target.Plan(ctx, planner, *source, *bucket, *prefix)
+	if err != nil {
+		fmt.Printf("Cannot build sync plan of %s\n%v\n", *source, err)
+		return
+	}
+	if *asJSON {
+		fmt.Printf("%s\n", utils.MarshalIndent(plan, "", "  ", &utils.JSONAPI{}))
+	} else {
+		plan.Render(os.Stdout)
+	}
+	if plan.Empty() {
+		return
+	}
+
+	result := plan.Apply(ctx, target, opts)
+	result.Render(os.Stdout)
+}
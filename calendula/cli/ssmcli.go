@@ -0,0 +1,343 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/xinnige/asteraceae/calendula/auth0api"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+	"github.com/xinnige/asteraceae/calendula/slackapi"
+	"github.com/xinnige/asteraceae/calendula/ssmconfig"
+	"github.com/xinnige/asteraceae/calendula/utils"
+)
+
+// SSMCLI defines ssmcli controller
+type SSMCLI struct {
+	*CLI
+}
+
+const (
+	cmdExport   = "export"
+	cmdImport   = "import"
+	cmdDiff     = "diff"
+	cmdRotate   = "rotate"
+	cmdPromote  = "promote"
+	cmdRollback = "rollback"
+)
+
+// NewSSMCLI return a CLI controller
+func NewSSMCLI() *SSMCLI {
+	return &SSMCLI{CLI: NewCLI()}
+}
+
+// Commands returns available commands
+func (cli *SSMCLI) Commands() map[string]func() {
+	mapper := map[string]func(){
+		cmdExport:   cli.methodExport,
+		cmdImport:   cli.methodImport,
+		cmdDiff:     cli.methodDiff,
+		cmdRotate:   cli.methodRotate,
+		cmdPromote:  cli.methodPromote,
+		cmdRollback: cli.methodRollback,
+	}
+	return mapper
+}
+
+// methodExport helps to export parameters of a path to a file
+func (cli *SSMCLI) methodExport() {
+	cmd := flag.NewFlagSet(cmdExport, cli.ErrorBehavior)
+	path := cmd.String("path", "", "specify the parameter path to export")
+	output := cmd.String("output", "", "specify the file to write")
+	secrets := cmd.Bool("secrets", false, "export decrypted SecureString values")
+	tags := cmd.Bool("tags", true, "export tags of parameters")
+	format := cmd.String("format", "",
+		"specify the file format json or yaml (by the file extension if empty)")
+
+	err := cmd.Parse(os.Args[2:])
+	if err != nil || !cmd.Parsed() {
+		fmt.Printf("Cannot parse arguments (%v)\n", err)
+		return
+	}
+	if *path == "" || *output == "" {
+		fmt.Println("Invalid input parameters, -h to show help message.")
+		fmt.Println("path and output cannot be empty")
+		return
+	}
+	siface, err := ssmconfig.ExportSerializer(*format, *output)
+	if err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
+	svc, err := cli.AWSClients.SSM()
+	if err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
+	export, err := ssmconfig.ExportPath(context.Background(), &awsapi.AWSAPI{}, svc, *path,
+		ssmconfig.ExportOptions{Secrets: *secrets, Tags: *tags})
+	if err != nil {
+		fmt.Printf("Cannot export %s\n%v\n", *path, err)
+		return
+	}
+	if err := export.Save(*output, siface); err != nil {
+		fmt.Printf("Cannot write %s\n%v\n", *output, err)
+		return
+	}
+	fmt.Printf("Exported %d parameters of %s to %s\n", len(export.Parameters), *path, *output)
+}
+
+// methodImport helps to import an export file into a path
+func (cli *SSMCLI) methodImport() {
+	cmd := flag.NewFlagSet(cmdImport, cli.ErrorBehavior)
+	input := cmd.String("input", "", "specify the export file to import")
+	path := cmd.String("path", "", "specify the target parameter path")
+	keyID := cmd.String("kms-key", "",
+		"specify the kms key id to encrypt SecureString (exported key if empty)")
+	overwrite := cmd.Bool("overwrite", false, "overwrite changed parameters")
+	prune := cmd.Bool("prune", false, "delete parameters missing in the export")
+	dryRun := cmd.Bool("dryrun", false, "print the actions w/o applying them")
+	format := cmd.String("format", "",
+		"specify the file format json or yaml (by the file extension if empty)")
+
+	err := cmd.Parse(os.Args[2:])
+	if err != nil || !cmd.Parsed() {
+		fmt.Printf("Cannot parse arguments (%v)\n", err)
+		return
+	}
+	if *input == "" || *path == "" {
+		fmt.Println("Invalid input parameters, -h to show help message.")
+		fmt.Println("input and path cannot be empty")
+		return
+	}
+	siface, err := ssmconfig.ExportSerializer(*format, *input)
+	if err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
+	export, err := ssmconfig.LoadExport(*input, siface)
+	if err != nil {
+		fmt.Printf("Cannot read %s\n%v\n", *input, err)
+		return
+	}
+	svc, err := cli.AWSClients.SSM()
+	if err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
+	result, err := ssmconfig.ImportExport(context.Background(), &awsapi.AWSAPI{}, svc,
+		export, *path, ssmconfig.ImportOptions{
+			KeyID:     *keyID,
+			Overwrite: *overwrite,
+			Prune:     *prune,
+			DryRun:    *dryRun,
+		})
+	if err != nil {
+		fmt.Printf("Cannot import %s into %s\n%v\n", *input, *path, err)
+		return
+	}
+	result.Render(os.Stdout)
+}
+
+// methodDiff helps to compare two paths, or a path and an export file
+func (cli *SSMCLI) methodDiff() {
+	cmd := flag.NewFlagSet(cmdDiff, cli.ErrorBehavior)
+	from := cmd.String("from", "", "specify the parameter path to compare from")
+	to := cmd.String("to", "", "specify the parameter path to compare to")
+	input := cmd.String("input", "", "specify an export file to compare to instead of a path")
+	showSecrets := cmd.Bool("show-secrets", false, "print SecureString values unmasked")
+	asJSON := cmd.Bool("json", false, "print the differences in json")
+	format := cmd.String("format", "",
+		"specify the file format json or yaml (by the file extension if empty)")
+
+	err := cmd.Parse(os.Args[2:])
+	if err != nil || !cmd.Parsed() {
+		fmt.Printf("Cannot parse arguments (%v)\n", err)
+		return
+	}
+	if *from == "" || (*to == "") == (*input == "") {
+		fmt.Println("Invalid input parameters, -h to show help message.")
+		fmt.Println("from and either to or input must be given")
+		return
+	}
+	svc, err := cli.AWSClients.SSM()
+	if err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
+
+	var result *ssmconfig.DiffResult
+	if *input != "" {
+		var siface utils.SerialInterface
+		if siface, err = ssmconfig.ExportSerializer(*format, *input); err != nil {
+			fmt.Printf("CLIError: %v\n", err)
+			return
+		}
+		var export *ssmconfig.Export
+		export, err = ssmconfig.LoadExport(*input, siface)
+		if err != nil {
+			fmt.Printf("Cannot read %s\n%v\n", *input, err)
+			return
+		}
+		var current *ssmconfig.Export
+		current, err = ssmconfig.ExportPath(context.Background(), &awsapi.AWSAPI{}, svc, *from,
+			ssmconfig.ExportOptions{Secrets: true, Tags: true})
+		if err == nil {
+			result = ssmconfig.DiffExports(current, export)
+		}
+	} else {
+		result, err = ssmconfig.DiffPaths(context.Background(), &awsapi.AWSAPI{}, svc, *from, *to)
+	}
+	if err != nil {
+		fmt.Printf("Cannot diff %s\n%v\n", *from, err)
+		return
+	}
+	if *asJSON {
+		fmt.Printf("%s\n", utils.MarshalIndent(result, "", "  ", &utils.JSONAPI{}))
+		return
+	}
+	result.Render(os.Stdout, *showSecrets)
+}
+
+// secretValidator returns a validator of rotated secrets by kind:
+// slack checks a token w/ auth.test, auth0 requests a token w/ a client secret
+// of the tenant of AUTH_ENDPOINT
+func secretValidator(kind, clientID, audience string) (ssmconfig.Validator, error) {
+	switch kind {
+	case "", "none":
+		return nil, nil
+	case "slack":
+		return func(ctx context.Context, secret string) error {
+			_, err := slackapi.NewClient(secret).AuthTest(ctx)
+			return err
+		}, nil
+	case "auth0":
+		endpoint := utils.GetEnv(envEndpoint, "")
+		if clientID == "" || endpoint == "" {
+			return nil, fmt.Errorf("auth0 validation requires a client id and %s", envEndpoint)
+		}
+		client := auth0api.NewAuth0Client("", endpoint)
+		return func(ctx context.Context, secret string) error {
+			_, err := client.ClientCredentialsToken(ctx, clientID, secret, audience)
+			return err
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown validation %q, expect none, slack or auth0", kind)
+}
+
+// methodRotate helps to rotate a SecureString w/ validation
+func (cli *SSMCLI) methodRotate() {
+	cmd := flag.NewFlagSet(cmdRotate, cli.ErrorBehavior)
+	name := cmd.String("name", "", "specify the SecureString parameter to rotate")
+	secretEnv := cmd.String("secret-env", "",
+		"specify the environment variable holding the new secret")
+	generate := cmd.Int("generate", 0, "generate a random secret of the bytes instead")
+	keyID := cmd.String("kms-key", "",
+		"specify the kms key id to encrypt the secret (current key if empty)")
+	validate := cmd.String("validate", "none", "specify the validation: none, slack or auth0")
+	clientID := cmd.String("client-id", "", "specify the auth0 client id of the secret")
+	audience := cmd.String("audience", "", "specify the auth0 audience (management api if empty)")
+
+	err := cmd.Parse(os.Args[2:])
+	if err != nil || !cmd.Parsed() {
+		fmt.Printf("Cannot parse arguments (%v)\n", err)
+		return
+	}
+	if *name == "" || (*secretEnv == "") == (*generate == 0) {
+		fmt.Println("Invalid input parameters, -h to show help message.")
+		fmt.Println("name and either secret-env or generate must be given")
+		return
+	}
+	opts := ssmconfig.RotateOptions{KeyID: *keyID}
+	if *secretEnv != "" {
+		if opts.Secret = os.Getenv(*secretEnv); opts.Secret == "" {
+			fmt.Printf("Empty secret of environment variable %s\n", *secretEnv)
+			return
+		}
+	} else {
+		opts.Generate = ssmconfig.RandomSecret(*generate)
+	}
+	if opts.Validate, err = secretValidator(*validate, *clientID, *audience); err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
+	svc, err := cli.AWSClients.SSM()
+	if err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
+	result, err := ssmconfig.RotateSecret(context.Background(), &awsapi.AWSAPI{}, svc, *name, opts)
+	if result != nil {
+		result.Render(os.Stdout)
+	}
+	if err != nil && (result == nil || result.Error == "") {
+		fmt.Printf("Cannot rotate %s\n%v\n", *name, err)
+	}
+}
+
+// methodPromote helps to promote the pending version of a secret
+func (cli *SSMCLI) methodPromote() {
+	cmd := flag.NewFlagSet(cmdPromote, cli.ErrorBehavior)
+	name := cmd.String("name", "", "specify the SecureString parameter to promote")
+	validate := cmd.String("validate", "none", "specify the validation: none, slack or auth0")
+	clientID := cmd.String("client-id", "", "specify the auth0 client id of the secret")
+	audience := cmd.String("audience", "", "specify the auth0 audience (management api if empty)")
+
+	err := cmd.Parse(os.Args[2:])
+	if err != nil || !cmd.Parsed() {
+		fmt.Printf("Cannot parse arguments (%v)\n", err)
+		return
+	}
+	if *name == "" {
+		fmt.Println("Invalid input parameters, -h to show help message.")
+		fmt.Println("name cannot be empty")
+		return
+	}
+	check, err := secretValidator(*validate, *clientID, *audience)
+	if err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
+	svc, err := cli.AWSClients.SSM()
+	if err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
+	result, err := ssmconfig.PromoteSecret(context.Background(), &awsapi.AWSAPI{}, svc, *name, check)
+	if result != nil {
+		result.Render(os.Stdout)
+	}
+	if err != nil && (result == nil || result.Error == "") {
+		fmt.Printf("Cannot promote %s\n%v\n", *name, err)
+	}
+}
+
+// methodRollback helps to roll a secret back to its previous version
+func (cli *SSMCLI) methodRollback() {
+	cmd := flag.NewFlagSet(cmdRollback, cli.ErrorBehavior)
+	name := cmd.String("name", "", "specify the SecureString parameter to roll back")
+
+	err := cmd.Parse(os.Args[2:])
+	if err != nil || !cmd.Parsed() {
+		fmt.Printf("Cannot parse arguments (%v)\n", err)
+		return
+	}
+	if *name == "" {
+		fmt.Println("Invalid input parameters, -h to show help message.")
+		fmt.Println("name cannot be empty")
+		return
+	}
+	svc, err := cli.AWSClients.SSM()
+	if err != nil {
+		fmt.Printf("CLIError: %v\n", err)
+		return
+	}
+	result, err := ssmconfig.RollbackSecret(context.Background(), &awsapi.AWSAPI{}, svc, *name)
+	if result != nil {
+		result.Render(os.Stdout)
+	}
+	if err != nil && (result == nil || result.Error == "") {
+		fmt.Printf("Cannot roll back %s\n%v\n", *name, err)
+	}
+}
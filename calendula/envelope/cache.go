@@ -0,0 +1,136 @@
+package envelope
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const (
+	deCacheTTL     = 5 * time.Minute
+	deCacheEntries = 100
+)
+
+type cacheEntry struct {
+	keyID     string
+	plaintext []byte
+	expiresAt time.Time
+}
+
+// KeyCache caches data keys decrypted by kms, so decrypting many envelopes
+// of the same data key calls kms once. It is safe for concurrent use.
+type KeyCache struct {
+	// TTL is the lifetime of a data key, 5 minutes if 0
+	TTL time.Duration
+	// MaxEntries limits cached data keys, 100 if 0
+	MaxEntries int
+	// Now returns the current time, time.Now if nil
+	Now func() time.Time
+
+	mutex   sync.Mutex
+	entries map[[sha256.Size]byte]*cacheEntry
+}
+
+// NewKeyCache returns a *KeyCache
+func NewKeyCache(ttl time.Duration, maxEntries int) *KeyCache {
+	return &KeyCache{TTL: ttl, MaxEntries: maxEntries}
+}
+
+func (cache *KeyCache) now() time.Time {
+	if cache.Now != nil {
+		return cache.Now()
+	}
+	return time.Now()
+}
+
+// cacheKey binds a data key to its context, the same encrypted key
+// w/ another context must be decrypted by kms to fail
+func cacheKey(encryptedKey []byte, encCtx map[string]string) [sha256.Size]byte {
+	if len(encCtx) == 0 {
+		encCtx = nil
+	}
+	content, _ := json.Marshal(encCtx)
+	hash := sha256.New()
+	hash.Write(encryptedKey)
+	hash.Write([]byte{0})
+	hash.Write(content)
+	var key [sha256.Size]byte
+	copy(key[:], hash.Sum(nil))
+	return key
+}
+
+// Get returns a copy of a cached data key and its master key id
+func (cache *KeyCache) Get(encryptedKey []byte, encCtx map[string]string) ([]byte, string, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	key := cacheKey(encryptedKey, encCtx)
+	entry, ok := cache.entries[key]
+	if !ok {
+		return nil, "", false
+	}
+	if !cache.now().Before(entry.expiresAt) {
+		cache.evict(key)
+		return nil, "", false
+	}
+	return append([]byte{}, entry.plaintext...), entry.keyID, true
+}
+
+// Put caches a data key, the oldest entry is evicted if full
+func (cache *KeyCache) Put(encryptedKey []byte, encCtx map[string]string,
+	keyID string, plaintext []byte) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if cache.entries == nil {
+		cache.entries = make(map[[sha256.Size]byte]*cacheEntry)
+	}
+	ttl, maxEntries := cache.TTL, cache.MaxEntries
+	if ttl <= 0 {
+		ttl = deCacheTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = deCacheEntries
+	}
+	key := cacheKey(encryptedKey, encCtx)
+	if _, ok := cache.entries[key]; !ok && len(cache.entries) >= maxEntries {
+		var oldest [sha256.Size]byte
+		var oldestAt time.Time
+		for candidate, entry := range cache.entries {
+			if oldestAt.IsZero() || entry.expiresAt.Before(oldestAt) {
+				oldest, oldestAt = candidate, entry.expiresAt
+			}
+		}
+		cache.evict(oldest)
+	}
+	cache.entries[key] = &cacheEntry{
+		keyID:     keyID,
+		plaintext: append([]byte{}, plaintext...),
+		expiresAt: cache.now().Add(ttl),
+	}
+}
+
+// Len returns the number of cached data keys
+func (cache *KeyCache) Len() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return len(cache.entries)
+}
+
+// Purge evicts all data keys
+func (cache *KeyCache) Purge() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for key := range cache.entries {
+		cache.evict(key)
+	}
+}
+
+// evict zeroes and removes a data key, must be called w/ mutex locked
+func (cache *KeyCache) evict(key [sha256.Size]byte) {
+	if entry, ok := cache.entries[key]; ok {
+		for idx := range entry.plaintext {
+			entry.plaintext[idx] = 0
+		}
+		delete(cache.entries, key)
+	}
+}
@@ -0,0 +1,50 @@
+package envelope
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyCache(t *testing.T) {
+	now := time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewKeyCache(time.Minute, 2)
+	cache.Now = func() time.Time { return now }
+	encCtx := map[string]string{"app": "calendula"}
+
+	_, _, ok := cache.Get([]byte("k1"), encCtx)
+	assert.False(t, ok)
+	plain := []byte{1, 2, 3}
+	cache.Put([]byte("k1"), encCtx, "key-1", plain)
+	plain[0] = 9
+	key, keyID, ok := cache.Get([]byte("k1"), encCtx)
+	assert.True(t, ok)
+	assert.Equal(t, []byte{1, 2, 3}, key)
+	assert.Equal(t, "key-1", keyID)
+	// a data key is bound to its context
+	_, _, ok = cache.Get([]byte("k1"), nil)
+	assert.False(t, ok)
+
+	// the oldest is evicted if full
+	now = now.Add(time.Second)
+	cache.Put([]byte("k2"), nil, "key-1", []byte{2})
+	cache.Put([]byte("k3"), nil, "key-1", []byte{3})
+	assert.Equal(t, 2, cache.Len())
+	_, _, ok = cache.Get([]byte("k1"), encCtx)
+	assert.False(t, ok)
+
+	// expired
+	now = now.Add(time.Minute)
+	_, _, ok = cache.Get([]byte("k2"), nil)
+	assert.False(t, ok)
+	assert.Equal(t, 1, cache.Len())
+
+	cache.Purge()
+	assert.Equal(t, 0, cache.Len())
+
+	defaults := &KeyCache{}
+	defaults.Put([]byte("k1"), nil, "key-1", []byte{1})
+	_, _, ok = defaults.Get([]byte("k1"), nil)
+	assert.True(t, ok)
+}
@@ -0,0 +1,155 @@
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+)
+
+// Client encrypts payloads w/ a new AES-256 data key of a master key each,
+// and decrypts envelopes of any master key the caller can use
+type Client struct {
+	AWSAPI *awsapi.AWSAPI
+	KMSAPI kmsiface.KMSAPI
+	// KeyID is the id, arn or alias of the master key to encrypt w/
+	KeyID string
+	// Cache caches decrypted data keys, every decryption calls kms if nil
+	Cache *KeyCache
+	// Rand is the source of nonces, crypto/rand if nil
+	Rand io.Reader
+}
+
+// NewClient returns a *Client of a master key
+func NewClient(svc kmsiface.KMSAPI, keyID string, cache *KeyCache) *Client {
+	return &Client{
+		AWSAPI: &awsapi.AWSAPI{},
+		KMSAPI: svc,
+		KeyID:  keyID,
+		Cache:  cache,
+	}
+}
+
+func (client *Client) rand() io.Reader {
+	if client.Rand != nil {
+		return client.Rand
+	}
+	return rand.Reader
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// zero clears a plain data key after use
+func zero(key []byte) {
+	for idx := range key {
+		key[idx] = 0
+	}
+}
+
+// Encrypt encrypts a payload w/ AES-GCM, the encryption context is
+// required to decrypt the data key and authenticated w/ the payload
+func (client *Client) Encrypt(ctx context.Context, plaintext []byte,
+	encCtx map[string]string) (*Envelope, error) {
+	dataKey, err := client.AWSAPI.GenerateDataKeyWithContext(ctx, client.KMSAPI,
+		client.KeyID, kms.DataKeySpecAes256, encCtx)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(dataKey.Plaintext)
+	aead, err := newGCM(dataKey.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+	if len(encCtx) == 0 {
+		encCtx = nil
+	}
+	env := &Envelope{
+		Version:      Version,
+		KeyID:        dataKey.KeyID,
+		EncryptedKey: dataKey.CiphertextBlob,
+		Nonce:        make([]byte, aead.NonceSize()),
+		Context:      encCtx,
+	}
+	if _, err := io.ReadFull(client.rand(), env.Nonce); err != nil {
+		return nil, err
+	}
+	aad, err := env.additionalData()
+	if err != nil {
+		return nil, err
+	}
+	env.Ciphertext = aead.Seal(nil, env.Nonce, plaintext, aad)
+	return env, nil
+}
+
+// dataKey returns the plain data key of an envelope, from the cache if any
+func (client *Client) dataKey(ctx context.Context, env *Envelope) ([]byte, error) {
+	if client.Cache != nil {
+		if key, keyID, ok := client.Cache.Get(env.EncryptedKey, env.Context); ok && keyID == env.KeyID {
+			return key, nil
+		}
+	}
+	dataKey, err := client.AWSAPI.DecryptDataKeyWithContext(ctx, client.KMSAPI,
+		env.EncryptedKey, env.Context)
+	if err != nil {
+		return nil, err
+	}
+	if dataKey.KeyID != env.KeyID {
+		zero(dataKey.Plaintext)
+		return nil, fmt.Errorf("envelope key %s mismatches data key of %s", env.KeyID, dataKey.KeyID)
+	}
+	if client.Cache != nil {
+		client.Cache.Put(env.EncryptedKey, env.Context, dataKey.KeyID, dataKey.Plaintext)
+	}
+	return dataKey.Plaintext, nil
+}
+
+// Decrypt decrypts an envelope, tampered envelopes fail authentication
+func (client *Client) Decrypt(ctx context.Context, env *Envelope) ([]byte, error) {
+	if env.Version != Version {
+		return nil, fmt.Errorf("unsupport envelope version %d", env.Version)
+	}
+	key, err := client.dataKey(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(key)
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid envelope nonce of %d bytes", len(env.Nonce))
+	}
+	aad, err := env.additionalData()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, env.Nonce, env.Ciphertext, aad)
+	if err != nil {
+		log.Printf("Error: cannot decrypt envelope of %s, err: %v\n", env.KeyID, err)
+		return nil, fmt.Errorf("cannot decrypt envelope, %v", err)
+	}
+	return plaintext, nil
+}
+
+// DecryptBytes parses an envelope in json or binary and decrypts it
+func (client *Client) DecryptBytes(ctx context.Context, data []byte) ([]byte, error) {
+	env, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return client.Decrypt(ctx, env)
+}
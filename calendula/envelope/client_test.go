@@ -0,0 +1,134 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+func TestClientEncrypt(t *testing.T) {
+	svc := mock.KMSAPIKeys("key-1")
+	client := NewClient(svc, "key-1", nil)
+	encCtx := map[string]string{"app": "calendula"}
+
+	env, err := client.Encrypt(context.Background(), []byte("hello"), encCtx)
+	assert.Nil(t, err)
+	assert.Equal(t, Version, env.Version)
+	assert.Equal(t, "arn:aws:kms:us-east-1:123456789012:key/key-1", env.KeyID)
+	assert.Equal(t, 12, len(env.Nonce))
+	assert.NotContains(t, string(env.Ciphertext), "hello")
+
+	plain, err := client.Decrypt(context.Background(), env)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(plain))
+
+	content, _ := json.Marshal(env)
+	plain, err = client.DecryptBytes(context.Background(), content)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(plain))
+	content, _ = env.MarshalBinary()
+	plain, err = client.DecryptBytes(context.Background(), content)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(plain))
+	_, err = client.DecryptBytes(context.Background(), []byte("AENV"))
+	assert.NotNil(t, err)
+
+	_, err = NewClient(svc, "key-2", nil).Encrypt(context.Background(), []byte("hello"), nil)
+	assert.NotNil(t, err)
+	client.Rand = bytes.NewReader(nil)
+	_, err = client.Encrypt(context.Background(), []byte("hello"), nil)
+	assert.NotNil(t, err)
+}
+
+func TestClientEncryptEmptyContext(t *testing.T) {
+	svc := mock.KMSAPIKeys("key-1")
+	client := NewClient(svc, "key-1", nil)
+	env, err := client.Encrypt(context.Background(), []byte("hello"), map[string]string{})
+	assert.Nil(t, err)
+	assert.Nil(t, env.Context)
+
+	content, _ := json.Marshal(env)
+	plain, err := client.DecryptBytes(context.Background(), content)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(plain))
+	content, _ = env.MarshalBinary()
+	plain, err = client.DecryptBytes(context.Background(), content)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(plain))
+
+	// an envelope built by hand w/ an empty context decrypts too
+	env.Context = map[string]string{}
+	plain, err = client.Decrypt(context.Background(), env)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(plain))
+}
+
+func TestClientDecryptTampered(t *testing.T) {
+	svc := mock.KMSAPIKeys("key-1", "key-2")
+	client := NewClient(svc, "key-1", NewKeyCache(time.Minute, 10))
+	encCtx := map[string]string{"app": "calendula"}
+	encrypt := func() *Envelope {
+		env, err := client.Encrypt(context.Background(), []byte("hello"), encCtx)
+		assert.Nil(t, err)
+		return env
+	}
+
+	env := encrypt()
+	env.Ciphertext[0] ^= 1
+	_, err := client.Decrypt(context.Background(), env)
+	assert.NotNil(t, err)
+
+	// the context is authenticated even if the data key is cached
+	env = encrypt()
+	_, err = client.Decrypt(context.Background(), env)
+	assert.Nil(t, err)
+	env.Context = map[string]string{"app": "other"}
+	_, err = client.Decrypt(context.Background(), env)
+	assert.NotNil(t, err)
+
+	env = encrypt()
+	env.KeyID = "arn:aws:kms:us-east-1:123456789012:key/key-2"
+	_, err = client.Decrypt(context.Background(), env)
+	assert.EqualError(t, err, "envelope key arn:aws:kms:us-east-1:123456789012:key/key-2 "+
+		"mismatches data key of arn:aws:kms:us-east-1:123456789012:key/key-1")
+
+	env = encrypt()
+	env.Nonce = env.Nonce[1:]
+	_, err = client.Decrypt(context.Background(), env)
+	assert.EqualError(t, err, "invalid envelope nonce of 11 bytes")
+
+	env.Version = 2
+	_, err = client.Decrypt(context.Background(), env)
+	assert.EqualError(t, err, "unsupport envelope version 2")
+}
+
+func TestClientDecryptCache(t *testing.T) {
+	svc := mock.KMSAPIKeys("key-1")
+	client := NewClient(svc, "key-1", NewKeyCache(time.Minute, 10))
+	env, err := client.Encrypt(context.Background(), []byte("hello"), nil)
+	assert.Nil(t, err)
+	for idx := 0; idx < 3; idx++ {
+		plain, err := client.Decrypt(context.Background(), env)
+		assert.Nil(t, err)
+		assert.Equal(t, "hello", string(plain))
+	}
+	assert.Equal(t, 1, svc.DecryptCalls())
+
+	// a cached data key decrypts w/o access to the master key
+	svc.RemoveKey("key-1")
+	_, err = client.Decrypt(context.Background(), env)
+	assert.Nil(t, err)
+	client.Cache.Purge()
+	_, err = client.Decrypt(context.Background(), env)
+	assert.NotNil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewClient(svc, "key-1", nil).Decrypt(ctx, env)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,128 @@
+package envelope
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// Version is the version of envelopes written by this package
+const Version = 1
+
+// magic starts a binary envelope
+var magic = []byte("AENV")
+
+// Envelope defines a payload encrypted by a data key of kms, it holds
+// everything needed to decrypt it except access to the master key
+type Envelope struct {
+	Version int `json:"version"`
+	// KeyID is the arn of the master key of the data key
+	KeyID string `json:"key_id"`
+	// EncryptedKey is the data key encrypted by kms
+	EncryptedKey []byte `json:"encrypted_key"`
+	Nonce        []byte `json:"nonce"`
+	Ciphertext   []byte `json:"ciphertext"`
+	// Context is the kms encryption context, it is authenticated w/ the payload
+	Context map[string]string `json:"context,omitempty"`
+}
+
+// additionalData returns the associated data of AES-GCM, so the version,
+// key and context of an envelope can not be swapped w/o failing decryption.
+// An empty context is read back as nil, so both are authenticated as null.
+func (env *Envelope) additionalData() ([]byte, error) {
+	values := env.Context
+	if len(values) == 0 {
+		values = nil
+	}
+	encCtx, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	buf.Write(magic)
+	buf.WriteByte(byte(env.Version))
+	buf.WriteString(env.KeyID)
+	buf.WriteByte(0)
+	buf.Write(encCtx)
+	return buf.Bytes(), nil
+}
+
+// MarshalBinary returns the envelope in a compact binary format:
+// magic, version, then length prefixed key id, encrypted key, nonce
+// and context, followed by the ciphertext
+func (env *Envelope) MarshalBinary() ([]byte, error) {
+	encCtx := []byte{}
+	if len(env.Context) != 0 {
+		var err error
+		if encCtx, err = json.Marshal(env.Context); err != nil {
+			return nil, err
+		}
+	}
+	buf := &bytes.Buffer{}
+	buf.Write(magic)
+	buf.WriteByte(byte(env.Version))
+	for _, field := range [][]byte{[]byte(env.KeyID), env.EncryptedKey, env.Nonce, encCtx} {
+		if len(field) > 0xffff {
+			return nil, fmt.Errorf("envelope field of %d bytes exceeds %d", len(field), 0xffff)
+		}
+		size := make([]byte, 2)
+		binary.BigEndian.PutUint16(size, uint16(len(field)))
+		buf.Write(size)
+		buf.Write(field)
+	}
+	buf.Write(env.Ciphertext)
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary reads an envelope of MarshalBinary
+func (env *Envelope) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, magic) || len(data) < len(magic)+1 {
+		return fmt.Errorf("invalid envelope, missing header")
+	}
+	data = data[len(magic):]
+	version := int(data[0])
+	data = data[1:]
+	fields := make([][]byte, 4)
+	for idx := range fields {
+		if len(data) < 2 {
+			return fmt.Errorf("invalid envelope, truncated header")
+		}
+		size := int(binary.BigEndian.Uint16(data))
+		if len(data) < 2+size {
+			return fmt.Errorf("invalid envelope, truncated header")
+		}
+		fields[idx] = data[2 : 2+size]
+		data = data[2+size:]
+	}
+	var encCtx map[string]string
+	if len(fields[3]) != 0 {
+		if err := json.Unmarshal(fields[3], &encCtx); err != nil {
+			return fmt.Errorf("invalid envelope context, %v", err)
+		}
+	}
+	*env = Envelope{
+		Version:      version,
+		KeyID:        string(fields[0]),
+		EncryptedKey: append([]byte{}, fields[1]...),
+		Nonce:        append([]byte{}, fields[2]...),
+		Ciphertext:   append([]byte{}, data...),
+		Context:      encCtx,
+	}
+	return nil
+}
+
+// Parse reads an envelope in json or binary
+func Parse(data []byte) (*Envelope, error) {
+	env := &Envelope{}
+	if bytes.HasPrefix(data, magic) {
+		if err := env.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return env, nil
+	}
+	if err := json.Unmarshal(data, env); err != nil {
+		return nil, fmt.Errorf("invalid envelope, %v", err)
+	}
+	return env, nil
+}
@@ -0,0 +1,65 @@
+package envelope
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeEnvelope() *Envelope {
+	return &Envelope{
+		Version:      Version,
+		KeyID:        "arn:aws:kms:us-east-1:123456789012:key/key-1",
+		EncryptedKey: []byte("encrypted"),
+		Nonce:        []byte("123456789012"),
+		Ciphertext:   []byte("ciphertext"),
+		Context:      map[string]string{"app": "calendula"},
+	}
+}
+
+func TestEnvelopeJSON(t *testing.T) {
+	env := fakeEnvelope()
+	content, err := json.Marshal(env)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"version":1,"key_id":"arn:aws:kms:us-east-1:123456789012:key/key-1",`+
+		`"encrypted_key":"ZW5jcnlwdGVk","nonce":"MTIzNDU2Nzg5MDEy","ciphertext":"Y2lwaGVydGV4dA==",`+
+		`"context":{"app":"calendula"}}`, string(content))
+	parsed, err := Parse(content)
+	assert.Nil(t, err)
+	assert.Equal(t, env, parsed)
+
+	_, err = Parse([]byte("{"))
+	assert.NotNil(t, err)
+}
+
+func TestEnvelopeBinary(t *testing.T) {
+	env := fakeEnvelope()
+	content, err := env.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, "AENV", string(content[:4]))
+	parsed, err := Parse(content)
+	assert.Nil(t, err)
+	assert.Equal(t, env, parsed)
+
+	env.Context = nil
+	content, err = env.MarshalBinary()
+	assert.Nil(t, err)
+	parsed, err = Parse(content)
+	assert.Nil(t, err)
+	assert.Equal(t, env, parsed)
+
+	for _, invalid := range [][]byte{
+		[]byte("AENV"),
+		content[:10],
+		append([]byte("AENV\x01\x00\x00\x00\x00\x00\x00\x00\x05"), '{', '}'),
+		append([]byte("AENV\x01\x00\x00\x00\x00\x00\x00\x00\x02"), 'x', 'x'),
+	} {
+		_, err = Parse(invalid)
+		assert.NotNil(t, err, "%q", invalid)
+	}
+
+	env.KeyID = string(make([]byte, 0x10000))
+	_, err = env.MarshalBinary()
+	assert.EqualError(t, err, "envelope field of 65536 bytes exceeds 65535")
+}
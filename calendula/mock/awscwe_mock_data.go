@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	cwe "github.com/aws/aws-sdk-go/service/cloudwatchevents"
 	cweiface "github.com/aws/aws-sdk-go/service/cloudwatchevents/cloudwatcheventsiface"
 )
@@ -64,6 +65,42 @@ func (cweapi *CWEAPI) PutTargets(
 	return cweapi.putTargetsOutput, cweapi.putTargetsError
 }
 
+// ListRuleNamesByTargetWithContext mocks CWEAPI.ListRuleNamesByTargetWithContext
+func (cweapi *CWEAPI) ListRuleNamesByTargetWithContext(ctx aws.Context,
+	input *cwe.ListRuleNamesByTargetInput, opts ...request.Option) (*cwe.ListRuleNamesByTargetOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return cweapi.ListRuleNamesByTarget(input)
+}
+
+// DescribeRuleWithContext mocks CWEAPI.DescribeRuleWithContext
+func (cweapi *CWEAPI) DescribeRuleWithContext(ctx aws.Context,
+	input *cwe.DescribeRuleInput, opts ...request.Option) (*cwe.DescribeRuleOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return cweapi.DescribeRule(input)
+}
+
+// PutRuleWithContext mocks CWEAPI.PutRuleWithContext
+func (cweapi *CWEAPI) PutRuleWithContext(ctx aws.Context,
+	input *cwe.PutRuleInput, opts ...request.Option) (*cwe.PutRuleOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return cweapi.PutRule(input)
+}
+
+// PutTargetsWithContext mocks CWEAPI.PutTargetsWithContext
+func (cweapi *CWEAPI) PutTargetsWithContext(ctx aws.Context,
+	input *cwe.PutTargetsInput, opts ...request.Option) (*cwe.PutTargetsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return cweapi.PutTargets(input)
+}
+
 func mockListRuleOutput(names []string) *cwe.ListRuleNamesByTargetOutput {
 	return &cwe.ListRuleNamesByTargetOutput{
 		RuleNames: aws.StringSlice(names),
@@ -1,9 +1,21 @@
 package mock
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
 )
@@ -15,19 +27,57 @@ type KMSAPI struct {
 	genErr           error
 	decryptOutput    kms.DecryptOutput
 	decryptErr       error
+	state            *kmsState
+}
+
+// kmsState records master keys of a fake kms, it is safe for concurrent use
+type kmsState struct {
+	mutex        sync.Mutex
+	keys         map[string]bool
+	disabled     map[string]bool
+	aliases      map[string]string
+	grants       []*kms.GrantListEntry
+	grantSeq     int
+	pageSize     int
+	genCalls     int
+	decryptCalls int
 }
 
 // GenerateDataKey mocks KMSAPI.GenerateDataKey
 func (m KMSAPI) GenerateDataKey(
-	*kms.GenerateDataKeyInput) (*kms.GenerateDataKeyOutput, error) {
+	input *kms.GenerateDataKeyInput) (*kms.GenerateDataKeyOutput, error) {
+	if m.state != nil {
+		return m.state.generateDataKey(input)
+	}
 	return &m.genDataKeyOutput, m.genErr
 }
 
 // Decrypt mocks KMSAPI.Decrypt
-func (m KMSAPI) Decrypt(*kms.DecryptInput) (*kms.DecryptOutput, error) {
+func (m KMSAPI) Decrypt(input *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	if m.state != nil {
+		return m.state.decrypt(input)
+	}
 	return &m.decryptOutput, m.decryptErr
 }
 
+// GenerateDataKeyWithContext mocks KMSAPI.GenerateDataKeyWithContext
+func (m KMSAPI) GenerateDataKeyWithContext(ctx aws.Context,
+	input *kms.GenerateDataKeyInput, opts ...request.Option) (*kms.GenerateDataKeyOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GenerateDataKey(input)
+}
+
+// DecryptWithContext mocks KMSAPI.DecryptWithContext
+func (m KMSAPI) DecryptWithContext(ctx aws.Context,
+	input *kms.DecryptInput, opts ...request.Option) (*kms.DecryptOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.Decrypt(input)
+}
+
 func mockDecryptOutput(plain []byte, keyID string) *kms.DecryptOutput {
 	return &kms.DecryptOutput{
 		Plaintext: plain,
@@ -82,3 +132,519 @@ func KMSAPIGenDataKeyError() *KMSAPI {
 		genErr:           errors.New("FakeGenDataKeyError"),
 	}
 }
+
+// fakeKeyARN returns the arn of a key id of the fake kms
+func fakeKeyARN(keyID string) string {
+	if strings.HasPrefix(keyID, "arn:") {
+		return keyID
+	}
+	return "arn:aws:kms:us-east-1:123456789012:key/" + keyID
+}
+
+// fakeContextHash returns a fingerprint of an encryption context
+func fakeContextHash(encCtx map[string]*string) string {
+	content, _ := json.Marshal(aws.StringValueMap(encCtx))
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:8])
+}
+
+// generateDataKey returns a blob of "fakekms|arn|context hash|hex plaintext",
+// which is not encrypted at all
+func (state *kmsState) generateDataKey(
+	input *kms.GenerateDataKeyInput) (*kms.GenerateDataKeyOutput, error) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.genCalls++
+	arn, err := state.usableKey(aws.StringValue(input.KeyId))
+	if err != nil {
+		return nil, err
+	}
+	size := int(aws.Int64Value(input.NumberOfBytes))
+	switch aws.StringValue(input.KeySpec) {
+	case kms.DataKeySpecAes256:
+		size = 32
+	case kms.DataKeySpecAes128:
+		size = 16
+	}
+	plain := make([]byte, size)
+	if _, err := rand.Read(plain); err != nil {
+		return nil, err
+	}
+	return &kms.GenerateDataKeyOutput{
+		KeyId:          aws.String(arn),
+		Plaintext:      plain,
+		CiphertextBlob: fakeBlob(arn, input.EncryptionContext, plain),
+	}, nil
+}
+
+// fakeBlob returns a ciphertext blob of the fake kms
+func fakeBlob(arn string, encCtx map[string]*string, plain []byte) []byte {
+	return []byte(strings.Join([]string{"fakekms", arn,
+		fakeContextHash(encCtx), hex.EncodeToString(plain)}, "|"))
+}
+
+// resolve returns the arn of a key id, arn or alias,
+// must be called w/ mutex locked
+func (state *kmsState) resolve(keyID string) (string, error) {
+	arn := fakeKeyARN(keyID)
+	if strings.HasPrefix(keyID, "alias/") {
+		arn = state.aliases[keyID]
+	}
+	if !state.keys[arn] {
+		return "", awserr.New(kms.ErrCodeNotFoundException,
+			"Key '"+fakeKeyARN(keyID)+"' does not exist", nil)
+	}
+	return arn, nil
+}
+
+// usableKey resolves a key for cryptographic operations,
+// must be called w/ mutex locked
+func (state *kmsState) usableKey(keyID string) (string, error) {
+	arn, err := state.resolve(keyID)
+	if err != nil {
+		return "", err
+	}
+	if state.disabled[arn] {
+		return "", awserr.New(kms.ErrCodeDisabledException, arn+" is disabled.", nil)
+	}
+	return arn, nil
+}
+
+// open returns the key and plaintext of a blob, must be called w/ mutex locked
+func (state *kmsState) open(blob []byte, encCtx map[string]*string) (string, []byte, error) {
+	invalid := awserr.New(kms.ErrCodeInvalidCiphertextException, "", nil)
+	parts := strings.Split(string(blob), "|")
+	if len(parts) != 4 || parts[0] != "fakekms" {
+		return "", nil, invalid
+	}
+	arn, err := state.usableKey(parts[1])
+	if err != nil {
+		return "", nil, err
+	}
+	if parts[2] != fakeContextHash(encCtx) {
+		return "", nil, invalid
+	}
+	plain, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return "", nil, invalid
+	}
+	return arn, plain, nil
+}
+
+func (state *kmsState) decrypt(input *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.decryptCalls++
+	arn, plain, err := state.open(input.CiphertextBlob, input.EncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.DecryptOutput{KeyId: aws.String(arn), Plaintext: plain}, nil
+}
+
+// GenerateCalls returns the number of kms.GenerateDataKey to the fake kms
+func (m KMSAPI) GenerateCalls() int {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	return m.state.genCalls
+}
+
+// DecryptCalls returns the number of kms.Decrypt to the fake kms
+func (m KMSAPI) DecryptCalls() int {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	return m.state.decryptCalls
+}
+
+// RemoveKey deletes a master key from the fake kms
+func (m KMSAPI) RemoveKey(keyID string) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	delete(m.state.keys, fakeKeyARN(keyID))
+}
+
+// KMSAPIKeys returns *KMSAPI w/ a fake kms of master keys,
+// keys are ids or arns, data keys are bound to their encryption context
+func KMSAPIKeys(keyIDs ...string) *KMSAPI {
+	state := &kmsState{
+		keys:     make(map[string]bool),
+		disabled: make(map[string]bool),
+		aliases:  make(map[string]string),
+	}
+	for _, keyID := range keyIDs {
+		state.keys[fakeKeyARN(keyID)] = true
+	}
+	return &KMSAPI{state: state}
+}
+
+// fakeKMSPageSize is the default page size of list operations of the fake kms
+const fakeKMSPageSize = 50
+
+// fakeKMSCreated is the creation date of keys and grants of the fake kms
+var fakeKMSCreated = time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+
+// Encrypt mocks KMSAPI.Encrypt of the fake kms
+func (m KMSAPI) Encrypt(input *kms.EncryptInput) (*kms.EncryptOutput, error) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	if len(input.Plaintext) == 0 || len(input.Plaintext) > 4096 {
+		return nil, awserr.New("ValidationException",
+			"plaintext must be 1 to 4096 bytes", nil)
+	}
+	arn, err := m.state.usableKey(aws.StringValue(input.KeyId))
+	if err != nil {
+		return nil, err
+	}
+	return &kms.EncryptOutput{
+		KeyId:          aws.String(arn),
+		CiphertextBlob: fakeBlob(arn, input.EncryptionContext, input.Plaintext),
+	}, nil
+}
+
+// EncryptWithContext mocks KMSAPI.EncryptWithContext
+func (m KMSAPI) EncryptWithContext(ctx aws.Context,
+	input *kms.EncryptInput, opts ...request.Option) (*kms.EncryptOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.Encrypt(input)
+}
+
+// ReEncrypt mocks KMSAPI.ReEncrypt of the fake kms
+func (m KMSAPI) ReEncrypt(input *kms.ReEncryptInput) (*kms.ReEncryptOutput, error) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	srcARN, plain, err := m.state.open(input.CiphertextBlob, input.SourceEncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	arn, err := m.state.usableKey(aws.StringValue(input.DestinationKeyId))
+	if err != nil {
+		return nil, err
+	}
+	return &kms.ReEncryptOutput{
+		KeyId:          aws.String(arn),
+		SourceKeyId:    aws.String(srcARN),
+		CiphertextBlob: fakeBlob(arn, input.DestinationEncryptionContext, plain),
+	}, nil
+}
+
+// ReEncryptWithContext mocks KMSAPI.ReEncryptWithContext
+func (m KMSAPI) ReEncryptWithContext(ctx aws.Context,
+	input *kms.ReEncryptInput, opts ...request.Option) (*kms.ReEncryptOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.ReEncrypt(input)
+}
+
+// DescribeKey mocks KMSAPI.DescribeKey of the fake kms
+func (m KMSAPI) DescribeKey(input *kms.DescribeKeyInput) (*kms.DescribeKeyOutput, error) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	arn, err := m.state.resolve(aws.StringValue(input.KeyId))
+	if err != nil {
+		return nil, err
+	}
+	keyState := kms.KeyStateEnabled
+	if m.state.disabled[arn] {
+		keyState = kms.KeyStateDisabled
+	}
+	return &kms.DescribeKeyOutput{KeyMetadata: &kms.KeyMetadata{
+		AWSAccountId: aws.String("123456789012"),
+		Arn:          aws.String(arn),
+		KeyId:        aws.String(arn[strings.LastIndex(arn, "/")+1:]),
+		Description:  aws.String("fake key"),
+		Enabled:      aws.Bool(!m.state.disabled[arn]),
+		KeyState:     aws.String(keyState),
+		KeyUsage:     aws.String(kms.KeyUsageTypeEncryptDecrypt),
+		KeyManager:   aws.String(kms.KeyManagerTypeCustomer),
+		Origin:       aws.String(kms.OriginTypeAwsKms),
+		CreationDate: aws.Time(fakeKMSCreated),
+	}}, nil
+}
+
+// DescribeKeyWithContext mocks KMSAPI.DescribeKeyWithContext
+func (m KMSAPI) DescribeKeyWithContext(ctx aws.Context,
+	input *kms.DescribeKeyInput, opts ...request.Option) (*kms.DescribeKeyOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.DescribeKey(input)
+}
+
+// page returns the range of a page of count entries and the next marker,
+// must be called w/ mutex locked
+func (state *kmsState) page(count int, marker *string, limit *int64) (int, int, *string) {
+	start, _ := strconv.Atoi(aws.StringValue(marker))
+	size := int(aws.Int64Value(limit))
+	if size <= 0 {
+		size = state.pageSize
+	}
+	if size <= 0 {
+		size = fakeKMSPageSize
+	}
+	if start > count {
+		start = count
+	}
+	end := start + size
+	if end >= count {
+		return start, count, nil
+	}
+	return start, end, aws.String(strconv.Itoa(end))
+}
+
+// ListAliases mocks KMSAPI.ListAliases of the fake kms, sorted by name
+func (m KMSAPI) ListAliases(input *kms.ListAliasesInput) (*kms.ListAliasesOutput, error) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	target := ""
+	if input.KeyId != nil {
+		arn, err := m.state.resolve(aws.StringValue(input.KeyId))
+		if err != nil {
+			return nil, err
+		}
+		target = arn
+	}
+	names := make([]string, 0, len(m.state.aliases))
+	for name, arn := range m.state.aliases {
+		if target == "" || arn == target {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	start, end, next := m.state.page(len(names), input.Marker, input.Limit)
+	output := &kms.ListAliasesOutput{
+		Aliases:    []*kms.AliasListEntry{},
+		NextMarker: next,
+		Truncated:  aws.Bool(next != nil),
+	}
+	for _, name := range names[start:end] {
+		arn := m.state.aliases[name]
+		output.Aliases = append(output.Aliases, &kms.AliasListEntry{
+			AliasName:   aws.String(name),
+			AliasArn:    aws.String("arn:aws:kms:us-east-1:123456789012:" + name),
+			TargetKeyId: aws.String(arn[strings.LastIndex(arn, "/")+1:]),
+		})
+	}
+	return output, nil
+}
+
+// ListAliasesWithContext mocks KMSAPI.ListAliasesWithContext
+func (m KMSAPI) ListAliasesWithContext(ctx aws.Context,
+	input *kms.ListAliasesInput, opts ...request.Option) (*kms.ListAliasesOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.ListAliases(input)
+}
+
+// ListAliasesPagesWithContext mocks KMSAPI.ListAliasesPagesWithContext
+func (m KMSAPI) ListAliasesPagesWithContext(ctx aws.Context, input *kms.ListAliasesInput,
+	fn func(*kms.ListAliasesOutput, bool) bool, opts ...request.Option) error {
+	pageInput := *input
+	for {
+		page, err := m.ListAliasesWithContext(ctx, &pageInput, opts...)
+		if err != nil {
+			return err
+		}
+		lastPage := page.NextMarker == nil
+		if !fn(page, lastPage) || lastPage {
+			return nil
+		}
+		pageInput.Marker = page.NextMarker
+	}
+}
+
+// CreateGrant mocks KMSAPI.CreateGrant of the fake kms,
+// grants are recorded but not enforced
+func (m KMSAPI) CreateGrant(input *kms.CreateGrantInput) (*kms.CreateGrantOutput, error) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	arn, err := m.state.resolve(aws.StringValue(input.KeyId))
+	if err != nil {
+		return nil, err
+	}
+	if len(input.Operations) == 0 {
+		return nil, awserr.New("ValidationException", "Operations is required", nil)
+	}
+	m.state.grantSeq++
+	grantID := fmt.Sprintf("grant-%d", m.state.grantSeq)
+	m.state.grants = append(m.state.grants, &kms.GrantListEntry{
+		GrantId:           aws.String(grantID),
+		KeyId:             aws.String(arn),
+		Name:              input.Name,
+		GranteePrincipal:  input.GranteePrincipal,
+		RetiringPrincipal: input.RetiringPrincipal,
+		IssuingAccount:    aws.String("arn:aws:iam::123456789012:root"),
+		Operations:        input.Operations,
+		Constraints:       input.Constraints,
+		CreationDate:      aws.Time(fakeKMSCreated),
+	})
+	return &kms.CreateGrantOutput{
+		GrantId:    aws.String(grantID),
+		GrantToken: aws.String("token-" + grantID),
+	}, nil
+}
+
+// CreateGrantWithContext mocks KMSAPI.CreateGrantWithContext
+func (m KMSAPI) CreateGrantWithContext(ctx aws.Context,
+	input *kms.CreateGrantInput, opts ...request.Option) (*kms.CreateGrantOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.CreateGrant(input)
+}
+
+// removeGrant deletes a grant of a key, must be called w/ mutex locked
+func (state *kmsState) removeGrant(keyID, grantID string) error {
+	arn, err := state.resolve(keyID)
+	if err != nil {
+		return err
+	}
+	for idx, grant := range state.grants {
+		if aws.StringValue(grant.KeyId) == arn && aws.StringValue(grant.GrantId) == grantID {
+			state.grants = append(state.grants[:idx], state.grants[idx+1:]...)
+			return nil
+		}
+	}
+	return awserr.New(kms.ErrCodeNotFoundException, "Grant "+grantID+" not found", nil)
+}
+
+// RetireGrant mocks KMSAPI.RetireGrant of the fake kms
+func (m KMSAPI) RetireGrant(input *kms.RetireGrantInput) (*kms.RetireGrantOutput, error) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	if err := m.state.removeGrant(aws.StringValue(input.KeyId),
+		aws.StringValue(input.GrantId)); err != nil {
+		return nil, err
+	}
+	return &kms.RetireGrantOutput{}, nil
+}
+
+// RetireGrantWithContext mocks KMSAPI.RetireGrantWithContext
+func (m KMSAPI) RetireGrantWithContext(ctx aws.Context,
+	input *kms.RetireGrantInput, opts ...request.Option) (*kms.RetireGrantOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.RetireGrant(input)
+}
+
+// RevokeGrant mocks KMSAPI.RevokeGrant of the fake kms
+func (m KMSAPI) RevokeGrant(input *kms.RevokeGrantInput) (*kms.RevokeGrantOutput, error) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	if err := m.state.removeGrant(aws.StringValue(input.KeyId),
+		aws.StringValue(input.GrantId)); err != nil {
+		return nil, err
+	}
+	return &kms.RevokeGrantOutput{}, nil
+}
+
+// RevokeGrantWithContext mocks KMSAPI.RevokeGrantWithContext
+func (m KMSAPI) RevokeGrantWithContext(ctx aws.Context,
+	input *kms.RevokeGrantInput, opts ...request.Option) (*kms.RevokeGrantOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.RevokeGrant(input)
+}
+
+// listGrants returns a page of grants matched, must be called w/ mutex locked
+func (state *kmsState) listGrants(match func(*kms.GrantListEntry) bool,
+	marker *string, limit *int64) *kms.ListGrantsResponse {
+	grants := []*kms.GrantListEntry{}
+	for _, grant := range state.grants {
+		if match(grant) {
+			grants = append(grants, grant)
+		}
+	}
+	start, end, next := state.page(len(grants), marker, limit)
+	return &kms.ListGrantsResponse{
+		Grants:     grants[start:end],
+		NextMarker: next,
+		Truncated:  aws.Bool(next != nil),
+	}
+}
+
+// ListGrants mocks KMSAPI.ListGrants of the fake kms
+func (m KMSAPI) ListGrants(input *kms.ListGrantsInput) (*kms.ListGrantsResponse, error) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	arn, err := m.state.resolve(aws.StringValue(input.KeyId))
+	if err != nil {
+		return nil, err
+	}
+	return m.state.listGrants(func(grant *kms.GrantListEntry) bool {
+		return aws.StringValue(grant.KeyId) == arn
+	}, input.Marker, input.Limit), nil
+}
+
+// ListGrantsWithContext mocks KMSAPI.ListGrantsWithContext
+func (m KMSAPI) ListGrantsWithContext(ctx aws.Context,
+	input *kms.ListGrantsInput, opts ...request.Option) (*kms.ListGrantsResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.ListGrants(input)
+}
+
+// ListGrantsPagesWithContext mocks KMSAPI.ListGrantsPagesWithContext
+func (m KMSAPI) ListGrantsPagesWithContext(ctx aws.Context, input *kms.ListGrantsInput,
+	fn func(*kms.ListGrantsResponse, bool) bool, opts ...request.Option) error {
+	pageInput := *input
+	for {
+		page, err := m.ListGrantsWithContext(ctx, &pageInput, opts...)
+		if err != nil {
+			return err
+		}
+		lastPage := page.NextMarker == nil
+		if !fn(page, lastPage) || lastPage {
+			return nil
+		}
+		pageInput.Marker = page.NextMarker
+	}
+}
+
+// ListRetirableGrants mocks KMSAPI.ListRetirableGrants of the fake kms
+func (m KMSAPI) ListRetirableGrants(
+	input *kms.ListRetirableGrantsInput) (*kms.ListGrantsResponse, error) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	principal := aws.StringValue(input.RetiringPrincipal)
+	return m.state.listGrants(func(grant *kms.GrantListEntry) bool {
+		return aws.StringValue(grant.RetiringPrincipal) == principal
+	}, input.Marker, input.Limit), nil
+}
+
+// ListRetirableGrantsWithContext mocks KMSAPI.ListRetirableGrantsWithContext
+func (m KMSAPI) ListRetirableGrantsWithContext(ctx aws.Context,
+	input *kms.ListRetirableGrantsInput, opts ...request.Option) (*kms.ListGrantsResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.ListRetirableGrants(input)
+}
+
+// SetAlias points an alias, e.g. alias/app, to a key of the fake kms
+func (m KMSAPI) SetAlias(alias string, keyID string) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	m.state.aliases[alias] = fakeKeyARN(keyID)
+}
+
+// SetKeyEnabled enables or disables a key of the fake kms,
+// disabled keys fail cryptographic operations
+func (m KMSAPI) SetKeyEnabled(keyID string, enabled bool) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	m.state.disabled[fakeKeyARN(keyID)] = !enabled
+}
+
+// SetPageSize sets the default page size of list operations of the fake kms
+func (m KMSAPI) SetPageSize(size int) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	m.state.pageSize = size
+}
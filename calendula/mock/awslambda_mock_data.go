@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
 )
@@ -29,6 +30,24 @@ func (m LambdaAPI) AddPermission(
 	return &m.addpermOutput, m.addpermErr
 }
 
+// InvokeWithContext mocks LambdaAPI.InvokeWithContext
+func (m LambdaAPI) InvokeWithContext(ctx aws.Context,
+	input *lambda.InvokeInput, opts ...request.Option) (*lambda.InvokeOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.Invoke(input)
+}
+
+// AddPermissionWithContext mocks LambdaAPI.AddPermissionWithContext
+func (m LambdaAPI) AddPermissionWithContext(ctx aws.Context,
+	input *lambda.AddPermissionInput, opts ...request.Option) (*lambda.AddPermissionOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.AddPermission(input)
+}
+
 func mockInvokeOutput(
 	payload []byte, status int64, errString string) *lambda.InvokeOutput {
 	return &lambda.InvokeOutput{
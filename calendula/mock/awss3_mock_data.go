@@ -5,8 +5,17 @@ import (
 	"errors"
 	"fmt"
 	io "io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -19,12 +28,45 @@ type S3API struct {
 	getIndex           *int
 	getObjectOutputs   []s3.GetObjectOutput
 	getErrors          []error
+	getInput           *s3.GetObjectInput
 	listIndex          *int
 	listObjectsOutputs []s3.ListObjectsV2Output
 	listErrors         []error
+	listInput          *s3.ListObjectsV2Input
+	listTree           map[string]*s3.ListObjectsV2Output
+	listTreeErrors     map[string]error
 	deleteIndex        *int
 	deleteObjectOutput *s3.DeleteObjectOutput
 	deleteErrors       []error
+	headObjectOutput   *s3.HeadObjectOutput
+	headErr            error
+	taggingOutput      *s3.GetObjectTaggingOutput
+	taggingErr         error
+	deleteInput        *s3.DeleteObjectInput
+	versionsIndex      *int
+	versionsOutputs    []s3.ListObjectVersionsOutput
+	versionsErr        error
+	copyObjectOutput   *s3.CopyObjectOutput
+	copyErr            error
+	copyInput          *s3.CopyObjectInput
+	batch              *s3Batch
+	presigner          *s3.S3
+	presignErr         error
+}
+
+// s3Batch records concurrent batch operations
+type s3Batch struct {
+	mutex          sync.Mutex
+	failKeys       map[string]bool
+	deleteCalls    int
+	deletedKeys    []string
+	copiedKeys     []string
+	copyInputs     []*s3.CopyObjectInput
+	partRanges     []string
+	partInputs     []*s3.UploadPartCopyInput
+	createInput    *s3.CreateMultipartUploadInput
+	completedParts []*s3.CompletedPart
+	aborted        bool
 }
 
 // S3ManagerAPI mocks s3manageriface.UploaderAPI
@@ -32,10 +74,32 @@ type S3ManagerAPI struct {
 	s3manageriface.UploaderAPI
 	uploadOutput s3manager.UploadOutput
 	err          error
+	uploadInput  *s3manager.UploadInput
+	uploader     *s3manager.Uploader
+	uploads      *s3Uploads
+}
+
+// s3Uploads records concurrent uploads
+type s3Uploads struct {
+	mutex    sync.Mutex
+	failKeys map[string]bool
+	bodies   map[string]string
+}
+
+// S3DownloaderAPI mocks s3manageriface.DownloaderAPI
+type S3DownloaderAPI struct {
+	s3manageriface.DownloaderAPI
+	content       string
+	err           error
+	downloadInput *s3.GetObjectInput
+	downloader    *s3manager.Downloader
 }
 
 // GetObject mocks S3API.GetObject
-func (m S3API) GetObject(*s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+func (m S3API) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	if m.getInput != nil {
+		*m.getInput = *input
+	}
 	var output s3.GetObjectOutput
 	if *m.getIndex < len(m.getObjectOutputs) {
 		output = m.getObjectOutputs[*m.getIndex]
@@ -48,9 +112,23 @@ func (m S3API) GetObject(*s3.GetObjectInput) (*s3.GetObjectOutput, error) {
 	return &output, err
 }
 
-// ListObjectsV2 mocks S3API.ListObjectsV2
+// ListObjectsV2 mocks S3API.ListObjectsV2,
+// a tree mock returns the output of the prefix and is safe for concurrent use
 func (m S3API) ListObjectsV2(
-	*s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	if m.listTree != nil {
+		prefix := aws.StringValue(input.Prefix)
+		if err, ok := m.listTreeErrors[prefix]; ok {
+			return nil, err
+		}
+		if output, ok := m.listTree[prefix]; ok {
+			return output, nil
+		}
+		return &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}, nil
+	}
+	if m.listInput != nil {
+		*m.listInput = *input
+	}
 	var output s3.ListObjectsV2Output
 	if *m.listIndex < len(m.listObjectsOutputs) {
 		output = m.listObjectsOutputs[*m.listIndex]
@@ -63,9 +141,17 @@ func (m S3API) ListObjectsV2(
 	return &output, err
 }
 
+// LastListObjectsInput returns the input of the last s3.ListObjectsV2
+func (m S3API) LastListObjectsInput() *s3.ListObjectsV2Input {
+	return m.listInput
+}
+
 // DeleteObject mocks S3API.DeleteObject
 func (m S3API) DeleteObject(
-	*s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	if m.deleteInput != nil {
+		*m.deleteInput = *input
+	}
 	var err error
 	if *m.deleteIndex < len(m.deleteErrors) {
 		err = m.deleteErrors[*m.deleteIndex]
@@ -74,12 +160,368 @@ func (m S3API) DeleteObject(
 	return m.deleteObjectOutput, err
 }
 
+// HeadObject mocks S3API.HeadObject
+func (m S3API) HeadObject(*s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	return m.headObjectOutput, m.headErr
+}
+
+// HeadObjectWithContext mocks S3API.HeadObjectWithContext
+func (m S3API) HeadObjectWithContext(ctx aws.Context,
+	input *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.HeadObject(input)
+}
+
+// GetObjectTagging mocks S3API.GetObjectTagging
+func (m S3API) GetObjectTagging(
+	*s3.GetObjectTaggingInput) (*s3.GetObjectTaggingOutput, error) {
+	return m.taggingOutput, m.taggingErr
+}
+
+// GetObjectTaggingWithContext mocks S3API.GetObjectTaggingWithContext
+func (m S3API) GetObjectTaggingWithContext(ctx aws.Context,
+	input *s3.GetObjectTaggingInput, opts ...request.Option) (*s3.GetObjectTaggingOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetObjectTagging(input)
+}
+
+// ListObjectVersions mocks S3API.ListObjectVersions
+func (m S3API) ListObjectVersions(
+	*s3.ListObjectVersionsInput) (*s3.ListObjectVersionsOutput, error) {
+	var output s3.ListObjectVersionsOutput
+	if *m.versionsIndex < len(m.versionsOutputs) {
+		output = m.versionsOutputs[*m.versionsIndex]
+		*m.versionsIndex = (*m.versionsIndex + 1) % len(m.versionsOutputs)
+	}
+	return &output, m.versionsErr
+}
+
+// ListObjectVersionsWithContext mocks S3API.ListObjectVersionsWithContext
+func (m S3API) ListObjectVersionsWithContext(ctx aws.Context,
+	input *s3.ListObjectVersionsInput, opts ...request.Option) (*s3.ListObjectVersionsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.ListObjectVersions(input)
+}
+
+// CopyObject mocks S3API.CopyObject
+func (m S3API) CopyObject(input *s3.CopyObjectInput) (*s3.CopyObjectOutput, error) {
+	if m.batch != nil {
+		m.batch.mutex.Lock()
+		defer m.batch.mutex.Unlock()
+		source, _ := url.PathUnescape(aws.StringValue(input.CopySource))
+		if m.batch.failKeys[source] {
+			return nil, awserr.New("AccessDenied", "FakeCopyObjectError", nil)
+		}
+		m.batch.copiedKeys = append(m.batch.copiedKeys, aws.StringValue(input.Key))
+		m.batch.copyInputs = append(m.batch.copyInputs, input)
+		return &s3.CopyObjectOutput{}, nil
+	}
+	if m.copyInput != nil {
+		*m.copyInput = *input
+	}
+	return m.copyObjectOutput, m.copyErr
+}
+
+// CopyObjectWithContext mocks S3API.CopyObjectWithContext
+func (m S3API) CopyObjectWithContext(ctx aws.Context,
+	input *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.CopyObject(input)
+}
+
+// DeleteObjects mocks S3API.DeleteObjects,
+// keys of failKeys are reported as errors
+func (m S3API) DeleteObjects(input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+	m.batch.mutex.Lock()
+	defer m.batch.mutex.Unlock()
+	m.batch.deleteCalls++
+	output := &s3.DeleteObjectsOutput{}
+	for _, obj := range input.Delete.Objects {
+		key := aws.StringValue(obj.Key)
+		if m.batch.failKeys[aws.StringValue(input.Bucket)+"/"+key] {
+			output.Errors = append(output.Errors, &s3.Error{
+				Key: obj.Key, Code: aws.String("AccessDenied"),
+				Message: aws.String("Access Denied")})
+			continue
+		}
+		m.batch.deletedKeys = append(m.batch.deletedKeys, key)
+		output.Deleted = append(output.Deleted, &s3.DeletedObject{Key: obj.Key})
+	}
+	return output, nil
+}
+
+// DeleteObjectsWithContext mocks S3API.DeleteObjectsWithContext
+func (m S3API) DeleteObjectsWithContext(ctx aws.Context,
+	input *s3.DeleteObjectsInput, opts ...request.Option) (*s3.DeleteObjectsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.DeleteObjects(input)
+}
+
+// CreateMultipartUploadWithContext mocks S3API.CreateMultipartUploadWithContext
+func (m S3API) CreateMultipartUploadWithContext(ctx aws.Context,
+	input *s3.CreateMultipartUploadInput,
+	opts ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	m.batch.mutex.Lock()
+	defer m.batch.mutex.Unlock()
+	m.batch.createInput = input
+	return &s3.CreateMultipartUploadOutput{
+		Bucket: input.Bucket, Key: input.Key, UploadId: aws.String("fake-upload-id")}, nil
+}
+
+// UploadPartCopyWithContext mocks S3API.UploadPartCopyWithContext,
+// the part fails if its range is in failKeys
+func (m S3API) UploadPartCopyWithContext(ctx aws.Context,
+	input *s3.UploadPartCopyInput, opts ...request.Option) (*s3.UploadPartCopyOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.batch.mutex.Lock()
+	defer m.batch.mutex.Unlock()
+	rng := aws.StringValue(input.CopySourceRange)
+	if m.batch.failKeys[rng] {
+		return nil, errors.New("FakeUploadPartCopyError")
+	}
+	m.batch.partRanges = append(m.batch.partRanges, rng)
+	m.batch.partInputs = append(m.batch.partInputs, input)
+	return &s3.UploadPartCopyOutput{CopyPartResult: &s3.CopyPartResult{
+		ETag: aws.String(fmt.Sprintf(`"etag-%d"`, aws.Int64Value(input.PartNumber)))}}, nil
+}
+
+// CompleteMultipartUploadWithContext mocks S3API.CompleteMultipartUploadWithContext
+func (m S3API) CompleteMultipartUploadWithContext(ctx aws.Context,
+	input *s3.CompleteMultipartUploadInput,
+	opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	m.batch.mutex.Lock()
+	defer m.batch.mutex.Unlock()
+	m.batch.completedParts = input.MultipartUpload.Parts
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+// AbortMultipartUpload mocks S3API.AbortMultipartUpload
+func (m S3API) AbortMultipartUpload(
+	*s3.AbortMultipartUploadInput) (*s3.AbortMultipartUploadOutput, error) {
+	m.batch.mutex.Lock()
+	defer m.batch.mutex.Unlock()
+	m.batch.aborted = true
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// DeleteCalls returns the number of s3.DeleteObjects calls
+func (m S3API) DeleteCalls() int {
+	return m.batch.deleteCalls
+}
+
+// DeletedKeys returns keys deleted by s3.DeleteObjects
+func (m S3API) DeletedKeys() []string {
+	return m.batch.deletedKeys
+}
+
+// CopiedKeys returns destination keys of s3.CopyObject
+func (m S3API) CopiedKeys() []string {
+	return m.batch.copiedKeys
+}
+
+// CopyObjectInputs returns inputs of s3.CopyObject
+func (m S3API) CopyObjectInputs() []*s3.CopyObjectInput {
+	return m.batch.copyInputs
+}
+
+// PartRanges returns source ranges of s3.UploadPartCopy
+func (m S3API) PartRanges() []string {
+	return m.batch.partRanges
+}
+
+// CompletedParts returns parts of s3.CompleteMultipartUpload
+func (m S3API) CompletedParts() []*s3.CompletedPart {
+	return m.batch.completedParts
+}
+
+// PartCopyInputs returns inputs of s3.UploadPartCopy
+func (m S3API) PartCopyInputs() []*s3.UploadPartCopyInput {
+	return m.batch.partInputs
+}
+
+// LastCreateMultipartUploadInput returns the input of s3.CreateMultipartUpload
+func (m S3API) LastCreateMultipartUploadInput() *s3.CreateMultipartUploadInput {
+	return m.batch.createInput
+}
+
+// SetHeadObject replaces the output of s3.HeadObject
+func (m S3API) SetHeadObject(output *s3.HeadObjectOutput) {
+	*m.headObjectOutput = *output
+}
+
+// SetObjectTags replaces the tags returned by s3.GetObjectTagging
+func (m S3API) SetObjectTags(tags map[string]string) {
+	m.taggingOutput.TagSet = make([]*s3.Tag, 0, len(tags))
+	for key, value := range tags {
+		m.taggingOutput.TagSet = append(m.taggingOutput.TagSet,
+			&s3.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+}
+
+// Aborted checks if s3.AbortMultipartUpload is called
+func (m S3API) Aborted() bool {
+	return m.batch.aborted
+}
+
+// LastDeleteObjectInput returns the input of the last s3.DeleteObject
+func (m S3API) LastDeleteObjectInput() *s3.DeleteObjectInput {
+	return m.deleteInput
+}
+
+// LastCopyObjectInput returns the input of the last s3.CopyObject
+func (m S3API) LastCopyObjectInput() *s3.CopyObjectInput {
+	return m.copyInput
+}
+
 // Upload mocks UploaderAPI.Upload
 func (m S3ManagerAPI) Upload(input *s3manager.UploadInput,
 	options ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	if m.uploads != nil {
+		return m.record(input)
+	}
+	if m.uploadInput != nil {
+		*m.uploadInput = *input
+	}
+	if m.uploader != nil {
+		for _, option := range options {
+			option(m.uploader)
+		}
+	}
 	return &m.uploadOutput, m.err
 }
 
+// GetObjectWithContext mocks S3API.GetObjectWithContext
+func (m S3API) GetObjectWithContext(ctx aws.Context,
+	input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetObject(input)
+}
+
+// ListObjectsV2WithContext mocks S3API.ListObjectsV2WithContext
+func (m S3API) ListObjectsV2WithContext(ctx aws.Context,
+	input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.ListObjectsV2(input)
+}
+
+// DeleteObjectWithContext mocks S3API.DeleteObjectWithContext
+func (m S3API) DeleteObjectWithContext(ctx aws.Context,
+	input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.DeleteObject(input)
+}
+
+// UploadWithContext mocks UploaderAPI.UploadWithContext
+func (m S3ManagerAPI) UploadWithContext(ctx aws.Context, input *s3manager.UploadInput,
+	options ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.Upload(input, options...)
+}
+
+// Download mocks DownloaderAPI.Download
+func (m S3DownloaderAPI) Download(w io.WriterAt, input *s3.GetObjectInput,
+	options ...func(*s3manager.Downloader)) (int64, error) {
+	*m.downloadInput = *input
+	for _, option := range options {
+		option(m.downloader)
+	}
+	if m.err != nil {
+		return 0, m.err
+	}
+	size, err := w.WriteAt([]byte(m.content), 0)
+	return int64(size), err
+}
+
+// DownloadWithContext mocks DownloaderAPI.DownloadWithContext
+func (m S3DownloaderAPI) DownloadWithContext(ctx aws.Context, w io.WriterAt,
+	input *s3.GetObjectInput, options ...func(*s3manager.Downloader)) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return m.Download(w, input, options...)
+}
+
+// LastGetObjectInput returns the input of the last s3.GetObject
+func (m S3API) LastGetObjectInput() *s3.GetObjectInput {
+	return m.getInput
+}
+
+// LastUploadInput returns the input of the last Upload
+func (m S3ManagerAPI) LastUploadInput() *s3manager.UploadInput {
+	return m.uploadInput
+}
+
+// record reads and records the body of an upload,
+// the upload fails if "bucket/key" is in failKeys
+func (m S3ManagerAPI) record(input *s3manager.UploadInput) (*s3manager.UploadOutput, error) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(input.Body); err != nil {
+		return nil, err
+	}
+	key := aws.StringValue(input.Key)
+	m.uploads.mutex.Lock()
+	defer m.uploads.mutex.Unlock()
+	if m.uploads.failKeys[aws.StringValue(input.Bucket)+"/"+key] {
+		return nil, errors.New("FakeUploadError")
+	}
+	m.uploads.bodies[key] = buf.String()
+	return &m.uploadOutput, nil
+}
+
+// UploadedKeys returns the sorted keys of recorded uploads
+func (m S3ManagerAPI) UploadedKeys() []string {
+	m.uploads.mutex.Lock()
+	defer m.uploads.mutex.Unlock()
+	keys := make([]string, 0, len(m.uploads.bodies))
+	for key := range m.uploads.bodies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// UploadedBody returns the body of a recorded upload
+func (m S3ManagerAPI) UploadedBody(key string) string {
+	m.uploads.mutex.Lock()
+	defer m.uploads.mutex.Unlock()
+	return m.uploads.bodies[key]
+}
+
+// Uploader returns the uploader configured by the last Upload options
+func (m S3ManagerAPI) Uploader() *s3manager.Uploader {
+	return m.uploader
+}
+
+// LastDownloadInput returns the input of the last Download
+func (m S3DownloaderAPI) LastDownloadInput() *s3.GetObjectInput {
+	return m.downloadInput
+}
+
+// Downloader returns the downloader configured by the last Download options
+func (m S3DownloaderAPI) Downloader() *s3manager.Downloader {
+	return m.downloader
+}
+
 // ReadCloser mocks io.ReadCloser
 type ReadCloser struct {
 	io.Reader
@@ -128,6 +570,7 @@ func S3APIGetObjects(contents []string, versionID string) *S3API {
 	s3api := &S3API{
 		getIndex:         aws.Int(0),
 		getObjectOutputs: make([]s3.GetObjectOutput, 0),
+		getInput:         &s3.GetObjectInput{},
 	}
 	for _, content := range contents {
 		s3api.getObjectOutputs = append(
@@ -173,6 +616,7 @@ func S3APIListObjects(bucket, prefix string, names []string) *S3API {
 		listIndex: aws.Int(0),
 		listObjectsOutputs: []s3.ListObjectsV2Output{
 			*mockListObjectsOutput(bucket, prefix, names, false)},
+		listInput: &s3.ListObjectsV2Input{},
 	}
 }
 
@@ -206,6 +650,7 @@ func S3APIDeleteObject(versionID string) *S3API {
 		deleteObjectOutput: mockDeleteObjectOutput(versionID),
 		deleteErrors:       nil,
 		deleteIndex:        aws.Int(0),
+		deleteInput:        &s3.DeleteObjectInput{},
 	}
 }
 
@@ -245,6 +690,8 @@ func S3ManagerAPIUpload(versionID string) *S3ManagerAPI {
 	return &S3ManagerAPI{
 		uploadOutput: *mockUploadOutput(versionID),
 		err:          nil,
+		uploadInput:  &s3manager.UploadInput{},
+		uploader:     &s3manager.Uploader{},
 	}
 }
 
@@ -255,3 +702,269 @@ func S3ManagerAPIUploadError() *S3ManagerAPI {
 		err:          errors.New("FakeUploadError"),
 	}
 }
+
+// S3ManagerAPIUploads returns mock.S3ManagerAPI recording concurrent uploads,
+// failKeys are "bucket/key" failing to upload
+func S3ManagerAPIUploads(versionID string, failKeys ...string) *S3ManagerAPI {
+	uploads := &s3Uploads{
+		failKeys: make(map[string]bool),
+		bodies:   make(map[string]string),
+	}
+	for _, key := range failKeys {
+		uploads.failKeys[key] = true
+	}
+	return &S3ManagerAPI{
+		uploadOutput: *mockUploadOutput(versionID),
+		uploads:      uploads,
+	}
+}
+
+// S3DownloaderAPIDownload returns mock.S3DownloaderAPI writing content
+func S3DownloaderAPIDownload(content string) *S3DownloaderAPI {
+	return &S3DownloaderAPI{
+		content:       content,
+		downloadInput: &s3.GetObjectInput{},
+		downloader:    &s3manager.Downloader{},
+	}
+}
+
+// S3DownloaderAPIDownloadError returns mock.S3DownloaderAPI w/ error
+func S3DownloaderAPIDownloadError() *S3DownloaderAPI {
+	return &S3DownloaderAPI{
+		err:           errors.New("FakeDownloadError"),
+		downloadInput: &s3.GetObjectInput{},
+		downloader:    &s3manager.Downloader{},
+	}
+}
+
+// S3APIHeadObject returns S3API with s3.HeadObject of a kms encrypted
+// and locked object
+func S3APIHeadObject(versionID string, metadata map[string]string,
+	kmsKeyID string, retainUntil time.Time) *S3API {
+	return &S3API{
+		headObjectOutput: &s3.HeadObjectOutput{
+			ContentLength:             aws.Int64(7),
+			ContentType:               aws.String("text/plain"),
+			ETag:                      aws.String(`"fake-etag"`),
+			LastModified:              aws.Time(retainUntil.AddDate(0, 0, -1)),
+			Metadata:                  aws.StringMap(metadata),
+			ObjectLockLegalHoldStatus: aws.String(s3.ObjectLockLegalHoldStatusOn),
+			ObjectLockMode:            aws.String(s3.ObjectLockModeGovernance),
+			ObjectLockRetainUntilDate: aws.Time(retainUntil),
+			SSEKMSKeyId:               aws.String(kmsKeyID),
+			ServerSideEncryption:      aws.String(s3.ServerSideEncryptionAwsKms),
+			VersionId:                 aws.String(versionID),
+		},
+	}
+}
+
+// S3APIHeadObjectError returns S3API with s3.HeadObject error
+func S3APIHeadObjectError() *S3API {
+	return &S3API{headErr: errors.New("FakeHeadObjectError")}
+}
+
+// S3APIGetObjectTagging returns S3API with s3.GetObjectTagging
+func S3APIGetObjectTagging(tags map[string]string) *S3API {
+	output := &s3.GetObjectTaggingOutput{TagSet: make([]*s3.Tag, 0)}
+	for key, value := range tags {
+		output.TagSet = append(output.TagSet,
+			&s3.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return &S3API{taggingOutput: output}
+}
+
+// S3APIGetObjectTaggingError returns S3API with s3.GetObjectTagging error
+func S3APIGetObjectTaggingError() *S3API {
+	return &S3API{taggingErr: errors.New("FakeGetObjectTaggingError")}
+}
+
+// S3ObjectVersion defines a version returned by S3APIListObjectVersions
+type S3ObjectVersion struct {
+	Key          string
+	VersionID    string
+	IsLatest     bool
+	DeleteMarker bool
+	LastModified time.Time
+}
+
+func mockListObjectVersionsOutput(versions []S3ObjectVersion,
+	nextKey, nextVersion string) *s3.ListObjectVersionsOutput {
+	output := &s3.ListObjectVersionsOutput{
+		IsTruncated: aws.Bool(nextKey != ""),
+	}
+	if nextKey != "" {
+		output.NextKeyMarker = aws.String(nextKey)
+		output.NextVersionIdMarker = aws.String(nextVersion)
+	}
+	for _, version := range versions {
+		if version.DeleteMarker {
+			output.DeleteMarkers = append(output.DeleteMarkers, &s3.DeleteMarkerEntry{
+				Key:          aws.String(version.Key),
+				VersionId:    aws.String(version.VersionID),
+				IsLatest:     aws.Bool(version.IsLatest),
+				LastModified: aws.Time(version.LastModified),
+			})
+			continue
+		}
+		output.Versions = append(output.Versions, &s3.ObjectVersion{
+			Key:          aws.String(version.Key),
+			VersionId:    aws.String(version.VersionID),
+			IsLatest:     aws.Bool(version.IsLatest),
+			LastModified: aws.Time(version.LastModified),
+			Size:         aws.Int64(int64(len(version.VersionID))),
+			ETag:         aws.String(fmt.Sprintf(`"etag-%s"`, version.VersionID)),
+		})
+	}
+	return output
+}
+
+// S3APIListObjectVersions returns S3API with s3.ListObjectVersions pages,
+// all pages but the last one are truncated
+func S3APIListObjectVersions(pages [][]S3ObjectVersion) *S3API {
+	s3api := &S3API{
+		versionsIndex:   aws.Int(0),
+		versionsOutputs: make([]s3.ListObjectVersionsOutput, 0),
+	}
+	for idx, page := range pages {
+		nextKey, nextVersion := "", ""
+		if idx < len(pages)-1 {
+			nextKey = fmt.Sprintf("next-key-%d", idx)
+			nextVersion = fmt.Sprintf("next-version-%d", idx)
+		}
+		s3api.versionsOutputs = append(s3api.versionsOutputs,
+			*mockListObjectVersionsOutput(page, nextKey, nextVersion))
+	}
+	return s3api
+}
+
+// S3APIListObjectVersionsError returns S3API with s3.ListObjectVersions error
+func S3APIListObjectVersionsError() *S3API {
+	return &S3API{
+		versionsIndex: aws.Int(0),
+		versionsErr:   errors.New("FakeListObjectVersionsError"),
+	}
+}
+
+// S3APICopyObject returns S3API with s3.CopyObject
+func S3APICopyObject(versionID string) *S3API {
+	return &S3API{
+		copyObjectOutput: &s3.CopyObjectOutput{VersionId: aws.String(versionID)},
+		copyInput:        &s3.CopyObjectInput{},
+	}
+}
+
+// S3APICopyObjectError returns S3API with s3.CopyObject error
+func S3APICopyObjectError() *S3API {
+	return &S3API{
+		copyErr:   errors.New("FakeCopyObjectError"),
+		copyInput: &s3.CopyObjectInput{},
+	}
+}
+
+// S3APIListObjectsTree returns S3API with s3.ListObjectsV2 by prefix,
+// tree maps a prefix to its children, children ending w/ "/" are common prefixes
+// and the others are objects w/ the size of their name.
+// Listing a prefix of failPrefixes returns an error.
+func S3APIListObjectsTree(bucket string, tree map[string][]string,
+	failPrefixes ...string) *S3API {
+	s3api := &S3API{
+		listTree:       make(map[string]*s3.ListObjectsV2Output),
+		listTreeErrors: make(map[string]error),
+	}
+	for prefix, children := range tree {
+		output := &s3.ListObjectsV2Output{
+			Name:        aws.String(bucket),
+			Prefix:      aws.String(prefix),
+			IsTruncated: aws.Bool(false),
+		}
+		for _, child := range children {
+			if strings.HasSuffix(child, "/") {
+				output.CommonPrefixes = append(output.CommonPrefixes,
+					&s3.CommonPrefix{Prefix: aws.String(prefix + child)})
+				continue
+			}
+			output.Contents = append(output.Contents, &s3.Object{
+				Key:          aws.String(prefix + child),
+				Size:         aws.Int64(int64(len(child))),
+				ETag:         aws.String(fmt.Sprintf(`"etag-%s"`, child)),
+				LastModified: aws.Time(time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)),
+				StorageClass: aws.String(s3.StorageClassStandard),
+			})
+		}
+		s3api.listTree[prefix] = output
+	}
+	for _, prefix := range failPrefixes {
+		s3api.listTreeErrors[prefix] = errors.New("FakeListObjectsError")
+	}
+	return s3api
+}
+
+// S3APIBatch returns S3API w/ batch operations over a tree of objects,
+// see S3APIListObjectsTree. HeadObject returns headSize as the content length.
+// failKeys are source "bucket/key" failing copy and delete,
+// or source ranges failing part copy
+func S3APIBatch(bucket string, tree map[string][]string, headSize int64,
+	failKeys ...string) *S3API {
+	s3api := S3APIListObjectsTree(bucket, tree)
+	s3api.headObjectOutput = &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(headSize),
+		ContentType:   aws.String("application/gzip"),
+		ETag:          aws.String(`"batch-etag"`),
+	}
+	s3api.taggingOutput = &s3.GetObjectTaggingOutput{}
+	s3api.deleteIndex = aws.Int(0)
+	s3api.deleteObjectOutput = &s3.DeleteObjectOutput{}
+	s3api.deleteInput = &s3.DeleteObjectInput{}
+	s3api.batch = &s3Batch{failKeys: make(map[string]bool)}
+	for _, key := range failKeys {
+		s3api.batch.failKeys[key] = true
+	}
+	return s3api
+}
+
+// S3APISync returns S3APIBatch listing objects under a prefix w/o delimiter,
+// failKeys are "bucket/key" failing to delete
+func S3APISync(bucket string, prefix string, objects []*s3.Object,
+	failKeys ...string) *S3API {
+	s3api := S3APIBatch(bucket, map[string][]string{}, 0, failKeys...)
+	s3api.listTree[prefix] = &s3.ListObjectsV2Output{
+		Name:        aws.String(bucket),
+		Prefix:      aws.String(prefix),
+		Contents:    objects,
+		IsTruncated: aws.Bool(false),
+	}
+	return s3api
+}
+
+// GetObjectRequest mocks S3API.GetObjectRequest w/ an offline s3 client
+func (m S3API) GetObjectRequest(
+	input *s3.GetObjectInput) (*request.Request, *s3.GetObjectOutput) {
+	req, output := m.presigner.GetObjectRequest(input)
+	req.Error = m.presignErr
+	return req, output
+}
+
+// PutObjectRequest mocks S3API.PutObjectRequest w/ an offline s3 client
+func (m S3API) PutObjectRequest(
+	input *s3.PutObjectInput) (*request.Request, *s3.PutObjectOutput) {
+	req, output := m.presigner.PutObjectRequest(input)
+	req.Error = m.presignErr
+	return req, output
+}
+
+// S3APIPresign returns S3API presigning requests in a region
+// w/ static credentials AKID/SECRET
+func S3APIPresign(region string) *S3API {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String(region),
+		Credentials: credentials.NewStaticCredentials("AKID", "SECRET", ""),
+	}))
+	return &S3API{presigner: s3.New(sess)}
+}
+
+// S3APIPresignError returns S3API failing to presign requests
+func S3APIPresignError() *S3API {
+	s3api := S3APIPresign("us-east-1")
+	s3api.presignErr = errors.New("FakePresignError")
+	return s3api
+}
@@ -1,9 +1,17 @@
 package mock
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
 )
@@ -25,22 +33,50 @@ type SSMAPI struct {
 	delparamsErr     error
 	getIndex         *int
 	listtagsIndex    *int
+	state            *ssmState
+}
+
+// ssmState records parameters of a fake parameter store,
+// it is safe for concurrent use
+type ssmState struct {
+	mutex     sync.Mutex
+	params    map[string][]*ssm.ParameterHistory
+	tags      map[string]map[string]string
+	putInputs []*ssm.PutParameterInput
+	getCalls  int
+	getErr    error
+	// deleteCancel is called after deleteCancelAt DeleteParameters calls
+	deleteCancel   func()
+	deleteCancelAt int
+	// deleteThrottles is the number of DeleteParameters calls to throttle
+	deleteThrottles int
+	deleteCalls     int
+	deleteErr       error
 }
 
 // PutParameter mocks SSMAPI.PutParameter
 func (m SSMAPI) PutParameter(
-	*ssm.PutParameterInput) (*ssm.PutParameterOutput, error) {
+	input *ssm.PutParameterInput) (*ssm.PutParameterOutput, error) {
+	if m.state != nil {
+		return m.state.putParameter(input)
+	}
 	return m.putparamOutput, m.putErr
 }
 
 // AddTagsToResource mocks SSMAPI.PutParameter
 func (m SSMAPI) AddTagsToResource(
-	*ssm.AddTagsToResourceInput) (*ssm.AddTagsToResourceOutput, error) {
+	input *ssm.AddTagsToResourceInput) (*ssm.AddTagsToResourceOutput, error) {
+	if m.state != nil {
+		return m.state.addTags(input)
+	}
 	return m.addtagsOutput, m.addtagsErr
 }
 
 // GetParametersByPath mocks SSMAPI.PutParameter
-func (m SSMAPI) GetParametersByPath(*ssm.GetParametersByPathInput) (*ssm.GetParametersByPathOutput, error) {
+func (m SSMAPI) GetParametersByPath(input *ssm.GetParametersByPathInput) (*ssm.GetParametersByPathOutput, error) {
+	if m.state != nil {
+		return m.state.getParametersByPath(input)
+	}
 	output := m.getparamsOutput
 	if *m.getIndex < len(m.getContents) {
 		output = m.getContents[*m.getIndex]
@@ -51,13 +87,19 @@ func (m SSMAPI) GetParametersByPath(*ssm.GetParametersByPathInput) (*ssm.GetPara
 
 // DeleteParameters mocks SSMAPI.DeleteParameters
 func (m SSMAPI) DeleteParameters(
-	*ssm.DeleteParametersInput) (*ssm.DeleteParametersOutput, error) {
+	input *ssm.DeleteParametersInput) (*ssm.DeleteParametersOutput, error) {
+	if m.state != nil {
+		return m.state.deleteParameters(input)
+	}
 	return m.delparamsOutput, m.delparamsErr
 }
 
 // ListTagsForResource mocks SSMAPI.ListTagsForResource
 func (m SSMAPI) ListTagsForResource(
-	*ssm.ListTagsForResourceInput) (*ssm.ListTagsForResourceOutput, error) {
+	input *ssm.ListTagsForResourceInput) (*ssm.ListTagsForResourceOutput, error) {
+	if m.state != nil {
+		return m.state.listTags(input)
+	}
 	output := m.listtagsOutput
 	if *m.listtagsIndex < len(m.listtagsContents) {
 		output = m.listtagsContents[*m.listtagsIndex]
@@ -66,6 +108,51 @@ func (m SSMAPI) ListTagsForResource(
 	return output, m.listtagsErr
 }
 
+// PutParameterWithContext mocks SSMAPI.PutParameterWithContext
+func (m SSMAPI) PutParameterWithContext(ctx aws.Context,
+	input *ssm.PutParameterInput, opts ...request.Option) (*ssm.PutParameterOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.PutParameter(input)
+}
+
+// AddTagsToResourceWithContext mocks SSMAPI.AddTagsToResourceWithContext
+func (m SSMAPI) AddTagsToResourceWithContext(ctx aws.Context,
+	input *ssm.AddTagsToResourceInput, opts ...request.Option) (*ssm.AddTagsToResourceOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.AddTagsToResource(input)
+}
+
+// GetParametersByPathWithContext mocks SSMAPI.GetParametersByPathWithContext
+func (m SSMAPI) GetParametersByPathWithContext(ctx aws.Context,
+	input *ssm.GetParametersByPathInput, opts ...request.Option) (*ssm.GetParametersByPathOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetParametersByPath(input)
+}
+
+// DeleteParametersWithContext mocks SSMAPI.DeleteParametersWithContext
+func (m SSMAPI) DeleteParametersWithContext(ctx aws.Context,
+	input *ssm.DeleteParametersInput, opts ...request.Option) (*ssm.DeleteParametersOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.DeleteParameters(input)
+}
+
+// ListTagsForResourceWithContext mocks SSMAPI.ListTagsForResourceWithContext
+func (m SSMAPI) ListTagsForResourceWithContext(ctx aws.Context,
+	input *ssm.ListTagsForResourceInput, opts ...request.Option) (*ssm.ListTagsForResourceOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.ListTagsForResource(input)
+}
+
 // SSMPutParameter returns a pointer to SSMAPI w/ ssm.PutParameter
 func SSMPutParameter(version int64) *SSMAPI {
 	return &SSMAPI{
@@ -176,3 +263,580 @@ func SSMDeleteParamsError() *SSMAPI {
 		delparamsErr: fmt.Errorf("FakeSSMDeleteParamsError"),
 	}
 }
+
+// ssmModifiedDate returns the fake modified date of a version
+func ssmModifiedDate(version int64) time.Time {
+	return time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(version) * time.Minute)
+}
+
+// latest returns the latest version of a parameter as a ssm.Parameter,
+// must be called w/ mutex locked
+func (state *ssmState) latest(name string) *ssm.Parameter {
+	history := state.params[name]
+	return ssmParameter(history[len(history)-1], "")
+}
+
+// ssmParameter converts a version of the fake store to a ssm.Parameter
+func ssmParameter(history *ssm.ParameterHistory, selector string) *ssm.Parameter {
+	param := &ssm.Parameter{
+		Name:             history.Name,
+		Type:             history.Type,
+		Value:            history.Value,
+		Version:          history.Version,
+		LastModifiedDate: history.LastModifiedDate,
+		ARN: aws.String("arn:aws:ssm:us-east-1:123456789012:parameter" +
+			aws.StringValue(history.Name)),
+	}
+	if selector != "" {
+		param.Selector = aws.String(selector)
+	}
+	return param
+}
+
+// resolve returns the version of a name w/ an optional ":version" or ":label"
+// selector, must be called w/ mutex locked
+func (state *ssmState) resolve(name string) (*ssm.ParameterHistory, string, error) {
+	selector := ""
+	if idx := strings.LastIndex(name, ":"); idx > strings.LastIndex(name, "/") {
+		name, selector = name[:idx], name[idx:]
+	}
+	history, ok := state.params[name]
+	if !ok {
+		return nil, "", awserr.New(ssm.ErrCodeParameterNotFound, "Parameter "+name+" not found.", nil)
+	}
+	if selector == "" {
+		return history[len(history)-1], "", nil
+	}
+	if version, err := strconv.ParseInt(selector[1:], 10, 64); err == nil {
+		if version < 1 || version > int64(len(history)) {
+			return nil, "", awserr.New(ssm.ErrCodeParameterVersionNotFound,
+				"Version "+selector[1:]+" of "+name+" not found.", nil)
+		}
+		return history[version-1], selector, nil
+	}
+	for _, version := range history {
+		for _, label := range version.Labels {
+			if aws.StringValue(label) == selector[1:] {
+				return version, selector, nil
+			}
+		}
+	}
+	return nil, "", awserr.New(ssm.ErrCodeParameterVersionNotFound,
+		"Label "+selector[1:]+" of "+name+" not found.", nil)
+}
+
+// names returns sorted names under a path
+func (state *ssmState) names(path string, recursive bool) []string {
+	path = strings.TrimSuffix(path, "/") + "/"
+	names := make([]string, 0)
+	for name := range state.params {
+		if !strings.HasPrefix(name, path) {
+			continue
+		}
+		if !recursive && strings.Contains(strings.TrimPrefix(name, path), "/") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (state *ssmState) getParametersByPath(
+	input *ssm.GetParametersByPathInput) (*ssm.GetParametersByPathOutput, error) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.getCalls++
+	if state.getErr != nil {
+		return nil, state.getErr
+	}
+	names := state.names(aws.StringValue(input.Path), aws.BoolValue(input.Recursive))
+	start, _ := strconv.Atoi(aws.StringValue(input.NextToken))
+	size := int(aws.Int64Value(input.MaxResults))
+	if size <= 0 {
+		size = 10
+	}
+	output := &ssm.GetParametersByPathOutput{Parameters: make([]*ssm.Parameter, 0)}
+	for idx := start; idx < len(names) && idx < start+size; idx++ {
+		output.Parameters = append(output.Parameters, state.latest(names[idx]))
+	}
+	if start+size < len(names) {
+		output.NextToken = aws.String(strconv.Itoa(start + size))
+	}
+	return output, nil
+}
+
+// SetParameter adds a version of a parameter to the fake store,
+// and returns the new version
+func (m SSMAPI) SetParameter(name, value, paramType string) int64 {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	input := &ssm.PutParameterInput{
+		Name:      aws.String(name),
+		Value:     aws.String(value),
+		Type:      aws.String(paramType),
+		Overwrite: aws.Bool(true),
+	}
+	if paramType == ssm.ParameterTypeSecureString {
+		input.KeyId = aws.String("alias/aws/ssm")
+	}
+	return m.state.appendVersion(input)
+}
+
+// appendVersion adds a version, must be called w/ mutex locked
+func (state *ssmState) appendVersion(input *ssm.PutParameterInput) int64 {
+	name := aws.StringValue(input.Name)
+	version := int64(len(state.params[name]) + 1)
+	state.params[name] = append(state.params[name], &ssm.ParameterHistory{
+		Name:             input.Name,
+		Value:            input.Value,
+		Type:             input.Type,
+		KeyId:            input.KeyId,
+		Description:      input.Description,
+		Tier:             input.Tier,
+		Version:          aws.Int64(version),
+		LastModifiedDate: aws.Time(ssmModifiedDate(version)),
+		LastModifiedUser: aws.String("arn:aws:iam::123456789012:user/fake"),
+		Labels:           make([]*string, 0),
+		Policies:         ssmPolicies(aws.StringValue(input.Policies)),
+	})
+	return version
+}
+
+// ssmPolicies returns the pending policies of a json of ssm.PutParameterInput.Policies
+func ssmPolicies(text string) []*ssm.ParameterInlinePolicy {
+	if text == "" {
+		return nil
+	}
+	var policies []map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &policies); err != nil {
+		return nil
+	}
+	inline := make([]*ssm.ParameterInlinePolicy, 0, len(policies))
+	for _, policy := range policies {
+		content, _ := json.Marshal(policy)
+		inline = append(inline, &ssm.ParameterInlinePolicy{
+			PolicyType:   aws.String(fmt.Sprint(policy["Type"])),
+			PolicyText:   aws.String(string(content)),
+			PolicyStatus: aws.String("Pending"),
+		})
+	}
+	return inline
+}
+
+func (state *ssmState) putParameter(input *ssm.PutParameterInput) (*ssm.PutParameterOutput, error) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	name := aws.StringValue(input.Name)
+	_, exists := state.params[name]
+	if exists && !aws.BoolValue(input.Overwrite) {
+		return nil, awserr.New(ssm.ErrCodeParameterAlreadyExists,
+			"The parameter already exists.", nil)
+	}
+	if exists && len(input.Tags) != 0 {
+		return nil, awserr.New("ValidationException",
+			"Invalid request: tags and overwrite can't be used together.", nil)
+	}
+	if input.Policies != nil && aws.StringValue(input.Tier) != ssm.ParameterTierAdvanced {
+		return nil, awserr.New("ValidationException",
+			"Parameter policies are supported only for advanced tier.", nil)
+	}
+	state.putInputs = append(state.putInputs, input)
+	version := state.appendVersion(input)
+	if !exists && len(input.Tags) != 0 {
+		state.tags[name] = make(map[string]string)
+		for _, tag := range input.Tags {
+			state.tags[name][aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+	return &ssm.PutParameterOutput{Version: aws.Int64(version)}, nil
+}
+
+func (state *ssmState) addTags(input *ssm.AddTagsToResourceInput) (*ssm.AddTagsToResourceOutput, error) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	name := aws.StringValue(input.ResourceId)
+	if _, ok := state.params[name]; !ok {
+		return nil, awserr.New(ssm.ErrCodeInvalidResourceId, "Invalid resource id", nil)
+	}
+	if state.tags[name] == nil {
+		state.tags[name] = make(map[string]string)
+	}
+	for _, tag := range input.Tags {
+		state.tags[name][aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return &ssm.AddTagsToResourceOutput{}, nil
+}
+
+func (state *ssmState) listTags(input *ssm.ListTagsForResourceInput) (*ssm.ListTagsForResourceOutput, error) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	name := aws.StringValue(input.ResourceId)
+	if _, ok := state.params[name]; !ok {
+		return nil, awserr.New(ssm.ErrCodeInvalidResourceId, "Invalid resource id", nil)
+	}
+	output := &ssm.ListTagsForResourceOutput{TagList: make([]*ssm.Tag, 0)}
+	keys := make([]string, 0, len(state.tags[name]))
+	for key := range state.tags[name] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		output.TagList = append(output.TagList,
+			&ssm.Tag{Key: aws.String(key), Value: aws.String(state.tags[name][key])})
+	}
+	return output, nil
+}
+
+func (state *ssmState) deleteParameters(
+	input *ssm.DeleteParametersInput) (*ssm.DeleteParametersOutput, error) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.deleteCalls++
+	if state.deleteCancel != nil && state.deleteCalls == state.deleteCancelAt {
+		defer state.deleteCancel()
+	}
+	if state.deleteThrottles > 0 {
+		state.deleteThrottles--
+		return nil, awserr.New("ThrottlingException", "Rate exceeded", nil)
+	}
+	if state.deleteErr != nil {
+		return nil, state.deleteErr
+	}
+	if len(input.Names) > 10 {
+		return nil, awserr.New("ValidationException",
+			"Member must have length less than or equal to 10", nil)
+	}
+	output := &ssm.DeleteParametersOutput{
+		DeletedParameters: make([]*string, 0),
+		InvalidParameters: make([]*string, 0),
+	}
+	for _, name := range input.Names {
+		if _, ok := state.params[aws.StringValue(name)]; !ok {
+			output.InvalidParameters = append(output.InvalidParameters, name)
+			continue
+		}
+		delete(state.params, aws.StringValue(name))
+		delete(state.tags, aws.StringValue(name))
+		output.DeletedParameters = append(output.DeletedParameters, name)
+	}
+	return output, nil
+}
+
+// DescribeParameters mocks SSMAPI.DescribeParameters,
+// the fake store supports Path and Name filters
+func (m SSMAPI) DescribeParameters(
+	input *ssm.DescribeParametersInput) (*ssm.DescribeParametersOutput, error) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	names := make([]string, 0)
+	for name := range m.state.params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, filter := range input.ParameterFilters {
+		values := aws.StringValueSlice(filter.Values)
+		switch aws.StringValue(filter.Key) {
+		case "Path":
+			recursive := aws.StringValue(filter.Option) == "Recursive"
+			names = m.state.names(values[0], recursive)
+		case "Name":
+			found := make([]string, 0)
+			for _, name := range names {
+				for _, value := range values {
+					if name == value {
+						found = append(found, name)
+					}
+				}
+			}
+			names = found
+		}
+	}
+	start, _ := strconv.Atoi(aws.StringValue(input.NextToken))
+	size := int(aws.Int64Value(input.MaxResults))
+	if size <= 0 {
+		size = 50
+	}
+	output := &ssm.DescribeParametersOutput{Parameters: make([]*ssm.ParameterMetadata, 0)}
+	for idx := start; idx < len(names) && idx < start+size; idx++ {
+		history := m.state.params[names[idx]]
+		last := history[len(history)-1]
+		output.Parameters = append(output.Parameters, &ssm.ParameterMetadata{
+			Name:             last.Name,
+			Type:             last.Type,
+			KeyId:            last.KeyId,
+			Description:      last.Description,
+			Tier:             last.Tier,
+			Version:          last.Version,
+			LastModifiedDate: last.LastModifiedDate,
+		})
+	}
+	if start+size < len(names) {
+		output.NextToken = aws.String(strconv.Itoa(start + size))
+	}
+	return output, nil
+}
+
+// DescribeParametersWithContext mocks SSMAPI.DescribeParametersWithContext
+func (m SSMAPI) DescribeParametersWithContext(ctx aws.Context,
+	input *ssm.DescribeParametersInput, opts ...request.Option) (*ssm.DescribeParametersOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.DescribeParameters(input)
+}
+
+// GetParameter mocks SSMAPI.GetParameter of the fake store
+func (m SSMAPI) GetParameter(input *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	m.state.getCalls++
+	if m.state.getErr != nil {
+		return nil, m.state.getErr
+	}
+	history, selector, err := m.state.resolve(aws.StringValue(input.Name))
+	if err != nil {
+		return nil, err
+	}
+	return &ssm.GetParameterOutput{Parameter: ssmParameter(history, selector)}, nil
+}
+
+// GetParameterWithContext mocks SSMAPI.GetParameterWithContext
+func (m SSMAPI) GetParameterWithContext(ctx aws.Context,
+	input *ssm.GetParameterInput, opts ...request.Option) (*ssm.GetParameterOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetParameter(input)
+}
+
+// GetParameters mocks SSMAPI.GetParameters of the fake store
+func (m SSMAPI) GetParameters(input *ssm.GetParametersInput) (*ssm.GetParametersOutput, error) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	m.state.getCalls++
+	if m.state.getErr != nil {
+		return nil, m.state.getErr
+	}
+	if len(input.Names) > 10 {
+		return nil, awserr.New("ValidationException",
+			"Member must have length less than or equal to 10", nil)
+	}
+	output := &ssm.GetParametersOutput{
+		Parameters:        make([]*ssm.Parameter, 0),
+		InvalidParameters: make([]*string, 0),
+	}
+	for _, name := range input.Names {
+		history, selector, err := m.state.resolve(aws.StringValue(name))
+		if err != nil {
+			output.InvalidParameters = append(output.InvalidParameters, name)
+			continue
+		}
+		output.Parameters = append(output.Parameters, ssmParameter(history, selector))
+	}
+	return output, nil
+}
+
+// GetParametersWithContext mocks SSMAPI.GetParametersWithContext
+func (m SSMAPI) GetParametersWithContext(ctx aws.Context,
+	input *ssm.GetParametersInput, opts ...request.Option) (*ssm.GetParametersOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetParameters(input)
+}
+
+// GetParameterHistory mocks SSMAPI.GetParameterHistory of the fake store
+func (m SSMAPI) GetParameterHistory(
+	input *ssm.GetParameterHistoryInput) (*ssm.GetParameterHistoryOutput, error) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	name := aws.StringValue(input.Name)
+	history, ok := m.state.params[name]
+	if !ok {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, "Parameter "+name+" not found.", nil)
+	}
+	start, _ := strconv.Atoi(aws.StringValue(input.NextToken))
+	size := int(aws.Int64Value(input.MaxResults))
+	if size <= 0 {
+		size = 50
+	}
+	output := &ssm.GetParameterHistoryOutput{Parameters: make([]*ssm.ParameterHistory, 0)}
+	for idx := start; idx < len(history) && idx < start+size; idx++ {
+		version := *history[idx]
+		version.Labels = append([]*string{}, version.Labels...)
+		output.Parameters = append(output.Parameters, &version)
+	}
+	if start+size < len(history) {
+		output.NextToken = aws.String(strconv.Itoa(start + size))
+	}
+	return output, nil
+}
+
+// GetParameterHistoryWithContext mocks SSMAPI.GetParameterHistoryWithContext
+func (m SSMAPI) GetParameterHistoryWithContext(ctx aws.Context,
+	input *ssm.GetParameterHistoryInput, opts ...request.Option) (*ssm.GetParameterHistoryOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetParameterHistory(input)
+}
+
+// invalidSSMLabel checks the label requirements of ssm
+func invalidSSMLabel(label string) bool {
+	lower := strings.ToLower(label)
+	return label == "" || len(label) > 100 || strings.HasPrefix(lower, "aws") ||
+		strings.HasPrefix(lower, "ssm") || (label[0] >= '0' && label[0] <= '9') ||
+		strings.ContainsAny(label, "/:")
+}
+
+// LabelParameterVersion mocks SSMAPI.LabelParameterVersion of the fake store,
+// labels are moved from other versions and a version has at most 10 labels
+func (m SSMAPI) LabelParameterVersion(
+	input *ssm.LabelParameterVersionInput) (*ssm.LabelParameterVersionOutput, error) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	name := aws.StringValue(input.Name)
+	history, ok := m.state.params[name]
+	if !ok {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, "Parameter "+name+" not found.", nil)
+	}
+	target := history[len(history)-1]
+	if input.ParameterVersion != nil {
+		version := aws.Int64Value(input.ParameterVersion)
+		if version < 1 || version > int64(len(history)) {
+			return nil, awserr.New(ssm.ErrCodeParameterVersionNotFound,
+				fmt.Sprintf("Version %d of %s not found.", version, name), nil)
+		}
+		target = history[version-1]
+	}
+	output := &ssm.LabelParameterVersionOutput{}
+	valid := make([]string, 0)
+	for _, label := range aws.StringValueSlice(input.Labels) {
+		if invalidSSMLabel(label) {
+			output.InvalidLabels = append(output.InvalidLabels, aws.String(label))
+			continue
+		}
+		valid = append(valid, label)
+	}
+	for _, version := range history {
+		kept := make([]*string, 0)
+		for _, label := range version.Labels {
+			moved := false
+			for _, add := range valid {
+				moved = moved || aws.StringValue(label) == add
+			}
+			if !moved {
+				kept = append(kept, label)
+			}
+		}
+		version.Labels = kept
+	}
+	if len(target.Labels)+len(valid) > 10 {
+		return nil, awserr.New(ssm.ErrCodeParameterVersionLabelLimitExceeded,
+			"A parameter version can have maximum 10 labels.", nil)
+	}
+	target.Labels = append(target.Labels, aws.StringSlice(valid)...)
+	return output, nil
+}
+
+// LabelParameterVersionWithContext mocks SSMAPI.LabelParameterVersionWithContext
+func (m SSMAPI) LabelParameterVersionWithContext(ctx aws.Context,
+	input *ssm.LabelParameterVersionInput, opts ...request.Option) (*ssm.LabelParameterVersionOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.LabelParameterVersion(input)
+}
+
+// SetTags sets the tags of a parameter of the fake store
+func (m SSMAPI) SetTags(name string, tags map[string]string) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	m.state.tags[name] = tags
+}
+
+// PutInputs returns the inputs of ssm.PutParameter to the fake store
+func (m SSMAPI) PutInputs() []*ssm.PutParameterInput {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	return m.state.putInputs
+}
+
+// Parameter returns the latest version of a parameter of the fake store, nil if not found
+func (m SSMAPI) Parameter(name string) *ssm.ParameterHistory {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	history, ok := m.state.params[name]
+	if !ok {
+		return nil
+	}
+	return history[len(history)-1]
+}
+
+// RemoveParameter removes a parameter from the fake store
+func (m SSMAPI) RemoveParameter(name string) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	delete(m.state.params, name)
+	delete(m.state.tags, name)
+}
+
+// SetDeleteThrottle makes the next count ssm.DeleteParameters of the fake store throttled
+func (m SSMAPI) SetDeleteThrottle(count int) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	m.state.deleteThrottles = count
+}
+
+// SetDeleteError makes ssm.DeleteParameters of the fake store fail w/ err,
+// nil to succeed again
+func (m SSMAPI) SetDeleteError(err error) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	m.state.deleteErr = err
+}
+
+// SetDeleteCancel calls cancel after count more ssm.DeleteParameters of the fake store
+func (m SSMAPI) SetDeleteCancel(count int, cancel func()) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	m.state.deleteCancel = cancel
+	m.state.deleteCancelAt = m.state.deleteCalls + count
+}
+
+// DeleteCalls returns the number of ssm.DeleteParameters to the fake store
+func (m SSMAPI) DeleteCalls() int {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	return m.state.deleteCalls
+}
+
+// SetGetError makes ssm.GetParametersByPath of the fake store fail w/ err,
+// or succeed again w/ nil
+func (m SSMAPI) SetGetError(err error) {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	m.state.getErr = err
+}
+
+// GetCalls returns the number of ssm.GetParametersByPath calls to the fake store
+func (m SSMAPI) GetCalls() int {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	return m.state.getCalls
+}
+
+// SSMParameterStore returns *SSMAPI w/ a fake parameter store,
+// params are "name=value" String parameters of version 1
+func SSMParameterStore(params ...string) *SSMAPI {
+	s := &SSMAPI{state: &ssmState{
+		params: make(map[string][]*ssm.ParameterHistory),
+		tags:   make(map[string]map[string]string),
+	}}
+	for _, param := range params {
+		pair := strings.SplitN(param, "=", 2)
+		s.SetParameter(pair[0], pair[1], ssm.ParameterTypeString)
+	}
+	return s
+}
@@ -0,0 +1,216 @@
+package s3sync
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+	"github.com/xinnige/asteraceae/calendula/utils"
+)
+
+// Operations of applied actions
+const (
+	OpUpload = "upload"
+	OpDelete = "delete"
+)
+
+const deConcurrency = 4
+
+// Target defines the s3 clients to sync w/
+type Target struct {
+	AWSAPI   *awsapi.AWSAPI
+	S3API    s3iface.S3API
+	Uploader s3manageriface.UploaderAPI
+}
+
+// NewTarget returns a *Target
+func NewTarget(s3api s3iface.S3API, uploader s3manageriface.UploaderAPI) *Target {
+	return &Target{
+		AWSAPI:   &awsapi.AWSAPI{},
+		S3API:    s3api,
+		Uploader: uploader,
+	}
+}
+
+// Plan scans the source directory and lists bucket/prefix to build a plan,
+// objects are headed by the target if the planner has no Head
+func (target *Target) Plan(ctx context.Context, planner *Planner,
+	source string, bucket string, prefix string) (*SyncPlan, error) {
+	files, err := ScanDir(source, planner.Filter)
+	if err != nil {
+		return nil, err
+	}
+	listed, err := target.AWSAPI.ListObjectInfosWithContext(ctx, target.S3API, bucket,
+		awsapi.ListOptions{Prefix: NormalizePrefix(prefix)})
+	if err != nil {
+		return nil, err
+	}
+	if planner.Head == nil {
+		headed := *planner
+		headed.Head = func(key string) (*awsapi.ObjectHead, error) {
+			return target.AWSAPI.HeadObjectWithContext(ctx, target.S3API, bucket, key)
+		}
+		planner = &headed
+	}
+	return planner.Plan(bucket, prefix, files, listed.Objects)
+}
+
+// Action defines an applied (or planned in dry-run) operation
+type Action struct {
+	Op    string `json:"op"`
+	Key   string `json:"key"`
+	Size  int64  `json:"size,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// ApplyResult holds the actions of an applied plan
+type ApplyResult struct {
+	DryRun  bool     `json:"dry_run"`
+	Actions []Action `json:"actions"`
+}
+
+// Failed returns the actions w/ errors
+func (result *ApplyResult) Failed() []Action {
+	failed := make([]Action, 0)
+	for _, action := range result.Actions {
+		if action.Error != "" {
+			failed = append(failed, action)
+		}
+	}
+	return failed
+}
+
+// Count returns the number of succeeded actions and their bytes of an operation
+func (result *ApplyResult) Count(op string) (int, int64) {
+	count, size := 0, int64(0)
+	for _, action := range result.Actions {
+		if action.Op == op && action.Error == "" {
+			count++
+			size += action.Size
+		}
+	}
+	return count, size
+}
+
+// Render writes a human readable summary of the result
+func (result *ApplyResult) Render(w io.Writer) {
+	mode := "applied"
+	if result.DryRun {
+		mode = "dry-run"
+	}
+	uploaded, size := result.Count(OpUpload)
+	deleted, _ := result.Count(OpDelete)
+	fmt.Fprintf(w, "Actions (%s): %d, uploaded: %d (%d bytes), deleted: %d, failed: %d\n",
+		mode, len(result.Actions), uploaded, size, deleted, len(result.Failed()))
+	for _, action := range result.Failed() {
+		fmt.Fprintf(w, "  %s %s: %s\n", action.Op, action.Key, action.Error)
+	}
+}
+
+// ApplyOptions defines how a plan is applied
+type ApplyOptions struct {
+	// DryRun only records the actions w/o calling s3
+	DryRun bool
+	// Concurrency limits the number of concurrent uploads, 4 if 0
+	Concurrency int
+	// Upload is applied to every upload, ContentType is guessed
+	// from the file extension if empty
+	Upload awsapi.UploadOptions
+}
+
+// Apply uploads and deletes the objects of the plan, failed actions are
+// recorded and do not stop the rest of the plan. Deletes run after uploads,
+// invalid upload options fail all uploads and skip deletes.
+func (plan *SyncPlan) Apply(ctx context.Context, target *Target, opts ApplyOptions) *ApplyResult {
+	result := &ApplyResult{DryRun: opts.DryRun, Actions: make([]Action, 0)}
+	if err := opts.Upload.Validate(); err != nil {
+		for _, upload := range plan.Uploads {
+			result.Actions = append(result.Actions,
+				Action{Op: OpUpload, Key: upload.Key, Error: err.Error()})
+		}
+		return result
+	}
+
+	uploads := make([]Action, len(plan.Uploads))
+	for idx, upload := range plan.Uploads {
+		uploads[idx] = Action{Op: OpUpload, Key: upload.Key, Size: upload.Source.Size}
+	}
+	if opts.DryRun {
+		result.Actions = append(result.Actions, uploads...)
+		for _, key := range plan.Deletes {
+			result.Actions = append(result.Actions, Action{Op: OpDelete, Key: key})
+		}
+		return result
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = deConcurrency
+	}
+	done := make([]bool, len(plan.Uploads))
+	utils.RunPool(ctx, concurrency, len(plan.Uploads), func(idx int) {
+		done[idx] = true
+		if err := target.upload(ctx, plan.Bucket, &plan.Uploads[idx], opts.Upload); err != nil {
+			uploads[idx].Error = err.Error()
+		}
+	})
+	for idx := range uploads {
+		if !done[idx] {
+			uploads[idx].Error = ctx.Err().Error()
+		}
+	}
+	result.Actions = append(result.Actions, uploads...)
+
+	if len(plan.Deletes) == 0 {
+		return result
+	}
+	if err := ctx.Err(); err != nil {
+		for _, key := range plan.Deletes {
+			result.Actions = append(result.Actions, Action{Op: OpDelete, Key: key, Error: err.Error()})
+		}
+		return result
+	}
+	deleted, err := target.AWSAPI.DeleteObjects(ctx, target.S3API, plan.Bucket, plan.Deletes)
+	failed := make(map[string]string)
+	for _, keyErr := range deleted.Errors {
+		failed[keyErr.Key] = keyErr.Code + " " + keyErr.Message
+	}
+	succeeded := make(map[string]bool)
+	for _, key := range deleted.Succeeded {
+		succeeded[key] = true
+	}
+	for _, key := range plan.Deletes {
+		action := Action{Op: OpDelete, Key: key}
+		if msg, ok := failed[key]; ok {
+			action.Error = msg
+		} else if !succeeded[key] && err != nil {
+			action.Error = err.Error()
+		}
+		result.Actions = append(result.Actions, action)
+	}
+	return result
+}
+
+// upload uploads a local file w/ a content type guessed from its extension
+func (target *Target) upload(ctx context.Context, bucket string,
+	upload *Upload, opts awsapi.UploadOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	reader, err := os.Open(upload.Source.Path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if opts.ContentType == "" {
+		opts.ContentType = mime.TypeByExtension(path.Ext(upload.Source.Rel))
+	}
+	_, err = target.AWSAPI.UploadObject(ctx, target.Uploader, bucket, upload.Key, reader, opts)
+	return err
+}
@@ -0,0 +1,124 @@
+package s3sync
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+func fakeSyncObjects() []*s3.Object {
+	newer := aws.Time(fakeModTime.Add(time.Hour))
+	return []*s3.Object{
+		{Key: aws.String("bundle/app.json"), Size: aws.Int64(2), LastModified: newer,
+			ETag: aws.String(`"99914b932bd37a50b983c5e7c90ae93b"`)},
+		{Key: aws.String("bundle/old.json"), Size: aws.Int64(2), LastModified: newer},
+		{Key: aws.String("bundle/locked.json"), Size: aws.Int64(2), LastModified: newer},
+	}
+}
+
+func TestTargetPlanKMS(t *testing.T) {
+	root := fakeSource(t, map[string]string{"app.json": "{}"})
+	defer os.RemoveAll(root)
+	// a bucket encrypted by sse-kms by default lists etags which are not md5
+	s3api := mock.S3APISync("bucket", "bundle/", []*s3.Object{
+		{Key: aws.String("bundle/app.json"), Size: aws.Int64(2),
+			LastModified: aws.Time(fakeModTime.Add(time.Hour)),
+			ETag:         aws.String(`"5d41402abc4b2a76b9719d911017c592"`)},
+	})
+	s3api.SetHeadObject(&s3.HeadObjectOutput{
+		ServerSideEncryption: aws.String(s3.ServerSideEncryptionAwsKms)})
+	planner := NewPlanner(CompareETag, nil, false)
+	plan, err := NewTarget(s3api, nil).Plan(context.Background(), planner,
+		root, "bucket", "bundle")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(plan.Uploads))
+	assert.Equal(t, 1, plan.Unchanged)
+	assert.Nil(t, planner.Head)
+}
+
+func TestTargetPlanApply(t *testing.T) {
+	root := fakeSource(t, map[string]string{
+		"app.json":     "{}",
+		"conf/db.yaml": "db: 1",
+		"index.html":   "<html/>",
+	})
+	defer os.RemoveAll(root)
+	s3api := mock.S3APISync("bucket", "bundle/", fakeSyncObjects(), "bucket/bundle/locked.json")
+	uploader := mock.S3ManagerAPIUploads("vid", "bucket/bundle/index.html")
+	target := NewTarget(s3api, uploader)
+
+	plan, err := target.Plan(context.Background(), NewPlanner(CompareETag, nil, true),
+		root, "bucket", "bundle")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(plan.Uploads))
+	assert.Equal(t, []string{"bundle/locked.json", "bundle/old.json"}, plan.Deletes)
+	assert.Equal(t, 1, plan.Unchanged)
+
+	result := plan.Apply(context.Background(), target, ApplyOptions{DryRun: true})
+	assert.True(t, result.DryRun)
+	assert.Equal(t, 4, len(result.Actions))
+	assert.Equal(t, 0, len(uploader.UploadedKeys()))
+	assert.Equal(t, 0, s3api.DeleteCalls())
+
+	result = plan.Apply(context.Background(), target, ApplyOptions{
+		Concurrency: 2,
+		Upload:      awsapi.UploadOptions{ServerSideEncryption: s3.ServerSideEncryptionAes256},
+	})
+	assert.Equal(t, []Action{
+		{Op: OpUpload, Key: "bundle/conf/db.yaml", Size: 5},
+		{Op: OpUpload, Key: "bundle/index.html", Size: 7, Error: "FakeUploadError"},
+		{Op: OpDelete, Key: "bundle/locked.json", Error: "AccessDenied Access Denied"},
+		{Op: OpDelete, Key: "bundle/old.json"},
+	}, result.Actions)
+	assert.Equal(t, []string{"bundle/conf/db.yaml"}, uploader.UploadedKeys())
+	assert.Equal(t, "db: 1", uploader.UploadedBody("bundle/conf/db.yaml"))
+	assert.Equal(t, []string{"bundle/old.json"}, s3api.DeletedKeys())
+
+	var buf bytes.Buffer
+	result.Render(&buf)
+	assert.Equal(t, "Actions (applied): 4, uploaded: 1 (5 bytes), deleted: 1, failed: 2\n"+
+		"  upload bundle/index.html: FakeUploadError\n"+
+		"  delete bundle/locked.json: AccessDenied Access Denied\n", buf.String())
+
+	_, err = target.Plan(context.Background(), NewPlanner(CompareETag, nil, true),
+		root, "bucket", "missing")
+	assert.Nil(t, err)
+	_, err = target.Plan(context.Background(), NewPlanner(CompareETag, nil, true),
+		root+"/missing", "bucket", "bundle")
+	assert.NotNil(t, err)
+}
+
+func TestApplyErrors(t *testing.T) {
+	plan := &SyncPlan{
+		Bucket:  "bucket",
+		Uploads: []Upload{{Key: "app.json", Source: LocalFile{Path: "/missing/app.json"}}},
+		Deletes: []string{"old.json"},
+	}
+	target := NewTarget(mock.S3APISync("bucket", "", nil), mock.S3ManagerAPIUploads("vid"))
+
+	result := plan.Apply(context.Background(), target,
+		ApplyOptions{Upload: awsapi.UploadOptions{ObjectLockMode: s3.ObjectLockModeGovernance}})
+	assert.Equal(t, []Action{{Op: OpUpload, Key: "app.json",
+		Error: "object lock mode and retain until date must be set together"}}, result.Actions)
+
+	result = plan.Apply(context.Background(), target, ApplyOptions{})
+	assert.Equal(t, 2, len(result.Actions))
+	assert.NotEqual(t, "", result.Actions[0].Error)
+	assert.Equal(t, Action{Op: OpDelete, Key: "old.json"}, result.Actions[1])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result = plan.Apply(ctx, target, ApplyOptions{})
+	assert.Equal(t, []Action{
+		{Op: OpUpload, Key: "app.json", Error: "context canceled"},
+		{Op: OpDelete, Key: "old.json", Error: "context canceled"},
+	}, result.Actions)
+}
@@ -0,0 +1,150 @@
+package s3sync
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ParsePatterns splits a comma separated list of glob patterns
+func ParsePatterns(raw string) []string {
+	patterns := make([]string, 0)
+	for _, pattern := range strings.Split(raw, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// Filter selects relative paths by glob patterns, a path is synced
+// if it matches any of Includes (or Includes is empty) and none of Excludes.
+// A pattern w/o "/" also matches the base name, e.g. "*.tmp",
+// and a pattern ending w/ "/" matches a directory, e.g. "cache/".
+type Filter struct {
+	Includes []string
+	Excludes []string
+}
+
+// Validate checks the syntax of patterns
+func (filter *Filter) Validate() error {
+	for _, pattern := range append(filter.Includes, filter.Excludes...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// Match checks if a slash separated relative path is synced
+func (filter *Filter) Match(rel string) bool {
+	if filter == nil {
+		return true
+	}
+	if len(filter.Includes) != 0 && !matchAny(filter.Includes, rel) {
+		return false
+	}
+	return !matchAny(filter.Excludes, rel)
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPattern(pattern, rel string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		if strings.HasPrefix(rel, pattern) {
+			return true
+		}
+		dir := strings.TrimSuffix(pattern, "/")
+		if strings.Contains(dir, "/") {
+			return false
+		}
+		segments := strings.Split(rel, "/")
+		for _, segment := range segments[:len(segments)-1] {
+			if ok, _ := path.Match(dir, segment); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if ok, _ := path.Match(pattern, rel); ok {
+		return true
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return false
+}
+
+// LocalFile defines a regular file of the source directory
+type LocalFile struct {
+	// Path is the file path on disk
+	Path string `json:"path"`
+	// Rel is the slash separated path relative to the source directory
+	Rel     string    `json:"rel"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// MD5 returns the hex md5 of the file content
+func (file *LocalFile) MD5() (string, error) {
+	reader, err := os.Open(file.Path)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	hash := md5.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ScanDir returns the regular files under root matching the filter,
+// sorted by relative path. Symbolic links are not followed.
+func ScanDir(root string, filter *Filter) ([]LocalFile, error) {
+	files := make([]LocalFile, 0)
+	err := filepath.Walk(root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !filter.Match(rel) {
+			return nil
+		}
+		files = append(files, LocalFile{
+			Path:    name,
+			Rel:     rel,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Rel < files[j].Rel })
+	return files, nil
+}
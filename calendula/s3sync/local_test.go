@@ -0,0 +1,81 @@
+package s3sync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var fakeModTime = time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+
+// fakeSource creates a directory of files, the caller removes it
+func fakeSource(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "s3sync")
+	assert.Nil(t, err)
+	for rel, content := range files {
+		name := filepath.Join(root, filepath.FromSlash(rel))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(name), 0755))
+		assert.Nil(t, ioutil.WriteFile(name, []byte(content), 0644))
+		assert.Nil(t, os.Chtimes(name, fakeModTime, fakeModTime))
+	}
+	return root
+}
+
+func TestParsePatterns(t *testing.T) {
+	assert.Equal(t, []string{"*.json", "conf/*"}, ParsePatterns(" *.json,,conf/* "))
+	assert.Equal(t, []string{}, ParsePatterns(""))
+}
+
+func TestFilterMatch(t *testing.T) {
+	var filter *Filter
+	assert.True(t, filter.Match("any/file"))
+
+	filter = &Filter{Excludes: []string{"*.tmp", "cache/", "logs/*.log"}}
+	assert.Nil(t, filter.Validate())
+	assert.True(t, filter.Match("app.json"))
+	assert.False(t, filter.Match("a/b/c.tmp"))
+	assert.False(t, filter.Match("cache/data"))
+	assert.False(t, filter.Match("app/cache/data"))
+	assert.False(t, filter.Match("logs/app.log"))
+	assert.True(t, filter.Match("logs/old/app.log"))
+
+	filter = &Filter{Includes: []string{"*.json"}, Excludes: []string{"secret*"}}
+	assert.True(t, filter.Match("conf/app.json"))
+	assert.False(t, filter.Match("conf/app.yaml"))
+	assert.False(t, filter.Match("conf/secret.json"))
+
+	filter = &Filter{Includes: []string{"[a-"}}
+	assert.EqualError(t, filter.Validate(), "invalid pattern \"[a-\"")
+}
+
+func TestScanDir(t *testing.T) {
+	root := fakeSource(t, map[string]string{
+		"app.json":      "{}",
+		"conf/db.yaml":  "db: 1",
+		"conf/tmp.swp":  "x",
+		"cache/ignored": "x",
+	})
+	defer os.RemoveAll(root)
+
+	files, err := ScanDir(root, &Filter{Excludes: []string{"*.swp", "cache/"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(files))
+	assert.Equal(t, "app.json", files[0].Rel)
+	assert.Equal(t, filepath.Join(root, "app.json"), files[0].Path)
+	assert.Equal(t, int64(2), files[0].Size)
+	assert.True(t, fakeModTime.Equal(files[0].ModTime))
+	assert.Equal(t, "conf/db.yaml", files[1].Rel)
+
+	sum, err := files[0].MD5()
+	assert.Nil(t, err)
+	assert.Equal(t, "99914b932bd37a50b983c5e7c90ae93b", sum)
+
+	_, err = ScanDir(filepath.Join(root, "missing"), nil)
+	assert.NotNil(t, err)
+	_, err = (&LocalFile{Path: filepath.Join(root, "missing")}).MD5()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,219 @@
+package s3sync
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+)
+
+// CompareMode defines how a local file is compared w/ its s3 object
+type CompareMode string
+
+// Available compare modes, a size change is always detected
+const (
+	// CompareETag compares the md5 of files w/ single part etags,
+	// multipart etags and etags of sse-kms objects fall back to CompareMtime
+	CompareETag CompareMode = "etag"
+	// CompareMtime uploads files modified after their objects
+	CompareMtime CompareMode = "mtime"
+	// CompareSize only compares sizes
+	CompareSize CompareMode = "size"
+)
+
+// ParseCompareMode validates a compare mode
+func ParseCompareMode(raw string) (CompareMode, error) {
+	switch mode := CompareMode(strings.TrimSpace(raw)); mode {
+	case CompareETag, CompareMtime, CompareSize:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unsupport compare mode %q", raw)
+}
+
+// Reasons of uploads
+const (
+	ReasonNew   = "new"
+	ReasonSize  = "size"
+	ReasonETag  = "etag"
+	ReasonMtime = "mtime"
+)
+
+// Upload defines a local file to upload
+type Upload struct {
+	Key    string    `json:"key"`
+	Source LocalFile `json:"source"`
+	Reason string    `json:"reason"`
+}
+
+// SyncPlan defines the changes to make a s3 prefix consistent w/ a directory
+type SyncPlan struct {
+	Bucket    string   `json:"bucket"`
+	Prefix    string   `json:"prefix"`
+	Uploads   []Upload `json:"uploads"`
+	Deletes   []string `json:"deletes"`
+	Unchanged int      `json:"unchanged"`
+}
+
+// Empty checks if the plan has no change
+func (plan *SyncPlan) Empty() bool {
+	return len(plan.Uploads)+len(plan.Deletes) == 0
+}
+
+// UploadSize returns the total bytes to upload
+func (plan *SyncPlan) UploadSize() int64 {
+	var size int64
+	for _, upload := range plan.Uploads {
+		size += upload.Source.Size
+	}
+	return size
+}
+
+// Render writes a human readable report of the plan
+func (plan *SyncPlan) Render(w io.Writer) {
+	fmt.Fprintf(w, "Target: s3://%s/%s\n", plan.Bucket, plan.Prefix)
+	fmt.Fprintf(w, "Uploads: %d (%d bytes)\n", len(plan.Uploads), plan.UploadSize())
+	for _, upload := range plan.Uploads {
+		fmt.Fprintf(w, "  + %s (%s)\n", upload.Key, upload.Reason)
+	}
+	fmt.Fprintf(w, "Deletes: %d\n", len(plan.Deletes))
+	for _, key := range plan.Deletes {
+		fmt.Fprintf(w, "  - %s\n", key)
+	}
+	fmt.Fprintf(w, "Unchanged: %d\n", plan.Unchanged)
+}
+
+// NormalizePrefix appends "/" to a non-empty prefix,
+// so a prefix is synced as a directory
+func NormalizePrefix(prefix string) string {
+	prefix = strings.TrimPrefix(prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// Planner builds a SyncPlan by comparing files w/ objects
+type Planner struct {
+	Compare CompareMode
+	// Filter is applied to relative paths of both files and objects,
+	// so excluded objects are never deleted
+	Filter *Filter
+	// Delete removes objects missing in the directory
+	Delete bool
+	// Head returns the head of an object by key. A single part etag which
+	// mismatches the md5 of a file of the same size is checked by the head,
+	// since etags of sse-kms objects are not md5. Etags are trusted if nil.
+	Head func(key string) (*awsapi.ObjectHead, error)
+}
+
+// NewPlanner returns a *Planner
+func NewPlanner(compare CompareMode, filter *Filter, deleteExtra bool) *Planner {
+	return &Planner{
+		Compare: compare,
+		Filter:  filter,
+		Delete:  deleteExtra,
+	}
+}
+
+// Plan compares files w/ the objects listed under bucket/prefix
+func (planner *Planner) Plan(bucket string, prefix string, files []LocalFile,
+	objects []awsapi.ObjectInfo) (*SyncPlan, error) {
+	prefix = NormalizePrefix(prefix)
+	plan := &SyncPlan{
+		Bucket:  bucket,
+		Prefix:  prefix,
+		Uploads: make([]Upload, 0),
+		Deletes: make([]string, 0),
+	}
+	remote := make(map[string]*awsapi.ObjectInfo)
+	for idx := range objects {
+		rel := strings.TrimPrefix(objects[idx].Key, prefix)
+		// skip "directory" placeholders and keys outside of the prefix
+		if rel == "" || strings.HasSuffix(rel, "/") ||
+			!strings.HasPrefix(objects[idx].Key, prefix) || !planner.Filter.Match(rel) {
+			continue
+		}
+		remote[rel] = &objects[idx]
+	}
+
+	for _, file := range files {
+		if !planner.Filter.Match(file.Rel) {
+			continue
+		}
+		obj, ok := remote[file.Rel]
+		delete(remote, file.Rel)
+		reason := ReasonNew
+		if ok {
+			var err error
+			reason, err = planner.compare(&file, obj)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if reason == "" {
+			plan.Unchanged++
+			continue
+		}
+		plan.Uploads = append(plan.Uploads, Upload{
+			Key:    prefix + file.Rel,
+			Source: file,
+			Reason: reason,
+		})
+	}
+
+	if planner.Delete {
+		for _, obj := range remote {
+			plan.Deletes = append(plan.Deletes, obj.Key)
+		}
+		sort.Strings(plan.Deletes)
+	}
+	return plan, nil
+}
+
+// compare returns the reason to upload a file, or "" if unchanged
+func (planner *Planner) compare(file *LocalFile, obj *awsapi.ObjectInfo) (string, error) {
+	if file.Size != obj.Size {
+		return ReasonSize, nil
+	}
+	switch planner.Compare {
+	case CompareSize:
+		return "", nil
+	case CompareETag:
+		// multipart etags are suffixed w/ the number of parts
+		if !strings.Contains(obj.ETag, "-") && len(obj.ETag) == 32 {
+			sum, err := file.MD5()
+			if err != nil {
+				return "", err
+			}
+			if sum == strings.ToLower(obj.ETag) {
+				return "", nil
+			}
+			md5, err := planner.etagIsMD5(obj)
+			if err != nil {
+				return "", err
+			}
+			if md5 {
+				return ReasonETag, nil
+			}
+		}
+	}
+	if file.ModTime.After(obj.LastModified) {
+		return ReasonMtime, nil
+	}
+	return "", nil
+}
+
+// etagIsMD5 checks if the etag of an object is the md5 of its content
+func (planner *Planner) etagIsMD5(obj *awsapi.ObjectInfo) (bool, error) {
+	if planner.Head == nil {
+		return true, nil
+	}
+	head, err := planner.Head(obj.Key)
+	if err != nil {
+		return false, err
+	}
+	return !strings.HasPrefix(head.ServerSideEncryption, s3.ServerSideEncryptionAwsKms), nil
+}
@@ -0,0 +1,135 @@
+package s3sync
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+)
+
+func TestParseCompareMode(t *testing.T) {
+	mode, err := ParseCompareMode(" mtime")
+	assert.Nil(t, err)
+	assert.Equal(t, CompareMtime, mode)
+	_, err = ParseCompareMode("crc")
+	assert.EqualError(t, err, "unsupport compare mode \"crc\"")
+}
+
+func TestNormalizePrefix(t *testing.T) {
+	assert.Equal(t, "", NormalizePrefix(""))
+	assert.Equal(t, "conf/", NormalizePrefix("/conf"))
+	assert.Equal(t, "conf/", NormalizePrefix("conf/"))
+}
+
+// fakeObjects returns objects of bundle/ compared w/ fakeSource files
+func fakeObjects() []awsapi.ObjectInfo {
+	older := fakeModTime.Add(-time.Hour)
+	newer := fakeModTime.Add(time.Hour)
+	return []awsapi.ObjectInfo{
+		// md5 of "{}", unchanged
+		{Key: "bundle/app.json", Size: 2, ETag: "99914b932bd37a50b983c5e7c90ae93b", LastModified: newer},
+		// same size, different content
+		{Key: "bundle/conf/db.yaml", Size: 5, ETag: "00000000000000000000000000000000", LastModified: newer},
+		// multipart etag, modified before the file
+		{Key: "bundle/conf/big.bin", Size: 3, ETag: "abc-2", LastModified: older},
+		// size changed
+		{Key: "bundle/conf/app.ini", Size: 1, ETag: "x", LastModified: newer},
+		{Key: "bundle/", Size: 0},
+		{Key: "bundle/extra.json", Size: 1},
+		{Key: "bundle/keep.tmp", Size: 1},
+	}
+}
+
+func TestPlan(t *testing.T) {
+	root := fakeSource(t, map[string]string{
+		"app.json":     "{}",
+		"conf/db.yaml": "db: 1",
+		"conf/big.bin": "bin",
+		"conf/app.ini": "a=1",
+		"conf/new.txt": "new",
+		"skip.tmp":     "x",
+	})
+	defer os.RemoveAll(root)
+	filter := &Filter{Excludes: []string{"*.tmp"}}
+	files, err := ScanDir(root, filter)
+	assert.Nil(t, err)
+
+	planner := NewPlanner(CompareETag, filter, true)
+	plan, err := planner.Plan("bucket", "bundle", files, fakeObjects())
+	assert.Nil(t, err)
+	assert.False(t, plan.Empty())
+	assert.Equal(t, "bundle/", plan.Prefix)
+	assert.Equal(t, []Upload{
+		{Key: "bundle/conf/app.ini", Source: files[1], Reason: ReasonSize},
+		{Key: "bundle/conf/big.bin", Source: files[2], Reason: ReasonMtime},
+		{Key: "bundle/conf/db.yaml", Source: files[3], Reason: ReasonETag},
+		{Key: "bundle/conf/new.txt", Source: files[4], Reason: ReasonNew},
+	}, plan.Uploads)
+	// excluded keep.tmp and the placeholder are not deleted
+	assert.Equal(t, []string{"bundle/extra.json"}, plan.Deletes)
+	assert.Equal(t, 1, plan.Unchanged)
+	assert.Equal(t, int64(14), plan.UploadSize())
+
+	// db.yaml is modified before its object, so it is unchanged by mtime
+	plan, err = NewPlanner(CompareMtime, filter, false).Plan("bucket", "bundle/", files, fakeObjects())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(plan.Uploads))
+	assert.Equal(t, ReasonMtime, plan.Uploads[1].Reason)
+	assert.Equal(t, 0, len(plan.Deletes))
+	assert.Equal(t, 2, plan.Unchanged)
+
+	plan, err = NewPlanner(CompareSize, filter, false).Plan("bucket", "bundle", files, fakeObjects())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(plan.Uploads))
+	assert.Equal(t, 3, plan.Unchanged)
+
+	// etags of sse-kms objects are not md5, db.yaml is unchanged by mtime
+	heads := make([]string, 0)
+	planner.Head = func(key string) (*awsapi.ObjectHead, error) {
+		heads = append(heads, key)
+		return &awsapi.ObjectHead{Key: key, ServerSideEncryption: "aws:kms"}, nil
+	}
+	plan, err = planner.Plan("bucket", "bundle", files, fakeObjects())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(plan.Uploads))
+	assert.Equal(t, 2, plan.Unchanged)
+	assert.Equal(t, []string{"bundle/conf/db.yaml"}, heads)
+	planner.Head = func(key string) (*awsapi.ObjectHead, error) {
+		return &awsapi.ObjectHead{Key: key, ServerSideEncryption: "AES256"}, nil
+	}
+	plan, err = planner.Plan("bucket", "bundle", files, fakeObjects())
+	assert.Nil(t, err)
+	assert.Equal(t, ReasonETag, plan.Uploads[2].Reason)
+	planner.Head = func(key string) (*awsapi.ObjectHead, error) {
+		return nil, errors.New("FakeHeadObjectError")
+	}
+	_, err = planner.Plan("bucket", "bundle", files, fakeObjects())
+	assert.EqualError(t, err, "FakeHeadObjectError")
+	planner.Head = nil
+
+	// the content of a file is read by etag compares
+	files[3].Path = root + "/missing"
+	_, err = planner.Plan("bucket", "bundle", files, fakeObjects())
+	assert.NotNil(t, err)
+}
+
+func TestPlanRender(t *testing.T) {
+	plan := &SyncPlan{
+		Bucket: "bucket",
+		Prefix: "bundle/",
+		Uploads: []Upload{{Key: "bundle/app.json", Reason: ReasonNew,
+			Source: LocalFile{Size: 2}}},
+		Deletes:   []string{"bundle/extra.json"},
+		Unchanged: 3,
+	}
+	var buf bytes.Buffer
+	plan.Render(&buf)
+	assert.Equal(t, "Target: s3://bucket/bundle/\n"+
+		"Uploads: 1 (2 bytes)\n  + bundle/app.json (new)\n"+
+		"Deletes: 1\n  - bundle/extra.json\nUnchanged: 3\n", buf.String())
+	assert.True(t, (&SyncPlan{}).Empty())
+}
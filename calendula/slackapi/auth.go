@@ -0,0 +1,32 @@
+package slackapi
+
+import (
+	"context"
+
+	misc "github.com/xinnige/asteraceae/calendula/astermisc"
+)
+
+// AuthTestResponse defines the identity of a token of auth.test
+type AuthTestResponse struct {
+	OK     bool   `json:"ok"`
+	Error  string `json:"error"`
+	URL    string `json:"url"`
+	Team   string `json:"team"`
+	User   string `json:"user"`
+	TeamID string `json:"team_id"`
+	UserID string `json:"user_id"`
+	BotID  string `json:"bot_id"`
+}
+
+// AuthTest checks the token of the client works and returns its identity
+func (client *Client) AuthTest(ctx context.Context) (*AuthTestResponse, error) {
+	response := &AuthTestResponse{}
+	if err := misc.PostJSON(ctx, client.client, APIURL+"auth.test", client.token,
+		nil, response, client.unmarshal, misc.NoDebug{}); err != nil {
+		return nil, err
+	}
+	if !response.OK {
+		return nil, errorString(response.Error)
+	}
+	return response, nil
+}
@@ -0,0 +1,42 @@
+package slackapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+func TestAuthTest(t *testing.T) {
+	client := fakeClient()
+	client.token = "xoxb-new"
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockClientiface := mock.NewMockAsterClient(mockCtrl)
+	mockClientiface.EXPECT().Do(gomock.Any()).DoAndReturn(
+		func(req *http.Request) (*http.Response, error) {
+			assert.Equal(t, "https://slack.com/api/auth.test", req.URL.String())
+			assert.Equal(t, "Bearer xoxb-new", req.Header.Get("Authorization"))
+			return fakeResponse([]byte(`{"ok":true,"team":"asteraceae","user":"bot","team_id":"T1","user_id":"U1"}`)), nil
+		}).Times(1)
+	client.client = mockClientiface
+	resp, err := client.AuthTest(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "T1", resp.TeamID)
+	assert.Equal(t, "U1", resp.UserID)
+
+	mockClientiface.EXPECT().Do(gomock.Any()).Return(
+		fakeResponse([]byte(`{"ok":false,"error":"invalid_auth"}`)), nil).Times(1)
+	_, err = client.AuthTest(context.Background())
+	assert.EqualError(t, err, "invalid_auth")
+
+	mockClientiface.EXPECT().Do(gomock.Any()).Return(
+		nil, errors.New("FakeHTTPError")).Times(1)
+	_, err = client.AuthTest(context.Background())
+	assert.EqualError(t, err, "FakeHTTPError")
+}
@@ -0,0 +1,164 @@
+package ssmconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// Operations of differences
+const (
+	DiffAdd    = "add"
+	DiffChange = "change"
+	DiffRemove = "remove"
+)
+
+// Fields compared by a diff
+const (
+	FieldValue       = "value"
+	FieldType        = "type"
+	FieldKeyID       = "key_id"
+	FieldDescription = "description"
+	FieldTags        = "tags"
+)
+
+// Difference defines a parameter added, changed or removed from one export to another
+type Difference struct {
+	Name   string             `json:"name"`
+	Op     string             `json:"op"`
+	Fields []string           `json:"fields,omitempty"`
+	Old    *ExportedParameter `json:"-"`
+	New    *ExportedParameter `json:"-"`
+}
+
+// DiffResult holds the differences of two exports sorted by name
+type DiffResult struct {
+	From        string       `json:"from"`
+	To          string       `json:"to"`
+	Differences []Difference `json:"differences"`
+	Unchanged   int          `json:"unchanged"`
+}
+
+// Empty checks if the exports are the same
+func (result *DiffResult) Empty() bool {
+	return len(result.Differences) == 0
+}
+
+// Count returns the number of differences of an operation
+func (result *DiffResult) Count(op string) int {
+	count := 0
+	for _, diff := range result.Differences {
+		if diff.Op == op {
+			count++
+		}
+	}
+	return count
+}
+
+// changedFields compares two parameters of the same name.
+// Values of redacted secrets and tags not exported are not compared.
+func changedFields(old, new *ExportedParameter) []string {
+	fields := make([]string, 0)
+	if old.Type != new.Type {
+		fields = append(fields, FieldType)
+	}
+	if !old.Redacted && !new.Redacted && old.Value != new.Value {
+		fields = append(fields, FieldValue)
+	}
+	if old.Secure() && new.Secure() && old.KeyID != new.KeyID {
+		fields = append(fields, FieldKeyID)
+	}
+	if old.Description != new.Description {
+		fields = append(fields, FieldDescription)
+	}
+	if old.Tags != nil && new.Tags != nil && !reflect.DeepEqual(old.Tags, new.Tags) {
+		fields = append(fields, FieldTags)
+	}
+	return fields
+}
+
+// DiffExports returns the changes to make from an export to another,
+// e.g. from prod to staging to promote staging
+func DiffExports(from, to *Export) *DiffResult {
+	result := &DiffResult{From: from.Path, To: to.Path, Differences: make([]Difference, 0)}
+	olds, news := from.index(), to.index()
+	for name, new := range news {
+		old, ok := olds[name]
+		if !ok {
+			result.Differences = append(result.Differences,
+				Difference{Name: name, Op: DiffAdd, New: new})
+			continue
+		}
+		fields := changedFields(old, new)
+		if len(fields) == 0 {
+			result.Unchanged++
+			continue
+		}
+		result.Differences = append(result.Differences,
+			Difference{Name: name, Op: DiffChange, Fields: fields, Old: old, New: new})
+	}
+	for name, old := range olds {
+		if _, ok := news[name]; !ok {
+			result.Differences = append(result.Differences,
+				Difference{Name: name, Op: DiffRemove, Old: old})
+		}
+	}
+	sort.Slice(result.Differences, func(i, j int) bool {
+		return result.Differences[i].Name < result.Differences[j].Name
+	})
+	return result
+}
+
+// MaskValue returns a printable value, SecureString values are replaced by
+// a short fingerprint so changed secrets are visible w/o being disclosed
+func MaskValue(param *ExportedParameter) string {
+	if param.Redacted {
+		return "<redacted>"
+	}
+	if !param.Secure() {
+		return param.Value
+	}
+	sum := sha256.Sum256([]byte(param.Value))
+	return fmt.Sprintf("****(sha256:%s)", hex.EncodeToString(sum[:])[:8])
+}
+
+// Render writes a human readable report of the differences,
+// secrets are masked unless showSecrets
+func (result *DiffResult) Render(w io.Writer, showSecrets bool) {
+	value := MaskValue
+	if showSecrets {
+		value = func(param *ExportedParameter) string { return param.Value }
+	}
+	fmt.Fprintf(w, "Diff: %s -> %s\n", result.From, result.To)
+	for _, diff := range result.Differences {
+		switch diff.Op {
+		case DiffAdd:
+			fmt.Fprintf(w, "  + %s = %s\n", diff.Name, value(diff.New))
+		case DiffRemove:
+			fmt.Fprintf(w, "  - %s = %s\n", diff.Name, value(diff.Old))
+		case DiffChange:
+			fmt.Fprintf(w, "  ~ %s\n", diff.Name)
+			for _, field := range diff.Fields {
+				var old, new interface{}
+				switch field {
+				case FieldValue:
+					old, new = value(diff.Old), value(diff.New)
+				case FieldType:
+					old, new = diff.Old.Type, diff.New.Type
+				case FieldKeyID:
+					old, new = diff.Old.KeyID, diff.New.KeyID
+				case FieldDescription:
+					old, new = diff.Old.Description, diff.New.Description
+				case FieldTags:
+					old, new = diff.Old.Tags, diff.New.Tags
+				}
+				fmt.Fprintf(w, "      %s: %v -> %v\n", field, old, new)
+			}
+		}
+	}
+	fmt.Fprintf(w, "Added: %d, changed: %d, removed: %d, unchanged: %d\n",
+		result.Count(DiffAdd), result.Count(DiffChange), result.Count(DiffRemove), result.Unchanged)
+}
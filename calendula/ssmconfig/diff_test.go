@@ -0,0 +1,78 @@
+package ssmconfig
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+)
+
+func TestDiffExports(t *testing.T) {
+	from := &Export{Path: "/app/prod", Parameters: []ExportedParameter{
+		{Name: "db/host", Type: "String", Value: "prod.local"},
+		{Name: "db/password", Type: "SecureString", Value: "secret1", KeyID: "alias/prod"},
+		{Name: "legacy", Type: "String", Value: "1"},
+		{Name: "port", Type: "String", Value: "80", Tags: map[string]string{"a": "1"}},
+		{Name: "token", Type: "SecureString", Value: "xoxb"},
+	}}
+	to := &Export{Path: "/app/staging", Parameters: []ExportedParameter{
+		{Name: "db/host", Type: "String", Value: "staging.local", Description: "host"},
+		{Name: "db/password", Type: "SecureString", Value: "secret2", KeyID: "alias/staging"},
+		{Name: "new", Type: "StringList", Value: "a,b"},
+		{Name: "port", Type: "String", Value: "80", Tags: map[string]string{"a": "2"}},
+		{Name: "token", Type: "SecureString", Redacted: true},
+	}}
+	result := DiffExports(from, to)
+	assert.False(t, result.Empty())
+	assert.Equal(t, 1, result.Unchanged)
+	assert.Equal(t, 1, result.Count(DiffAdd))
+	assert.Equal(t, 3, result.Count(DiffChange))
+	assert.Equal(t, 1, result.Count(DiffRemove))
+	assert.Equal(t, []string{FieldValue, FieldDescription}, result.Differences[0].Fields)
+	assert.Equal(t, []string{FieldValue, FieldKeyID}, result.Differences[1].Fields)
+	assert.Equal(t, []string{FieldTags}, result.Differences[4].Fields)
+
+	buf := &bytes.Buffer{}
+	result.Render(buf, false)
+	assert.Equal(t, "Diff: /app/prod -> /app/staging\n"+
+		"  ~ db/host\n"+
+		"      value: prod.local -> staging.local\n"+
+		"      description:  -> host\n"+
+		"  ~ db/password\n"+
+		"      value: ****(sha256:5b11618c) -> ****(sha256:35224d0d)\n"+
+		"      key_id: alias/prod -> alias/staging\n"+
+		"  - legacy = 1\n"+
+		"  + new = a,b\n"+
+		"  ~ port\n"+
+		"      tags: map[a:1] -> map[a:2]\n"+
+		"Added: 1, changed: 3, removed: 1, unchanged: 1\n", buf.String())
+	assert.NotContains(t, buf.String(), "secret")
+
+	buf.Reset()
+	result.Render(buf, true)
+	assert.Contains(t, buf.String(), "value: secret1 -> secret2")
+
+	assert.True(t, DiffExports(from, from).Empty())
+}
+
+func TestMaskValue(t *testing.T) {
+	assert.Equal(t, "80", MaskValue(&ExportedParameter{Type: "String", Value: "80"}))
+	assert.Equal(t, "<redacted>", MaskValue(&ExportedParameter{Type: "SecureString", Redacted: true}))
+	masked := MaskValue(&ExportedParameter{Type: "SecureString", Value: "secret"})
+	assert.Regexp(t, `^\*\*\*\*\(sha256:[0-9a-f]{8}\)$`, masked)
+	assert.Equal(t, masked, MaskValue(&ExportedParameter{Type: "SecureString", Value: "secret"}))
+}
+
+func TestDiffPaths(t *testing.T) {
+	result, err := DiffPaths(context.Background(), &awsapi.AWSAPI{}, fakeStaging(),
+		"/app/prod", "/app/staging")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(result.Differences))
+	assert.Equal(t, "db/host", result.Differences[0].Name)
+	assert.Equal(t, []string{FieldValue, FieldTags}, result.Differences[0].Fields)
+	assert.Equal(t, []string{FieldValue}, result.Differences[1].Fields)
+	assert.Equal(t, DiffRemove, result.Differences[2].Op)
+	assert.Equal(t, 1, result.Unchanged)
+}
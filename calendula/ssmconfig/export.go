@@ -0,0 +1,166 @@
+package ssmconfig
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+	"github.com/xinnige/asteraceae/calendula/utils"
+)
+
+const resourceTypeParameter = "Parameter"
+
+// Formats of export files
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// ExportSerializer returns the serializer of an export format,
+// the format is guessed from the extension of filename if empty (json by default)
+func ExportSerializer(format, filename string) (utils.SerialInterface, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(filename)) {
+		case ".yaml", ".yml":
+			format = FormatYAML
+		default:
+			format = FormatJSON
+		}
+	}
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		return &utils.JSONAPI{}, nil
+	case FormatYAML, "yml":
+		return &utils.YAMLAPI{}, nil
+	}
+	return nil, fmt.Errorf("unsupport export format %q, expect json or yaml", format)
+}
+
+// ExportedParameter defines a parameter of an export, Name is relative to the path
+type ExportedParameter struct {
+	Name  string `json:"name" yaml:"name"`
+	Type  string `json:"type" yaml:"type"`
+	Value string `json:"value" yaml:"value"`
+	// Redacted is set if a SecureString value is not exported
+	Redacted    bool   `json:"redacted,omitempty" yaml:"redacted,omitempty"`
+	KeyID       string `json:"key_id,omitempty" yaml:"key_id,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Tags is nil if tags are not exported, so they are not compared
+	Tags ParameterTags `json:"tags" yaml:"tags"`
+}
+
+// ParameterTags defines the tags of an exported parameter
+type ParameterTags map[string]string
+
+// MarshalYAML writes nil tags as null, yaml.v2 writes a nil map as {}
+// which would compare the tags of an export w/o tags
+func (tags ParameterTags) MarshalYAML() (interface{}, error) {
+	if tags == nil {
+		return nil, nil
+	}
+	return map[string]string(tags), nil
+}
+
+// Secure checks if the parameter is a SecureString
+func (param *ExportedParameter) Secure() bool {
+	return param.Type == ssm.ParameterTypeSecureString
+}
+
+// Export defines the parameters of a path tree sorted by name
+type Export struct {
+	Path       string              `json:"path" yaml:"path"`
+	Parameters []ExportedParameter `json:"parameters" yaml:"parameters"`
+}
+
+func (export *Export) index() map[string]*ExportedParameter {
+	index := make(map[string]*ExportedParameter, len(export.Parameters))
+	for idx := range export.Parameters {
+		index[export.Parameters[idx].Name] = &export.Parameters[idx]
+	}
+	return index
+}
+
+// ExportOptions defines what is exported
+type ExportOptions struct {
+	// Secrets exports decrypted SecureString values, they are redacted if false
+	Secrets bool
+	// Tags exports the tags of every parameter, a request per parameter
+	Tags bool
+}
+
+// ExportPath exports the parameters under path recursively
+func ExportPath(ctx context.Context, api *awsapi.AWSAPI, svc ssmiface.SSMAPI,
+	path string, opts ExportOptions) (*Export, error) {
+	params, err := fetchParameters(ctx, api, svc, path)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := api.DescribeParametersByPathWithContext(ctx, svc, path, true)
+	if err != nil {
+		return nil, err
+	}
+	described := make(map[string]*ssm.ParameterMetadata, len(metadata))
+	for _, meta := range metadata {
+		described[aws.StringValue(meta.Name)] = meta
+	}
+
+	export := &Export{Path: path, Parameters: make([]ExportedParameter, 0, len(params))}
+	for _, param := range params {
+		name := aws.StringValue(param.Name)
+		exported := ExportedParameter{
+			Name:  RelativeName(path, name),
+			Type:  aws.StringValue(param.Type),
+			Value: aws.StringValue(param.Value),
+		}
+		if meta, ok := described[name]; ok {
+			exported.KeyID = aws.StringValue(meta.KeyId)
+			exported.Description = aws.StringValue(meta.Description)
+		}
+		if exported.Secure() && !opts.Secrets {
+			exported.Value = ""
+			exported.Redacted = true
+		}
+		if opts.Tags {
+			tags, err := api.ListTagsForResourceWithContext(ctx, svc, name, resourceTypeParameter)
+			if err != nil {
+				return nil, err
+			}
+			exported.Tags = tags
+		}
+		export.Parameters = append(export.Parameters, exported)
+	}
+	sort.Slice(export.Parameters, func(i, j int) bool {
+		return export.Parameters[i].Name < export.Parameters[j].Name
+	})
+	return export, nil
+}
+
+// LoadExport reads an export file
+func LoadExport(filename string, siface utils.SerialInterface) (*Export, error) {
+	content, err := utils.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	export := &Export{}
+	if err := siface.Unmarshal(content, export); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// Save writes the export to a file readable only by the owner,
+// since it may hold decrypted secrets
+func (export *Export) Save(filename string, siface utils.SerialInterface) error {
+	content, err := siface.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Clean(filename), content, 0600)
+}
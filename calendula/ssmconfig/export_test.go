@@ -0,0 +1,134 @@
+package ssmconfig
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+	"github.com/xinnige/asteraceae/calendula/mock"
+	"github.com/xinnige/asteraceae/calendula/utils"
+)
+
+// fakeStaging returns a fake store of /app/staging and /app/prod
+func fakeStaging() *mock.SSMAPI {
+	svc := mock.SSMParameterStore(
+		"/app/staging/db/host=staging.local",
+		"/app/staging/port=80",
+		"/app/prod/db/host=prod.local",
+		"/app/prod/port=80",
+		"/app/prod/legacy=1",
+	)
+	svc.SetParameter("/app/staging/db/password", "secret2", ssm.ParameterTypeSecureString)
+	svc.SetParameter("/app/prod/db/password", "secret1", ssm.ParameterTypeSecureString)
+	svc.SetTags("/app/staging/db/host", map[string]string{"team": "infra"})
+	return svc
+}
+
+func TestExportPath(t *testing.T) {
+	svc := fakeStaging()
+	export, err := ExportPath(context.Background(), &awsapi.AWSAPI{}, svc,
+		"/app/staging", ExportOptions{Tags: true})
+	assert.Nil(t, err)
+	assert.Equal(t, &Export{Path: "/app/staging", Parameters: []ExportedParameter{
+		{Name: "db/host", Type: "String", Value: "staging.local",
+			Tags: map[string]string{"team": "infra"}},
+		{Name: "db/password", Type: "SecureString", Redacted: true,
+			KeyID: "alias/aws/ssm", Tags: map[string]string{}},
+		{Name: "port", Type: "String", Value: "80", Tags: map[string]string{}},
+	}}, export)
+
+	export, err = ExportPath(context.Background(), &awsapi.AWSAPI{}, svc,
+		"/app/staging", ExportOptions{Secrets: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "secret2", export.Parameters[1].Value)
+	assert.False(t, export.Parameters[1].Redacted)
+	assert.Nil(t, export.Parameters[0].Tags)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ExportPath(ctx, &awsapi.AWSAPI{}, svc, "/app/staging", ExportOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestExportSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ssmconfig")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "export.json")
+
+	export, err := ExportPath(context.Background(), &awsapi.AWSAPI{}, fakeStaging(),
+		"/app/staging", ExportOptions{Secrets: true, Tags: true})
+	assert.Nil(t, err)
+	assert.Nil(t, export.Save(filename, &utils.JSONAPI{}))
+	info, err := os.Stat(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	loaded, err := LoadExport(filename, &utils.JSONAPI{})
+	assert.Nil(t, err)
+	assert.Equal(t, export, loaded)
+
+	_, err = LoadExport(filepath.Join(dir, "missing.json"), &utils.JSONAPI{})
+	assert.NotNil(t, err)
+	assert.NotNil(t, export.Save(filepath.Join(dir, "missing", "export.json"), &utils.JSONAPI{}))
+}
+
+func TestExportSaveYAML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ssmconfig")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "export.yaml")
+
+	export, err := ExportPath(context.Background(), &awsapi.AWSAPI{}, fakeStaging(),
+		"/app/staging", ExportOptions{Secrets: true, Tags: true})
+	assert.Nil(t, err)
+	siface, err := ExportSerializer("", filename)
+	assert.Nil(t, err)
+	assert.IsType(t, &utils.YAMLAPI{}, siface)
+	assert.Nil(t, export.Save(filename, siface))
+	loaded, err := LoadExport(filename, siface)
+	assert.Nil(t, err)
+	assert.Equal(t, export, loaded)
+
+	// tags which are not exported stay nil
+	export, err = ExportPath(context.Background(), &awsapi.AWSAPI{}, fakeStaging(),
+		"/app/staging", ExportOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, export.Save(filename, siface))
+	loaded, err = LoadExport(filename, siface)
+	assert.Nil(t, err)
+	assert.Equal(t, export, loaded)
+	assert.Nil(t, loaded.Parameters[0].Tags)
+
+	// unquoted numbers and booleans of a hand edit are string values
+	assert.Nil(t, ioutil.WriteFile(filename, []byte(`path: /app/staging
+parameters:
+- name: port
+  type: String
+  value: 8080
+  tags: {}
+- name: debug
+  type: String
+  value: true
+`), 0600))
+	loaded, err = LoadExport(filename, siface)
+	assert.Nil(t, err)
+	assert.Equal(t, &Export{Path: "/app/staging", Parameters: []ExportedParameter{
+		{Name: "port", Type: "String", Value: "8080", Tags: ParameterTags{}},
+		{Name: "debug", Type: "String", Value: "true"},
+	}}, loaded)
+
+	siface, err = ExportSerializer("", "export.out")
+	assert.Nil(t, err)
+	assert.IsType(t, &utils.JSONAPI{}, siface)
+	siface, err = ExportSerializer("YAML", "export.json")
+	assert.Nil(t, err)
+	assert.IsType(t, &utils.YAMLAPI{}, siface)
+	_, err = ExportSerializer("toml", "export.toml")
+	assert.EqualError(t, err, `unsupport export format "toml", expect json or yaml`)
+}
@@ -0,0 +1,250 @@
+package ssmconfig
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+)
+
+// Operations of imported actions
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpSkip   = "skip"
+	OpDelete = "delete"
+)
+
+// ImportOptions defines how an export is imported
+type ImportOptions struct {
+	// KeyID encrypts SecureString parameters w/ a kms key of the target,
+	// e.g. a key of another account, the exported key is used if empty
+	KeyID string
+	// Overwrite updates changed parameters, they are skipped if false
+	Overwrite bool
+	// Prune deletes parameters of the target path missing in the export
+	Prune bool
+	// DryRun only records the actions w/o writing to ssm
+	DryRun bool
+}
+
+// ImportAction defines an imported (or planned in dry-run) parameter
+type ImportAction struct {
+	Op     string `json:"op"`
+	Name   string `json:"name"`
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportResult holds the actions of an import
+type ImportResult struct {
+	DryRun  bool           `json:"dry_run"`
+	Actions []ImportAction `json:"actions"`
+}
+
+// Failed returns the actions w/ errors
+func (result *ImportResult) Failed() []ImportAction {
+	failed := make([]ImportAction, 0)
+	for _, action := range result.Actions {
+		if action.Error != "" {
+			failed = append(failed, action)
+		}
+	}
+	return failed
+}
+
+// Count returns the number of succeeded actions of an operation
+func (result *ImportResult) Count(op string) int {
+	count := 0
+	for _, action := range result.Actions {
+		if action.Op == op && action.Error == "" {
+			count++
+		}
+	}
+	return count
+}
+
+// Render writes a human readable summary of the result
+func (result *ImportResult) Render(w io.Writer) {
+	mode := "applied"
+	if result.DryRun {
+		mode = "dry-run"
+	}
+	fmt.Fprintf(w, "Actions (%s): %d, created: %d, updated: %d, deleted: %d, skipped: %d, failed: %d\n",
+		mode, len(result.Actions), result.Count(OpCreate), result.Count(OpUpdate),
+		result.Count(OpDelete), result.Count(OpSkip), len(result.Failed()))
+	for _, action := range result.Actions {
+		if action.Op == OpSkip {
+			fmt.Fprintf(w, "  skip %s: %s\n", action.Name, action.Reason)
+		}
+	}
+	for _, action := range result.Failed() {
+		fmt.Fprintf(w, "  %s %s: %s\n", action.Op, action.Name, action.Error)
+	}
+}
+
+// JoinName returns the full name of a parameter relative to path
+func JoinName(path, name string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "/" + name
+	}
+	return "/" + path + "/" + name
+}
+
+// desired returns a copy of the export to import w/ key overridden
+func (export *Export) desired(keyID string) *Export {
+	params := make([]ExportedParameter, len(export.Parameters))
+	copy(params, export.Parameters)
+	for idx := range params {
+		if keyID != "" && params[idx].Secure() {
+			params[idx].KeyID = keyID
+		}
+	}
+	return &Export{Path: export.Path, Parameters: params}
+}
+
+// ImportExport writes the parameters of an export under path, e.g. to promote
+// staging to prod. Values are re-encrypted by the key of the target, tags
+// are added but never removed. Redacted secrets can not be imported and are
+// skipped. Failed actions are recorded and do not stop the rest.
+func ImportExport(ctx context.Context, api *awsapi.AWSAPI, svc ssmiface.SSMAPI,
+	export *Export, path string, opts ImportOptions) (*ImportResult, error) {
+	current, err := ExportPath(ctx, api, svc, path, ExportOptions{Secrets: true, Tags: true})
+	if err != nil {
+		return nil, err
+	}
+	diff := DiffExports(current, export.desired(opts.KeyID))
+
+	result := &ImportResult{DryRun: opts.DryRun, Actions: make([]ImportAction, 0)}
+	prune := make([]string, 0)
+	for _, change := range diff.Differences {
+		name := JoinName(path, change.Name)
+		switch {
+		case change.Op == DiffRemove:
+			if opts.Prune {
+				prune = append(prune, name)
+			}
+		case change.New.Redacted:
+			result.Actions = append(result.Actions, ImportAction{
+				Op: OpSkip, Name: name, Reason: "secret value is redacted"})
+		case change.Op == DiffAdd:
+			action := ImportAction{Op: OpCreate, Name: name}
+			if !opts.DryRun {
+				action.Error = errorString(putParameter(ctx, svc, name, change.New, false))
+			}
+			result.Actions = append(result.Actions, action)
+		case !opts.Overwrite:
+			result.Actions = append(result.Actions, ImportAction{Op: OpSkip, Name: name,
+				Reason: "changed " + strings.Join(change.Fields, ",") + " w/o overwrite"})
+		default:
+			action := ImportAction{Op: OpUpdate, Name: name}
+			if !opts.DryRun {
+				action.Error = errorString(updateParameter(ctx, api, svc, name, change))
+			}
+			result.Actions = append(result.Actions, action)
+		}
+	}
+
+	if len(prune) == 0 {
+		return result, nil
+	}
+	if opts.DryRun {
+		for _, name := range prune {
+			result.Actions = append(result.Actions, ImportAction{Op: OpDelete, Name: name})
+		}
+		return result, nil
+	}
+	// a failed batch is reported per name by the result
+	deleted, _ := api.DeleteParametersWithContext(ctx, svc, prune)
+	failed := make(map[string]string, len(deleted.Errors))
+	for _, keyErr := range deleted.Errors {
+		failed[keyErr.Key] = keyErr.Message
+	}
+	for _, name := range prune {
+		result.Actions = append(result.Actions,
+			ImportAction{Op: OpDelete, Name: name, Error: failed[name]})
+	}
+	return result, nil
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// updateParameter overwrites a changed parameter, tags can not be put w/ overwrite
+func updateParameter(ctx context.Context, api *awsapi.AWSAPI, svc ssmiface.SSMAPI,
+	name string, change Difference) error {
+	tagged := false
+	for _, field := range change.Fields {
+		if field == FieldTags {
+			tagged = true
+		}
+	}
+	if !tagged || len(change.Fields) > 1 {
+		if err := putParameter(ctx, svc, name, change.New, true); err != nil {
+			return err
+		}
+	}
+	if tagged && len(change.New.Tags) != 0 {
+		return api.AddTagsToResourceWithContext(ctx, svc, name,
+			resourceTypeParameter, change.New.Tags)
+	}
+	return nil
+}
+
+// putParameter puts an exported parameter, unlike awsapi.PutParameter
+// it keeps the type, description and tags and never logs values
+func putParameter(ctx context.Context, svc ssmiface.SSMAPI, name string,
+	param *ExportedParameter, overwrite bool) error {
+	input := &ssm.PutParameterInput{
+		Name:      aws.String(name),
+		Type:      aws.String(param.Type),
+		Value:     aws.String(param.Value),
+		Overwrite: aws.Bool(overwrite),
+	}
+	if param.Secure() && param.KeyID != "" {
+		input.KeyId = aws.String(param.KeyID)
+	}
+	if param.Description != "" {
+		input.Description = aws.String(param.Description)
+	}
+	if !overwrite {
+		for key, value := range param.Tags {
+			input.Tags = append(input.Tags, &ssm.Tag{Key: aws.String(key), Value: aws.String(value)})
+		}
+	}
+	result, err := svc.PutParameterWithContext(ctx, input)
+	if err != nil {
+		log.Printf("SSMError: fail to put parameter %s (type=%s, keyid=%s, overwrite=%t), err: %v\n",
+			name, param.Type, param.KeyID, overwrite, err)
+		return err
+	}
+	log.Printf("SSM: succeed to put parameter %s (type=%s, keyid=%s, overwrite=%t), version %d\n",
+		name, param.Type, param.KeyID, overwrite, aws.Int64Value(result.Version))
+	return nil
+}
+
+// DiffPaths exports two paths w/ secrets and tags and diffs them
+func DiffPaths(ctx context.Context, api *awsapi.AWSAPI, svc ssmiface.SSMAPI,
+	from, to string) (*DiffResult, error) {
+	opts := ExportOptions{Secrets: true, Tags: true}
+	fromExport, err := ExportPath(ctx, api, svc, from, opts)
+	if err != nil {
+		return nil, err
+	}
+	toExport, err := ExportPath(ctx, api, svc, to, opts)
+	if err != nil {
+		return nil, err
+	}
+	return DiffExports(fromExport, toExport), nil
+}
@@ -0,0 +1,123 @@
+package ssmconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+)
+
+func TestImportExport(t *testing.T) {
+	svc := fakeStaging()
+	api := &awsapi.AWSAPI{}
+	export, err := ExportPath(context.Background(), api, svc, "/app/staging",
+		ExportOptions{Secrets: true, Tags: true})
+	assert.Nil(t, err)
+
+	// dry-run w/o overwrite only creates nothing and skips changes
+	result, err := ImportExport(context.Background(), api, svc, export, "/app/prod",
+		ImportOptions{DryRun: true, Prune: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []ImportAction{
+		{Op: OpSkip, Name: "/app/prod/db/host", Reason: "changed value,tags w/o overwrite"},
+		{Op: OpSkip, Name: "/app/prod/db/password", Reason: "changed value w/o overwrite"},
+		{Op: OpDelete, Name: "/app/prod/legacy"},
+	}, result.Actions)
+	assert.Equal(t, "prod.local", aws.StringValue(svc.Parameter("/app/prod/db/host").Value))
+	assert.NotNil(t, svc.Parameter("/app/prod/legacy"))
+
+	result, err = ImportExport(context.Background(), api, svc, export, "/app/prod",
+		ImportOptions{Overwrite: true, Prune: true, KeyID: "alias/prod"})
+	assert.Nil(t, err)
+	assert.Equal(t, []ImportAction{
+		{Op: OpUpdate, Name: "/app/prod/db/host"},
+		{Op: OpUpdate, Name: "/app/prod/db/password"},
+		{Op: OpDelete, Name: "/app/prod/legacy"},
+	}, result.Actions)
+	host := svc.Parameter("/app/prod/db/host")
+	assert.Equal(t, "staging.local", aws.StringValue(host.Value))
+	password := svc.Parameter("/app/prod/db/password")
+	assert.Equal(t, "secret2", aws.StringValue(password.Value))
+	assert.Equal(t, "alias/prod", aws.StringValue(password.KeyId))
+	assert.Nil(t, svc.Parameter("/app/prod/legacy"))
+	tags, _ := api.ListTagsForResource(svc, "/app/prod/db/host", "Parameter")
+	assert.Equal(t, map[string]string{"team": "infra"}, tags)
+
+	// imported, nothing to do
+	result, err = ImportExport(context.Background(), api, svc, export, "/app/prod",
+		ImportOptions{Overwrite: true, Prune: true, KeyID: "alias/prod"})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(result.Actions))
+
+	// created w/ tags into a new path
+	result, err = ImportExport(context.Background(), api, svc, export, "/app/dev", ImportOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, result.Count(OpCreate))
+	tags, _ = api.ListTagsForResource(svc, "/app/dev/db/host", "Parameter")
+	assert.Equal(t, map[string]string{"team": "infra"}, tags)
+	password = svc.Parameter("/app/dev/db/password")
+	assert.Equal(t, "alias/aws/ssm", aws.StringValue(password.KeyId))
+
+	buf := &bytes.Buffer{}
+	result.Render(buf)
+	assert.Equal(t, "Actions (applied): 3, created: 3, updated: 0, deleted: 0, skipped: 0, failed: 0\n",
+		buf.String())
+}
+
+func TestImportExportRedacted(t *testing.T) {
+	svc := fakeStaging()
+	api := &awsapi.AWSAPI{}
+	export, err := ExportPath(context.Background(), api, svc, "/app/staging", ExportOptions{})
+	assert.Nil(t, err)
+	result, err := ImportExport(context.Background(), api, svc, export, "/app/qa", ImportOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, result.Count(OpCreate))
+	assert.Equal(t, 1, result.Count(OpSkip))
+	assert.Nil(t, svc.Parameter("/app/qa/db/password"))
+
+	buf := &bytes.Buffer{}
+	result.Render(buf)
+	assert.Equal(t, "Actions (applied): 3, created: 2, updated: 0, deleted: 0, skipped: 1, failed: 0\n"+
+		"  skip /app/qa/db/password: secret value is redacted\n", buf.String())
+}
+
+func TestImportExportPrune(t *testing.T) {
+	svc := fakeStaging()
+	api := &awsapi.AWSAPI{}
+	// more than a DeleteParameters request of 10 names
+	for idx := 0; idx < 12; idx++ {
+		svc.SetParameter(fmt.Sprintf("/app/dev/old%02d", idx), "x", "String")
+	}
+	export := &Export{Path: "/app/staging", Parameters: []ExportedParameter{}}
+	result, err := ImportExport(context.Background(), api, svc, export, "/app/dev",
+		ImportOptions{Prune: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 12, result.Count(OpDelete))
+	assert.Equal(t, 0, len(result.Failed()))
+	assert.Nil(t, svc.Parameter("/app/dev/old11"))
+
+	// failed deletes are reported per name
+	svc.SetParameter("/app/dev/old", "x", "String")
+	svc.SetDeleteError(errors.New("AccessDenied"))
+	result, err = ImportExport(context.Background(), api, svc, export, "/app/dev",
+		ImportOptions{Prune: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []ImportAction{{Op: OpDelete, Name: "/app/dev/old", Error: "AccessDenied"}},
+		result.Failed())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ImportExport(ctx, api, svc, export, "/app/dev", ImportOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestJoinName(t *testing.T) {
+	assert.Equal(t, "/app/db/host", JoinName("/app/", "db/host"))
+	assert.Equal(t, "/app/db/host", JoinName("app", "db/host"))
+	assert.Equal(t, "/db/host", JoinName("/", "db/host"))
+}
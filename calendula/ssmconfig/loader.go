@@ -0,0 +1,249 @@
+package ssmconfig
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+	"github.com/xinnige/asteraceae/calendula/utils"
+)
+
+const (
+	tagName    = "ssm"
+	tagDefault = "default"
+	optRequire = "required"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// Loader binds config values into structs,
+// a name is looked up in Sources in order and the first found value wins,
+// so earlier sources override later ones, e.g. env, ssm, then a file fallback.
+//
+// Fields are bound by tags:
+//
+//	type Config struct {
+//		Port    int           `ssm:"port" default:"8080"`
+//		Timeout time.Duration `ssm:"timeout" default:"5s"`
+//		Hosts   []string      `ssm:"hosts"`
+//		DB      struct {
+//			Password string `ssm:"password,required"`
+//		} `ssm:"db"`
+//	}
+//
+// binds "port", "timeout", "hosts" and "db/password". Slices are split by ","
+// like StringList parameters, time.Time values are parsed as RFC 3339,
+// fields w/o tags or tagged "-" are left as is.
+type Loader struct {
+	Sources []Source
+}
+
+// NewLoader returns a *Loader
+func NewLoader(sources ...Source) *Loader {
+	return &Loader{Sources: sources}
+}
+
+// Lookup returns the value of a name from the first source having it
+func (loader *Loader) Lookup(name string) (string, bool) {
+	for _, source := range loader.Sources {
+		if value, ok := source.Lookup(name); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Load binds values into a pointer to a struct,
+// all missing required fields and invalid values are reported in one error
+func (loader *Loader) Load(v interface{}) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a non-nil pointer to a struct, got %T", v)
+	}
+	errs := make([]string, 0)
+	loader.bind(value.Elem(), "", &errs)
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func parseTag(tag string) (string, bool) {
+	parts := strings.Split(tag, ",")
+	required := false
+	for _, opt := range parts[1:] {
+		if strings.TrimSpace(opt) == optRequire {
+			required = true
+		}
+	}
+	return strings.Trim(strings.TrimSpace(parts[0]), "/"), required
+}
+
+func (loader *Loader) bind(value reflect.Value, prefix string, errs *[]string) {
+	for idx := 0; idx < value.NumField(); idx++ {
+		field := value.Type().Field(idx)
+		target := value.Field(idx)
+		// exported fields of unexported embedded structs are still settable
+		if !target.CanSet() && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		tag, tagged := field.Tag.Lookup(tagName)
+		name, required := parseTag(tag)
+		if name == "-" {
+			continue
+		}
+
+		// nested structs extend the prefix, embedded ones w/o tags share it
+		if isStruct(field.Type) {
+			if !tagged && !field.Anonymous {
+				continue
+			}
+			nested := prefix
+			if name != "" {
+				nested = prefix + name + "/"
+			}
+			if target.Kind() == reflect.Ptr {
+				if target.IsNil() {
+					target.Set(reflect.New(field.Type.Elem()))
+				}
+				target = target.Elem()
+			}
+			loader.bind(target, nested, errs)
+			continue
+		}
+		if !tagged || name == "" {
+			continue
+		}
+
+		name = prefix + name
+		raw, ok := loader.Lookup(name)
+		if !ok {
+			raw, ok = field.Tag.Lookup(tagDefault)
+		}
+		if !ok {
+			if required {
+				*errs = append(*errs, fmt.Sprintf("%s is required", name))
+			}
+			continue
+		}
+		if err := setValue(target, raw); err != nil {
+			*errs = append(*errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+}
+
+// isStruct checks if a field is a struct of fields to bind, time.Time and
+// structs w/o exported fields are bound as values, so they fail as unsupported
+// types instead of being skipped
+func isStruct(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ == timeType {
+		return false
+	}
+	for idx := 0; idx < typ.NumField(); idx++ {
+		if field := typ.Field(idx); field.PkgPath == "" || field.Anonymous {
+			return true
+		}
+	}
+	return false
+}
+
+// setValue converts a raw string into a field
+func setValue(target reflect.Value, raw string) error {
+	if target.Type() == durationType {
+		duration, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return err
+		}
+		target.SetInt(int64(duration))
+		return nil
+	}
+	if target.Type() == timeType {
+		parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(raw))
+		if err != nil {
+			return err
+		}
+		target.Set(reflect.ValueOf(parsed))
+		return nil
+	}
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return err
+		}
+		target.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(strings.TrimSpace(raw), 10, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(strings.TrimSpace(raw), 10, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(raw), target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetFloat(parsed)
+	case reflect.Slice:
+		items := make([]string, 0)
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		slice := reflect.MakeSlice(target.Type(), len(items), len(items))
+		for idx, item := range items {
+			if err := setValue(slice.Index(idx), item); err != nil {
+				return err
+			}
+		}
+		target.Set(slice)
+	case reflect.Ptr:
+		elem := reflect.New(target.Type().Elem())
+		if err := setValue(elem.Elem(), raw); err != nil {
+			return err
+		}
+		target.Set(elem)
+	default:
+		return fmt.Errorf("unsupport type %s", target.Type())
+	}
+	return nil
+}
+
+// LoadSSM binds v from environment variables w/ envPrefix, then parameters
+// under path, then a json file as the fallback if filename is not empty
+func LoadSSM(ctx context.Context, api *awsapi.AWSAPI, svc ssmiface.SSMAPI,
+	path string, envPrefix string, filename string, v interface{}) error {
+	ssm, err := NewSSMSource(ctx, api, svc, path)
+	if err != nil {
+		return err
+	}
+	loader := NewLoader(EnvSource{Prefix: envPrefix}, ssm)
+	if filename != "" {
+		file, err := LoadFileSource(filename, &utils.JSONAPI{})
+		if err != nil {
+			return err
+		}
+		loader.Sources = append(loader.Sources, file)
+	}
+	return loader.Load(v)
+}
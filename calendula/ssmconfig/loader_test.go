@@ -0,0 +1,149 @@
+package ssmconfig
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+	"github.com/xinnige/asteraceae/calendula/mock"
+	"github.com/xinnige/asteraceae/calendula/utils"
+)
+
+type fakeDBConfig struct {
+	Host     string `ssm:"host,required"`
+	Password string `ssm:"password,required"`
+	MaxConns int    `ssm:"max_conns" default:"10"`
+}
+
+type fakeCommon struct {
+	Debug bool `ssm:"debug"`
+}
+
+type fakeConfig struct {
+	fakeCommon
+	Port     uint16        `ssm:"port" default:"80"`
+	Timeout  time.Duration `ssm:"timeout" default:"5s"`
+	Ratio    float64       `ssm:"ratio" default:"0.5"`
+	Hosts    []string      `ssm:"hosts"`
+	Ports    []int         `ssm:"ports"`
+	Token    *string       `ssm:"token"`
+	DB       fakeDBConfig  `ssm:"db"`
+	Replica  *fakeDBConfig `ssm:"replica"`
+	Ignored  string        `ssm:"-"`
+	Untagged string
+	Labels   map[string]int `ssm:"labels"`
+}
+
+func TestLoaderLoad(t *testing.T) {
+	file, err := LoadFileSource("../test/ssmconfig/config.json", &utils.JSONAPI{})
+	assert.Nil(t, err)
+	ssm := MapSource{
+		"db/host":          "ssm.local",
+		"db/password":      "secret",
+		"timeout":          "1m30s",
+		"ports":            "80, 443",
+		"token":            "xoxb",
+		"replica/host":     "replica.local",
+		"replica/password": "secret2",
+		"-":                "ignored",
+	}
+	os.Setenv("SSMCONFIG_TEST_DB_HOST", "env.local")
+	defer os.Unsetenv("SSMCONFIG_TEST_DB_HOST")
+	loader := NewLoader(EnvSource{Prefix: "SSMCONFIG_TEST_"}, ssm, file)
+
+	config := &fakeConfig{Untagged: "keep"}
+	assert.Nil(t, loader.Load(config))
+	assert.True(t, config.Debug)
+	assert.Equal(t, uint16(8080), config.Port)
+	assert.Equal(t, 90*time.Second, config.Timeout)
+	assert.Equal(t, 0.5, config.Ratio)
+	assert.Equal(t, []string{"a.local", "b.local"}, config.Hosts)
+	assert.Equal(t, []int{80, 443}, config.Ports)
+	assert.Equal(t, "xoxb", *config.Token)
+	// env overrides ssm, which overrides the file
+	assert.Equal(t, "env.local", config.DB.Host)
+	assert.Equal(t, "secret", config.DB.Password)
+	assert.Equal(t, 1000000, config.DB.MaxConns)
+	assert.Equal(t, "replica.local", config.Replica.Host)
+	assert.Equal(t, 10, config.Replica.MaxConns)
+	assert.Equal(t, "", config.Ignored)
+	assert.Equal(t, "keep", config.Untagged)
+	assert.Nil(t, config.Labels)
+}
+
+func TestLoaderLoadErrors(t *testing.T) {
+	loader := NewLoader(MapSource{
+		"port":    "http",
+		"timeout": "5",
+		"ports":   "80,x",
+		"labels":  "a=1",
+	})
+	config := &fakeConfig{}
+	err := loader.Load(config)
+	assert.EqualError(t, err, "invalid config: "+
+		"port: strconv.ParseUint: parsing \"http\": invalid syntax; "+
+		"timeout: time: missing unit in duration \"5\"; "+
+		"ports: strconv.ParseInt: parsing \"x\": invalid syntax; "+
+		"db/host is required; db/password is required; "+
+		"replica/host is required; replica/password is required; "+
+		"labels: unsupport type map[string]int")
+
+	assert.EqualError(t, loader.Load(*config),
+		"config must be a non-nil pointer to a struct, got ssmconfig.fakeConfig")
+	var nilConfig *fakeConfig
+	assert.NotNil(t, loader.Load(nilConfig))
+}
+
+type fakeZone struct {
+	name string
+}
+
+type fakeSchedule struct {
+	Start time.Time  `ssm:"start,required"`
+	End   *time.Time `ssm:"end"`
+	Zone  fakeZone   `ssm:"zone"`
+}
+
+func TestLoaderLoadTime(t *testing.T) {
+	schedule := &fakeSchedule{}
+	err := NewLoader(MapSource{
+		"start": "2019-05-01T09:00:00Z",
+		"end":   "2019-05-01T18:00:00+09:00",
+	}).Load(schedule)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2019, 5, 1, 9, 0, 0, 0, time.UTC), schedule.Start)
+	assert.Equal(t, time.Date(2019, 5, 1, 9, 0, 0, 0, time.UTC), schedule.End.UTC())
+	assert.Equal(t, fakeZone{}, schedule.Zone)
+
+	// a struct w/o exported fields is reported instead of ignored
+	err = NewLoader(MapSource{
+		"end":  "2019-05-01",
+		"zone": "Asia/Tokyo",
+	}).Load(&fakeSchedule{})
+	assert.EqualError(t, err, "invalid config: start is required; "+
+		"end: parsing time \"2019-05-01\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"\" as \"T\"; "+
+		"zone: unsupport type ssmconfig.fakeZone")
+}
+
+func TestLoadSSM(t *testing.T) {
+	svc := mock.SSMGetParams([][]string{{"/app/db/host", "/app/db/password"}},
+		[][]string{{"ssm.local", "secret"}}, []*string{nil})
+	config := &fakeConfig{}
+	err := LoadSSM(context.Background(), &awsapi.AWSAPI{}, svc, "/app",
+		"SSMCONFIG_TEST_", "../test/ssmconfig/config.json", config)
+	// replica is not configured
+	assert.EqualError(t, err, "invalid config: "+
+		"replica/host is required; replica/password is required")
+	assert.Equal(t, "ssm.local", config.DB.Host)
+	assert.Equal(t, uint16(8080), config.Port)
+
+	err = LoadSSM(context.Background(), &awsapi.AWSAPI{}, svc, "/app",
+		"SSMCONFIG_TEST_", "../test/ssmconfig/missing.json", config)
+	assert.NotNil(t, err)
+	err = LoadSSM(context.Background(), &awsapi.AWSAPI{}, mock.SSMGetParamsError(), "/app",
+		"SSMCONFIG_TEST_", "", config)
+	assert.NotNil(t, err)
+}
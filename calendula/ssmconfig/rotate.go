@@ -0,0 +1,312 @@
+package ssmconfig
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+)
+
+// Labels of the versions of a rotated secret. Consumers must read the name
+// w/ the current label, e.g. "/slack/token:current", since ssm returns the
+// latest version for a bare name, which may be pending or failed validation.
+const (
+	LabelCurrent  = "current"
+	LabelPending  = "pending"
+	LabelPrevious = "previous"
+)
+
+// Operations of rotations
+const (
+	OpRotate   = "rotate"
+	OpPromote  = "promote"
+	OpRollback = "rollback"
+)
+
+// Generator returns a new secret, e.g. a random password or a token issued by a provider
+type Generator func(ctx context.Context) (string, error)
+
+// Validator checks a secret works before it is promoted,
+// e.g. by calling slack auth.test w/ a token
+type Validator func(ctx context.Context, secret string) error
+
+// RandomSecret returns a Generator of url safe base64 secrets of size random bytes
+func RandomSecret(size int) Generator {
+	return func(ctx context.Context) (string, error) {
+		if size < 16 {
+			return "", fmt.Errorf("random secret must be at least 16 bytes, got %d", size)
+		}
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+			return "", err
+		}
+		return base64.RawURLEncoding.EncodeToString(buf), nil
+	}
+}
+
+// RotateOptions defines how a secret is rotated
+type RotateOptions struct {
+	// Secret is the new secret, it is generated by Generate if empty
+	Secret   string
+	Generate Generator
+	// Validate checks the pending secret, it is promoted w/o a check if nil
+	Validate Validator
+	// KeyID encrypts the new secret, the key of the current version if empty
+	KeyID string
+}
+
+func (opts *RotateOptions) secret(ctx context.Context) (string, error) {
+	if opts.Secret != "" {
+		return opts.Secret, nil
+	}
+	if opts.Generate == nil {
+		return "", fmt.Errorf("either a secret or a generator must be given")
+	}
+	secret, err := opts.Generate(ctx)
+	if err != nil {
+		return "", err
+	}
+	if secret == "" {
+		return "", fmt.Errorf("generated secret is empty")
+	}
+	return secret, nil
+}
+
+// RotateResult holds the labeled versions of a secret after an operation,
+// a version is 0 if it is not labeled. Pending is 0 once it is promoted,
+// though the promoted version keeps the pending label.
+type RotateResult struct {
+	Op       string `json:"op"`
+	Name     string `json:"name"`
+	Current  int64  `json:"current"`
+	Pending  int64  `json:"pending"`
+	Previous int64  `json:"previous"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Render writes a human readable summary of the result
+func (result *RotateResult) Render(w io.Writer) {
+	pending := "none"
+	if result.Pending != 0 {
+		pending = strconv.FormatInt(result.Pending, 10)
+	}
+	fmt.Fprintf(w, "%s %s: current %d, pending %s, previous %d\n",
+		result.Op, result.Name, result.Current, pending, result.Previous)
+	if result.Error != "" {
+		fmt.Fprintf(w, "  error: %s\n", result.Error)
+	}
+}
+
+// secretVersions is the history of a secret, oldest first
+type secretVersions []awsapi.ParameterVersion
+
+func (versions secretVersions) labeled(label string) *awsapi.ParameterVersion {
+	for idx := range versions {
+		for _, versionLabel := range versions[idx].Labels {
+			if versionLabel == label {
+				return &versions[idx]
+			}
+		}
+	}
+	return nil
+}
+
+// current returns the current version,
+// the latest version is current if no version is labeled yet
+func (versions secretVersions) current() *awsapi.ParameterVersion {
+	if current := versions.labeled(LabelCurrent); current != nil {
+		return current
+	}
+	return &versions[len(versions)-1]
+}
+
+func (versions secretVersions) version(label string) int64 {
+	if version := versions.labeled(label); version != nil {
+		return version.Version
+	}
+	return 0
+}
+
+func getSecretVersions(ctx context.Context, api *awsapi.AWSAPI, svc ssmiface.SSMAPI,
+	name string) (secretVersions, error) {
+	versions, err := api.GetParameterHistoryWithContext(ctx, svc, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("parameter %s has no versions", name)
+	}
+	if current := secretVersions(versions).current(); current.Type != ssm.ParameterTypeSecureString {
+		return nil, fmt.Errorf("parameter %s is not a %s", name, ssm.ParameterTypeSecureString)
+	}
+	return versions, nil
+}
+
+// labelVersion attaches a label to a version, moving it from another version
+func labelVersion(ctx context.Context, api *awsapi.AWSAPI, svc ssmiface.SSMAPI,
+	name string, version int64, label string) error {
+	invalid, err := api.LabelParameterVersionWithContext(ctx, svc, name, version, []string{label})
+	if err != nil {
+		return err
+	}
+	if len(invalid) != 0 {
+		return fmt.Errorf("invalid labels %v of parameter %s", invalid, name)
+	}
+	return nil
+}
+
+// promote labels the current version previous and the pending one current
+func promote(ctx context.Context, api *awsapi.AWSAPI, svc ssmiface.SSMAPI,
+	result *RotateResult, current, pending int64) error {
+	if err := labelVersion(ctx, api, svc, result.Name, current, LabelPrevious); err != nil {
+		result.Error = err.Error()
+		return err
+	}
+	result.Previous = current
+	if err := labelVersion(ctx, api, svc, result.Name, pending, LabelCurrent); err != nil {
+		result.Error = err.Error()
+		return err
+	}
+	result.Current, result.Pending = pending, 0
+	log.Printf("SSM: promoted secret %s version %d, previous version %d\n",
+		result.Name, pending, current)
+	return nil
+}
+
+// validate checks a pending secret, a failure is recorded in the result
+func validate(ctx context.Context, check Validator, result *RotateResult, secret string) error {
+	if check == nil {
+		return nil
+	}
+	if err := check(ctx, secret); err != nil {
+		log.Printf("SSMError: pending secret %s version %d fails validation, err: %v\n",
+			result.Name, result.Pending, err)
+		err = fmt.Errorf("pending secret %s version %d fails validation, %v",
+			result.Name, result.Pending, err)
+		result.Error = err.Error()
+		return err
+	}
+	return nil
+}
+
+// RotateSecret writes a new version of a SecureString labeled pending,
+// validates it, then promotes it to current and labels the replaced version
+// previous for RollbackSecret. If the validation fails, the new version is
+// left pending and the current one is untouched. Secrets are never logged.
+func RotateSecret(ctx context.Context, api *awsapi.AWSAPI, svc ssmiface.SSMAPI,
+	name string, opts RotateOptions) (*RotateResult, error) {
+	versions, err := getSecretVersions(ctx, api, svc, name)
+	if err != nil {
+		return nil, err
+	}
+	current := versions.current()
+	secret, err := opts.secret(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if secret == current.Value {
+		return nil, fmt.Errorf("new secret of %s equals the current one", name)
+	}
+	// label the current version first, so it is not mistaken for the latest
+	if versions.labeled(LabelCurrent) == nil {
+		if err := labelVersion(ctx, api, svc, name, current.Version, LabelCurrent); err != nil {
+			return nil, err
+		}
+	}
+	keyID := opts.KeyID
+	if keyID == "" {
+		keyID = current.KeyID
+	}
+	pending, err := api.PutParameterWithOptionsWithContext(ctx, svc, name, secret,
+		awsapi.PutParameterOptions{
+			Type:        ssm.ParameterTypeSecureString,
+			KeyID:       keyID,
+			Description: current.Description,
+			Tier:        current.Tier,
+			Overwrite:   true,
+		})
+	if err != nil {
+		return nil, err
+	}
+	result := &RotateResult{
+		Op:       OpRotate,
+		Name:     name,
+		Current:  current.Version,
+		Pending:  pending,
+		Previous: versions.version(LabelPrevious),
+	}
+	if err := labelVersion(ctx, api, svc, name, pending, LabelPending); err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+	if err := validate(ctx, opts.Validate, result, secret); err != nil {
+		return result, err
+	}
+	return result, promote(ctx, api, svc, result, current.Version, pending)
+}
+
+// PromoteSecret promotes the pending version of a secret to current,
+// e.g. after a failed validation is fixed, it is validated again if check is set
+func PromoteSecret(ctx context.Context, api *awsapi.AWSAPI, svc ssmiface.SSMAPI,
+	name string, check Validator) (*RotateResult, error) {
+	versions, err := getSecretVersions(ctx, api, svc, name)
+	if err != nil {
+		return nil, err
+	}
+	current, pending := versions.current(), versions.labeled(LabelPending)
+	if pending == nil || pending.Version == current.Version {
+		return nil, fmt.Errorf("no pending version of %s to promote", name)
+	}
+	result := &RotateResult{
+		Op:       OpPromote,
+		Name:     name,
+		Current:  current.Version,
+		Pending:  pending.Version,
+		Previous: versions.version(LabelPrevious),
+	}
+	if err := validate(ctx, check, result, pending.Value); err != nil {
+		return result, err
+	}
+	return result, promote(ctx, api, svc, result, current.Version, pending.Version)
+}
+
+// RollbackSecret labels the previous version of a secret current again,
+// the rolled back version is labeled pending so it can be promoted later
+func RollbackSecret(ctx context.Context, api *awsapi.AWSAPI, svc ssmiface.SSMAPI,
+	name string) (*RotateResult, error) {
+	versions, err := getSecretVersions(ctx, api, svc, name)
+	if err != nil {
+		return nil, err
+	}
+	current, previous := versions.current(), versions.labeled(LabelPrevious)
+	if previous == nil || previous.Version == current.Version {
+		return nil, fmt.Errorf("no previous version of %s to roll back to", name)
+	}
+	result := &RotateResult{
+		Op:       OpRollback,
+		Name:     name,
+		Current:  current.Version,
+		Pending:  versions.version(LabelPending),
+		Previous: previous.Version,
+	}
+	if err := labelVersion(ctx, api, svc, name, current.Version, LabelPending); err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+	result.Pending = current.Version
+	if err := labelVersion(ctx, api, svc, name, previous.Version, LabelCurrent); err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+	result.Current = previous.Version
+	log.Printf("SSM: rolled back secret %s from version %d to %d\n",
+		name, current.Version, previous.Version)
+	return result, nil
+}
@@ -0,0 +1,161 @@
+package ssmconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+func fakeSecret() *mock.SSMAPI {
+	svc := mock.SSMParameterStore("/slack/channel=general")
+	svc.SetParameter("/slack/token", "xoxb-1", ssm.ParameterTypeSecureString)
+	return svc
+}
+
+func checkSecret(valid string) Validator {
+	return func(ctx context.Context, secret string) error {
+		if secret != valid {
+			return errors.New("invalid_auth")
+		}
+		return nil
+	}
+}
+
+func labelsOf(t *testing.T, svc *mock.SSMAPI, name string) map[string]int64 {
+	versions, err := (&awsapi.AWSAPI{}).GetParameterHistory(svc, name)
+	assert.Nil(t, err)
+	labels := map[string]int64{}
+	for _, version := range versions {
+		for _, label := range version.Labels {
+			labels[label] = version.Version
+		}
+	}
+	return labels
+}
+
+func TestRotateSecret(t *testing.T) {
+	svc := fakeSecret()
+	api := &awsapi.AWSAPI{}
+
+	result, err := RotateSecret(context.Background(), api, svc, "/slack/token",
+		RotateOptions{Secret: "xoxb-2", Validate: checkSecret("xoxb-2"), KeyID: "alias/slack"})
+	assert.Nil(t, err)
+	// the promoted version keeps the pending label but is not reported pending
+	assert.Equal(t, &RotateResult{Op: OpRotate, Name: "/slack/token",
+		Current: 2, Previous: 1}, result)
+	assert.Equal(t, map[string]int64{"current": 2, "pending": 2, "previous": 1},
+		labelsOf(t, svc, "/slack/token"))
+	assert.Equal(t, "alias/slack", aws.StringValue(svc.Parameter("/slack/token").KeyId))
+	info, err := api.GetParameter(svc, "/slack/token:current")
+	assert.Nil(t, err)
+	assert.Equal(t, "xoxb-2", info.Value)
+
+	// the key of the current version is kept
+	result, err = RotateSecret(context.Background(), api, svc, "/slack/token",
+		RotateOptions{Generate: RandomSecret(32)})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), result.Current)
+	assert.Equal(t, int64(2), result.Previous)
+	assert.Equal(t, "alias/slack", aws.StringValue(svc.Parameter("/slack/token").KeyId))
+	assert.Equal(t, 43, len(aws.StringValue(svc.Parameter("/slack/token").Value)))
+
+	var buf bytes.Buffer
+	result.Render(&buf)
+	assert.Equal(t, "rotate /slack/token: current 3, pending none, previous 2\n", buf.String())
+}
+
+func TestRotateSecretValidationFails(t *testing.T) {
+	svc := fakeSecret()
+	api := &awsapi.AWSAPI{}
+
+	result, err := RotateSecret(context.Background(), api, svc, "/slack/token",
+		RotateOptions{Secret: "xoxb-bad", Validate: checkSecret("xoxb-2")})
+	assert.EqualError(t, err, "pending secret /slack/token version 2 fails validation, invalid_auth")
+	assert.Equal(t, &RotateResult{Op: OpRotate, Name: "/slack/token",
+		Current: 1, Pending: 2, Error: err.Error()}, result)
+	// the current version is untouched
+	assert.Equal(t, map[string]int64{"current": 1, "pending": 2}, labelsOf(t, svc, "/slack/token"))
+	info, err := api.GetParameter(svc, "/slack/token:3")
+	assert.NotNil(t, err)
+	assert.Nil(t, info)
+
+	var buf bytes.Buffer
+	result.Render(&buf)
+	assert.Equal(t, "rotate /slack/token: current 1, pending 2, previous 0\n"+
+		"  error: pending secret /slack/token version 2 fails validation, invalid_auth\n", buf.String())
+
+	// promoted after revalidation
+	_, err = PromoteSecret(context.Background(), api, svc, "/slack/token", checkSecret("xoxb-2"))
+	assert.NotNil(t, err)
+	result, err = PromoteSecret(context.Background(), api, svc, "/slack/token", checkSecret("xoxb-bad"))
+	assert.Nil(t, err)
+	assert.Equal(t, &RotateResult{Op: OpPromote, Name: "/slack/token",
+		Current: 2, Previous: 1}, result)
+	_, err = PromoteSecret(context.Background(), api, svc, "/slack/token", nil)
+	assert.EqualError(t, err, "no pending version of /slack/token to promote")
+}
+
+func TestRollbackSecret(t *testing.T) {
+	svc := fakeSecret()
+	api := &awsapi.AWSAPI{}
+
+	_, err := RollbackSecret(context.Background(), api, svc, "/slack/token")
+	assert.EqualError(t, err, "no previous version of /slack/token to roll back to")
+
+	_, err = RotateSecret(context.Background(), api, svc, "/slack/token",
+		RotateOptions{Secret: "xoxb-2"})
+	assert.Nil(t, err)
+	result, err := RollbackSecret(context.Background(), api, svc, "/slack/token")
+	assert.Nil(t, err)
+	assert.Equal(t, &RotateResult{Op: OpRollback, Name: "/slack/token",
+		Current: 1, Pending: 2, Previous: 1}, result)
+	info, err := api.GetParameter(svc, "/slack/token:current")
+	assert.Nil(t, err)
+	assert.Equal(t, "xoxb-1", info.Value)
+
+	_, err = RollbackSecret(context.Background(), api, svc, "/slack/token")
+	assert.EqualError(t, err, "no previous version of /slack/token to roll back to")
+
+	// the rolled back version can be promoted again
+	result, err = PromoteSecret(context.Background(), api, svc, "/slack/token", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), result.Current)
+	assert.Equal(t, int64(1), result.Previous)
+}
+
+func TestRotateSecretError(t *testing.T) {
+	svc := fakeSecret()
+	api := &awsapi.AWSAPI{}
+	ctx := context.Background()
+
+	_, err := RotateSecret(ctx, api, svc, "/slack/missing", RotateOptions{Secret: "x"})
+	assert.NotNil(t, err)
+	_, err = RotateSecret(ctx, api, svc, "/slack/channel", RotateOptions{Secret: "x"})
+	assert.EqualError(t, err, "parameter /slack/channel is not a SecureString")
+	_, err = RotateSecret(ctx, api, svc, "/slack/token", RotateOptions{})
+	assert.EqualError(t, err, "either a secret or a generator must be given")
+	_, err = RotateSecret(ctx, api, svc, "/slack/token", RotateOptions{Secret: "xoxb-1"})
+	assert.EqualError(t, err, "new secret of /slack/token equals the current one")
+	_, err = RotateSecret(ctx, api, svc, "/slack/token", RotateOptions{Generate: RandomSecret(8)})
+	assert.EqualError(t, err, "random secret must be at least 16 bytes, got 8")
+	_, err = RotateSecret(ctx, api, svc, "/slack/token", RotateOptions{
+		Generate: func(ctx context.Context) (string, error) { return "", nil }})
+	assert.EqualError(t, err, "generated secret is empty")
+	_, err = PromoteSecret(ctx, api, svc, "/slack/missing", nil)
+	assert.NotNil(t, err)
+	_, err = RollbackSecret(ctx, api, svc, "/slack/missing")
+	assert.NotNil(t, err)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = RotateSecret(cancelled, api, svc, "/slack/token", RotateOptions{Secret: "xoxb-2"})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, int64(1), aws.Int64Value(svc.Parameter("/slack/token").Version))
+}
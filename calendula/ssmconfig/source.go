@@ -0,0 +1,147 @@
+package ssmconfig
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+	"github.com/xinnige/asteraceae/calendula/utils"
+)
+
+const ssmPageSize = 10
+
+// Source provides raw config values by a slash separated name, e.g. "db/port"
+type Source interface {
+	Lookup(name string) (string, bool)
+}
+
+// MapSource is a Source of static values
+type MapSource map[string]string
+
+// Lookup returns the value of a name
+func (source MapSource) Lookup(name string) (string, bool) {
+	value, ok := source[name]
+	return value, ok
+}
+
+// Names returns the sorted names of the source
+func (source MapSource) Names() []string {
+	names := make([]string, 0, len(source))
+	for name := range source {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RelativeName returns the name of a parameter relative to a path,
+// e.g. "/app/prod/db/port" under "/app/prod" is "db/port"
+func RelativeName(path, name string) string {
+	path = "/" + strings.Trim(path, "/")
+	if path == "/" {
+		return strings.TrimPrefix(name, "/")
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(name, path), "/")
+}
+
+// NewSSMSource fetches all parameters under path recursively,
+// SecureString values are decrypted. An empty path is not an error.
+func NewSSMSource(ctx context.Context, api *awsapi.AWSAPI, svc ssmiface.SSMAPI,
+	path string) (MapSource, error) {
+	params, err := fetchParameters(ctx, api, svc, path)
+	if err != nil {
+		return nil, err
+	}
+	source := make(MapSource)
+	for _, param := range params {
+		source[RelativeName(path, aws.StringValue(param.Name))] = aws.StringValue(param.Value)
+	}
+	return source, nil
+}
+
+// fetchParameters returns all parameters under path w/ decrypted values
+func fetchParameters(ctx context.Context, api *awsapi.AWSAPI, svc ssmiface.SSMAPI,
+	path string) ([]*ssm.Parameter, error) {
+	found := make([]*ssm.Parameter, 0)
+	var next *string
+	for {
+		params, token, err := api.GetParametersByPathIterWithContext(ctx, svc,
+			path, next, true, ssmPageSize)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, params...)
+		if token == nil {
+			return found, nil
+		}
+		next = token
+	}
+}
+
+// LoadFileSource reads a json object as a source,
+// nested objects are joined by "/" and arrays by ","
+func LoadFileSource(filename string, siface utils.SerialInterface) (MapSource, error) {
+	content, err := utils.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	object := make(map[string]interface{})
+	if err := siface.Unmarshal(content, &object); err != nil {
+		return nil, err
+	}
+	source := make(MapSource)
+	flatten(source, "", object)
+	return source, nil
+}
+
+func flatten(source MapSource, prefix string, object map[string]interface{}) {
+	for key, value := range object {
+		name := prefix + key
+		switch value := value.(type) {
+		case map[string]interface{}:
+			flatten(source, name+"/", value)
+		case []interface{}:
+			items := make([]string, len(value))
+			for idx, item := range value {
+				items[idx] = scalar(item)
+			}
+			source[name] = strings.Join(items, ",")
+		case nil:
+		default:
+			source[name] = scalar(value)
+		}
+	}
+}
+
+// scalar formats a json value, numbers w/o exponents
+func scalar(value interface{}) string {
+	if number, ok := value.(float64); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// EnvSource looks up environment variables named by Prefix and the upper case
+// name w/ "/", "-" and "." replaced by "_", e.g. "db/port" => APP_DB_PORT
+type EnvSource struct {
+	Prefix string
+}
+
+var envReplacer = strings.NewReplacer("/", "_", "-", "_", ".", "_")
+
+// EnvName returns the environment variable of a name
+func (source EnvSource) EnvName(name string) string {
+	return source.Prefix + strings.ToUpper(envReplacer.Replace(name))
+}
+
+// Lookup returns the value of the environment variable of a name
+func (source EnvSource) Lookup(name string) (string, bool) {
+	return os.LookupEnv(source.EnvName(name))
+}
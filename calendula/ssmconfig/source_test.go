@@ -0,0 +1,73 @@
+package ssmconfig
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+	"github.com/xinnige/asteraceae/calendula/mock"
+	"github.com/xinnige/asteraceae/calendula/utils"
+)
+
+func TestRelativeName(t *testing.T) {
+	assert.Equal(t, "db/port", RelativeName("/app/prod", "/app/prod/db/port"))
+	assert.Equal(t, "db/port", RelativeName("/app/prod/", "/app/prod/db/port"))
+	assert.Equal(t, "app/prod", RelativeName("/", "/app/prod"))
+}
+
+func TestNewSSMSource(t *testing.T) {
+	svc := mock.SSMGetParams(
+		[][]string{{"/app/prod/port", "/app/prod/db/host"}, {"/app/prod/hosts"}},
+		[][]string{{"8080", "db.local"}, {"a.local,b.local"}},
+		[]*string{aws.String("next"), nil})
+	source, err := NewSSMSource(context.Background(), &awsapi.AWSAPI{}, svc, "/app/prod")
+	assert.Nil(t, err)
+	assert.Equal(t, MapSource{
+		"port":    "8080",
+		"db/host": "db.local",
+		"hosts":   "a.local,b.local",
+	}, source)
+	assert.Equal(t, []string{"db/host", "hosts", "port"}, source.Names())
+
+	// an empty path is an empty source
+	source, err = NewSSMSource(context.Background(), &awsapi.AWSAPI{},
+		mock.SSMGetParams([][]string{{}}, [][]string{{}}, []*string{nil}), "/app/dev")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(source))
+
+	_, err = NewSSMSource(context.Background(), &awsapi.AWSAPI{}, mock.SSMGetParamsError(), "/app")
+	assert.NotNil(t, err)
+}
+
+func TestLoadFileSource(t *testing.T) {
+	source, err := LoadFileSource("../test/ssmconfig/config.json", &utils.JSONAPI{})
+	assert.Nil(t, err)
+	assert.Equal(t, MapSource{
+		"port":         "8080",
+		"debug":        "true",
+		"hosts":        "a.local,b.local",
+		"db/host":      "db.local",
+		"db/max_conns": "1000000",
+	}, source)
+
+	_, err = LoadFileSource("../test/ssmconfig/missing.json", &utils.JSONAPI{})
+	assert.NotNil(t, err)
+	_, err = LoadFileSource("../test/auth0/directory.csv", &utils.JSONAPI{})
+	assert.NotNil(t, err)
+}
+
+func TestEnvSource(t *testing.T) {
+	source := EnvSource{Prefix: "SSMCONFIG_TEST_"}
+	assert.Equal(t, "SSMCONFIG_TEST_DB_MAX_CONNS", source.EnvName("db/max-conns"))
+
+	os.Setenv("SSMCONFIG_TEST_DB_HOST", "env.local")
+	defer os.Unsetenv("SSMCONFIG_TEST_DB_HOST")
+	value, ok := source.Lookup("db/host")
+	assert.True(t, ok)
+	assert.Equal(t, "env.local", value)
+	_, ok = source.Lookup("db/port")
+	assert.False(t, ok)
+}
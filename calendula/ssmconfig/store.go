@@ -0,0 +1,286 @@
+package ssmconfig
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+)
+
+const (
+	deEventBuffer     = 16
+	deRefreshInterval = time.Minute
+)
+
+// Parameter defines a cached parameter, Name is relative to the store path
+type Parameter struct {
+	Name         string    `json:"name"`
+	Value        string    `json:"value"`
+	Type         string    `json:"type"`
+	Version      int64     `json:"version"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// ChangeEvent defines a change found by a refresh,
+// Old is nil for an added parameter and New is nil for a removed one
+type ChangeEvent struct {
+	Name string     `json:"name"`
+	Old  *Parameter `json:"old"`
+	New  *Parameter `json:"new"`
+}
+
+type subscription struct {
+	names  map[string]bool
+	events chan ChangeEvent
+}
+
+// Store caches the parameters of a path in memory,
+// e.g. in a global variable of a lambda to survive warm invocations.
+// Reads after TTL refresh the cache, a failed refresh keeps serving
+// the stale values so a throttled ssm does not fail callers.
+type Store struct {
+	AWSAPI *awsapi.AWSAPI
+	SSMAPI ssmiface.SSMAPI
+	Path   string
+	// TTL is the lifetime of the cache, values never expire if 0
+	TTL time.Duration
+	// Now returns the current time, time.Now if nil
+	Now func() time.Time
+
+	// refresh serializes refreshes, so concurrent cold reads call ssm once
+	refresh  sync.Mutex
+	mutex    sync.RWMutex
+	params   map[string]Parameter
+	loadedAt time.Time
+	subs     map[*subscription]bool
+}
+
+// NewStore returns a *Store of a path
+func NewStore(api *awsapi.AWSAPI, svc ssmiface.SSMAPI, path string, ttl time.Duration) *Store {
+	return &Store{
+		AWSAPI: api,
+		SSMAPI: svc,
+		Path:   path,
+		TTL:    ttl,
+		subs:   make(map[*subscription]bool),
+	}
+}
+
+func (store *Store) now() time.Time {
+	if store.Now != nil {
+		return store.Now()
+	}
+	return time.Now()
+}
+
+// stale checks if the cache needs a refresh, must be called w/ mutex locked
+func (store *Store) stale() bool {
+	if store.params == nil {
+		return true
+	}
+	return store.TTL > 0 && store.now().Sub(store.loadedAt) >= store.TTL
+}
+
+// Refresh reloads all parameters and notifies subscribers of changes
+func (store *Store) Refresh(ctx context.Context) error {
+	store.refresh.Lock()
+	defer store.refresh.Unlock()
+	return store.reload(ctx)
+}
+
+// reload must be called w/ refresh locked
+func (store *Store) reload(ctx context.Context) error {
+	fetched, err := fetchParameters(ctx, store.AWSAPI, store.SSMAPI, store.Path)
+	if err != nil {
+		return err
+	}
+	params := make(map[string]Parameter, len(fetched))
+	for _, param := range fetched {
+		name := RelativeName(store.Path, aws.StringValue(param.Name))
+		params[name] = Parameter{
+			Name:         name,
+			Value:        aws.StringValue(param.Value),
+			Type:         aws.StringValue(param.Type),
+			Version:      aws.Int64Value(param.Version),
+			LastModified: aws.TimeValue(param.LastModifiedDate),
+		}
+	}
+
+	store.mutex.Lock()
+	old := store.params
+	store.params = params
+	store.loadedAt = store.now()
+	store.mutex.Unlock()
+
+	// the first load is not a change
+	if old != nil {
+		store.notify(diffParameters(old, params))
+	}
+	return nil
+}
+
+// diffParameters returns the changes from old to fresh params sorted by name
+func diffParameters(old, fresh map[string]Parameter) []ChangeEvent {
+	events := make([]ChangeEvent, 0)
+	for name, param := range fresh {
+		param := param
+		prev, ok := old[name]
+		if !ok {
+			events = append(events, ChangeEvent{Name: name, New: &param})
+			continue
+		}
+		if prev.Version != param.Version || prev.Value != param.Value {
+			events = append(events, ChangeEvent{Name: name, Old: &prev, New: &param})
+		}
+	}
+	for name, param := range old {
+		param := param
+		if _, ok := fresh[name]; !ok {
+			events = append(events, ChangeEvent{Name: name, Old: &param})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Name < events[j].Name })
+	return events
+}
+
+// notify sends events to subscribers w/o blocking,
+// events are dropped if the buffer of a subscriber is full
+func (store *Store) notify(events []ChangeEvent) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	for sub := range store.subs {
+		for _, event := range events {
+			if len(sub.names) != 0 && !sub.names[event.Name] {
+				continue
+			}
+			select {
+			case sub.events <- event:
+			default:
+				log.Printf("SSMError: drop change event of %s, subscriber is full", event.Name)
+			}
+		}
+	}
+}
+
+// ensure refreshes the cache if stale, the stale values are kept on errors
+func (store *Store) ensure(ctx context.Context) error {
+	store.mutex.RLock()
+	stale, loaded := store.stale(), store.params != nil
+	store.mutex.RUnlock()
+	if !stale {
+		return nil
+	}
+
+	store.refresh.Lock()
+	defer store.refresh.Unlock()
+	// another caller may have refreshed while waiting
+	store.mutex.RLock()
+	stale = store.stale()
+	store.mutex.RUnlock()
+	if !stale {
+		return nil
+	}
+	err := store.reload(ctx)
+	if err != nil && loaded {
+		log.Printf("SSMError: fail to refresh %s, serve stale values, err: %v\n", store.Path, err)
+		return nil
+	}
+	return err
+}
+
+// Get returns a parameter by name, refreshing the cache if stale
+func (store *Store) Get(ctx context.Context, name string) (Parameter, bool, error) {
+	if err := store.ensure(ctx); err != nil {
+		return Parameter{}, false, err
+	}
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	param, ok := store.params[name]
+	return param, ok, nil
+}
+
+// Value returns the value of a parameter, an error if not found
+func (store *Store) Value(ctx context.Context, name string) (string, error) {
+	param, ok, err := store.Get(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("parameter %s not found under %s", name, store.Path)
+	}
+	return param.Value, nil
+}
+
+// Version returns the version of a cached parameter, 0 if not found
+func (store *Store) Version(name string) int64 {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.params[name].Version
+}
+
+// Lookup returns a cached value, so a Store is a Source of a Loader.
+// It does not refresh, call Refresh before loading.
+func (store *Store) Lookup(name string) (string, bool) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	param, ok := store.params[name]
+	return param.Value, ok
+}
+
+// Subscribe returns a channel of changes of names (all if empty),
+// and a function to unsubscribe which closes the channel
+func (store *Store) Subscribe(names ...string) (<-chan ChangeEvent, func()) {
+	sub := &subscription{
+		names:  make(map[string]bool),
+		events: make(chan ChangeEvent, deEventBuffer),
+	}
+	for _, name := range names {
+		sub.names[name] = true
+	}
+	store.mutex.Lock()
+	if store.subs == nil {
+		store.subs = make(map[*subscription]bool)
+	}
+	store.subs[sub] = true
+	store.mutex.Unlock()
+
+	var once sync.Once
+	return sub.events, func() {
+		once.Do(func() {
+			store.mutex.Lock()
+			delete(store.subs, sub)
+			store.mutex.Unlock()
+			close(sub.events)
+		})
+	}
+}
+
+// Run refreshes the cache every interval until the context is done,
+// errors are logged and the next tick retries. The interval defaults
+// to TTL if not positive, or to a minute if values never expire.
+func (store *Store) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = store.TTL
+	}
+	if interval <= 0 {
+		interval = deRefreshInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := store.Refresh(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("SSMError: fail to refresh %s, err: %v\n", store.Path, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package ssmconfig
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/awsapi"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+// fakeClock is a manual clock of a store
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.now
+}
+
+func (clock *fakeClock) Add(d time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.now = clock.now.Add(d)
+}
+
+func TestStoreGet(t *testing.T) {
+	svc := mock.SSMParameterStore("/app/prod/db/host=db.local", "/app/dev/db/host=dev.local")
+	svc.SetParameter("/app/prod/slack/token", "xoxb-1", ssm.ParameterTypeSecureString)
+	clock := &fakeClock{now: time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewStore(&awsapi.AWSAPI{}, svc, "/app/prod", time.Minute)
+	store.Now = clock.Now
+
+	param, ok, err := store.Get(context.Background(), "slack/token")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Parameter{Name: "slack/token", Value: "xoxb-1", Type: "SecureString",
+		Version: 1, LastModified: time.Date(2019, 7, 1, 0, 1, 0, 0, time.UTC)}, param)
+	_, ok, err = store.Get(context.Background(), "missing")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	value, err := store.Value(context.Background(), "db/host")
+	assert.Nil(t, err)
+	assert.Equal(t, "db.local", value)
+	_, err = store.Value(context.Background(), "missing")
+	assert.EqualError(t, err, "parameter missing not found under /app/prod")
+	assert.Equal(t, 1, svc.GetCalls())
+
+	// cached until TTL
+	svc.SetParameter("/app/prod/slack/token", "xoxb-2", ssm.ParameterTypeSecureString)
+	clock.Add(59 * time.Second)
+	value, _ = store.Value(context.Background(), "slack/token")
+	assert.Equal(t, "xoxb-1", value)
+	clock.Add(time.Second)
+	value, _ = store.Value(context.Background(), "slack/token")
+	assert.Equal(t, "xoxb-2", value)
+	assert.Equal(t, int64(2), store.Version("slack/token"))
+	assert.Equal(t, 2, svc.GetCalls())
+
+	// stale values are served if a refresh fails
+	svc.SetGetError(errors.New("ThrottlingException"))
+	clock.Add(time.Minute)
+	value, err = store.Value(context.Background(), "slack/token")
+	assert.Nil(t, err)
+	assert.Equal(t, "xoxb-2", value)
+	assert.NotNil(t, store.Refresh(context.Background()))
+
+	// a Store is a Source
+	loaded, ok := store.Lookup("db/host")
+	assert.True(t, ok)
+	assert.Equal(t, "db.local", loaded)
+	assert.Equal(t, int64(0), store.Version("missing"))
+}
+
+func TestStoreGetError(t *testing.T) {
+	svc := mock.SSMParameterStore()
+	svc.SetGetError(errors.New("ThrottlingException"))
+	store := NewStore(&awsapi.AWSAPI{}, svc, "/app", 0)
+	_, _, err := store.Get(context.Background(), "db/host")
+	assert.EqualError(t, err, "ThrottlingException")
+	_, err = store.Value(context.Background(), "db/host")
+	assert.NotNil(t, err)
+}
+
+func TestStoreConcurrentGet(t *testing.T) {
+	svc := mock.SSMParameterStore("/app/db/host=db.local")
+	store := NewStore(&awsapi.AWSAPI{}, svc, "/app", time.Hour)
+	var wg sync.WaitGroup
+	for idx := 0; idx < 10; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := store.Value(context.Background(), "db/host")
+			assert.Nil(t, err)
+			assert.Equal(t, "db.local", value)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, svc.GetCalls())
+}
+
+func TestStoreSubscribe(t *testing.T) {
+	svc := mock.SSMParameterStore("/app/db/host=db.local", "/app/slack/token=xoxb-1")
+	store := NewStore(&awsapi.AWSAPI{}, svc, "/app", 0)
+	assert.Nil(t, store.Refresh(context.Background()))
+
+	tokens, unsubscribe := store.Subscribe("slack/token")
+	all, unsubscribeAll := store.Subscribe()
+	defer unsubscribeAll()
+
+	svc.SetParameter("/app/slack/token", "xoxb-2", ssm.ParameterTypeString)
+	svc.SetParameter("/app/db/port", "5432", ssm.ParameterTypeString)
+	svc.RemoveParameter("/app/db/host")
+	assert.Nil(t, store.Refresh(context.Background()))
+
+	event := <-tokens
+	assert.Equal(t, "slack/token", event.Name)
+	assert.Equal(t, "xoxb-1", event.Old.Value)
+	assert.Equal(t, "xoxb-2", event.New.Value)
+	assert.Equal(t, int64(2), event.New.Version)
+	assert.Equal(t, 0, len(tokens))
+
+	event = <-all
+	assert.Equal(t, "db/host", event.Name)
+	assert.Nil(t, event.New)
+	event = <-all
+	assert.Equal(t, "db/port", event.Name)
+	assert.Nil(t, event.Old)
+	event = <-all
+	assert.Equal(t, "slack/token", event.Name)
+
+	// no change, no event
+	assert.Nil(t, store.Refresh(context.Background()))
+	assert.Equal(t, 0, len(all))
+
+	unsubscribe()
+	unsubscribe()
+	_, open := <-tokens
+	assert.False(t, open)
+}
+
+func TestStoreRun(t *testing.T) {
+	svc := mock.SSMParameterStore("/app/slack/token=xoxb-1")
+	store := NewStore(&awsapi.AWSAPI{}, svc, "/app", 0)
+	assert.Nil(t, store.Refresh(context.Background()))
+	tokens, unsubscribe := store.Subscribe("slack/token")
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		store.Run(ctx, time.Millisecond)
+		close(done)
+	}()
+	svc.SetParameter("/app/slack/token", "xoxb-2", ssm.ParameterTypeString)
+	event := <-tokens
+	assert.Equal(t, "xoxb-2", event.New.Value)
+	cancel()
+	<-done
+}
+
+func TestStoreLiteral(t *testing.T) {
+	svc := mock.SSMParameterStore("/app/slack/token=xoxb-1")
+	store := &Store{AWSAPI: &awsapi.AWSAPI{}, SSMAPI: svc, Path: "/app"}
+	tokens, unsubscribe := store.Subscribe("slack/token")
+	defer unsubscribe()
+	assert.Nil(t, store.Refresh(context.Background()))
+	svc.SetParameter("/app/slack/token", "xoxb-2", ssm.ParameterTypeString)
+	assert.Nil(t, store.Refresh(context.Background()))
+	event := <-tokens
+	assert.Equal(t, "xoxb-2", event.New.Value)
+
+	// a non positive interval falls back to a default
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	store.Run(ctx, 0)
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Algorithms of authenticated ciphertexts
+const (
+	CipherAESGCM byte = 1
+)
+
+const cipherVersion byte = 1
+
+// cipherMagic starts a versioned ciphertext:
+// magic, version, algorithm, nonce, then the sealed text w/ its tag
+var cipherMagic = []byte("AEAD")
+
+// cipherHeaderSize is the size of magic, version and algorithm
+var cipherHeaderSize = len(cipherMagic) + 2
+
+// ErrInvalidCiphertext is returned if a ciphertext is truncated, tampered,
+// or decrypted w/ a wrong key or associated data
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// IsVersionedCiphertext checks if a ciphertext has the header of EncryptTextGCM,
+// a legacy ciphertext of EncryptTextAES starts w/ a random iv instead
+func IsVersionedCiphertext(cipherText []byte) bool {
+	return len(cipherText) >= cipherHeaderSize && bytes.HasPrefix(cipherText, cipherMagic)
+}
+
+// EncryptTextGCM encrypts plain text w/ AES-GCM, the associated data is
+// authenticated but not encrypted and must be given again to decrypt
+func EncryptTextGCM(key []byte, plainText []byte, aad []byte,
+	ioiface IOInterface) ([]byte, error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		log.Printf("Error: cannot encrypt AES-GCM, err: %+v\n", err)
+		return nil, err
+	}
+	header := append(append([]byte{}, cipherMagic...), cipherVersion, CipherAESGCM)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := ioiface.ReadFull(rand.Reader, nonce); err != nil {
+		log.Printf("Error: cannot encrypt AES-GCM, err: %+v\n", err)
+		return nil, err
+	}
+	cipherText := append(append([]byte{}, header...), nonce...)
+	return aead.Seal(cipherText, nonce, plainText, append(header, aad...)), nil
+}
+
+// DecryptTextGCM decrypts a ciphertext of EncryptTextGCM,
+// ErrInvalidCiphertext is returned if it fails authentication
+func DecryptTextGCM(key []byte, cipherText []byte, aad []byte) ([]byte, error) {
+	if !IsVersionedCiphertext(cipherText) {
+		return nil, ErrInvalidCiphertext
+	}
+	header := cipherText[:cipherHeaderSize]
+	version, algorithm := header[len(cipherMagic)], header[len(cipherMagic)+1]
+	if version != cipherVersion {
+		return nil, fmt.Errorf("unsupport ciphertext version %d", version)
+	}
+	if algorithm != CipherAESGCM {
+		return nil, fmt.Errorf("unsupport ciphertext algorithm %d", algorithm)
+	}
+	aead, err := newAESGCM(key)
+	if err != nil {
+		log.Printf("Error: cannot decrypt AES-GCM, err: %+v\n", err)
+		return nil, err
+	}
+	body := cipherText[cipherHeaderSize:]
+	if len(body) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrInvalidCiphertext
+	}
+	nonce := body[:aead.NonceSize()]
+	plainText, err := aead.Open(nil, nonce, body[aead.NonceSize():],
+		append(append([]byte{}, header...), aad...))
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plainText, nil
+}
+
+// EncryptTextGCMEncoded encrypts plain text w/ a base64 AES key,
+// and returns the base64 ciphertext
+func EncryptTextGCMEncoded(encodeKey string, plainText []byte, aad []byte,
+	ioiface IOInterface) (string, error) {
+	plainKey := DecodeBase64(encodeKey)
+	if plainKey == nil {
+		return "", fmt.Errorf("invalid base64 key")
+	}
+	cipherText, err := EncryptTextGCM(plainKey, plainText, aad, ioiface)
+	if err != nil {
+		return "", err
+	}
+	return EncodeBase64(cipherText), nil
+}
+
+// DecryptTextGCMEncoded decrypts a base64 ciphertext of EncryptTextGCMEncoded
+func DecryptTextGCMEncoded(encodeKey string, cipherText string, aad []byte) ([]byte, error) {
+	plainKey := DecodeBase64(encodeKey)
+	if plainKey == nil {
+		return nil, fmt.Errorf("invalid base64 key")
+	}
+	decoded := DecodeBase64(cipherText)
+	if decoded == nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return DecryptTextGCM(plainKey, decoded, aad)
+}
+
+// DecryptTextCompat decrypts a versioned ciphertext, or a legacy one of
+// EncryptTextAES w/o a header, legacy is set for the latter so it can be
+// re-encrypted. Legacy ciphertexts are not authenticated and aad is not
+// checked, a versioned ciphertext never falls back to the legacy cipher.
+func DecryptTextCompat(key []byte, cipherText []byte, aad []byte) ([]byte, bool, error) {
+	if IsVersionedCiphertext(cipherText) {
+		plainText, err := DecryptTextGCM(key, cipherText, aad)
+		return plainText, false, err
+	}
+	if _, err := aes.NewCipher(key); err != nil {
+		return nil, true, err
+	}
+	if len(cipherText) <= aes.BlockSize {
+		return nil, true, ErrInvalidCiphertext
+	}
+	return DecryptTextAES(key, cipherText), true, nil
+}
+
+// MigrateTextAES re-encrypts a legacy ciphertext of EncryptTextAES w/
+// EncryptTextGCM, versioned ciphertexts are verified and returned as is
+func MigrateTextAES(key []byte, cipherText []byte, aad []byte,
+	ioiface IOInterface) ([]byte, error) {
+	plainText, legacy, err := DecryptTextCompat(key, cipherText, aad)
+	if err != nil {
+		return nil, err
+	}
+	if !legacy {
+		return cipherText, nil
+	}
+	return EncryptTextGCM(key, plainText, aad, ioiface)
+}
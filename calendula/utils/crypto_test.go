@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+func TestEncryptTextGCM(t *testing.T) {
+	key := DecodeBase64("DfdjIjQDhx9Nh5aWA13fuQQsIGsk8yYUWrm4dbPwokA=")
+	plainText := []byte("plain text with several words")
+	aad := []byte("user-1")
+	cipherText, err := EncryptTextGCM(key, plainText, aad, &CommonAPI{})
+	assert.Nil(t, err)
+	// header 6, nonce 12, text 29, tag 16
+	assert.Equal(t, 63, len(cipherText))
+	assert.True(t, IsVersionedCiphertext(cipherText))
+	assert.Equal(t, []byte{'A', 'E', 'A', 'D', 1, CipherAESGCM}, cipherText[:6])
+
+	decrypted, err := DecryptTextGCM(key, cipherText, aad)
+	assert.Nil(t, err)
+	assert.Equal(t, plainText, decrypted)
+
+	// wrong aad, key or tampered text fails
+	_, err = DecryptTextGCM(key, cipherText, []byte("user-2"))
+	assert.Equal(t, ErrInvalidCiphertext, err)
+	_, err = DecryptTextGCM(make([]byte, 32), cipherText, aad)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+	tampered := append([]byte{}, cipherText...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = DecryptTextGCM(key, tampered, aad)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+	_, err = DecryptTextGCM(key, cipherText[:30], aad)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+	_, err = DecryptTextGCM(key, []byte("plain"), aad)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+
+	// the header is authenticated
+	tampered = append([]byte{}, cipherText...)
+	tampered[4] = 2
+	_, err = DecryptTextGCM(key, tampered, aad)
+	assert.EqualError(t, err, "unsupport ciphertext version 2")
+	tampered[4], tampered[5] = 1, 9
+	_, err = DecryptTextGCM(key, tampered, aad)
+	assert.EqualError(t, err, "unsupport ciphertext algorithm 9")
+	_, err = DecryptTextGCM([]byte("00000"), cipherText, aad)
+	assert.NotNil(t, err)
+}
+
+func TestEncryptTextGCMError(t *testing.T) {
+	_, err := EncryptTextGCM([]byte("00000"), []byte("plain"), nil, &CommonAPI{})
+	assert.NotNil(t, err)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockIOIface := mock.NewMockIOInterface(mockCtrl)
+	mockIOIface.EXPECT().ReadFull(gomock.Any(), gomock.Any()).Return(
+		0, errors.New("FakeIOError")).Times(1)
+	_, err = EncryptTextGCM([]byte("1234567890123456"), []byte("plain"), nil, mockIOIface)
+	assert.EqualError(t, err, "FakeIOError")
+}
+
+func TestEncryptTextGCMEncoded(t *testing.T) {
+	encoded := "DfdjIjQDhx9Nh5aWA13fuQQsIGsk8yYUWrm4dbPwokA="
+	cipherText, err := EncryptTextGCMEncoded(encoded, []byte("plain"), nil, &CommonAPI{})
+	assert.Nil(t, err)
+	plainText, err := DecryptTextGCMEncoded(encoded, cipherText, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "plain", string(plainText))
+
+	_, err = EncryptTextGCMEncoded("XXXXX", []byte("plain"), nil, &CommonAPI{})
+	assert.EqualError(t, err, "invalid base64 key")
+	_, err = EncryptTextGCMEncoded("MDAwMDA=", []byte("plain"), nil, &CommonAPI{})
+	assert.NotNil(t, err)
+	_, err = DecryptTextGCMEncoded("XXXXX", cipherText, nil)
+	assert.EqualError(t, err, "invalid base64 key")
+	_, err = DecryptTextGCMEncoded(encoded, "XXXXX", nil)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+}
+
+func TestDecryptTextCompat(t *testing.T) {
+	key := DecodeBase64("DfdjIjQDhx9Nh5aWA13fuQQsIGsk8yYUWrm4dbPwokA=")
+	legacyText := DecodeBase64("Wu5g0oSKIU/o91EClgB8qSRV2XlVdCGHqPKWwPuXDumoXvqO2unVKWo6t10S")
+	plainText, legacy, err := DecryptTextCompat(key, legacyText, nil)
+	assert.Nil(t, err)
+	assert.True(t, legacy)
+	assert.Equal(t, "plain text with several words", string(plainText))
+
+	cipherText, _ := EncryptTextGCM(key, []byte("plain"), []byte("aad"), &CommonAPI{})
+	plainText, legacy, err = DecryptTextCompat(key, cipherText, []byte("aad"))
+	assert.Nil(t, err)
+	assert.False(t, legacy)
+	assert.Equal(t, "plain", string(plainText))
+	// a versioned ciphertext never falls back to the legacy cipher
+	_, legacy, err = DecryptTextCompat(key, cipherText, nil)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+	assert.False(t, legacy)
+
+	_, _, err = DecryptTextCompat(key, []byte("short"), nil)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+	_, _, err = DecryptTextCompat([]byte("00000"), legacyText, nil)
+	assert.NotNil(t, err)
+}
+
+func TestMigrateTextAES(t *testing.T) {
+	key := DecodeBase64("DfdjIjQDhx9Nh5aWA13fuQQsIGsk8yYUWrm4dbPwokA=")
+	legacyText := DecodeBase64("Wu5g0oSKIU/o91EClgB8qSRV2XlVdCGHqPKWwPuXDumoXvqO2unVKWo6t10S")
+	migrated, err := MigrateTextAES(key, legacyText, []byte("aad"), &CommonAPI{})
+	assert.Nil(t, err)
+	assert.True(t, IsVersionedCiphertext(migrated))
+	plainText, err := DecryptTextGCM(key, migrated, []byte("aad"))
+	assert.Nil(t, err)
+	assert.Equal(t, "plain text with several words", string(plainText))
+
+	again, err := MigrateTextAES(key, migrated, []byte("aad"), &CommonAPI{})
+	assert.Nil(t, err)
+	assert.Equal(t, migrated, again)
+	_, err = MigrateTextAES(key, migrated, nil, &CommonAPI{})
+	assert.Equal(t, ErrInvalidCiphertext, err)
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+// RunPool calls fn w/ 0..count-1 using at most concurrency goroutines,
+// it stops scheduling once the context is cancelled
+func RunPool(ctx context.Context, concurrency int, count int, fn func(idx int)) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency && worker < count; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				fn(idx)
+			}
+		}()
+	}
+schedule:
+	for idx := 0; idx < count; idx++ {
+		select {
+		case jobs <- idx:
+		case <-ctx.Done():
+			break schedule
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunPool(t *testing.T) {
+	var count, running, peak int32
+	RunPool(context.Background(), 3, 20, func(idx int) {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+				break
+			}
+		}
+		atomic.AddInt32(&count, 1)
+		atomic.AddInt32(&running, -1)
+	})
+	assert.Equal(t, int32(20), count)
+	assert.True(t, peak <= 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	count = 0
+	RunPool(ctx, 2, 20, func(idx int) { atomic.AddInt32(&count, 1) })
+	assert.True(t, count < 20)
+}
@@ -0,0 +1,352 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// StreamChunkSize is the default size of plain chunks of a stream
+const StreamChunkSize = 64 << 10
+
+// maxStreamChunkSize limits chunk sizes read from a stream header
+const maxStreamChunkSize = 16 << 20
+
+const streamNoncePrefixSize = 7
+
+// streamMagic starts an encrypted stream:
+// magic, version, algorithm, chunk size, nonce prefix, then the sealed chunks
+var streamMagic = []byte("AEAS")
+
+var streamHeaderSize = len(streamMagic) + 2 + 4 + streamNoncePrefixSize
+
+// ErrStreamClosed is returned by writes after Close
+var ErrStreamClosed = errors.New("write to closed stream")
+
+// streamCipher seals and opens chunks of a stream in order,
+// the nonce of a chunk is the nonce prefix, its counter and a final flag,
+// so chunks can not be reordered, and a stream can not be truncated
+type streamCipher struct {
+	aead      cipher.AEAD
+	header    []byte
+	aad       []byte
+	chunkSize int
+	counter   uint32
+	exhausted bool
+}
+
+func newStreamCipher(key []byte, header []byte, aad []byte) (*streamCipher, error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &streamCipher{
+		aead:      aead,
+		header:    header,
+		aad:       append(append([]byte{}, header...), aad...),
+		chunkSize: int(binary.BigEndian.Uint32(header[len(streamMagic)+2:])),
+	}, nil
+}
+
+func (sc *streamCipher) nonce(final bool) ([]byte, error) {
+	if sc.exhausted {
+		return nil, fmt.Errorf("stream exceeds %d chunks", uint64(math.MaxUint32)+1)
+	}
+	nonce := make([]byte, sc.aead.NonceSize())
+	copy(nonce, sc.header[streamHeaderSize-streamNoncePrefixSize:])
+	binary.BigEndian.PutUint32(nonce[streamNoncePrefixSize:], sc.counter)
+	if final {
+		nonce[len(nonce)-1] = 1
+	}
+	if sc.counter == math.MaxUint32 {
+		sc.exhausted = true
+	}
+	sc.counter++
+	return nonce, nil
+}
+
+func (sc *streamCipher) seal(dst []byte, chunk []byte, final bool) ([]byte, error) {
+	nonce, err := sc.nonce(final)
+	if err != nil {
+		return nil, err
+	}
+	return sc.aead.Seal(dst, nonce, chunk, sc.aad), nil
+}
+
+func (sc *streamCipher) open(dst []byte, chunk []byte, final bool) ([]byte, error) {
+	nonce, err := sc.nonce(final)
+	if err != nil {
+		return nil, err
+	}
+	plainText, err := sc.aead.Open(dst, nonce, chunk, sc.aad)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plainText, nil
+}
+
+// newStreamEncrypter returns the cipher and header of a new stream
+func newStreamEncrypter(key []byte, aad []byte, chunkSize int,
+	ioiface IOInterface) (*streamCipher, error) {
+	if chunkSize == 0 {
+		chunkSize = StreamChunkSize
+	}
+	if chunkSize < 0 || chunkSize > maxStreamChunkSize {
+		return nil, fmt.Errorf("stream chunk size must be in (0, %d], got %d",
+			maxStreamChunkSize, chunkSize)
+	}
+	header := make([]byte, streamHeaderSize)
+	copy(header, streamMagic)
+	header[len(streamMagic)] = cipherVersion
+	header[len(streamMagic)+1] = CipherAESGCM
+	binary.BigEndian.PutUint32(header[len(streamMagic)+2:], uint32(chunkSize))
+	if _, err := ioiface.ReadFull(rand.Reader,
+		header[streamHeaderSize-streamNoncePrefixSize:]); err != nil {
+		return nil, err
+	}
+	return newStreamCipher(key, header, aad)
+}
+
+// EncryptWriter encrypts everything written to it as a stream of chunks,
+// Close must be called to write the final chunk
+type EncryptWriter struct {
+	w       io.Writer
+	sc      *streamCipher
+	buf     []byte
+	out     []byte
+	written bool
+	closed  bool
+	err     error
+}
+
+// NewEncryptWriter returns an *EncryptWriter writing to w, the chunk size
+// defaults to StreamChunkSize if 0, the associated data is authenticated
+// but not written and must be given again to decrypt
+func NewEncryptWriter(w io.Writer, key []byte, aad []byte, chunkSize int,
+	ioiface IOInterface) (*EncryptWriter, error) {
+	sc, err := newStreamEncrypter(key, aad, chunkSize, ioiface)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptWriter{
+		w:   w,
+		sc:  sc,
+		buf: make([]byte, 0, sc.chunkSize),
+	}, nil
+}
+
+// flush seals the buffered chunk and writes it, w/ the header if first
+func (ew *EncryptWriter) flush(final bool) error {
+	ew.out = ew.out[:0]
+	if !ew.written {
+		ew.out = append(ew.out, ew.sc.header...)
+	}
+	out, err := ew.sc.seal(ew.out, ew.buf, final)
+	if err != nil {
+		return err
+	}
+	ew.out = out
+	if _, err := ew.w.Write(ew.out); err != nil {
+		return err
+	}
+	ew.written = true
+	ew.buf = ew.buf[:0]
+	return nil
+}
+
+// Write encrypts p, a full chunk is sealed only once more data is written,
+// so the last chunk can be sealed as final by Close
+func (ew *EncryptWriter) Write(p []byte) (int, error) {
+	if ew.closed {
+		return 0, ErrStreamClosed
+	}
+	if ew.err != nil {
+		return 0, ew.err
+	}
+	written := 0
+	for len(p) > 0 {
+		if len(ew.buf) == ew.sc.chunkSize {
+			if ew.err = ew.flush(false); ew.err != nil {
+				return written, ew.err
+			}
+		}
+		n := copy(ew.buf[len(ew.buf):cap(ew.buf)], p)
+		ew.buf = ew.buf[:len(ew.buf)+n]
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Close writes the final chunk, the underlying writer is not closed
+func (ew *EncryptWriter) Close() error {
+	if ew.closed {
+		return ew.err
+	}
+	ew.closed = true
+	if ew.err != nil {
+		return ew.err
+	}
+	ew.err = ew.flush(true)
+	return ew.err
+}
+
+// chunkReader reads chunks of a fixed size and tells if a chunk is the last
+type chunkReader struct {
+	src *bufio.Reader
+}
+
+// next reads a chunk into buf, a short chunk or a full one at eof is final
+func (cr *chunkReader) next(buf []byte) (int, bool, error) {
+	n, err := io.ReadFull(cr.src, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return n, true, nil
+	}
+	if err != nil {
+		return n, false, err
+	}
+	if _, err := cr.src.Peek(1); err == io.EOF {
+		return n, true, nil
+	} else if err != nil {
+		return n, false, err
+	}
+	return n, false, nil
+}
+
+// EncryptReader encrypts a plain reader as a stream of chunks when read,
+// e.g. as the body of a s3 upload
+type EncryptReader struct {
+	src    chunkReader
+	sc     *streamCipher
+	buf    []byte
+	sealed []byte
+	out    []byte
+	done   bool
+	err    error
+}
+
+// NewEncryptReader returns an *EncryptReader reading plain text from r,
+// the chunk size defaults to StreamChunkSize if 0
+func NewEncryptReader(r io.Reader, key []byte, aad []byte, chunkSize int,
+	ioiface IOInterface) (*EncryptReader, error) {
+	sc, err := newStreamEncrypter(key, aad, chunkSize, ioiface)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptReader{
+		src:    chunkReader{src: bufio.NewReader(r)},
+		sc:     sc,
+		buf:    make([]byte, sc.chunkSize),
+		sealed: make([]byte, 0, sc.chunkSize+sc.aead.Overhead()),
+		out:    sc.header,
+	}, nil
+}
+
+// Read reads the encrypted stream
+func (er *EncryptReader) Read(p []byte) (int, error) {
+	for len(er.out) == 0 {
+		if er.err != nil {
+			return 0, er.err
+		}
+		if er.done {
+			return 0, io.EOF
+		}
+		n, final, err := er.src.next(er.buf)
+		if err != nil {
+			er.err = err
+			return 0, err
+		}
+		out, err := er.sc.seal(er.sealed[:0], er.buf[:n], final)
+		if err != nil {
+			er.err = err
+			return 0, err
+		}
+		er.out, er.done = out, final
+	}
+	n := copy(p, er.out)
+	er.out = er.out[n:]
+	return n, nil
+}
+
+// DecryptReader decrypts a stream of EncryptWriter or EncryptReader,
+// ErrInvalidCiphertext is returned if a chunk fails authentication
+// or the stream is truncated. Plain text is returned a chunk at a time,
+// only after the chunk is authenticated.
+type DecryptReader struct {
+	src   chunkReader
+	sc    *streamCipher
+	buf   []byte
+	plain []byte
+	out   []byte
+	done  bool
+	err   error
+}
+
+// NewDecryptReader reads the header of a stream and returns a *DecryptReader
+func NewDecryptReader(r io.Reader, key []byte, aad []byte) (*DecryptReader, error) {
+	header := make([]byte, streamHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrInvalidCiphertext
+		}
+		return nil, err
+	}
+	if !bytes.HasPrefix(header, streamMagic) {
+		return nil, ErrInvalidCiphertext
+	}
+	version, algorithm := header[len(streamMagic)], header[len(streamMagic)+1]
+	if version != cipherVersion {
+		return nil, fmt.Errorf("unsupport ciphertext version %d", version)
+	}
+	if algorithm != CipherAESGCM {
+		return nil, fmt.Errorf("unsupport ciphertext algorithm %d", algorithm)
+	}
+	sc, err := newStreamCipher(key, header, aad)
+	if err != nil {
+		return nil, err
+	}
+	if sc.chunkSize <= 0 || sc.chunkSize > maxStreamChunkSize {
+		return nil, ErrInvalidCiphertext
+	}
+	return &DecryptReader{
+		src:   chunkReader{src: bufio.NewReader(r)},
+		sc:    sc,
+		buf:   make([]byte, sc.chunkSize+sc.aead.Overhead()),
+		plain: make([]byte, 0, sc.chunkSize),
+	}, nil
+}
+
+// Read reads the plain text
+func (dr *DecryptReader) Read(p []byte) (int, error) {
+	for len(dr.out) == 0 {
+		if dr.err != nil {
+			return 0, dr.err
+		}
+		if dr.done {
+			return 0, io.EOF
+		}
+		n, final, err := dr.src.next(dr.buf)
+		if err == nil && n < dr.sc.aead.Overhead() {
+			err = ErrInvalidCiphertext
+		}
+		if err != nil {
+			dr.err = err
+			return 0, err
+		}
+		out, err := dr.sc.open(dr.plain[:0], dr.buf[:n], final)
+		if err != nil {
+			dr.err = err
+			return 0, err
+		}
+		dr.out, dr.done = out, final
+	}
+	n := copy(p, dr.out)
+	dr.out = dr.out[n:]
+	return n, nil
+}
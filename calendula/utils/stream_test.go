@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xinnige/asteraceae/calendula/mock"
+)
+
+var streamKey = DecodeBase64("DfdjIjQDhx9Nh5aWA13fuQQsIGsk8yYUWrm4dbPwokA=")
+
+func encryptStream(t *testing.T, plainText []byte, aad []byte, chunkSize int) []byte {
+	var buf bytes.Buffer
+	writer, err := NewEncryptWriter(&buf, streamKey, aad, chunkSize, &CommonAPI{})
+	assert.Nil(t, err)
+	// write in odd pieces to cross chunk boundaries
+	for len(plainText) > 0 {
+		size := 7
+		if size > len(plainText) {
+			size = len(plainText)
+		}
+		n, err := writer.Write(plainText[:size])
+		assert.Nil(t, err)
+		assert.Equal(t, size, n)
+		plainText = plainText[size:]
+	}
+	assert.Nil(t, writer.Close())
+	return buf.Bytes()
+}
+
+func decryptStream(cipherText []byte, aad []byte) ([]byte, error) {
+	reader, err := NewDecryptReader(bytes.NewReader(cipherText), streamKey, aad)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(reader)
+}
+
+func TestEncryptWriter(t *testing.T) {
+	aad := []byte("audit/2019.tar.gz")
+	for _, size := range []int{0, 1, 15, 16, 17, 32, 100} {
+		plainText := bytes.Repeat([]byte("0123456789"), 10)[:size]
+		cipherText := encryptStream(t, plainText, aad, 16)
+		chunks := size / 16
+		if size == 0 || size%16 != 0 {
+			chunks++
+		}
+		assert.Equal(t, streamHeaderSize+size+16*chunks, len(cipherText), size)
+
+		decrypted, err := decryptStream(cipherText, aad)
+		assert.Nil(t, err, size)
+		assert.Equal(t, plainText, append([]byte{}, decrypted...), size)
+	}
+
+	// default chunk size
+	plainText := bytes.Repeat([]byte("a"), StreamChunkSize+1)
+	cipherText := encryptStream(t, plainText, nil, 0)
+	assert.Equal(t, streamHeaderSize+len(plainText)+32, len(cipherText))
+	decrypted, err := decryptStream(cipherText, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, plainText, decrypted)
+}
+
+func TestEncryptWriterError(t *testing.T) {
+	_, err := NewEncryptWriter(&bytes.Buffer{}, []byte("00000"), nil, 0, &CommonAPI{})
+	assert.NotNil(t, err)
+	_, err = NewEncryptWriter(&bytes.Buffer{}, streamKey, nil, -1, &CommonAPI{})
+	assert.EqualError(t, err, "stream chunk size must be in (0, 16777216], got -1")
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockIOIface := mock.NewMockIOInterface(mockCtrl)
+	mockIOIface.EXPECT().ReadFull(gomock.Any(), gomock.Any()).Return(
+		0, errors.New("FakeIOError")).Times(1)
+	_, err = NewEncryptWriter(&bytes.Buffer{}, streamKey, nil, 0, mockIOIface)
+	assert.EqualError(t, err, "FakeIOError")
+
+	writer, err := NewEncryptWriter(failWriter{}, streamKey, nil, 4, &CommonAPI{})
+	assert.Nil(t, err)
+	n, err := writer.Write([]byte("1234"))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, n)
+	n, err = writer.Write([]byte("5678"))
+	assert.EqualError(t, err, "FakeWriteError")
+	assert.Equal(t, 0, n)
+	assert.EqualError(t, writer.Close(), "FakeWriteError")
+	_, err = writer.Write([]byte("9"))
+	assert.Equal(t, ErrStreamClosed, err)
+
+	writer, _ = NewEncryptWriter(&bytes.Buffer{}, streamKey, nil, 4, &CommonAPI{})
+	assert.Nil(t, writer.Close())
+	assert.Nil(t, writer.Close())
+	_, err = writer.Write([]byte("9"))
+	assert.Equal(t, ErrStreamClosed, err)
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) { return 0, errors.New("FakeWriteError") }
+
+type failReader struct{}
+
+func (failReader) Read(p []byte) (int, error) { return 0, errors.New("FakeReadError") }
+
+func TestEncryptReader(t *testing.T) {
+	aad := []byte("audit/2019.tar.gz")
+	for _, size := range []int{0, 15, 16, 33, 100} {
+		plainText := bytes.Repeat([]byte("0123456789"), 10)[:size]
+		reader, err := NewEncryptReader(bytes.NewReader(plainText), streamKey, aad, 16, &CommonAPI{})
+		assert.Nil(t, err)
+		cipherText, err := ioutil.ReadAll(reader)
+		assert.Nil(t, err)
+		decrypted, err := decryptStream(cipherText, aad)
+		assert.Nil(t, err, size)
+		assert.Equal(t, plainText, append([]byte{}, decrypted...), size)
+	}
+
+	reader, err := NewEncryptReader(io.MultiReader(strings.NewReader("0123456789abcdefg"),
+		failReader{}), streamKey, nil, 16, &CommonAPI{})
+	assert.Nil(t, err)
+	_, err = ioutil.ReadAll(reader)
+	assert.EqualError(t, err, "FakeReadError")
+	_, err = reader.Read(make([]byte, 1))
+	assert.EqualError(t, err, "FakeReadError")
+
+	_, err = NewEncryptReader(strings.NewReader(""), []byte("00000"), nil, 0, &CommonAPI{})
+	assert.NotNil(t, err)
+}
+
+func TestDecryptReaderTampered(t *testing.T) {
+	aad := []byte("audit")
+	plainText := bytes.Repeat([]byte("0123456789"), 5)
+	cipherText := encryptStream(t, plainText, aad, 16)
+	chunk := 16 + 16
+
+	_, err := decryptStream(cipherText, []byte("other"))
+	assert.Equal(t, ErrInvalidCiphertext, err)
+
+	tampered := append([]byte{}, cipherText...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = decryptStream(tampered, aad)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+
+	// truncated at a chunk boundary, or w/o any chunk
+	_, err = decryptStream(cipherText[:streamHeaderSize+2*chunk], aad)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+	_, err = decryptStream(cipherText[:streamHeaderSize], aad)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+	_, err = decryptStream(cipherText[:streamHeaderSize+10], aad)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+
+	// reordered chunks
+	header, body := cipherText[:streamHeaderSize], cipherText[streamHeaderSize:]
+	reordered := append(append(append(append([]byte{}, header...),
+		body[chunk:2*chunk]...), body[:chunk]...), body[2*chunk:]...)
+	_, err = decryptStream(reordered, aad)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+
+	// extended after the final chunk
+	_, err = decryptStream(append(append([]byte{}, cipherText...), body[:chunk]...), aad)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+
+	// plain text of authenticated chunks is returned before an error
+	tampered = append([]byte{}, cipherText...)
+	tampered[streamHeaderSize+chunk] ^= 1
+	reader, err := NewDecryptReader(bytes.NewReader(tampered), streamKey, aad)
+	assert.Nil(t, err)
+	decrypted, err := ioutil.ReadAll(reader)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+	assert.Equal(t, plainText[:16], decrypted)
+	_, err = reader.Read(make([]byte, 1))
+	assert.Equal(t, ErrInvalidCiphertext, err)
+}
+
+func TestDecryptReaderHeader(t *testing.T) {
+	cipherText := encryptStream(t, []byte("plain"), nil, 16)
+
+	_, err := decryptStream(cipherText[:5], nil)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+	_, err = decryptStream(append([]byte("XXXX"), cipherText[4:]...), nil)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+
+	header := append([]byte{}, cipherText...)
+	header[4] = 2
+	_, err = decryptStream(header, nil)
+	assert.EqualError(t, err, "unsupport ciphertext version 2")
+	header[4], header[5] = 1, 9
+	_, err = decryptStream(header, nil)
+	assert.EqualError(t, err, "unsupport ciphertext algorithm 9")
+	header[5], header[6] = 1, 0xff
+	_, err = decryptStream(header, nil)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+
+	_, err = NewDecryptReader(bytes.NewReader(cipherText), []byte("00000"), nil)
+	assert.NotNil(t, err)
+	_, err = NewDecryptReader(failReader{}, streamKey, nil)
+	assert.EqualError(t, err, "FakeReadError")
+}
@@ -235,6 +235,8 @@ func DecodeBase64(encoded string) []byte {
 }
 
 // EncryptTextAES encrypts plain text with AES
+//
+// Deprecated: AES-CTR is not authenticated, use EncryptTextGCM.
 func EncryptTextAES(key []byte, plainText []byte, ioiface IOInterface) []byte {
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -257,6 +259,9 @@ func EncryptTextAES(key []byte, plainText []byte, ioiface IOInterface) []byte {
 }
 
 // DecryptTextAES decrypts cipher text with AES
+//
+// Deprecated: tampered ciphertexts are not detected, use DecryptTextCompat
+// to decrypt legacy ciphertexts and MigrateTextAES to re-encrypt them.
 func DecryptTextAES(key []byte, cipherText []byte) []byte {
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// YAMLAPI implements SerialInterface w/ gopkg.in/yaml.v2, fields are named
+// by their yaml tags. Plain scalars like 8080 or true decode into string
+// fields as they are written.
+type YAMLAPI struct {
+}
+
+// Marshal wraps yaml.Marshal
+func (api *YAMLAPI) Marshal(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+// MarshalIndent encodes v as yaml, every line starts w/ prefix,
+// indent is ignored since yaml.v2 always indents by 2 spaces
+func (api *YAMLAPI) MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
+	content, err := yaml.Marshal(v)
+	if err != nil || prefix == "" {
+		return content, err
+	}
+	var buf bytes.Buffer
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		if line != "" {
+			buf.WriteString(prefix + line)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal wraps yaml.Unmarshal
+func (api *YAMLAPI) Unmarshal(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
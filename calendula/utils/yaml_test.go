@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type yamlParam struct {
+	Name     string            `yaml:"name"`
+	Value    string            `yaml:"value"`
+	Version  int64             `yaml:"version"`
+	Redacted bool              `yaml:"redacted,omitempty"`
+	Tags     map[string]string `yaml:"tags,omitempty"`
+}
+
+type yamlExport struct {
+	Path       string      `yaml:"path"`
+	Parameters []yamlParam `yaml:"parameters"`
+	Labels     []string    `yaml:"labels"`
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	export := yamlExport{
+		Path: "/app",
+		Parameters: []yamlParam{
+			{Name: "db/host", Value: "db.local: 5432 # primary", Version: 3,
+				Tags: map[string]string{"team": "sec ops", "yes": "no"}},
+			{Name: "port", Value: "8080"},
+			{Name: "motd", Value: "line1\nline2 \"quoted\" 'single'"},
+		},
+		Labels: []string{"true", "-1", ""},
+	}
+	api := &YAMLAPI{}
+	content, err := api.Marshal(export)
+	assert.Nil(t, err)
+	loaded := yamlExport{}
+	assert.Nil(t, api.Unmarshal(content, &loaded))
+	assert.Equal(t, export, loaded)
+
+	content, err = api.MarshalIndent([]string{"a", "b"}, "# ", "\t")
+	assert.Nil(t, err)
+	assert.Equal(t, "# - a\n# - b\n", string(content))
+}
+
+func TestYAMLUnmarshal(t *testing.T) {
+	api := &YAMLAPI{}
+	loaded := yamlExport{}
+	// plain numbers and booleans of a hand edit decode into strings
+	err := api.Unmarshal([]byte(`# exported by hand
+path: /app
+parameters:
+- name: port
+  value: 8080
+  version: 2
+- name: enabled
+  value: true
+labels: [yes, 1.5]
+`), &loaded)
+	assert.Nil(t, err)
+	assert.Equal(t, yamlExport{
+		Path: "/app",
+		Parameters: []yamlParam{
+			{Name: "port", Value: "8080", Version: 2},
+			{Name: "enabled", Value: "true"},
+		},
+		Labels: []string{"yes", "1.5"},
+	}, loaded)
+
+	content, err := api.Marshal(loaded)
+	assert.Nil(t, err)
+	reloaded := yamlExport{}
+	assert.Nil(t, api.Unmarshal(content, &reloaded))
+	assert.Equal(t, loaded, reloaded)
+
+	err = api.Unmarshal([]byte("path: /app\nparameters:\n- name: [a]"), &yamlExport{})
+	assert.EqualError(t, err, "yaml: unmarshal errors:\n  line 3: cannot unmarshal !!seq into string")
+	err = api.Unmarshal([]byte("path: \"/app"), &yamlExport{})
+	assert.NotNil(t, err)
+}
@@ -8,4 +8,5 @@ require (
 	github.com/rs/xid v1.2.1
 	github.com/stretchr/testify v1.3.0
 	github.com/teris-io/shortid v0.0.0-20171029131806-771a37caa5cf
+	gopkg.in/yaml.v2 v2.4.0
 )